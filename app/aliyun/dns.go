@@ -1,21 +1,32 @@
 package aliyun
 
 import (
-	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 )
 
 const aliyunAPIURL = "https://alidns.aliyuncs.com"
 
-// DNSRecord 表示一个DNS记录
+// DNSRecord 表示一个DNS记录，Name 是相对托管域的主机记录（RR），如 www、@
 type DNSRecord struct {
-	Type    string `json:"Type"`
-	Name    string `json:"RR"`
-	Content string `json:"Value"`
-	TTL     int    `json:"TTL"`
+	RecordId string `json:"RecordId"`
+	Type     string `json:"Type"`
+	Name     string `json:"RR"`
+	Content  string `json:"Value"`
+	TTL      int    `json:"TTL"`
 }
 
 // AliyunResponse 表示阿里云API的响应
@@ -26,94 +37,183 @@ type AliyunResponse struct {
 	Message   string `json:"Message"`
 }
 
-// EmptyFunction 是一个空函数，用于在其他包中调用
-func EmptyFunction() {
-	// 这里什么都不做
+// APIError 阿里云 API 返回的错误，如 InvalidDomainName.NoExist
+type APIError struct {
+	Code    string
+	Message string
 }
 
-// AddDNSRecord 添加DNS记录
-func AddDNSRecord(accessKeyId, accessKeySecret, domain string, record DNSRecord) error {
-	url := fmt.Sprintf("%s/?Action=AddDomainRecord&DomainName=%s&RR=%s&Type=%s&Value=%s&TTL=%d", aliyunAPIURL, domain, record.Name, record.Type, record.Content, record.TTL)
-	return sendRequest("GET", url, accessKeyId, accessKeySecret, nil)
+func (e *APIError) Error() string {
+	return fmt.Sprintf("request failed: %s: %s", e.Code, e.Message)
 }
 
-// UpdateDNSRecord 更新DNS记录
-func UpdateDNSRecord(accessKeyId, accessKeySecret, recordId string, record DNSRecord) error {
-	url := fmt.Sprintf("%s/?Action=UpdateDomainRecord&RecordId=%s&RR=%s&Type=%s&Value=%s&TTL=%d", aliyunAPIURL, recordId, record.Name, record.Type, record.Content, record.TTL)
-	return sendRequest("GET", url, accessKeyId, accessKeySecret, nil)
+// Client 阿里云云解析 API 客户端，BaseURL 和 HTTPClient 为空时使用官方地址和默认客户端
+type Client struct {
+	AccessKeyID     string
+	AccessKeySecret string
+	BaseURL         string
+	HTTPClient      *http.Client
 }
 
-// GetDNSRecordID 获取DNS记录ID
-func GetDNSRecordID(accessKeyId, accessKeySecret, domain string, record DNSRecord) (string, error) {
-	url := fmt.Sprintf("%s/?Action=DescribeDomainRecords&DomainName=%s&RRKeyWord=%s&Type=%s", aliyunAPIURL, domain, record.Name, record.Type)
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return "", fmt.Errorf("failed to create request: %v", err)
-	}
-
-	req.Header.Set("Authorization", "APPCODE "+accessKeyId+":"+accessKeySecret)
-	req.Header.Set("Content-Type", "application/json")
+// NewClient 创建使用 AccessKey 认证的客户端
+func NewClient(accessKeyID, accessKeySecret string) *Client {
+	return &Client{AccessKeyID: accessKeyID, AccessKeySecret: accessKeySecret}
+}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("failed to send request: %v", err)
-	}
-	defer resp.Body.Close()
+// Domain 账号下的托管域
+type Domain struct {
+	DomainId   string `json:"DomainId"`
+	DomainName string `json:"DomainName"`
+}
 
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("failed to read response body: %v", err)
+// ListDomains 列出账号下的全部托管域
+func (c *Client) ListDomains(ctx context.Context) ([]Domain, error) {
+	var domains []Domain
+	for page := 1; ; page++ {
+		var aliyunResp struct {
+			TotalCount int `json:"TotalCount"`
+			Domains    struct {
+				Domain []Domain `json:"Domain"`
+			} `json:"Domains"`
+		}
+		err := c.sendRequest(ctx, map[string]string{
+			"Action":     "DescribeDomains",
+			"PageNumber": strconv.Itoa(page),
+			"PageSize":   "100",
+		}, &aliyunResp)
+		if err != nil {
+			return nil, err
+		}
+		domains = append(domains, aliyunResp.Domains.Domain...)
+		if len(aliyunResp.Domains.Domain) == 0 || len(domains) >= aliyunResp.TotalCount {
+			return domains, nil
+		}
 	}
+}
 
+// GetDomain 确认域名是账号下的托管域，返回域名 ID
+func (c *Client) GetDomain(ctx context.Context, domain string) (string, error) {
 	var aliyunResp struct {
-		DomainRecords struct {
-			Record []struct {
-				RecordId string `json:"RecordId"`
-			} `json:"Record"`
-		} `json:"DomainRecords"`
+		DomainId   string `json:"DomainId"`
+		DomainName string `json:"DomainName"`
 	}
-	if err := json.Unmarshal(body, &aliyunResp); err != nil {
-		return "", fmt.Errorf("failed to unmarshal response: %v", err)
+	err := c.sendRequest(ctx, map[string]string{
+		"Action":     "DescribeDomainInfo",
+		"DomainName": domain,
+	}, &aliyunResp)
+	if err != nil {
+		return "", err
 	}
+	return aliyunResp.DomainId, nil
+}
 
-	if len(aliyunResp.DomainRecords.Record) == 0 {
-		return "", nil
+// ListDNSRecords 列出托管域的全部记录，record 的 Name、Type 不为空时按其筛选（主机记录为模糊匹配）
+func (c *Client) ListDNSRecords(ctx context.Context, domain string, record DNSRecord) ([]DNSRecord, error) {
+	var records []DNSRecord
+	for page := 1; ; page++ {
+		var aliyunResp struct {
+			TotalCount    int `json:"TotalCount"`
+			DomainRecords struct {
+				Record []DNSRecord `json:"Record"`
+			} `json:"DomainRecords"`
+		}
+		params := map[string]string{
+			"Action":     "DescribeDomainRecords",
+			"DomainName": domain,
+			"PageNumber": strconv.Itoa(page),
+			"PageSize":   "500",
+		}
+		if record.Name != "" {
+			params["RRKeyWord"] = record.Name
+		}
+		if record.Type != "" {
+			params["TypeKeyWord"] = record.Type
+		}
+		if err := c.sendRequest(ctx, params, &aliyunResp); err != nil {
+			return nil, err
+		}
+		records = append(records, aliyunResp.DomainRecords.Record...)
+		if len(aliyunResp.DomainRecords.Record) == 0 || len(records) >= aliyunResp.TotalCount {
+			return records, nil
+		}
 	}
+}
 
-	return aliyunResp.DomainRecords.Record[0].RecordId, nil
+// AddDNSRecord 添加DNS记录，返回记录ID
+func (c *Client) AddDNSRecord(ctx context.Context, domain string, record DNSRecord) (string, error) {
+	var aliyunResp AliyunResponse
+	err := c.sendRequest(ctx, map[string]string{
+		"Action":     "AddDomainRecord",
+		"DomainName": domain,
+		"RR":         record.Name,
+		"Type":       record.Type,
+		"Value":      record.Content,
+		"TTL":        strconv.Itoa(record.TTL),
+	}, &aliyunResp)
+	return aliyunResp.RecordId, err
 }
 
-// sendRequest 发送HTTP请求
-func sendRequest(method, url, accessKeyId, accessKeySecret string, data interface{}) error {
-	client := &http.Client{}
-	var req *http.Request
-	var err error
+// UpdateDNSRecord 更新DNS记录
+func (c *Client) UpdateDNSRecord(ctx context.Context, recordId string, record DNSRecord) error {
+	return c.sendRequest(ctx, map[string]string{
+		"Action":   "UpdateDomainRecord",
+		"RecordId": recordId,
+		"RR":       record.Name,
+		"Type":     record.Type,
+		"Value":    record.Content,
+		"TTL":      strconv.Itoa(record.TTL),
+	}, nil)
+}
 
-	if data != nil {
-		jsonData, err := json.Marshal(data)
-		if err != nil {
-			return fmt.Errorf("failed to marshal data: %v", err)
-		}
-		req, err = http.NewRequest(method, url, bytes.NewBuffer(jsonData))
-	} else {
-		req, err = http.NewRequest(method, url, nil)
+// DeleteDNSRecord 删除DNS记录
+func (c *Client) DeleteDNSRecord(ctx context.Context, recordId string) error {
+	return c.sendRequest(ctx, map[string]string{
+		"Action":   "DeleteDomainRecord",
+		"RecordId": recordId,
+	}, nil)
+}
+
+// sendRequest 按阿里云 RPC 签名（HMAC-SHA1）发送请求，result 不为空时把响应解析进去
+func (c *Client) sendRequest(ctx context.Context, params map[string]string, result interface{}) error {
+	baseURL := c.BaseURL
+	if baseURL == "" {
+		baseURL = aliyunAPIURL
+	}
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
 	}
 
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %v", err)
+	}
+	query := map[string]string{
+		"Format":           "JSON",
+		"Version":          "2015-01-09",
+		"AccessKeyId":      c.AccessKeyID,
+		"SignatureMethod":  "HMAC-SHA1",
+		"SignatureVersion": "1.0",
+		"SignatureNonce":   hex.EncodeToString(nonce),
+		"Timestamp":        time.Now().UTC().Format("2006-01-02T15:04:05Z"),
+	}
+	for key, value := range params {
+		query[key] = value
+	}
+	canonicalized := canonicalize(query)
+	signature := Sign(http.MethodGet, canonicalized, c.AccessKeySecret)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+"/?"+canonicalized+"&Signature="+percentEncode(signature), nil)
 	if err != nil {
 		return fmt.Errorf("failed to create request: %v", err)
 	}
-
-	req.Header.Set("Authorization", "APPCODE "+accessKeyId+":"+accessKeySecret)
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := client.Do(req)
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("failed to send request: %v", err)
 	}
 	defer resp.Body.Close()
 
-	body, err := ioutil.ReadAll(resp.Body)
+	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return fmt.Errorf("failed to read response body: %v", err)
 	}
@@ -122,10 +222,44 @@ func sendRequest(method, url, accessKeyId, accessKeySecret string, data interfac
 	if err := json.Unmarshal(body, &aliyunResp); err != nil {
 		return fmt.Errorf("failed to unmarshal response: %v", err)
 	}
-
 	if aliyunResp.Code != "" {
-		return fmt.Errorf("request failed: %v", aliyunResp.Message)
+		return &APIError{Code: aliyunResp.Code, Message: aliyunResp.Message}
+	}
+	if result != nil {
+		if err := json.Unmarshal(body, result); err != nil {
+			return fmt.Errorf("failed to unmarshal response: %v", err)
+		}
 	}
-
 	return nil
 }
+
+// Sign 计算 RPC 签名：StringToSign = Method&%2F&percentEncode(规范化查询串)，密钥为 AccessKeySecret 加 &
+func Sign(method, canonicalizedQuery, accessKeySecret string) string {
+	stringToSign := method + "&" + percentEncode("/") + "&" + percentEncode(canonicalizedQuery)
+	mac := hmac.New(sha1.New, []byte(accessKeySecret+"&"))
+	mac.Write([]byte(stringToSign))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// canonicalize 参数按名称排序后编码拼接
+func canonicalize(params map[string]string) string {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, percentEncode(key)+"="+percentEncode(params[key]))
+	}
+	return strings.Join(pairs, "&")
+}
+
+// percentEncode 阿里云要求的 RFC 3986 编码：空格为 %20，* 为 %2A，~ 不编码
+func percentEncode(value string) string {
+	encoded := url.QueryEscape(value)
+	encoded = strings.ReplaceAll(encoded, "+", "%20")
+	encoded = strings.ReplaceAll(encoded, "*", "%2A")
+	encoded = strings.ReplaceAll(encoded, "%7E", "~")
+	return encoded
+}
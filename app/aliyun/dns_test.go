@@ -0,0 +1,125 @@
+package aliyun
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+// 阿里云云解析文档“签名机制”一节的示例：AccessKeySecret 为 testsecret
+func TestSignDocumentVector(t *testing.T) {
+	params := map[string]string{
+		"Format":           "XML",
+		"AccessKeyId":      "testid",
+		"Action":           "DescribeDomainRecords",
+		"SignatureMethod":  "HMAC-SHA1",
+		"DomainName":       "example.com",
+		"SignatureNonce":   "f59ed6a9-83fc-473b-9cc6-99c95df3856e",
+		"SignatureVersion": "1.0",
+		"Version":          "2015-01-09",
+		"Timestamp":        "2016-03-24T16:41:54Z",
+	}
+	wantQuery := "AccessKeyId=testid&Action=DescribeDomainRecords&DomainName=example.com&Format=XML" +
+		"&SignatureMethod=HMAC-SHA1&SignatureNonce=f59ed6a9-83fc-473b-9cc6-99c95df3856e&SignatureVersion=1.0" +
+		"&Timestamp=2016-03-24T16%3A41%3A54Z&Version=2015-01-09"
+	query := canonicalize(params)
+	if query != wantQuery {
+		t.Fatalf("canonicalize =\n%s\nwant\n%s", query, wantQuery)
+	}
+	if got, want := Sign(http.MethodGet, query, "testsecret"), "uRpHwaSEt3J+6KQD//svCh/x+pI="; got != want {
+		t.Errorf("Sign = %s, want %s", got, want)
+	}
+}
+
+func TestPercentEncode(t *testing.T) {
+	tests := map[string]string{
+		"a b":  "a%20b",
+		"a*b":  "a%2Ab",
+		"a~b":  "a~b",
+		"a+b":  "a%2Bb",
+		"中":    "%E4%B8%AD",
+		"/:=&": "%2F%3A%3D%26",
+	}
+	for in, want := range tests {
+		if got := percentEncode(in); got != want {
+			t.Errorf("percentEncode(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+// verifySignature 按收到的参数重新计算签名，和请求里的 Signature 比较
+func verifySignature(t *testing.T, query url.Values, secret string) {
+	t.Helper()
+	params := make(map[string]string)
+	for key := range query {
+		if key != "Signature" {
+			params[key] = query.Get(key)
+		}
+	}
+	if want := Sign(http.MethodGet, canonicalize(params), secret); query.Get("Signature") != want {
+		t.Errorf("签名不一致: got %s, want %s", query.Get("Signature"), want)
+	}
+}
+
+func TestClientRequests(t *testing.T) {
+	var actions []url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		verifySignature(t, query, "secret")
+		actions = append(actions, query)
+		switch query.Get("Action") {
+		case "DescribeDomainRecords":
+			// 分两页返回，每页一条
+			if query.Get("PageNumber") == "1" {
+				w.Write([]byte(`{"TotalCount":2,"DomainRecords":{"Record":[{"RecordId":"1","RR":"www","Type":"A","Value":"1.2.3.4","TTL":600}]}}`))
+			} else {
+				w.Write([]byte(`{"TotalCount":2,"DomainRecords":{"Record":[{"RecordId":"2","RR":"@","Type":"TXT","Value":"v=spf1 -all","TTL":600}]}}`))
+			}
+		case "AddDomainRecord":
+			w.Write([]byte(`{"RequestId":"req","RecordId":"99"}`))
+		case "DeleteDomainRecord":
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"RequestId":"req","Code":"DomainRecordNotBelongToUser","Message":"The DNS record does not belong to you."}`))
+		default:
+			w.Write([]byte(`{"RequestId":"req"}`))
+		}
+	}))
+	defer server.Close()
+
+	client := &Client{AccessKeyID: "id", AccessKeySecret: "secret", BaseURL: server.URL, HTTPClient: server.Client()}
+	ctx := context.Background()
+
+	records, err := client.ListDNSRecords(ctx, "example.com", DNSRecord{Name: "www", Type: "A"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].Name != "www" || records[1].Content != "v=spf1 -all" {
+		t.Errorf("ListDNSRecords = %+v", records)
+	}
+	if first := actions[0]; first.Get("RRKeyWord") != "www" || first.Get("TypeKeyWord") != "A" || first.Get("DomainName") != "example.com" {
+		t.Errorf("筛选参数不对: %v", first)
+	}
+	for _, key := range []string{"AccessKeyId", "SignatureNonce", "Timestamp", "SignatureMethod", "SignatureVersion", "Version", "Format"} {
+		if actions[0].Get(key) == "" {
+			t.Errorf("缺少公共参数 %s", key)
+		}
+	}
+
+	id, err := client.AddDNSRecord(ctx, "example.com", DNSRecord{Name: "nav", Type: "A", Content: "5.6.7.8", TTL: 600})
+	if err != nil || id != "99" {
+		t.Fatalf("AddDNSRecord = %q, %v", id, err)
+	}
+	add := actions[len(actions)-1]
+	if add.Get("RR") != "nav" || add.Get("Value") != "5.6.7.8" || add.Get("TTL") != "600" {
+		t.Errorf("AddDomainRecord 参数不对: %v", add)
+	}
+
+	err = client.DeleteDNSRecord(ctx, "99")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != "DomainRecordNotBelongToUser" {
+		t.Errorf("DeleteDNSRecord 错误 = %v, want APIError", err)
+	}
+}
@@ -6,18 +6,25 @@ import (
 	_ "image/gif"
 	_ "image/jpeg"
 	_ "image/png"
+	"mime"
 	"nav-web-site/app/api/v1/admin"
 	"nav-web-site/mydb"
 	"nav-web-site/util"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+const (
+	uploadFileCacheKeyPrefix = "upload_file_hash_" // 哈希 -> 文件记录的缓存key前缀
+	uploadFileCacheDuration  = time.Hour           // 文件记录的缓存时间
+)
+
 type ImgReturnData struct {
 	Hash    string `json:"hash"`
 	ImgPath string `json:"img_path"`
@@ -31,6 +38,7 @@ type ImgReturnData struct {
 // @Produce application/json
 // @Param LoginToken header string true "认证Token"
 // @Param file formData file true "图片文件"
+// @Param is_private formData bool false "是否私有，私有文件只能通过签名链接访问"
 // @Success 200 {object} util.APIResponse{code=int,message=string,data=ImgReturnData}
 // @Failure 400 {object} util.APIResponse{code=int,message=string,data=object}
 // @Router /upload/image [post]
@@ -118,7 +126,9 @@ func UploadImage(c *gin.Context) {
 		FileType:   "img",
 		Extension:  ext,
 		UploadTime: util.GetTimestamp(10),
+		AdminID:    adminID,
 	}
+	newFile.IsPrivate, _ = strconv.ParseBool(c.PostForm("is_private"))
 	_, _, err = newFile.Insert([]mydb.StructUploadFile{newFile})
 	if err != nil {
 		fmt.Println(err)
@@ -131,23 +141,26 @@ func UploadImage(c *gin.Context) {
 }
 
 // GetImageByHash 根据哈希值获取图片
+// 文件按内容哈希寻址，内容永远不会变化，直接用哈希作为强 ETag；但公开文件随时可能改成私有，
+// 所以不能让缓存长期直接使用，每次都要回源校验，If-None-Match / If-Modified-Since 命中时由 http.ServeContent 返回 304
 func GetImageByHash(c *gin.Context) {
 	hash := c.Param("hash")
 
 	// 查询文件记录
-	var uploadFile mydb.StructUploadFile
-	params := mydb.QueryParams{
-		Condition: fmt.Sprintf("hash='%s'", hash),
-	}
-	existingFile, err := uploadFile.Find(params)
+	existingFile, err := findFileByHash(hash)
 	if err != nil {
 		c.JSON(http.StatusNotFound, util.APIResponse{Code: http.StatusNotFound, Message: "图片未找到", Data: "null"})
 		return
 	}
 
+	// 私有文件必须带有效的签名
+	if existingFile.IsPrivate && !verifyImageSign(c, existingFile.Hash) {
+		c.JSON(http.StatusForbidden, util.APIResponse{Code: http.StatusForbidden, Message: "无权访问该图片", Data: "null"})
+		return
+	}
+
 	// 读取图片文件
-	filePath := existingFile.FilePath
-	file, err := os.Open(filePath)
+	file, err := os.Open(existingFile.FilePath)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, util.APIResponse{Code: http.StatusInternalServerError, Message: "读取图片文件失败", Data: "null"})
 		return
@@ -155,6 +168,58 @@ func GetImageByHash(c *gin.Context) {
 	defer file.Close()
 
 	// 返回图片文件
-	c.Header("Content-Type", "image/jpeg")
-	c.File(filePath)
+	c.Header("ETag", `"`+existingFile.Hash+`"`)
+	if existingFile.IsPrivate {
+		// 签名链接会过期，只允许浏览器缓存到过期时间为止
+		expires, _ := strconv.ParseInt(c.Query("expires"), 10, 64)
+		c.Header("Cache-Control", fmt.Sprintf("private, max-age=%d", expires-util.GetTimestamp(10)))
+	} else {
+		// 改成私有后回源校验会得到 403，缓存不会继续提供文件
+		c.Header("Cache-Control", "public, no-cache")
+	}
+	c.Header("Content-Type", contentTypeByExtension(existingFile.Extension))
+	http.ServeContent(c.Writer, c.Request, existingFile.FileName, time.Unix(existingFile.UploadTime, 0), file)
+}
+
+// findFileByHash 根据哈希查询文件记录，查到的记录缓存在内存里，避免每次请求图片都查库
+func findFileByHash(hash string) (mydb.StructUploadFile, error) {
+	cacheKey := uploadFileCacheKeyPrefix + hash
+	if cacheValue, found := util.C.Get(cacheKey); found {
+		if cachedFile, ok := cacheValue.(mydb.StructUploadFile); ok {
+			return cachedFile, nil
+		}
+	}
+
+	var uploadFile mydb.StructUploadFile
+	params := mydb.QueryParams{
+		Condition: fmt.Sprintf("hash='%s'", mydb.EscapeString(hash)),
+	}
+	existingFile, err := uploadFile.Find(params)
+	if err != nil {
+		return existingFile, err
+	}
+
+	util.C.Set(cacheKey, existingFile, uploadFileCacheDuration)
+	return existingFile, nil
+}
+
+// forgetFileByHash 文件被删除后清掉内存里的记录
+func forgetFileByHash(hash string) {
+	util.C.Delete(uploadFileCacheKeyPrefix + hash)
+}
+
+// contentTypeByExtension 根据扩展名返回 Content-Type
+func contentTypeByExtension(ext string) string {
+	if !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	ext = strings.ToLower(ext)
+	if ext == ".ico" {
+		// 部分系统的 mime 表里没有 .ico
+		return "image/x-icon"
+	}
+	if contentType := mime.TypeByExtension(ext); contentType != "" {
+		return contentType
+	}
+	return "application/octet-stream"
 }
@@ -0,0 +1,329 @@
+package upload
+
+import (
+	"fmt"
+	"nav-web-site/app/api/v1/admin"
+	"nav-web-site/config"
+	"nav-web-site/mydb"
+	"nav-web-site/util"
+	"nav-web-site/util/log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FileItem 媒体库列表中的单个文件
+type FileItem struct {
+	mydb.StructUploadFile
+	Url      string `json:"url"`
+	RefCount int    `json:"ref_count"` // 被引用次数，仅在 with_refs=true 时统计
+}
+
+// FileListData 媒体库列表返回的数据
+type FileListData struct {
+	Total    int        `json:"total"`
+	Page     int        `json:"page"`
+	PageSize int        `json:"page_size"`
+	List     []FileItem `json:"list"`
+}
+
+// GetFileList 获取上传文件列表
+// @Summary 获取上传文件列表
+// @Description 媒体库列表，支持按类型、日期、大小、上传者和文件名筛选
+// @Tags upload
+// @Produce application/json
+// @Param LoginToken header string true "认证Token"
+// @Param file_type query string false "文件类型，如 img"
+// @Param start_date query string false "开始日期(2006-01-02)"
+// @Param end_date query string false "结束日期(2006-01-02)，包含当天"
+// @Param min_size query int false "最小文件大小(字节)"
+// @Param max_size query int false "最大文件大小(字节)"
+// @Param admin_id query int false "上传者ID"
+// @Param keyword query string false "文件名关键词"
+// @Param with_refs query bool false "是否统计引用次数"
+// @Param page query int false "页码"
+// @Param page_size query int false "每页数量"
+// @Success 200 {object} util.APIResponse{code=int,message=string,data=FileListData}
+// @Failure 400 {object} util.APIResponse{code=int,message=string,data=string}
+// @Failure 500 {object} util.APIResponse{code=int,message=string,data=string}
+// @Router /upload/list [get]
+func GetFileList(c *gin.Context) {
+	loginToken := c.GetHeader("LoginToken")
+	if _, err := admin.GetAdminIDFromToken(loginToken); err != nil {
+		c.JSON(http.StatusUnauthorized, util.APIResponse{Code: http.StatusUnauthorized, Message: "无权限进行此操作", Data: err.Error()})
+		return
+	}
+
+	var conditions []string
+	if fileType := c.Query("file_type"); fileType != "" {
+		conditions = append(conditions, fmt.Sprintf("file_type='%s'", mydb.EscapeString(fileType)))
+	}
+	if startDate := c.Query("start_date"); startDate != "" {
+		start, err := time.ParseInLocation("2006-01-02", startDate, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, util.APIResponse{Code: http.StatusBadRequest, Message: "无效的开始日期", Data: err.Error()})
+			return
+		}
+		conditions = append(conditions, fmt.Sprintf("upload_time>=%d", start.Unix()))
+	}
+	if endDate := c.Query("end_date"); endDate != "" {
+		end, err := time.ParseInLocation("2006-01-02", endDate, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, util.APIResponse{Code: http.StatusBadRequest, Message: "无效的结束日期", Data: err.Error()})
+			return
+		}
+		conditions = append(conditions, fmt.Sprintf("upload_time<%d", end.AddDate(0, 0, 1).Unix()))
+	}
+	if minSize, err := strconv.ParseInt(c.Query("min_size"), 10, 64); err == nil {
+		conditions = append(conditions, fmt.Sprintf("file_size>=%d", minSize))
+	}
+	if maxSize, err := strconv.ParseInt(c.Query("max_size"), 10, 64); err == nil {
+		conditions = append(conditions, fmt.Sprintf("file_size<=%d", maxSize))
+	}
+	if uploader, err := strconv.Atoi(c.Query("admin_id")); err == nil {
+		conditions = append(conditions, fmt.Sprintf("admin_id=%d", uploader))
+	}
+	if keyword := c.Query("keyword"); keyword != "" {
+		conditions = append(conditions, fmt.Sprintf("file_name LIKE '%%%s%%'", mydb.EscapeString(keyword)))
+	}
+	condition := strings.Join(conditions, " AND ")
+
+	page, err := strconv.Atoi(c.Query("page"))
+	if err != nil || page <= 0 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(c.Query("page_size"))
+	if err != nil || pageSize <= 0 {
+		pageSize = 20
+	}
+
+	var uploadFile mydb.StructUploadFile
+	total, err := uploadFile.Count(condition)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, util.APIResponse{Code: http.StatusInternalServerError, Message: "获取文件列表失败", Data: err.Error()})
+		return
+	}
+
+	data := FileListData{Total: total, Page: page, PageSize: pageSize, List: []FileItem{}}
+	if total == 0 {
+		c.JSON(http.StatusOK, util.APIResponse{Code: http.StatusOK, Message: "获取文件列表成功", Data: data})
+		return
+	}
+
+	params := mydb.QueryParams{
+		Condition: condition,
+		OrderBy:   "upload_time DESC",
+		Limit:     pageSize,
+		Page:      page,
+		PageSize:  pageSize,
+	}
+	files, code, err := uploadFile.Select(params)
+	if err != nil && code != 200 {
+		c.JSON(http.StatusInternalServerError, util.APIResponse{Code: http.StatusInternalServerError, Message: "获取文件列表失败", Data: err.Error()})
+		return
+	}
+
+	var refHashes map[string]int
+	if withRefs, _ := strconv.ParseBool(c.Query("with_refs")); withRefs {
+		refHashes, err = uploadFile.ReferencedHashes()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, util.APIResponse{Code: http.StatusInternalServerError, Message: "统计文件引用失败", Data: err.Error()})
+			return
+		}
+	}
+
+	for _, file := range files {
+		data.List = append(data.List, FileItem{StructUploadFile: file, Url: "/images/" + file.Hash, RefCount: refHashes[file.Hash]})
+	}
+	c.JSON(http.StatusOK, util.APIResponse{Code: http.StatusOK, Message: "获取文件列表成功", Data: data})
+}
+
+// GetFileRefs 获取文件的引用情况
+// @Summary 获取文件的引用情况
+// @Description 根据哈希值列出引用该文件的 news.imgurl、nav.icon、admin.avatar 记录
+// @Tags upload
+// @Produce application/json
+// @Param LoginToken header string true "认证Token"
+// @Param hash path string true "文件哈希"
+// @Success 200 {object} util.APIResponse{code=int,message=string,data=[]mydb.UploadFileRef}
+// @Failure 500 {object} util.APIResponse{code=int,message=string,data=string}
+// @Router /upload/refs/{hash} [get]
+func GetFileRefs(c *gin.Context) {
+	loginToken := c.GetHeader("LoginToken")
+	if _, err := admin.GetAdminIDFromToken(loginToken); err != nil {
+		c.JSON(http.StatusUnauthorized, util.APIResponse{Code: http.StatusUnauthorized, Message: "无权限进行此操作", Data: err.Error()})
+		return
+	}
+
+	hash := c.Param("hash")
+	if !mydb.IsUploadHash(hash) {
+		c.JSON(http.StatusBadRequest, util.APIResponse{Code: http.StatusBadRequest, Message: "无效的文件哈希", Data: "null"})
+		return
+	}
+	refs, err := (&mydb.StructUploadFile{}).FindReferences(hash)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, util.APIResponse{Code: http.StatusInternalServerError, Message: "获取文件引用失败", Data: err.Error()})
+		return
+	}
+	if refs == nil {
+		refs = []mydb.UploadFileRef{}
+	}
+	c.JSON(http.StatusOK, util.APIResponse{Code: http.StatusOK, Message: "获取文件引用成功", Data: refs})
+}
+
+// DeleteFile 删除未被引用的文件
+// @Summary 删除上传文件
+// @Description 根据哈希值删除文件记录和磁盘文件，仍被引用的文件不能删除
+// @Tags upload
+// @Produce application/json
+// @Param LoginToken header string true "认证Token"
+// @Param hash path string true "文件哈希"
+// @Success 200 {object} util.APIResponse{code=int,message=string}
+// @Failure 409 {object} util.APIResponse{code=int,message=string,data=[]mydb.UploadFileRef}
+// @Failure 500 {object} util.APIResponse{code=int,message=string,data=string}
+// @Router /upload/delete/{hash} [delete]
+func DeleteFile(c *gin.Context) {
+	loginToken := c.GetHeader("LoginToken")
+	if _, err := admin.GetAdminIDFromToken(loginToken); err != nil {
+		c.JSON(http.StatusUnauthorized, util.APIResponse{Code: http.StatusUnauthorized, Message: "无权限进行此操作", Data: err.Error()})
+		return
+	}
+
+	var uploadFile mydb.StructUploadFile
+	hash := c.Param("hash")
+	if !mydb.IsUploadHash(hash) {
+		c.JSON(http.StatusBadRequest, util.APIResponse{Code: http.StatusBadRequest, Message: "无效的文件哈希", Data: "null"})
+		return
+	}
+	refs, err := uploadFile.FindReferences(hash)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, util.APIResponse{Code: http.StatusInternalServerError, Message: "获取文件引用失败", Data: err.Error()})
+		return
+	}
+	if len(refs) > 0 {
+		c.JSON(http.StatusConflict, util.APIResponse{Code: http.StatusConflict, Message: "文件仍被引用，不能删除", Data: refs})
+		return
+	}
+
+	file, err := uploadFile.Find(mydb.QueryParams{Condition: fmt.Sprintf("hash='%s'", mydb.EscapeString(hash))})
+	if err != nil {
+		c.JSON(http.StatusNotFound, util.APIResponse{Code: http.StatusNotFound, Message: "文件未找到", Data: err.Error()})
+		return
+	}
+
+	if err := removeUploadFile(file); err != nil {
+		c.JSON(http.StatusInternalServerError, util.APIResponse{Code: http.StatusInternalServerError, Message: "删除文件失败", Data: err.Error()})
+		return
+	}
+	log.InfoLogger.Printf("上传文件已删除,hash=%s,path=%s", file.Hash, file.FilePath)
+
+	c.JSON(http.StatusOK, util.APIResponse{Code: http.StatusOK, Message: "文件删除成功"})
+}
+
+// CleanOrphans 清理孤儿文件
+// @Summary 清理孤儿文件
+// @Description 列出（dry_run=true）或删除超过宽限期且未被引用的上传文件
+// @Tags upload
+// @Produce application/json
+// @Param LoginToken header string true "认证Token"
+// @Param dry_run query bool false "只列出不删除"
+// @Success 200 {object} util.APIResponse{code=int,message=string,data=[]mydb.StructUploadFile}
+// @Failure 500 {object} util.APIResponse{code=int,message=string,data=string}
+// @Router /upload/gc [post]
+func CleanOrphans(c *gin.Context) {
+	loginToken := c.GetHeader("LoginToken")
+	if _, err := admin.GetAdminIDFromToken(loginToken); err != nil {
+		c.JSON(http.StatusUnauthorized, util.APIResponse{Code: http.StatusUnauthorized, Message: "无权限进行此操作", Data: err.Error()})
+		return
+	}
+
+	dryRun, _ := strconv.ParseBool(c.Query("dry_run"))
+	if dryRun {
+		orphans, err := FindOrphanFiles()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, util.APIResponse{Code: http.StatusInternalServerError, Message: "查找孤儿文件失败", Data: err.Error()})
+			return
+		}
+		if orphans == nil {
+			orphans = []mydb.StructUploadFile{}
+		}
+		c.JSON(http.StatusOK, util.APIResponse{Code: http.StatusOK, Message: "查找孤儿文件成功", Data: orphans})
+		return
+	}
+
+	removed, err := CleanOrphanFiles()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, util.APIResponse{Code: http.StatusInternalServerError, Message: "清理孤儿文件失败", Data: err.Error()})
+		return
+	}
+	if removed == nil {
+		removed = []mydb.StructUploadFile{}
+	}
+	c.JSON(http.StatusOK, util.APIResponse{Code: http.StatusOK, Message: "清理孤儿文件成功", Data: removed})
+}
+
+// FindOrphanFiles 查找超过宽限期且未被任何字段引用的文件
+func FindOrphanFiles() ([]mydb.StructUploadFile, error) {
+	graceHours := config.Config.Upload.OrphanGraceHours
+	if graceHours <= 0 {
+		graceHours = 24
+	}
+	deadline := time.Now().Add(-time.Duration(graceHours) * time.Hour).Unix()
+
+	var uploadFile mydb.StructUploadFile
+	files, code, err := uploadFile.Select(mydb.QueryParams{Condition: fmt.Sprintf("upload_time<%d", deadline)})
+	if err != nil {
+		if code == 200 {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	refHashes, err := uploadFile.ReferencedHashes()
+	if err != nil {
+		return nil, err
+	}
+
+	var orphans []mydb.StructUploadFile
+	for _, file := range files {
+		if refHashes[file.Hash] == 0 {
+			orphans = append(orphans, file)
+		}
+	}
+	return orphans, nil
+}
+
+// CleanOrphanFiles 删除孤儿文件，供计划任务 upload_gc 和清理接口调用
+func CleanOrphanFiles() ([]mydb.StructUploadFile, error) {
+	orphans, err := FindOrphanFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []mydb.StructUploadFile
+	for _, file := range orphans {
+		if err := removeUploadFile(file); err != nil {
+			log.ErrorLogger.Printf("删除孤儿文件失败,hash=%s: %v", file.Hash, err)
+			continue
+		}
+		removed = append(removed, file)
+	}
+	log.InfoLogger.Printf("孤儿文件清理完成,找到%d个,删除%d个", len(orphans), len(removed))
+	return removed, nil
+}
+
+// removeUploadFile 删除磁盘文件和数据库记录
+func removeUploadFile(file mydb.StructUploadFile) error {
+	if err := os.Remove(file.FilePath); err != nil && !os.IsNotExist(err) {
+		return util.WrapError(err, "删除磁盘文件失败:")
+	}
+	if _, _, err := file.Delete(fmt.Sprintf("id=%d", file.ID)); err != nil {
+		return err
+	}
+	forgetFileByHash(file.Hash)
+	return nil
+}
@@ -0,0 +1,157 @@
+package upload
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"nav-web-site/app/api/v1/admin"
+	"nav-web-site/config"
+	"nav-web-site/mydb"
+	"nav-web-site/util"
+	"nav-web-site/util/log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SignedURLData 签名链接接口返回的数据
+type SignedURLData struct {
+	Url     string `json:"url"`
+	Expires int64  `json:"expires"`
+}
+
+// SignImageURL 为私有文件生成带过期时间的签名链接，clientIP 不为空时链接只能由该IP访问
+func SignImageURL(hash string, expires int64, clientIP string) (string, error) {
+	secret := config.Config.Upload.SignSecret
+	if secret == "" {
+		return "", fmt.Errorf("未配置签名密钥 upload.sign_secret")
+	}
+
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expires, 10))
+	if clientIP != "" {
+		query.Set("bind_ip", "1")
+	}
+	query.Set("sign", imageSignature(secret, hash, expires, clientIP))
+	return "/images/" + hash + "?" + query.Encode(), nil
+}
+
+// verifyImageSign 校验签名链接的有效期、签名以及绑定的IP。
+// 访问者IP取 c.ClientIP()，只有来自 server.trusted_proxies 的请求才采用 X-Forwarded-For，其余用连接的对端地址
+func verifyImageSign(c *gin.Context, hash string) bool {
+	secret := config.Config.Upload.SignSecret
+	if secret == "" {
+		return false
+	}
+
+	expires, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if err != nil || expires < util.GetTimestamp(10) {
+		return false
+	}
+	clientIP := ""
+	if c.Query("bind_ip") == "1" {
+		clientIP = c.ClientIP()
+	}
+
+	expected := imageSignature(secret, hash, expires, clientIP)
+	return hmac.Equal([]byte(expected), []byte(c.Query("sign")))
+}
+
+// imageSignature 计算 hash|expires|ip 的 HMAC-SHA256
+func imageSignature(secret string, hash string, expires int64, clientIP string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(fmt.Sprintf("%s|%d|%s", hash, expires, clientIP)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignFileURL 生成私有文件的签名链接
+// @Summary 生成签名链接
+// @Description 为文件生成带过期时间的签名链接，可选绑定访问者IP
+// @Tags upload
+// @Accept application/x-www-form-urlencoded
+// @Produce application/json
+// @Param LoginToken header string true "认证Token"
+// @Param hash formData string true "文件哈希"
+// @Param ttl formData int false "有效期(秒)，默认取配置 upload.sign_ttl"
+// @Param ip formData string false "绑定的访问者IP"
+// @Success 200 {object} util.APIResponse{code=int,message=string,data=SignedURLData}
+// @Failure 400 {object} util.APIResponse{code=int,message=string,data=string}
+// @Failure 500 {object} util.APIResponse{code=int,message=string,data=string}
+// @Router /upload/sign [post]
+func SignFileURL(c *gin.Context) {
+	loginToken := c.GetHeader("LoginToken")
+	if _, err := admin.GetAdminIDFromToken(loginToken); err != nil {
+		c.JSON(http.StatusUnauthorized, util.APIResponse{Code: http.StatusUnauthorized, Message: "无权限进行此操作", Data: err.Error()})
+		return
+	}
+
+	file, err := findFileByHash(c.PostForm("hash"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, util.APIResponse{Code: http.StatusNotFound, Message: "文件未找到", Data: err.Error()})
+		return
+	}
+
+	ttl, err := strconv.Atoi(c.PostForm("ttl"))
+	if err != nil || ttl <= 0 {
+		ttl = config.Config.Upload.SignTTL
+	}
+	if ttl <= 0 {
+		ttl = 3600
+	}
+	expires := time.Now().Add(time.Duration(ttl) * time.Second).Unix()
+
+	signedURL, err := SignImageURL(file.Hash, expires, c.PostForm("ip"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, util.APIResponse{Code: http.StatusInternalServerError, Message: "生成签名链接失败", Data: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, util.APIResponse{Code: http.StatusOK, Message: "生成签名链接成功", Data: SignedURLData{Url: signedURL, Expires: expires}})
+}
+
+// SetFileVisibility 设置文件是否私有
+// @Summary 设置文件可见性
+// @Description 设置文件为私有（只能通过签名链接访问）或公开
+// @Tags upload
+// @Accept application/x-www-form-urlencoded
+// @Produce application/json
+// @Param LoginToken header string true "认证Token"
+// @Param hash path string true "文件哈希"
+// @Param is_private formData bool true "是否私有"
+// @Success 200 {object} util.APIResponse{code=int,message=string}
+// @Failure 400 {object} util.APIResponse{code=int,message=string,data=string}
+// @Failure 500 {object} util.APIResponse{code=int,message=string,data=string}
+// @Router /upload/visibility/{hash} [put]
+func SetFileVisibility(c *gin.Context) {
+	loginToken := c.GetHeader("LoginToken")
+	if _, err := admin.GetAdminIDFromToken(loginToken); err != nil {
+		c.JSON(http.StatusUnauthorized, util.APIResponse{Code: http.StatusUnauthorized, Message: "无权限进行此操作", Data: err.Error()})
+		return
+	}
+
+	isPrivate, err := strconv.ParseBool(c.PostForm("is_private"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, util.APIResponse{Code: http.StatusBadRequest, Message: "无效的is_private参数", Data: err.Error()})
+		return
+	}
+
+	file, err := findFileByHash(c.Param("hash"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, util.APIResponse{Code: http.StatusNotFound, Message: "文件未找到", Data: err.Error()})
+		return
+	}
+
+	file.IsPrivate = isPrivate
+	if _, _, err := file.Update([]mydb.StructUploadFile{file}, "id="+strconv.Itoa(file.ID)); err != nil {
+		c.JSON(http.StatusInternalServerError, util.APIResponse{Code: http.StatusInternalServerError, Message: "设置文件可见性失败", Data: err.Error()})
+		return
+	}
+	forgetFileByHash(file.Hash)
+	log.InfoLogger.Printf("文件可见性已修改,hash=%s,is_private=%v", file.Hash, isPrivate)
+
+	c.JSON(http.StatusOK, util.APIResponse{Code: http.StatusOK, Message: "设置文件可见性成功"})
+}
@@ -0,0 +1,63 @@
+package upload
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"nav-web-site/mydb"
+	"nav-web-site/util"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// imageExtensions 按内容识别出的图片类型对应的扩展名
+var imageExtensions = map[string]string{
+	"image/jpeg":   ".jpg",
+	"image/png":    ".png",
+	"image/gif":    ".gif",
+	"image/webp":   ".webp",
+	"image/x-icon": ".ico",
+}
+
+// SaveImageBytes 把图片内容存进上传目录并登记，和后台上传的图片一样按哈希寻址，
+// 内容已存在时直接返回已有记录。用于转存抓取和提交的新闻里的外链图片
+func SaveImageBytes(data []byte, adminID int) (mydb.StructUploadFile, error) {
+	var uploadFile mydb.StructUploadFile
+
+	ext, ok := imageExtensions[http.DetectContentType(data)]
+	if !ok {
+		return uploadFile, fmt.Errorf("不支持的图片格式")
+	}
+
+	hash := fmt.Sprintf("%x", sha256.Sum256(data))
+	if existingFile, err := findFileByHash(hash); err == nil {
+		return existingFile, nil
+	}
+
+	now := time.Now()
+	fileName := hash + ext
+	filePath := filepath.Join("uploads", "img", now.Format("2006"), now.Format("01"), now.Format("02"), fileName)
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return uploadFile, util.WrapError(err, "创建上传目录失败:")
+	}
+	if err := os.WriteFile(filePath, data, 0644); err != nil {
+		return uploadFile, util.WrapError(err, "保存文件失败:")
+	}
+
+	uploadFile = mydb.StructUploadFile{
+		FileName:   fileName,
+		FilePath:   filePath,
+		FileSize:   int64(len(data)),
+		Hash:       hash,
+		FileType:   "img",
+		Extension:  ext,
+		UploadTime: util.GetTimestamp(10),
+		AdminID:    adminID,
+	}
+	if _, _, err := uploadFile.Insert([]mydb.StructUploadFile{uploadFile}); err != nil {
+		os.Remove(filePath)
+		return uploadFile, util.WrapError(err, "插入文件记录失败:")
+	}
+	return uploadFile, nil
+}
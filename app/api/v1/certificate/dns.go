@@ -1,83 +1,119 @@
 package certificate
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
-	"nav-web-site/app/aliyun"
-	"nav-web-site/app/cloudflare"
+	"nav-web-site/app/dns"
+	"nav-web-site/config"
+	"net/http"
 	"strings"
+	"time"
 )
 
-func CallEmptyFunctions() {
-	aliyun.EmptyFunction()
-	cloudflare.EmptyFunction()
+// challengeSolver 发布和清理 DNS-01 验证用的 TXT 记录，fqdn 形如 _acme-challenge.example.com
+type challengeSolver interface {
+	Present(ctx context.Context, fqdn, value string) error
+	CleanUp(ctx context.Context, fqdn, value string) error
 }
 
-func AddDNSRecord(domain string) error {
-	dnsProvider, err := getDNSProvider(domain)
+// getDNSProvider 按 tls.dns_provider 配置返回 DNS-01 验证所用的服务商，为空或 auto 时按域名的 NS 记录自动判断
+func getDNSProvider(ctx context.Context, domain string) (challengeSolver, error) {
+	if config.Config.TLS.DNSProvider == "challtestsrv" {
+		managementURL := config.Config.TLS.ChallTestSrv
+		if managementURL == "" {
+			managementURL = "http://localhost:8055"
+		}
+		return challTestSrvSolver{managementURL: strings.TrimRight(managementURL, "/")}, nil
+	}
+	provider, err := dns.ForDomain(ctx, config.Config.TLS.DNSProvider, strings.TrimPrefix(domain, "*."))
 	if err != nil {
-		return fmt.Errorf("failed to get DNS provider: %v", err)
+		return nil, err
 	}
+	return providerSolver{provider: provider}, nil
+}
 
-	switch dnsProvider {
-	case "cloudflare":
-		apiKey := ""
-		record := cloudflare.DNSRecord{
-			Type:    "",
-			Name:    "",
-			Content: "",
-			TTL:     60,
-		} // 初始化 record 变量
+// propagationWait 添加 TXT 记录后等待生效的时间
+func propagationWait() time.Duration {
+	if config.Config.TLS.PropagationWait > 0 {
+		return time.Duration(config.Config.TLS.PropagationWait) * time.Second
+	}
+	if config.Config.TLS.DNSProvider == "challtestsrv" {
+		return 0
+	}
+	return 60 * time.Second
+}
 
-		// 检查DNS记录是否存在
-		existingRecordID, err := cloudflare.GetDNSRecordID(apiKey, domain, record)
-		if err != nil {
-			return fmt.Errorf("failed to check DNS record: %v", err)
-		}
+// providerSolver 通过 DNS 服务商的 API 发布 TXT 记录
+type providerSolver struct {
+	provider dns.Provider
+}
 
-		if existingRecordID != "" {
-			// 更新DNS记录
-			return cloudflare.UpdateDNSRecord(apiKey, domain, existingRecordID, record)
-		} else {
-			// 添加DNS记录
-			return cloudflare.AddDNSRecord(apiKey, domain, record)
-		}
-	case "aliyun":
-		accessKeyId := ""
-		accessKeySecret := ""
-		record := aliyun.DNSRecord{
-			Type:    "",
-			Name:    "",
-			Content: "",
-			TTL:     60,
-		}
+func (s providerSolver) Present(ctx context.Context, fqdn, value string) error {
+	zone, err := s.provider.FindZone(ctx, fqdn)
+	if err != nil {
+		return err
+	}
+	// TTL 留空使用服务商的默认值，阿里云免费版不支持小于600的 TTL
+	_, err = s.provider.Create(ctx, zone, dns.Record{Type: "TXT", Name: fqdn, Content: value})
+	return err
+}
 
-		// 检查DNS记录是否存在
-		existingRecordID, err := aliyun.GetDNSRecordID(accessKeyId, accessKeySecret, domain, record)
-		if err != nil {
-			return fmt.Errorf("failed to check DNS record: %v", err)
+func (s providerSolver) CleanUp(ctx context.Context, fqdn, value string) error {
+	zone, err := s.provider.FindZone(ctx, fqdn)
+	if err != nil {
+		return err
+	}
+	records, err := s.provider.List(ctx, zone, dns.Record{Type: "TXT", Name: fqdn, Content: value})
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		if err := s.provider.Delete(ctx, zone, record.ID); err != nil {
+			return err
 		}
+	}
+	return nil
+}
 
-		if existingRecordID != "" {
-			// 更新DNS记录
-			return aliyun.UpdateDNSRecord(accessKeyId, accessKeySecret, existingRecordID, record)
-		} else {
-			// 添加DNS记录
-			return aliyun.AddDNSRecord(accessKeyId, accessKeySecret, domain, record)
-		}
-	default:
-		return fmt.Errorf("unsupported DNS provider: %s", dnsProvider)
+// challTestSrvSolver 通过 pebble-challtestsrv 的管理接口发布 TXT 记录，用于本地对接 Pebble 测试
+type challTestSrvSolver struct {
+	managementURL string
+}
+
+func (s challTestSrvSolver) Present(ctx context.Context, fqdn, value string) error {
+	return s.post(ctx, "/set-txt", map[string]string{"host": dnsName(fqdn), "value": value})
+}
+
+func (s challTestSrvSolver) CleanUp(ctx context.Context, fqdn, value string) error {
+	return s.post(ctx, "/clear-txt", map[string]string{"host": dnsName(fqdn)})
+}
+
+func (s challTestSrvSolver) post(ctx context.Context, path string, data interface{}) error {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.managementURL+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("challtestsrv %s returned %s", path, resp.Status)
 	}
+	return nil
 }
 
-func getDNSProvider(domain string) (string, error) {
-	// 这里实现检测域名 DNS 服务商的逻辑
-	// 例如，通过 DNS 查询或 API 调用来确定服务商
-	// 返回 "cloudflare" 或 "aliyun" 等
-	// 这是一个示例实现，具体实现需要根据实际情况编写
-	if strings.HasSuffix(domain, ".cf") {
-		return "cloudflare", nil
-	} else if strings.HasSuffix(domain, ".ali") {
-		return "aliyun", nil
+// dnsName 补上结尾的点
+func dnsName(fqdn string) string {
+	if strings.HasSuffix(fqdn, ".") {
+		return fqdn
 	}
-	return "", fmt.Errorf("unknown DNS provider for domain: %s", domain)
+	return fqdn + "."
 }
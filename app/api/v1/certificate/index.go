@@ -2,38 +2,66 @@ package certificate
 
 import (
 	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/tls"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/pem"
+	"errors"
 	"fmt"
-	"log"
+	"nav-web-site/config"
+	"nav-web-site/util/log"
+	"net/http"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 
 	"golang.org/x/crypto/acme"
 )
 
 /*
-Let's Encrypt 证书申请
+Let's Encrypt 证书申请（ACME DNS-01 验证）
 */
 
-// 生成私钥
-func generatePrivateKey() (*rsa.PrivateKey, error) {
-	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+// LetsEncryptStagingURL Let's Encrypt 测试环境，签发的证书不受信任但频率限制宽松，tls.directory_url 填 staging 时使用
+const LetsEncryptStagingURL = "https://acme-staging-v02.api.letsencrypt.org/directory"
+
+var (
+	acmeClients   = make(map[string]*acme.Client) // 按目录地址缓存已注册账户的客户端
+	acmeClientsMu sync.Mutex
+)
+
+// 生成私钥：tls.key_type 为 rsa 时生成 RSA-2048，默认 ECDSA P-256
+func generatePrivateKey() (crypto.Signer, error) {
+	var privateKey crypto.Signer
+	var err error
+	switch keyType := strings.ToLower(config.Config.TLS.KeyType); keyType {
+	case "", "ecdsa":
+		privateKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case "rsa":
+		privateKey, err = rsa.GenerateKey(rand.Reader, 2048)
+	default:
+		return nil, fmt.Errorf("unsupported key type: %s", keyType)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate private key: %v", err)
 	}
 	return privateKey, nil
 }
 
-// 生成证书请求
-func generateCSR(privateKey *rsa.PrivateKey, domain string) ([]byte, error) {
+// 生成证书请求，第一个域名作为 CommonName，全部域名（含通配符）写入 SAN
+func generateCSR(privateKey crypto.Signer, domains []string) ([]byte, error) {
 	template := x509.CertificateRequest{
 		Subject: pkix.Name{
-			CommonName: domain,
+			CommonName: domains[0],
 		},
-		DNSNames: []string{domain},
+		DNSNames: domains,
 	}
 	csrBytes, err := x509.CreateCertificateRequest(rand.Reader, &template, privateKey)
 	if err != nil {
@@ -42,119 +70,316 @@ func generateCSR(privateKey *rsa.PrivateKey, domain string) ([]byte, error) {
 	return csrBytes, nil
 }
 
-// 申请证书
-func requestCertificate(domain string) (*x509.Certificate, *rsa.PrivateKey, error) {
-	client := &acme.Client{
-		DirectoryURL: acme.LetsEncryptURL,
+// encodePrivateKey 私钥编码成 PKCS#8 PEM
+func encodePrivateKey(privateKey crypto.Signer) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal private key: %v", err)
 	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
 
-	privateKey, err := generatePrivateKey()
+// directoryURL ACME 服务目录地址，默认 Let's Encrypt 正式环境
+func directoryURL() string {
+	switch config.Config.TLS.DirectoryURL {
+	case "":
+		return acme.LetsEncryptURL
+	case "staging":
+		return LetsEncryptStagingURL
+	default:
+		return config.Config.TLS.DirectoryURL
+	}
+}
+
+// accountKeyFile ACME 账户私钥的保存位置，默认在证书目录下
+func accountKeyFile() string {
+	if config.Config.TLS.AccountKey != "" {
+		return config.Config.TLS.AccountKey
+	}
+	return filepath.Join(certDir(), "acme_account.key")
+}
+
+// loadAccountKey 读取 ACME 账户私钥，不存在时生成 ECDSA P-256 私钥并保存，之后一直使用同一个账户
+func loadAccountKey() (crypto.Signer, error) {
+	keyFile := accountKeyFile()
+	data, err := os.ReadFile(keyFile)
+	if err == nil {
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("no private key found in %s", keyFile)
+		}
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse account key: %v", err)
+		}
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported account key in %s", keyFile)
+		}
+		return signer, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read account key: %v", err)
+	}
+
+	accountKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
 	if err != nil {
-		return nil, nil, err
+		return nil, fmt.Errorf("failed to generate account key: %v", err)
+	}
+	keyPEM, err := encodePrivateKey(accountKey)
+	if err != nil {
+		return nil, err
 	}
+	if err := writePrivateFile(keyFile, keyPEM); err != nil {
+		return nil, fmt.Errorf("failed to save account key: %v", err)
+	}
+	log.InfoLogger.Printf("已生成 ACME 账户私钥: %s", keyFile)
+	return accountKey, nil
+}
+
+// newACMEClient 按 tls 配置返回 ACME 客户端。账户私钥保存在本地，每个目录地址只注册一次，
+// 服务端已有该账户时直接使用
+func newACMEClient(ctx context.Context) (*acme.Client, error) {
+	acmeClientsMu.Lock()
+	defer acmeClientsMu.Unlock()
 
-	csrBytes, err := generateCSR(privateKey, domain)
+	directory := directoryURL()
+	if client, ok := acmeClients[directory]; ok {
+		return client, nil
+	}
+
+	accountKey, err := loadAccountKey()
 	if err != nil {
-		return nil, nil, err
+		return nil, err
+	}
+	httpClient, err := acmeHTTPClient()
+	if err != nil {
+		return nil, err
+	}
+	client := &acme.Client{
+		Key:          accountKey,
+		DirectoryURL: directory,
+		HTTPClient:   httpClient,
 	}
 
-	account := &acme.Account{
-		Contact: []string{"mailto:your-email@example.com"},
+	account := &acme.Account{}
+	if config.Config.TLS.Email != "" {
+		account.Contact = []string{"mailto:" + config.Config.TLS.Email}
+	}
+	if _, err := client.Register(ctx, account, acme.AcceptTOS); err != nil && !errors.Is(err, acme.ErrAccountAlreadyExists) {
+		return nil, fmt.Errorf("failed to register account: %v", err)
 	}
+	acmeClients[directory] = client
+	return client, nil
+}
 
-	ctx := context.Background()
-	account, err = client.Register(ctx, account, acme.AcceptTOS)
+// acmeHTTPClient 配置了 tls.ca_file 时额外信任该 CA，用于访问 Pebble 等使用自签名证书的 ACME 服务
+func acmeHTTPClient() (*http.Client, error) {
+	if config.Config.TLS.CAFile == "" {
+		return http.DefaultClient, nil
+	}
+	caPEM, err := os.ReadFile(config.Config.TLS.CAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ca file: %v", err)
+	}
+	pool, err := x509.SystemCertPool()
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to register account: %v", err)
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("no certificate found in ca file: %s", config.Config.TLS.CAFile)
 	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	return &http.Client{Transport: transport, Timeout: 60 * time.Second}, nil
+}
 
-	authz, err := client.Authorize(ctx, domain)
+// 申请证书：一个订单包含全部域名（SAN），通配符域名同样通过 DNS-01 验证。先为所有待验证的授权发布 TXT 记录，
+// 统一等待生效后通知服务端验证，最后清理记录并提交 CSR 取回证书链。返回 PEM 格式的证书链和 PKCS#8 私钥
+func requestCertificate(ctx context.Context, domains []string) ([]byte, []byte, error) {
+	client, err := newACMEClient(ctx)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to authorize domain: %v", err)
+		return nil, nil, err
 	}
 
-	const DNS01 = "dns-01"
-	var dnsChallenge *acme.Challenge
-	for _, challenge := range authz.Challenges {
-		if challenge.Type == DNS01 {
-			dnsChallenge = challenge
-			break
-		}
+	order, err := client.AuthorizeOrder(ctx, acme.DomainIDs(domains...))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create order: %v", err)
 	}
 
-	if dnsChallenge == nil {
-		return nil, nil, fmt.Errorf("dns-01 challenge not found")
+	var pending []*acme.Authorization
+	for _, authzURL := range order.AuthzURLs {
+		authz, err := client.GetAuthorization(ctx, authzURL)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get authorization: %v", err)
+		}
+		if authz.Status != acme.StatusValid {
+			pending = append(pending, authz)
+		}
+	}
+	if err := solveDNS01(ctx, client, pending); err != nil {
+		return nil, nil, err
 	}
 
-	challenge, err := client.Accept(ctx, dnsChallenge)
+	order, err = client.WaitOrder(ctx, order.URI)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to accept challenge: %v", err)
+		return nil, nil, fmt.Errorf("failed to wait for order: %v", err)
 	}
-	_ = challenge
 
-	_, err = client.WaitAuthorization(ctx, authz.URI)
+	privateKey, err := generatePrivateKey()
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to wait for authorization: %v", err)
+		return nil, nil, err
 	}
-
-	cert, _, err := client.CreateCert(ctx, csrBytes, 0, true)
+	csrBytes, err := generateCSR(privateKey, domains)
+	if err != nil {
+		return nil, nil, err
+	}
+	chain, _, err := client.CreateOrderCert(ctx, order.FinalizeURL, csrBytes, true)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to create certificate: %v", err)
 	}
 
-	certBlock, _ := pem.Decode(cert[0])
-	if certBlock == nil {
-		return nil, nil, fmt.Errorf("failed to decode certificate PEM")
+	var certPEM []byte
+	for _, der := range chain {
+		certPEM = append(certPEM, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
 	}
-
-	certificate, err := x509.ParseCertificate(certBlock.Bytes)
+	keyPEM, err := encodePrivateKey(privateKey)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to parse certificate: %v", err)
+		return nil, nil, err
 	}
+	return certPEM, keyPEM, nil
+}
 
-	return certificate, privateKey, nil
+// dnsChallenge 一个授权的 DNS-01 验证
+type dnsChallenge struct {
+	authz     *acme.Authorization
+	challenge *acme.Challenge
+	solver    challengeSolver
+	fqdn      string
+	value     string
 }
 
-// 保存证书和私钥到文件
-func saveCertificateAndKey(cert *x509.Certificate, privateKey *rsa.PrivateKey, certFile, keyFile string) error {
-	certOut, err := os.Create(certFile)
-	if err != nil {
-		return fmt.Errorf("failed to open cert file for writing: %v", err)
+// solveDNS01 完成授权的 DNS-01 验证，结束后无论成功与否都清理 TXT 记录。
+// example.com 和 *.example.com 的验证记录都在 _acme-challenge.example.com，两条 TXT 记录同时存在
+func solveDNS01(ctx context.Context, client *acme.Client, authzs []*acme.Authorization) error {
+	if len(authzs) == 0 {
+		return nil
 	}
-	defer certOut.Close()
 
-	err = pem.Encode(certOut, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
-	if err != nil {
-		return fmt.Errorf("failed to write data to cert file: %v", err)
+	var presented []dnsChallenge
+	defer func() {
+		for _, c := range presented {
+			if err := c.solver.CleanUp(context.Background(), c.fqdn, c.value); err != nil {
+				log.ErrorLogger.Printf("清理验证记录失败,%s: %v", c.fqdn, err)
+			}
+		}
+	}()
+
+	for _, authz := range authzs {
+		c := dnsChallenge{authz: authz, fqdn: "_acme-challenge." + authz.Identifier.Value}
+		for _, challenge := range authz.Challenges {
+			if challenge.Type == "dns-01" {
+				c.challenge = challenge
+				break
+			}
+		}
+		if c.challenge == nil {
+			return fmt.Errorf("dns-01 challenge not found for %s", authz.Identifier.Value)
+		}
+
+		var err error
+		c.value, err = client.DNS01ChallengeRecord(c.challenge.Token)
+		if err != nil {
+			return fmt.Errorf("failed to compute dns-01 record: %v", err)
+		}
+		c.solver, err = getDNSProvider(ctx, authz.Identifier.Value)
+		if err != nil {
+			return fmt.Errorf("failed to get DNS provider: %v", err)
+		}
+		if err := c.solver.Present(ctx, c.fqdn, c.value); err != nil {
+			return fmt.Errorf("failed to add TXT record %s: %v", c.fqdn, err)
+		}
+		presented = append(presented, c)
 	}
+	log.InfoLogger.Printf("已添加%d条验证记录,等待%s生效", len(presented), propagationWait())
 
-	keyOut, err := os.Create(keyFile)
-	if err != nil {
-		return fmt.Errorf("failed to open key file for writing: %v", err)
+	select {
+	case <-time.After(propagationWait()):
+	case <-ctx.Done():
+		return ctx.Err()
 	}
-	defer keyOut.Close()
 
-	err = pem.Encode(keyOut, &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(privateKey)})
-	if err != nil {
-		return fmt.Errorf("failed to write data to key file: %v", err)
+	for _, c := range presented {
+		if _, err := client.Accept(ctx, c.challenge); err != nil {
+			return fmt.Errorf("failed to accept challenge: %v", err)
+		}
 	}
+	for _, c := range presented {
+		if _, err := client.WaitAuthorization(ctx, c.authz.URI); err != nil {
+			return fmt.Errorf("failed to wait for authorization %s: %v", c.authz.Identifier.Value, err)
+		}
+	}
+	return nil
+}
 
+// 保存证书和私钥到文件，私钥只有所有者可读写
+func saveCertificateAndKey(certPEM, keyPEM []byte, certFile, keyFile string) error {
+	if err := os.MkdirAll(filepath.Dir(certFile), 0755); err != nil {
+		return fmt.Errorf("failed to create cert dir: %v", err)
+	}
+	if err := writePrivateFile(keyFile, keyPEM); err != nil {
+		return fmt.Errorf("failed to write data to key file: %v", err)
+	}
+	if err := os.WriteFile(certFile, certPEM, 0644); err != nil {
+		return fmt.Errorf("failed to write data to cert file: %v", err)
+	}
 	return nil
 }
 
-// 自动申请证书并保存
-// err := AutoRequestCertificate("your-domain.com", "path/to/cert.pem", "path/to/key.pem")
-func AutoRequestCertificate(domain, certFile, keyFile string) error {
-	cert, privateKey, err := requestCertificate(domain)
+// writePrivateFile 以 0600 权限写入私钥：先写临时文件再改名，已有文件的权限过宽时也会被替换
+func writePrivateFile(name string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
+
+// 自动申请证书并保存，domains 的第一个域名作为证书清单里的域名，结果记录到证书清单
+// err := AutoRequestCertificate(ctx, []string{"example.com", "*.example.com"}, "path/to/cert.pem", "path/to/key.pem")
+func AutoRequestCertificate(ctx context.Context, domains []string, certFile, keyFile string) (err error) {
+	if len(domains) == 0 {
+		return fmt.Errorf("no domains to request")
+	}
+	defer func() {
+		trackCertificate(domains[0], certFile, keyFile, true, err)
+	}()
+
+	certPEM, keyPEM, err := requestCertificate(ctx, domains)
 	if err != nil {
 		return fmt.Errorf("failed to request certificate: %v", err)
 	}
 
-	err = saveCertificateAndKey(cert, privateKey, certFile, keyFile)
+	err = saveCertificateAndKey(certPEM, keyPEM, certFile, keyFile)
 	if err != nil {
 		return fmt.Errorf("failed to save certificate and key: %v", err)
 	}
 
-	log.Printf("Certificate and key for domain %s saved to %s and %s", domain, certFile, keyFile)
+	log.InfoLogger.Printf("Certificate and key for %s saved to %s and %s", strings.Join(domains, ","), certFile, keyFile)
 	return nil
 }
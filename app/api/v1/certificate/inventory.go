@@ -0,0 +1,241 @@
+package certificate
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"nav-web-site/config"
+	"nav-web-site/mydb"
+	"nav-web-site/util"
+	"nav-web-site/util/log"
+	"nav-web-site/util/notify"
+	"nav-web-site/util/redislock"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	RenewSuccess = "success"
+	RenewFailed  = "failed"
+
+	StatusValid    = "valid"    // 有效期充足
+	StatusExpiring = "expiring" // 进入续期时间
+	StatusExpired  = "expired"  // 已过期
+	StatusMissing  = "missing"  // 还没有签发或证书文件读取失败
+
+	notifyInterval = 24 * time.Hour // 同一证书两次到期告警的最小间隔
+
+	renewLockKey = "cert_renew_lock" // 整个集群同一时间只有一个实例申请证书
+	renewLockTTL = 5 * time.Minute   // 持有期间自动续期，实例退出后最多这么久锁自动释放
+)
+
+// errRenewing 其他实例正在申请证书，本次不申请，等它完成后从共享的证书目录加载
+var errRenewing = errors.New("其他实例正在申请证书")
+
+var (
+	renewMu sync.Mutex              // 同一时间只申请一个证书，避免续期任务和 HTTPS 管理器重复申请
+	serving atomic.Pointer[Manager] // 正在提供 HTTPS 的管理器，续期任务更新证书后通知它重新加载
+)
+
+// withRenewLock 在本进程和整个集群都只有自己申请证书时执行 fn，锁被其他实例持有时返回 errRenewing。
+// 多个实例的证书目录（tls.cert_dir）应当共享，否则没拿到锁的实例读不到新证书
+func withRenewLock(ctx context.Context, fn func(ctx context.Context) error) error {
+	renewMu.Lock()
+	defer renewMu.Unlock()
+	lock, err := redislock.Acquire(ctx, mydb.RedisClient, renewLockKey, renewLockTTL)
+	if errors.Is(err, redislock.ErrNotAcquired) {
+		return errRenewing
+	}
+	if err != nil {
+		return util.WrapError(err, "获取证书续期锁失败:")
+	}
+	defer func() {
+		if err := lock.Release(context.Background()); err != nil {
+			log.ErrorLogger.Printf("释放证书续期锁失败: %v", err)
+		}
+	}()
+	return fn(lock.Hold(ctx))
+}
+
+// warnBefore 剩余有效期少于这个时间且续期没有成功时告警
+func warnBefore() time.Duration {
+	if config.Config.TLS.WarnBefore > 0 {
+		return time.Duration(config.Config.TLS.WarnBefore) * 24 * time.Hour
+	}
+	return 14 * 24 * time.Hour
+}
+
+// readLeaf 读取证书文件里的第一个证书
+func readLeaf(certFile string) (*x509.Certificate, error) {
+	data, err := os.ReadFile(certFile)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("no certificate found in %s", certFile)
+		}
+		if block.Type == "CERTIFICATE" {
+			return x509.ParseCertificate(block.Bytes)
+		}
+	}
+}
+
+// renewIfDue 证书缺失、快到期或不包含全部域名时申请新证书，返回是否申请了。
+// 拿到锁后按文件重新判断，其他调用或其他实例刚续期过的证书不会重复申请；其他实例正在申请时返回 errRenewing
+func renewIfDue(ctx context.Context, domains []string, certFile, keyFile string) (bool, error) {
+	renewed := false
+	err := withRenewLock(ctx, func(ctx context.Context) error {
+		if leaf, err := readLeaf(certFile); err == nil && time.Until(leaf.NotAfter) > renewBefore() && covers(leaf, domains) {
+			return nil
+		}
+		renewed = true
+		return AutoRequestCertificate(ctx, domains, certFile, keyFile)
+	})
+	return renewed, err
+}
+
+// trackCertificate 把证书文件的信息写入证书清单，renewErr 为 nil 且 renewed 时记为续期成功，不为 nil 时记为失败。
+// 数据库不可用时只记日志
+func trackCertificate(domain, certFile, keyFile string, renewed bool, renewErr error) {
+	item, err := mydb.Tables.Certificate.Find(mydb.QueryParams{
+		Condition: fmt.Sprintf("domain='%s'", mydb.EscapeString(domain)),
+	})
+	exists := err == nil
+	now := util.GetTimestamp(10)
+	if !exists {
+		item = mydb.StructCertificate{Domain: domain, Create_time: now}
+	}
+	item.Cert_file = certFile
+	item.Key_file = keyFile
+	item.Update_time = now
+
+	if leaf, err := readLeaf(certFile); err == nil {
+		item.Sans = strings.Join(leaf.DNSNames, ",")
+		item.Issuer = leaf.Issuer.CommonName
+		if item.Issuer == "" && len(leaf.Issuer.Organization) > 0 {
+			item.Issuer = leaf.Issuer.Organization[0]
+		}
+		item.Not_before = leaf.NotBefore.Unix()
+		item.Not_after = leaf.NotAfter.Unix()
+	}
+	if renewed || renewErr != nil {
+		item.Last_renew_time = now
+		item.Last_renew_status = RenewSuccess
+		item.Last_renew_error = ""
+		if renewErr != nil {
+			item.Last_renew_status = RenewFailed
+			item.Last_renew_error = util.Truncate(renewErr.Error(), 1024)
+		}
+	}
+
+	if exists {
+		_, _, err = item.Update([]mydb.StructCertificate{item}, fmt.Sprintf("id=%d", item.ID))
+	} else {
+		_, _, err = item.Insert([]mydb.StructCertificate{item})
+	}
+	if err != nil {
+		log.ErrorLogger.Printf("更新证书清单失败,域名=%s: %v", domain, err)
+	}
+}
+
+// Status 按到期时间判断证书状态
+func Status(item mydb.StructCertificate, now time.Time) string {
+	if item.Not_after == 0 {
+		return StatusMissing
+	}
+	left := time.Unix(item.Not_after, 0).Sub(now)
+	switch {
+	case left <= 0:
+		return StatusExpired
+	case left <= renewBefore():
+		return StatusExpiring
+	default:
+		return StatusValid
+	}
+}
+
+// RenewExpiring 扫描证书清单，续期进入续期时间的证书，续期后仍快到期的发送告警。
+// 返回续期成功和失败的数量，有失败时返回错误
+func RenewExpiring(ctx context.Context) (int, int, error) {
+	items, code, err := mydb.Tables.Certificate.Select(mydb.QueryParams{OrderBy: "not_after ASC"})
+	if err != nil && code != 200 {
+		return 0, 0, err
+	}
+
+	renewed, failed := 0, 0
+	for _, item := range items {
+		if ctx.Err() != nil {
+			return renewed, failed, ctx.Err()
+		}
+		if Status(item, time.Now()) == StatusValid {
+			continue
+		}
+		// 续期时保留原证书的全部域名，证书清单里的域名放在第一个
+		domains := splitDomains(item.Domain + "," + item.Sans)
+		ok, err := renewIfDue(ctx, domains, item.Cert_file, item.Key_file)
+		if errors.Is(err, errRenewing) {
+			log.InfoLogger.Printf("其他实例正在申请证书,跳过,域名=%s", item.Domain)
+		} else if err != nil {
+			failed++
+			log.ErrorLogger.Printf("续期证书失败,域名=%s: %v", item.Domain, err)
+		} else if ok {
+			renewed++
+			if m := serving.Load(); m != nil && m.get(item.Domain) != nil {
+				m.reload(item.Domain)
+			}
+		}
+	}
+
+	warnExpiring(ctx)
+	if failed > 0 {
+		return renewed, failed, fmt.Errorf("%d 个证书续期失败", failed)
+	}
+	return renewed, failed, nil
+}
+
+// warnExpiring 对快到期或最近一次续期失败的证书发送告警，同一证书每天最多一次，发送失败的下次再发
+func warnExpiring(ctx context.Context) {
+	items, _, err := mydb.Tables.Certificate.Select(mydb.QueryParams{OrderBy: "not_after ASC"})
+	if err != nil {
+		return
+	}
+	now := time.Now()
+	for _, item := range items {
+		expiring := item.Not_after == 0 || time.Unix(item.Not_after, 0).Sub(now) <= warnBefore()
+		if !expiring && item.Last_renew_status != RenewFailed {
+			continue
+		}
+		if now.Sub(time.Unix(item.Notify_time, 0)) < notifyInterval {
+			continue
+		}
+
+		title := "证书续期失败"
+		if expiring {
+			title = "证书即将过期"
+		}
+		text := fmt.Sprintf("域名: %s\n状态: %s", item.Domain, Status(item, now))
+		if item.Not_after > 0 {
+			left := time.Unix(item.Not_after, 0).Sub(now)
+			text += fmt.Sprintf("\n到期时间: %s（剩余%d天）", time.Unix(item.Not_after, 0).Format(time.DateTime), int(left.Hours()/24))
+		}
+		if item.Last_renew_status == RenewFailed {
+			text += "\n最近一次续期失败: " + item.Last_renew_error
+		}
+		if err := notify.Send(ctx, title, text); err != nil {
+			continue
+		}
+
+		item.Notify_time = now.Unix()
+		if _, _, err := item.Update([]mydb.StructCertificate{item}, fmt.Sprintf("id=%d", item.ID)); err != nil {
+			log.ErrorLogger.Printf("更新证书告警时间失败,域名=%s: %v", item.Domain, err)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package certificate
+
+import (
+	"nav-web-site/app/api/v1/admin"
+	"nav-web-site/mydb"
+	"nav-web-site/util"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CertificateItem 证书列表的一项，Status 和 Days_left 按查询时的时间计算
+type CertificateItem struct {
+	mydb.StructCertificate
+	Status    string `json:"Status"`    // valid、expiring、expired、missing
+	Days_left int    `json:"Days_left"` // 剩余天数，已过期时为负数
+}
+
+// GetCertificateList 获取证书清单
+// @Summary 获取证书清单
+// @Description 按到期时间列出已签发的证书、状态和最近一次续期的结果
+// @Tags admin
+// @Produce application/json
+// @Param LoginToken header string true "认证Token"
+// @Param status query string false "状态：valid、expiring、expired、missing"
+// @Success 200 {object} util.APIResponse{code=int,message=string,data=[]CertificateItem} "获取证书清单成功"
+// @Failure 500 {object} util.APIResponse{code=int,message=string,data=interface{}} "获取证书清单失败"
+// @Router /admin/certificates/list [get]
+func GetCertificateList(c *gin.Context) {
+	loginToken := c.GetHeader("LoginToken")
+	if _, err := admin.GetAdminIDFromToken(loginToken); err != nil {
+		c.JSON(http.StatusUnauthorized, util.APIResponse{Code: http.StatusUnauthorized, Message: "无权限进行此操作", Data: err.Error()})
+		return
+	}
+
+	certificates, code, err := mydb.Tables.Certificate.Select(mydb.QueryParams{OrderBy: "not_after ASC"})
+	if err != nil && code != 200 {
+		c.JSON(http.StatusInternalServerError, util.APIResponse{Code: http.StatusInternalServerError, Message: "获取证书清单失败", Data: err.Error()})
+		return
+	}
+
+	now := time.Now()
+	status := c.Query("status")
+	items := []CertificateItem{}
+	for _, certificate := range certificates {
+		item := CertificateItem{StructCertificate: certificate, Status: Status(certificate, now)}
+		if certificate.Not_after > 0 {
+			item.Days_left = int(time.Unix(certificate.Not_after, 0).Sub(now).Hours() / 24)
+		}
+		if status != "" && item.Status != status {
+			continue
+		}
+		items = append(items, item)
+	}
+
+	c.JSON(http.StatusOK, util.APIResponse{Code: http.StatusOK, Message: "获取证书清单成功", Data: items})
+}
@@ -0,0 +1,246 @@
+package certificate
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"nav-web-site/config"
+	"nav-web-site/util/log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	renewCheckInterval = 12 * time.Hour
+	renewRetryInterval = time.Minute // 其他实例正在申请证书时，隔这么久再加载它申请到的证书
+)
+
+// Manager 管理 HTTPS 证书：启动时从证书目录加载，缺失或快到期时通过 ACME 申请，
+// 新证书通过 GetCertificate 立即生效，不需要重启服务
+type Manager struct {
+	mu    sync.RWMutex
+	certs map[string]*tls.Certificate // 域名对应的证书
+}
+
+// NewManager 创建证书管理器
+func NewManager() *Manager {
+	return &Manager{certs: make(map[string]*tls.Certificate)}
+}
+
+// certDir 证书保存目录
+func certDir() string {
+	if config.Config.TLS.CertDir != "" {
+		return config.Config.TLS.CertDir
+	}
+	return "certs"
+}
+
+// certFiles 域名对应的证书和私钥文件
+func certFiles(domain string) (string, string) {
+	name := strings.ReplaceAll(domain, "*", "_")
+	return filepath.Join(certDir(), name+".crt"), filepath.Join(certDir(), name+".key")
+}
+
+// certDomains 按 tls.domains 配置返回要申请的证书，每一项可以用逗号分隔多个域名（SAN），第一个域名作为证书文件名
+func certDomains() [][]string {
+	var groups [][]string
+	for _, entry := range config.Config.TLS.Domains {
+		if domains := splitDomains(entry); len(domains) > 0 {
+			groups = append(groups, domains)
+		}
+	}
+	return groups
+}
+
+// splitDomains 拆分逗号分隔的域名，去掉空白和重复
+func splitDomains(value string) []string {
+	var domains []string
+	seen := make(map[string]bool)
+	for _, domain := range strings.Split(value, ",") {
+		domain = strings.ToLower(strings.TrimSpace(domain))
+		if domain != "" && !seen[domain] {
+			seen[domain] = true
+			domains = append(domains, domain)
+		}
+	}
+	return domains
+}
+
+// covers 证书是否包含全部域名，配置里新增了 SAN 时需要重新申请
+func covers(leaf *x509.Certificate, domains []string) bool {
+	names := make(map[string]bool)
+	for _, name := range leaf.DNSNames {
+		names[strings.ToLower(name)] = true
+	}
+	for _, domain := range domains {
+		if !names[domain] {
+			return false
+		}
+	}
+	return true
+}
+
+// renewBefore 剩余有效期少于这个时间时续期
+func renewBefore() time.Duration {
+	if config.Config.TLS.RenewBefore > 0 {
+		return time.Duration(config.Config.TLS.RenewBefore) * 24 * time.Hour
+	}
+	return 30 * 24 * time.Hour
+}
+
+// TLSConfig 返回使用管理器证书的 tls.Config
+func (m *Manager) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: m.GetCertificate,
+	}
+}
+
+// GetCertificate 按 SNI 选择证书，精确匹配不到时尝试通配符证书，客户端没有发送 SNI 时用第一个配置的域名
+func (m *Manager) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	name := strings.ToLower(strings.TrimSuffix(hello.ServerName, "."))
+	if groups := certDomains(); name == "" && len(groups) > 0 {
+		name = groups[0][0]
+	}
+	if cert, ok := m.certs[name]; ok {
+		return cert, nil
+	}
+	if i := strings.Index(name, "."); i > 0 {
+		if cert, ok := m.certs["*"+name[i:]]; ok {
+			return cert, nil
+		}
+	}
+	return nil, fmt.Errorf("no certificate for %q", hello.ServerName)
+}
+
+// set 替换域名的证书，证书里的每个域名（SAN）都指向它，之后的握手立即使用新证书
+func (m *Manager) set(domain string, cert *tls.Certificate) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.certs[domain] = cert
+	for _, name := range cert.Leaf.DNSNames {
+		m.certs[strings.ToLower(name)] = cert
+	}
+}
+
+func (m *Manager) get(domain string) *tls.Certificate {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.certs[domain]
+}
+
+// Load 从证书目录加载已有的证书
+func (m *Manager) Load() {
+	for _, domains := range certDomains() {
+		domain := domains[0]
+		certFile, keyFile := certFiles(domain)
+		cert, err := loadCertificate(certFile, keyFile)
+		if err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				log.ErrorLogger.Printf("加载证书失败,域名=%s: %v", domain, err)
+			}
+			continue
+		}
+		m.set(domain, cert)
+		trackCertificate(domain, certFile, keyFile, false, nil)
+		log.InfoLogger.Printf("已加载证书,域名=%s,到期时间=%s", domain, cert.Leaf.NotAfter.Format(time.DateTime))
+	}
+}
+
+// loadCertificate 读取证书和私钥，并解析出叶子证书用于判断到期时间
+func loadCertificate(certFile, keyFile string) (*tls.Certificate, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	if cert.Leaf == nil {
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			return nil, err
+		}
+		cert.Leaf = leaf
+	}
+	return &cert, nil
+}
+
+// RenewAll 为缺少证书、证书快到期或缺少新配置域名的证书重新申请，单个域名失败不影响其他域名。
+// 申请时和 cert_renew 任务共用集群续期锁，其他实例正在申请时返回 true，稍后再加载它申请到的证书
+func (m *Manager) RenewAll(ctx context.Context) (pending bool) {
+	for _, domains := range certDomains() {
+		if ctx.Err() != nil {
+			return false
+		}
+		domain := domains[0]
+		if cert := m.get(domain); cert != nil && time.Until(cert.Leaf.NotAfter) > renewBefore() && covers(cert.Leaf, domains) {
+			continue
+		}
+		certFile, keyFile := certFiles(domain)
+		_, err := renewIfDue(ctx, domains, certFile, keyFile)
+		if errors.Is(err, errRenewing) {
+			log.InfoLogger.Printf("其他实例正在申请证书,稍后重新加载,域名=%s", domain)
+			pending = true
+			continue
+		}
+		if err != nil {
+			log.ErrorLogger.Printf("申请证书失败,域名=%s: %v", domain, err)
+			continue
+		}
+		// 没有申请时是其他调用或其他实例刚续期过，同样加载新文件
+		m.reload(domain)
+	}
+	return pending
+}
+
+// Obtain 申请包含全部域名的证书，保存到证书目录（以第一个域名命名）并立即生效
+func (m *Manager) Obtain(ctx context.Context, domains []string) error {
+	domain := domains[0]
+	log.InfoLogger.Printf("开始申请证书,域名=%s", strings.Join(domains, ","))
+	certFile, keyFile := certFiles(domain)
+	err := withRenewLock(ctx, func(ctx context.Context) error {
+		return AutoRequestCertificate(ctx, domains, certFile, keyFile)
+	})
+	if err != nil {
+		return err
+	}
+	return m.reload(domain)
+}
+
+// reload 从证书目录重新加载域名的证书并立即生效
+func (m *Manager) reload(domain string) error {
+	certFile, keyFile := certFiles(domain)
+	cert, err := loadCertificate(certFile, keyFile)
+	if err != nil {
+		log.ErrorLogger.Printf("加载新证书失败,域名=%s: %v", domain, err)
+		return fmt.Errorf("failed to load new certificate: %v", err)
+	}
+	m.set(domain, cert)
+	log.InfoLogger.Printf("证书已更新,域名=%s,到期时间=%s", domain, cert.Leaf.NotAfter.Format(time.DateTime))
+	return nil
+}
+
+// Run 立即检查一次，之后每12小时检查并续期，其他实例正在申请时每分钟重新检查，ctx 取消时退出。
+// 运行期间证书清单的续期任务更新证书后也通知它重新加载
+func (m *Manager) Run(ctx context.Context) {
+	serving.Store(m)
+	for {
+		interval := renewCheckInterval
+		if m.RenewAll(ctx) {
+			interval = renewRetryInterval
+		}
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+	"nav-web-site/app/api/v1/admin"
+	"nav-web-site/app/dns"
+	"nav-web-site/config"
+	"nav-web-site/mydb"
+	"nav-web-site/util"
+	"nav-web-site/util/log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	sourceAPI  = "api"  // 后台接口直接修改
+	sourceSync = "sync" // YAML 同步
+)
+
+// AuditListData 审计日志列表
+type AuditListData struct {
+	Total    int                   `json:"total"`
+	Page     int                   `json:"page"`
+	PageSize int                   `json:"page_size"`
+	List     []mydb.StructDNSAudit `json:"list"`
+}
+
+// writeAudit 记录一次解析记录改动，写入失败只记日志
+func writeAudit(adminID int, source string, change dns.Change) {
+	audit := mydb.StructDNSAudit{
+		Admin_id:    adminID,
+		Source:      source,
+		Action:      change.Action,
+		Provider:    change.Provider,
+		Zone:        change.Zone,
+		Create_time: util.GetTimestamp(10),
+	}
+	for _, record := range []*dns.Record{change.After, change.Before} {
+		if record != nil {
+			audit.Record_id = record.ID
+			audit.Record_type = record.Type
+			audit.Record_name = record.Name
+			break
+		}
+	}
+	if change.Before != nil {
+		data, _ := json.Marshal(change.Before)
+		audit.Old_value = string(data)
+	}
+	if change.After != nil {
+		data, _ := json.Marshal(change.After)
+		audit.New_value = string(data)
+	}
+	if _, _, err := audit.Insert([]mydb.StructDNSAudit{audit}); err != nil {
+		log.ErrorLogger.Printf("记录解析记录审计日志失败,%s %s: %v", audit.Action, audit.Record_name, err)
+	}
+}
+
+// GetAuditList 获取解析记录审计日志
+// @Summary 获取解析记录审计日志
+// @Description 按时间倒序列出后台接口和 YAML 同步对解析记录的改动
+// @Tags dns
+// @Produce application/json
+// @Param LoginToken header string true "认证Token"
+// @Param zone query string false "托管域"
+// @Param source query string false "来源：api、sync"
+// @Param page query int false "页码"
+// @Param page_size query int false "每页数量"
+// @Success 200 {object} util.APIResponse{code=int,message=string,data=AuditListData} "获取审计日志成功"
+// @Failure 500 {object} util.APIResponse{code=int,message=string,data=interface{}} "获取审计日志失败"
+// @Router /dns/audit [get]
+func GetAuditList(c *gin.Context) {
+	loginToken := c.GetHeader("LoginToken")
+	if _, err := admin.GetAdminIDFromToken(loginToken); err != nil {
+		c.JSON(http.StatusUnauthorized, util.APIResponse{Code: http.StatusUnauthorized, Message: "无权限进行此操作", Data: err.Error()})
+		return
+	}
+
+	page, err := strconv.Atoi(c.Query("page"))
+	if err != nil || page <= 0 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(c.Query("page_size"))
+	if err != nil || pageSize <= 0 {
+		pageSize = 20
+	}
+
+	var conditions []string
+	if zone := c.Query("zone"); zone != "" {
+		conditions = append(conditions, fmt.Sprintf("zone='%s'", mydb.EscapeString(dns.Normalize(zone))))
+	}
+	if source := c.Query("source"); source != "" {
+		conditions = append(conditions, fmt.Sprintf("source='%s'", mydb.EscapeString(source)))
+	}
+	condition := strings.Join(conditions, " AND ")
+
+	total, err := mydb.GenericCount(mydb.Tables.DNSAudit.GetTableName(), condition, config.Config.MySQL.TablePrefix, "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, util.APIResponse{Code: http.StatusInternalServerError, Message: "获取审计日志失败", Data: err.Error()})
+		return
+	}
+
+	audits, code, err := mydb.Tables.DNSAudit.Select(mydb.QueryParams{
+		Condition: condition,
+		OrderBy:   "id DESC",
+		Limit:     pageSize,
+		Page:      page,
+		PageSize:  pageSize,
+	})
+	if err != nil && code != 200 {
+		c.JSON(http.StatusInternalServerError, util.APIResponse{Code: http.StatusInternalServerError, Message: "获取审计日志失败", Data: err.Error()})
+		return
+	}
+	if audits == nil {
+		audits = []mydb.StructDNSAudit{}
+	}
+
+	c.JSON(http.StatusOK, util.APIResponse{Code: http.StatusOK, Message: "获取审计日志成功", Data: AuditListData{Total: total, Page: page, PageSize: pageSize, List: audits}})
+}
@@ -0,0 +1,285 @@
+// Package domain 后台管理托管域的解析记录，所有改动都写入 dns_audit 审计日志
+package domain
+
+import (
+	"context"
+	"fmt"
+	"nav-web-site/app/api/v1/admin"
+	"nav-web-site/app/dns"
+	"nav-web-site/util"
+	"nav-web-site/util/log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requestTimeout 调用服务商 API 的超时
+const requestTimeout = 30 * time.Second
+
+// ZoneItem 托管域列表的一项
+type ZoneItem struct {
+	Provider string `json:"provider"`
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+}
+
+// GetZoneList 获取托管域列表
+// @Summary 获取托管域列表
+// @Description 列出所有已配置凭据的 DNS 服务商账号下的托管域
+// @Tags dns
+// @Produce application/json
+// @Param LoginToken header string true "认证Token"
+// @Success 200 {object} util.APIResponse{code=int,message=string,data=[]ZoneItem} "获取托管域列表成功"
+// @Failure 500 {object} util.APIResponse{code=int,message=string,data=interface{}} "获取托管域列表失败"
+// @Router /dns/zones [get]
+func GetZoneList(c *gin.Context) {
+	loginToken := c.GetHeader("LoginToken")
+	if _, err := admin.GetAdminIDFromToken(loginToken); err != nil {
+		c.JSON(http.StatusUnauthorized, util.APIResponse{Code: http.StatusUnauthorized, Message: "无权限进行此操作", Data: err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), requestTimeout)
+	defer cancel()
+
+	items := []ZoneItem{}
+	for _, provider := range dns.Configured() {
+		zones, err := provider.Zones(ctx)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, util.APIResponse{Code: http.StatusInternalServerError, Message: "获取托管域列表失败", Data: provider.Name() + ": " + err.Error()})
+			return
+		}
+		for _, zone := range zones {
+			items = append(items, ZoneItem{Provider: provider.Name(), ID: zone.ID, Name: zone.Name})
+		}
+	}
+
+	c.JSON(http.StatusOK, util.APIResponse{Code: http.StatusOK, Message: "获取托管域列表成功", Data: items})
+}
+
+// GetRecordList 获取解析记录列表
+// @Summary 获取解析记录列表
+// @Description 列出托管域的解析记录，可按类型和名称筛选
+// @Tags dns
+// @Produce application/json
+// @Param LoginToken header string true "认证Token"
+// @Param zone query string true "托管域，如 example.com"
+// @Param provider query string false "DNS服务商：cloudflare、aliyun，为空时按NS记录判断"
+// @Param type query string false "记录类型"
+// @Param name query string false "主机记录或完整域名"
+// @Success 200 {object} util.APIResponse{code=int,message=string,data=[]dns.Record} "获取解析记录成功"
+// @Failure 400 {object} util.APIResponse{code=int,message=string,data=interface{}} "托管域不存在"
+// @Failure 500 {object} util.APIResponse{code=int,message=string,data=interface{}} "获取解析记录失败"
+// @Router /dns/records [get]
+func GetRecordList(c *gin.Context) {
+	loginToken := c.GetHeader("LoginToken")
+	if _, err := admin.GetAdminIDFromToken(loginToken); err != nil {
+		c.JSON(http.StatusUnauthorized, util.APIResponse{Code: http.StatusUnauthorized, Message: "无权限进行此操作", Data: err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), requestTimeout)
+	defer cancel()
+
+	provider, zone, err := dns.Resolve(ctx, c.Query("provider"), c.Query("zone"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, util.APIResponse{Code: http.StatusBadRequest, Message: "托管域不存在", Data: err.Error()})
+		return
+	}
+	filter := dns.Record{Type: c.Query("type")}
+	if name := c.Query("name"); name != "" {
+		filter.Name = dns.Qualify(name, zone.Name)
+	}
+	records, err := provider.List(ctx, zone, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, util.APIResponse{Code: http.StatusInternalServerError, Message: "获取解析记录失败", Data: err.Error()})
+		return
+	}
+	if records == nil {
+		records = []dns.Record{}
+	}
+
+	c.JSON(http.StatusOK, util.APIResponse{Code: http.StatusOK, Message: "获取解析记录成功", Data: records})
+}
+
+// AddRecord 添加解析记录
+// @Summary 添加解析记录
+// @Description 在托管域下添加一条解析记录
+// @Tags dns
+// @Accept application/x-www-form-urlencoded
+// @Produce application/json
+// @Param LoginToken header string true "认证Token"
+// @Param zone formData string true "托管域"
+// @Param provider formData string false "DNS服务商，为空时按NS记录判断"
+// @Param type formData string true "记录类型，如 A、AAAA、CNAME、TXT"
+// @Param name formData string true "主机记录（如 nav、@）或完整域名"
+// @Param content formData string true "记录值"
+// @Param ttl formData int false "TTL（秒），为空时用服务商的默认值"
+// @Success 200 {object} util.APIResponse{code=int,message=string,data=dns.Record} "添加解析记录成功"
+// @Failure 400 {object} util.APIResponse{code=int,message=string,data=interface{}} "请求参数错误"
+// @Failure 500 {object} util.APIResponse{code=int,message=string,data=interface{}} "添加解析记录失败"
+// @Router /dns/records/add [post]
+func AddRecord(c *gin.Context) {
+	loginToken := c.GetHeader("LoginToken")
+	adminID, err := admin.GetAdminIDFromToken(loginToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, util.APIResponse{Code: http.StatusUnauthorized, Message: "无权限进行此操作", Data: err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), requestTimeout)
+	defer cancel()
+
+	provider, zone, err := dns.Resolve(ctx, c.PostForm("provider"), c.PostForm("zone"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, util.APIResponse{Code: http.StatusBadRequest, Message: "托管域不存在", Data: err.Error()})
+		return
+	}
+	ttl, _ := strconv.Atoi(c.PostForm("ttl"))
+	record := dns.Record{
+		Type:    strings.ToUpper(strings.TrimSpace(c.PostForm("type"))),
+		Name:    dns.Qualify(c.PostForm("name"), zone.Name),
+		Content: strings.TrimSpace(c.PostForm("content")),
+		TTL:     ttl,
+	}
+	if record.Type == "" || record.Content == "" || ttl < 0 {
+		c.JSON(http.StatusBadRequest, util.APIResponse{Code: http.StatusBadRequest, Message: "请求参数错误", Data: "null"})
+		return
+	}
+
+	created, err := provider.Create(ctx, zone, record)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, util.APIResponse{Code: http.StatusInternalServerError, Message: "添加解析记录失败", Data: err.Error()})
+		return
+	}
+	writeAudit(adminID, sourceAPI, dns.Change{Action: dns.ActionCreate, Provider: provider.Name(), Zone: zone.Name, After: &created})
+	log.InfoLogger.Printf("管理员添加解析记录,管理员id=%d,%s %s %s", adminID, created.Type, created.Name, created.Content)
+
+	c.JSON(http.StatusOK, util.APIResponse{Code: http.StatusOK, Message: "添加解析记录成功", Data: created})
+}
+
+// UpdateRecord 修改解析记录
+// @Summary 修改解析记录
+// @Description 修改解析记录，没有传的字段保持不变
+// @Tags dns
+// @Accept application/x-www-form-urlencoded
+// @Produce application/json
+// @Param LoginToken header string true "认证Token"
+// @Param id path string true "记录ID"
+// @Param zone formData string true "托管域"
+// @Param provider formData string false "DNS服务商，为空时按NS记录判断"
+// @Param type formData string false "记录类型"
+// @Param name formData string false "主机记录或完整域名"
+// @Param content formData string false "记录值"
+// @Param ttl formData int false "TTL（秒）"
+// @Success 200 {object} util.APIResponse{code=int,message=string,data=dns.Record} "修改解析记录成功"
+// @Failure 404 {object} util.APIResponse{code=int,message=string,data=interface{}} "解析记录不存在"
+// @Failure 500 {object} util.APIResponse{code=int,message=string,data=interface{}} "修改解析记录失败"
+// @Router /dns/records/update/{id} [put]
+func UpdateRecord(c *gin.Context) {
+	loginToken := c.GetHeader("LoginToken")
+	adminID, err := admin.GetAdminIDFromToken(loginToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, util.APIResponse{Code: http.StatusUnauthorized, Message: "无权限进行此操作", Data: err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), requestTimeout)
+	defer cancel()
+
+	provider, zone, err := dns.Resolve(ctx, c.PostForm("provider"), c.PostForm("zone"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, util.APIResponse{Code: http.StatusBadRequest, Message: "托管域不存在", Data: err.Error()})
+		return
+	}
+	before, err := findRecord(ctx, provider, zone, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, util.APIResponse{Code: http.StatusNotFound, Message: "解析记录不存在", Data: err.Error()})
+		return
+	}
+
+	after := before
+	if recordType := strings.TrimSpace(c.PostForm("type")); recordType != "" {
+		after.Type = strings.ToUpper(recordType)
+	}
+	if name := c.PostForm("name"); name != "" {
+		after.Name = dns.Qualify(name, zone.Name)
+	}
+	if content := strings.TrimSpace(c.PostForm("content")); content != "" {
+		after.Content = content
+	}
+	if ttl, err := strconv.Atoi(c.PostForm("ttl")); err == nil && ttl > 0 {
+		after.TTL = ttl
+	}
+
+	if err := provider.Update(ctx, zone, after); err != nil {
+		c.JSON(http.StatusInternalServerError, util.APIResponse{Code: http.StatusInternalServerError, Message: "修改解析记录失败", Data: err.Error()})
+		return
+	}
+	writeAudit(adminID, sourceAPI, dns.Change{Action: dns.ActionUpdate, Provider: provider.Name(), Zone: zone.Name, Before: &before, After: &after})
+	log.InfoLogger.Printf("管理员修改解析记录,管理员id=%d,记录id=%s", adminID, after.ID)
+
+	c.JSON(http.StatusOK, util.APIResponse{Code: http.StatusOK, Message: "修改解析记录成功", Data: after})
+}
+
+// DeleteRecord 删除解析记录
+// @Summary 删除解析记录
+// @Description 删除托管域下的一条解析记录
+// @Tags dns
+// @Produce application/json
+// @Param LoginToken header string true "认证Token"
+// @Param id path string true "记录ID"
+// @Param zone query string true "托管域"
+// @Param provider query string false "DNS服务商，为空时按NS记录判断"
+// @Success 200 {object} util.APIResponse{code=int,message=string,data=interface{}} "删除解析记录成功"
+// @Failure 404 {object} util.APIResponse{code=int,message=string,data=interface{}} "解析记录不存在"
+// @Failure 500 {object} util.APIResponse{code=int,message=string,data=interface{}} "删除解析记录失败"
+// @Router /dns/records/delete/{id} [delete]
+func DeleteRecord(c *gin.Context) {
+	loginToken := c.GetHeader("LoginToken")
+	adminID, err := admin.GetAdminIDFromToken(loginToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, util.APIResponse{Code: http.StatusUnauthorized, Message: "无权限进行此操作", Data: err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), requestTimeout)
+	defer cancel()
+
+	provider, zone, err := dns.Resolve(ctx, c.Query("provider"), c.Query("zone"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, util.APIResponse{Code: http.StatusBadRequest, Message: "托管域不存在", Data: err.Error()})
+		return
+	}
+	before, err := findRecord(ctx, provider, zone, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, util.APIResponse{Code: http.StatusNotFound, Message: "解析记录不存在", Data: err.Error()})
+		return
+	}
+
+	if err := provider.Delete(ctx, zone, before.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, util.APIResponse{Code: http.StatusInternalServerError, Message: "删除解析记录失败", Data: err.Error()})
+		return
+	}
+	writeAudit(adminID, sourceAPI, dns.Change{Action: dns.ActionDelete, Provider: provider.Name(), Zone: zone.Name, Before: &before})
+	log.InfoLogger.Printf("管理员删除解析记录,管理员id=%d,%s %s %s", adminID, before.Type, before.Name, before.Content)
+
+	c.JSON(http.StatusOK, util.APIResponse{Code: http.StatusOK, Message: "删除解析记录成功", Data: "null"})
+}
+
+// findRecord 按ID查找托管域下的记录，改动前保存到审计日志
+func findRecord(ctx context.Context, provider dns.Provider, zone dns.Zone, recordID string) (dns.Record, error) {
+	records, err := provider.List(ctx, zone, dns.Record{})
+	if err != nil {
+		return dns.Record{}, err
+	}
+	for _, record := range records {
+		if record.ID == recordID {
+			return record, nil
+		}
+	}
+	return dns.Record{}, fmt.Errorf("记录不存在: %s", recordID)
+}
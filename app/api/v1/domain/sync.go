@@ -0,0 +1,97 @@
+package domain
+
+import (
+	"context"
+	"nav-web-site/app/api/v1/admin"
+	"nav-web-site/app/dns"
+	"nav-web-site/util"
+	"nav-web-site/util/log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// syncTimeout 同步时调用服务商 API 的总超时，托管域和记录多时比单次请求长
+const syncTimeout = 5 * time.Minute
+
+// SyncResult 同步结果，dry_run 时 Changes 是将要执行的改动，否则是已执行的改动
+type SyncResult struct {
+	DryRun  bool         `json:"dry_run"`
+	Changes []dns.Change `json:"changes"`
+	Applied int          `json:"applied"`
+	Error   string       `json:"error,omitempty"`
+}
+
+// SyncRecords 按 YAML 同步解析记录
+// @Summary 按 YAML 同步解析记录
+// @Description 对比 YAML 声明的期望记录和服务商上的现有记录。dry_run 默认开启，只返回差异；关闭后执行改动并逐条写入审计日志
+// @Tags dns
+// @Accept application/x-www-form-urlencoded
+// @Produce application/json
+// @Param LoginToken header string true "认证Token"
+// @Param yaml formData string true "期望记录，格式见 readme.txt"
+// @Param dry_run formData bool false "只查看差异，默认 true"
+// @Success 200 {object} util.APIResponse{code=int,message=string,data=SyncResult} "同步成功"
+// @Failure 400 {object} util.APIResponse{code=int,message=string,data=interface{}} "请求参数错误"
+// @Failure 500 {object} util.APIResponse{code=int,message=string,data=SyncResult} "同步失败"
+// @Router /dns/sync [post]
+func SyncRecords(c *gin.Context) {
+	loginToken := c.GetHeader("LoginToken")
+	adminID, err := admin.GetAdminIDFromToken(loginToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, util.APIResponse{Code: http.StatusUnauthorized, Message: "无权限进行此操作", Data: err.Error()})
+		return
+	}
+
+	state, err := dns.ParseDesiredState([]byte(c.PostForm("yaml")))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, util.APIResponse{Code: http.StatusBadRequest, Message: "请求参数错误", Data: err.Error()})
+		return
+	}
+	dryRun := c.DefaultPostForm("dry_run", "true") != "false" && c.PostForm("dry_run") != "0"
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), syncTimeout)
+	defer cancel()
+
+	changes, err := dns.Plan(ctx, state)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, util.APIResponse{Code: http.StatusBadRequest, Message: "获取现有记录失败", Data: err.Error()})
+		return
+	}
+	if changes == nil {
+		changes = []dns.Change{}
+	}
+	if dryRun {
+		c.JSON(http.StatusOK, util.APIResponse{Code: http.StatusOK, Message: "同步预览", Data: SyncResult{DryRun: true, Changes: changes}})
+		return
+	}
+
+	// 逐条执行，遇到错误停止，已执行的改动照常记录审计日志
+	result := SyncResult{Changes: []dns.Change{}}
+	for i := range changes {
+		change := &changes[i]
+		if err := change.Apply(ctx); err != nil {
+			result.Error = change.Action + " " + recordName(change) + ": " + err.Error()
+			break
+		}
+		writeAudit(adminID, sourceSync, *change)
+		result.Changes = append(result.Changes, *change)
+		result.Applied++
+	}
+	log.InfoLogger.Printf("管理员同步解析记录,管理员id=%d,执行%d/%d条改动", adminID, result.Applied, len(changes))
+
+	if result.Error != "" {
+		c.JSON(http.StatusInternalServerError, util.APIResponse{Code: http.StatusInternalServerError, Message: "同步失败", Data: result})
+		return
+	}
+	c.JSON(http.StatusOK, util.APIResponse{Code: http.StatusOK, Message: "同步成功", Data: result})
+}
+
+// recordName 改动涉及的域名
+func recordName(change *dns.Change) string {
+	if change.After != nil {
+		return change.After.Name
+	}
+	return change.Before.Name
+}
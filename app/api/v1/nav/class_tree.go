@@ -0,0 +1,156 @@
+package nav
+
+import (
+	"fmt"
+	"nav-web-site/mydb"
+	"nav-web-site/util"
+	"nav-web-site/util/classtree"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ClassNode 导航分类树的一个节点
+type ClassNode struct {
+	mydb.StructNavClass
+	Children []*ClassNode `json:"Children"`
+}
+
+// GetClassTree 获取导航分类树
+// @Summary 获取导航分类树
+// @Description 按父级分类组织成树，同级按 Sort 从小到大排列；父级不存在的分类放在顶级
+// @Tags nav
+// @Produce application/json
+// @Param only_show query bool false "只返回启用且显示的分类，隐藏分类的子分类也不返回"
+// @Success 200 {object} util.APIResponse{code=int,message=string,data=[]ClassNode}
+// @Failure 500 {object} util.APIResponse{code=int,message=string,data=string}
+// @Router /nav/getClassTree [get]
+func GetClassTree(c *gin.Context) {
+	onlyShow, _ := strconv.ParseBool(c.Query("only_show"))
+	classes, err := allClasses()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, util.APIResponse{Code: http.StatusInternalServerError, Message: "获取导航分类树失败", Data: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, util.APIResponse{Code: http.StatusOK, Message: "获取导航分类树成功", Data: ClassTree(classes, onlyShow)})
+}
+
+// GetClassPath 获取导航分类的路径（面包屑）
+// @Summary 获取导航分类的路径
+// @Description 从顶级分类到该分类依次排列，用于面包屑导航
+// @Tags nav
+// @Produce application/json
+// @Param id path int true "导航分类ID"
+// @Success 200 {object} util.APIResponse{code=int,message=string,data=[]mydb.StructNavClass}
+// @Failure 404 {object} util.APIResponse{code=int,message=string,data=string}
+// @Router /nav/getClassPath/{id} [get]
+func GetClassPath(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	classes, err := allClasses()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, util.APIResponse{Code: http.StatusInternalServerError, Message: "获取导航分类路径失败", Data: err.Error()})
+		return
+	}
+	ids, err := classtree.Path(classItems(classes), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, util.APIResponse{Code: http.StatusNotFound, Message: "获取导航分类路径失败", Data: err.Error()})
+		return
+	}
+	byID := make(map[int]mydb.StructNavClass, len(classes))
+	for _, class := range classes {
+		byID[class.ID] = class
+	}
+	path := make([]mydb.StructNavClass, 0, len(ids))
+	for _, classID := range ids {
+		path = append(path, byID[classID])
+	}
+	c.JSON(http.StatusOK, util.APIResponse{Code: http.StatusOK, Message: "获取导航分类路径成功", Data: path})
+}
+
+// ClassTree 把分类组织成树，onlyShow 时去掉禁用或隐藏的分类和它们的子分类
+func ClassTree(classes []mydb.StructNavClass, onlyShow bool) []*ClassNode {
+	byID := make(map[int]mydb.StructNavClass, len(classes))
+	for _, class := range classes {
+		byID[class.ID] = class
+	}
+	children := classtree.Children(classItems(classes))
+
+	var build func(parent int) []*ClassNode
+	build = func(parent int) []*ClassNode {
+		nodes := []*ClassNode{}
+		for _, id := range children[parent] {
+			class := byID[id]
+			if onlyShow && (class.Status != 1 || !class.Is_show) {
+				continue
+			}
+			nodes = append(nodes, &ClassNode{StructNavClass: class, Children: build(id)})
+		}
+		return nodes
+	}
+	return build(0)
+}
+
+// allClasses 查询全部导航分类，没有分类时返回空列表
+func allClasses() ([]mydb.StructNavClass, error) {
+	classes, code, err := mydb.Tables.NavClass.Select(mydb.QueryParams{})
+	if err != nil && code != 200 {
+		return nil, err
+	}
+	return classes, nil
+}
+
+// checkClassParent 检查分类 id（新分类为0）的父级能否设为 parentID
+func checkClassParent(id int, parentID int) error {
+	classes, err := allClasses()
+	if err != nil {
+		return err
+	}
+	return classtree.CheckParent(classItems(classes), id, parentID)
+}
+
+// deleteClassTree 删除分类。cascade 为 false 时分类下有子分类或导航就拒绝删除；
+// 为 true 时在一个事务里连同所有子孙分类、其中的导航以及导航的健康检查结果和点击统计一起删除。返回删除的分类数和导航数
+func deleteClassTree(id int, cascade bool) (int, int, error) {
+	classes, err := allClasses()
+	if err != nil {
+		return 0, 0, err
+	}
+	descendants := classtree.Descendants(classItems(classes), id)
+	ids := append([]int{id}, descendants...)
+	idList := joinIDs(ids)
+
+	navCount, err := mydb.GenericCount(mydb.Tables.Nav.GetTableName(), "class_id IN ("+idList+")", "", "")
+	if err != nil {
+		return 0, 0, err
+	}
+	if !cascade && (len(descendants) > 0 || navCount > 0) {
+		return 0, 0, fmt.Errorf("%w: %d 个子分类，%d 个导航", classtree.ErrNotEmpty, len(descendants), navCount)
+	}
+
+	deleted, navIDs, err := mydb.Tables.NavClass.DeleteTree(ids)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, navID := range navIDs {
+		forgetRedirect(navID)
+	}
+	return deleted, len(navIDs), nil
+}
+
+func classItems(classes []mydb.StructNavClass) []classtree.Item {
+	items := make([]classtree.Item, 0, len(classes))
+	for _, class := range classes {
+		items = append(items, classtree.Item{ID: class.ID, Parent_id: class.Parent_id, Sort: class.Sort})
+	}
+	return items
+}
+
+func joinIDs(ids []int) string {
+	parts := make([]string, 0, len(ids))
+	for _, id := range ids {
+		parts = append(parts, strconv.Itoa(id))
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,212 @@
+package nav
+
+import (
+	"fmt"
+	"nav-web-site/app/navclick"
+	"nav-web-site/mydb"
+	"nav-web-site/util"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	redirectCacheKeyPrefix = "nav_go_" // 导航id -> 跳转用的导航记录的缓存key前缀
+	redirectCacheDuration  = time.Minute
+	defaultTopDays         = 7
+	maxTopDays             = 366
+	defaultTopLimit        = 10
+	maxTopLimit            = 100
+)
+
+// TopLink 热门链接的一项
+type TopLink struct {
+	ID     int    `json:"ID"`
+	Title  string `json:"Title"`
+	Url    string `json:"Url"`
+	Icon   string `json:"Icon"`
+	Clicks int64  `json:"Clicks"` // 时间范围内的点击数
+}
+
+// ClassTopLinks 一个分类的热门链接
+type ClassTopLinks struct {
+	Class_id   int       `json:"Class_id"`
+	Class_name string    `json:"Class_name"`
+	Links      []TopLink `json:"Links"`
+}
+
+// Redirect 跳转到导航链接并记一次点击
+// @Summary 跳转到导航链接
+// @Description 302 跳转到导航的链接地址，点击数先暂存再批量写回，爬虫的请求只跳转不计数
+// @Tags nav
+// @Param id path int true "导航ID"
+// @Success 302
+// @Failure 404 {object} util.APIResponse{code=int,message=string,data=string}
+// @Router /go/{id} [get]
+func Redirect(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusNotFound, util.APIResponse{Code: http.StatusNotFound, Message: "导航不存在", Data: "null"})
+		return
+	}
+
+	var nav mydb.StructNav
+	cacheKey := redirectCacheKeyPrefix + strconv.Itoa(id)
+	if cached, found := util.C.Get(cacheKey); found {
+		nav, _ = cached.(mydb.StructNav)
+	} else {
+		nav, err = mydb.Tables.Nav.Find(mydb.QueryParams{Condition: "id=" + strconv.Itoa(id)})
+		if err != nil {
+			nav = mydb.StructNav{}
+		}
+		// 不存在的导航也缓存，避免被刷接口时一直查库
+		util.C.Set(cacheKey, nav, redirectCacheDuration)
+	}
+	if nav.ID == 0 || nav.Status != 1 || nav.Url == "" {
+		c.JSON(http.StatusNotFound, util.APIResponse{Code: http.StatusNotFound, Message: "导航不存在", Data: "null"})
+		return
+	}
+
+	if !navclick.IsBot(c.Request.UserAgent()) {
+		navclick.Record(c.Request.Context(), nav.ID)
+	}
+	// 不让浏览器缓存跳转，否则重复点击不会再经过这里
+	c.Header("Cache-Control", "no-store")
+	c.Redirect(http.StatusFound, nav.Url)
+}
+
+// forgetRedirect 导航修改或删除后清掉跳转用的缓存
+func forgetRedirect(id int) {
+	util.C.Delete(redirectCacheKeyPrefix + strconv.Itoa(id))
+}
+
+// GetTopLinks 获取各分类的热门链接
+// @Summary 获取各分类的热门链接
+// @Description 按时间范围内的点击数排列各分类显示中的导航，默认最近7天，每个分类10个
+// @Tags nav
+// @Produce application/json
+// @Param class_id query int false "只看这个分类"
+// @Param start query string false "开始日期，如 2024-01-01"
+// @Param end query string false "结束日期，默认今天"
+// @Param limit query int false "每个分类的数量，默认10，最多100"
+// @Success 200 {object} util.APIResponse{code=int,message=string,data=[]ClassTopLinks}
+// @Failure 500 {object} util.APIResponse{code=int,message=string,data=string}
+// @Router /nav/stats/top [get]
+func GetTopLinks(c *gin.Context) {
+	start, end, err := dateRange(c.Query("start"), c.Query("end"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, util.APIResponse{Code: http.StatusBadRequest, Message: "日期格式错误", Data: err.Error()})
+		return
+	}
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil || limit <= 0 {
+		limit = defaultTopLimit
+	}
+	limit = min(limit, maxTopLimit)
+	classID, _ := strconv.Atoi(c.Query("class_id"))
+
+	result, err := TopLinks(start, end, classID, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, util.APIResponse{Code: http.StatusInternalServerError, Message: "获取热门链接失败", Data: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, util.APIResponse{Code: http.StatusOK, Message: "获取热门链接成功", Data: result})
+}
+
+// TopLinks 按 [start, end] 之间的点击数取各分类显示中的导航，每个分类最多 limit 个，classID 大于0时只取这个分类
+func TopLinks(start time.Time, end time.Time, classID int, limit int) ([]ClassTopLinks, error) {
+	totals, err := mydb.Tables.NavClickDaily.Totals(dateNumber(start), dateNumber(end))
+	if err != nil {
+		return nil, err
+	}
+	result := []ClassTopLinks{}
+	if len(totals) == 0 {
+		return result, nil
+	}
+
+	ids := make([]string, 0, len(totals))
+	for _, total := range totals {
+		ids = append(ids, strconv.Itoa(total.Nav_id))
+	}
+	condition := "status=1 AND is_show=1 AND id IN (" + strings.Join(ids, ",") + ")"
+	if classID > 0 {
+		condition += fmt.Sprintf(" AND class_id=%d", classID)
+	}
+	navs, code, err := mydb.Tables.Nav.Select(mydb.QueryParams{Condition: condition})
+	if err != nil && code != 200 {
+		return nil, err
+	}
+	navMap := make(map[int]mydb.StructNav, len(navs))
+	for _, nav := range navs {
+		navMap[nav.ID] = nav
+	}
+	classes, code, err := mydb.Tables.NavClass.Select(mydb.QueryParams{})
+	if err != nil && code != 200 {
+		return nil, err
+	}
+	classNames := make(map[int]string, len(classes))
+	for _, class := range classes {
+		classNames[class.ID] = class.Name
+	}
+
+	// totals 已按点击数倒序，依次放进各自的分类
+	byClass := make(map[int]*ClassTopLinks)
+	for _, total := range totals {
+		nav, ok := navMap[total.Nav_id]
+		if !ok {
+			continue
+		}
+		group, ok := byClass[nav.Class_id]
+		if !ok {
+			group = &ClassTopLinks{Class_id: nav.Class_id, Class_name: classNames[nav.Class_id], Links: []TopLink{}}
+			byClass[nav.Class_id] = group
+		}
+		if len(group.Links) < limit {
+			group.Links = append(group.Links, TopLink{ID: nav.ID, Title: nav.Title, Url: nav.Url, Icon: nav.Icon, Clicks: total.Clicks})
+		}
+	}
+	for _, group := range byClass {
+		result = append(result, *group)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Class_id < result[j].Class_id
+	})
+	return result, nil
+}
+
+// dateRange 解析开始和结束日期，结束日期默认今天，开始日期默认往前 defaultTopDays 天，范围不超过 maxTopDays 天
+func dateRange(startValue string, endValue string) (time.Time, time.Time, error) {
+	end := time.Now()
+	if endValue != "" {
+		parsed, err := time.ParseInLocation(time.DateOnly, endValue, time.Local)
+		if err != nil {
+			return end, end, err
+		}
+		end = parsed
+	}
+	start := end.AddDate(0, 0, 1-defaultTopDays)
+	if startValue != "" {
+		parsed, err := time.ParseInLocation(time.DateOnly, startValue, time.Local)
+		if err != nil {
+			return start, end, err
+		}
+		start = parsed
+	}
+	if start.After(end) {
+		start, end = end, start
+	}
+	if end.Sub(start) > maxTopDays*24*time.Hour {
+		start = end.AddDate(0, 0, -maxTopDays)
+	}
+	return start, end, nil
+}
+
+// dateNumber 把日期转成 nav_click_daily 表里的格式，如 20240131
+func dateNumber(t time.Time) int {
+	n, _ := strconv.Atoi(t.Format("20060102"))
+	return n
+}
@@ -0,0 +1,79 @@
+package nav
+
+import (
+	"fmt"
+	"nav-web-site/app/api/v1/admin"
+	"nav-web-site/mydb"
+	"nav-web-site/util"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BrokenLink 死链报告的一项
+type BrokenLink struct {
+	mydb.StructNavHealth
+	Title    string `json:"Title"`    // 导航标题
+	Class_id int    `json:"Class_id"` // 导航分类
+	Is_show  bool   `json:"Is_show"`  // 导航当前是否显示
+}
+
+// GetBrokenLinks 获取死链报告
+// @Summary 获取死链报告
+// @Description 列出最近一次检查失败的导航链接，按连续失败次数倒序
+// @Tags nav
+// @Produce application/json
+// @Param LoginToken header string true "认证Token"
+// @Param min_fails query int false "最少连续失败次数，默认1"
+// @Success 200 {object} util.APIResponse{code=int,message=string,data=[]BrokenLink} "获取死链报告成功"
+// @Failure 500 {object} util.APIResponse{code=int,message=string,data=string} "获取死链报告失败"
+// @Router /nav/health/broken [get]
+func GetBrokenLinks(c *gin.Context) {
+	loginToken := c.GetHeader("LoginToken")
+	if _, err := admin.GetAdminIDFromToken(loginToken); err != nil {
+		c.JSON(http.StatusUnauthorized, util.APIResponse{Code: http.StatusUnauthorized, Message: "无权限进行此操作", Data: err.Error()})
+		return
+	}
+
+	minFails, err := strconv.Atoi(c.Query("min_fails"))
+	if err != nil || minFails <= 0 {
+		minFails = 1
+	}
+	healths, code, err := mydb.Tables.NavHealth.Select(mydb.QueryParams{
+		Condition: fmt.Sprintf("is_ok=0 AND fail_count>=%d", minFails),
+		OrderBy:   "fail_count DESC, id ASC",
+	})
+	if err != nil && code != 200 {
+		c.JSON(http.StatusInternalServerError, util.APIResponse{Code: http.StatusInternalServerError, Message: "获取死链报告失败", Data: err.Error()})
+		return
+	}
+
+	links := []BrokenLink{}
+	if len(healths) > 0 {
+		ids := make([]string, 0, len(healths))
+		for _, health := range healths {
+			ids = append(ids, strconv.Itoa(health.Nav_id))
+		}
+		navs, code, err := mydb.Tables.Nav.Select(mydb.QueryParams{Condition: "id IN (" + strings.Join(ids, ",") + ")"})
+		if err != nil && code != 200 {
+			c.JSON(http.StatusInternalServerError, util.APIResponse{Code: http.StatusInternalServerError, Message: "获取死链报告失败", Data: err.Error()})
+			return
+		}
+		navMap := make(map[int]mydb.StructNav, len(navs))
+		for _, nav := range navs {
+			navMap[nav.ID] = nav
+		}
+		for _, health := range healths {
+			nav, ok := navMap[health.Nav_id]
+			if !ok {
+				// 导航已删除
+				continue
+			}
+			links = append(links, BrokenLink{StructNavHealth: health, Title: nav.Title, Class_id: nav.Class_id, Is_show: nav.Is_show})
+		}
+	}
+
+	c.JSON(http.StatusOK, util.APIResponse{Code: http.StatusOK, Message: "获取死链报告成功", Data: links})
+}
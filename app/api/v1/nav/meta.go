@@ -0,0 +1,74 @@
+package nav
+
+import (
+	"errors"
+	"nav-web-site/app/api/v1/admin"
+	"nav-web-site/app/navmeta"
+	"nav-web-site/mydb"
+	"nav-web-site/util"
+	"nav-web-site/util/log"
+	"nav-web-site/util/safehttp"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FetchMetadata 抓取链接的网页信息
+// @Summary 抓取链接的网页信息
+// @Description 抓取网页的标题、描述、关键词、OpenGraph 信息和图标，图标转存到上传目录，返回建议填写的导航字段
+// @Tags nav
+// @Accept application/x-www-form-urlencoded
+// @Produce application/json
+// @Param LoginToken header string true "认证Token"
+// @Param url formData string true "链接地址"
+// @Success 200 {object} util.APIResponse{code=int,message=string,data=navmeta.Metadata}
+// @Failure 400 {object} util.APIResponse{code=int,message=string,data=string} "链接为空，或链接指向内网或本机地址"
+// @Failure 500 {object} util.APIResponse{code=int,message=string,data=string}
+// @Router /nav/fetchMeta [post]
+func FetchMetadata(c *gin.Context) {
+	adminID, err := admin.GetAdminIDFromToken(c.GetHeader("LoginToken"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, util.APIResponse{Code: http.StatusUnauthorized, Message: "未授权操作", Data: err.Error()})
+		return
+	}
+	pageURL := c.PostForm("url")
+	if pageURL == "" {
+		c.JSON(http.StatusBadRequest, util.APIResponse{Code: http.StatusBadRequest, Message: "链接地址不能为空", Data: "null"})
+		return
+	}
+
+	meta, err := navmeta.Fetch(c.Request.Context(), pageURL, adminID)
+	if errors.Is(err, safehttp.ErrBlockedAddress) {
+		c.JSON(http.StatusBadRequest, util.APIResponse{Code: http.StatusBadRequest, Message: "不能抓取内网或本机地址", Data: err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, util.APIResponse{Code: http.StatusInternalServerError, Message: "抓取网页信息失败", Data: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, util.APIResponse{Code: http.StatusOK, Message: "抓取网页信息成功", Data: meta})
+}
+
+// autoFill 用抓取到的网页信息填写导航里没有填的标题、描述、关键词和图标，抓取失败时保持原样
+func autoFill(c *gin.Context, data *mydb.StructNav) {
+	meta, err := navmeta.Fetch(c.Request.Context(), data.Url, data.Admin_id)
+	if err != nil {
+		log.ErrorLogger.Printf("自动填写导航信息失败,url=%s: %v", data.Url, err)
+		return
+	}
+	if data.Title == "" {
+		data.Title = meta.Title
+		if meta.SiteName != "" {
+			data.Title = meta.SiteName
+		}
+	}
+	if data.Description == "" {
+		data.Description = meta.Description
+	}
+	if data.Keywords == "" {
+		data.Keywords = meta.Keywords
+	}
+	if data.Icon == "" {
+		data.Icon = meta.Icon
+	}
+}
@@ -2,6 +2,7 @@ package nav
 
 import (
 	"nav-web-site/app/api/v1/admin"
+	"nav-web-site/app/sitecache"
 	"nav-web-site/mydb"
 	"nav-web-site/util"
 	"nav-web-site/util/log"
@@ -29,6 +30,7 @@ import (
 // @Param is_show formData bool false "是否显示"
 // @Param is_recommend formData bool false "是否推荐"
 // @Param status formData int false "状态:0=禁用,1=启用"
+// @Param auto_fill formData bool false "抓取网页信息填写没有填的标题、描述、关键词和图标"
 // @Success 200 {object} util.APIResponse{code=int,message=string}
 // @Failure 500 {object} util.APIResponse{code=int,message=string,data=string}
 // @Router /nav/addData [post]
@@ -57,6 +59,9 @@ func AddData(c *gin.Context) {
 	data.Status, _ = strconv.Atoi(c.PostForm("status"))
 	data.Create_time = util.GetTimestamp(10)
 	data.Update_time = util.GetTimestamp(10)
+	if autoFillEnabled, _ := strconv.ParseBool(c.PostForm("auto_fill")); autoFillEnabled && data.Url != "" {
+		autoFill(c, &data)
+	}
 
 	id, rowsAffected, err := data.Insert([]mydb.StructNav{data})
 	if err != nil {
@@ -64,6 +69,7 @@ func AddData(c *gin.Context) {
 		return
 	}
 	log.InfoLogger.Printf("导航信息添加成功,id=%d,添加记录数:%d", id, rowsAffected)
+	sitecache.ForgetHome()
 
 	c.JSON(http.StatusOK, util.APIResponse{Code: http.StatusOK, Message: "导航信息添加成功"})
 }
@@ -119,7 +125,9 @@ func UpdateData(c *gin.Context) {
 	if c.PostForm("subtitle") != "" {
 		data.Subtitle = c.PostForm("subtitle")
 	}
+	urlChanged := false
 	if c.PostForm("url") != "" {
+		urlChanged = c.PostForm("url") != data.Url
 		data.Url = c.PostForm("url")
 	}
 	if c.PostForm("description") != "" {
@@ -150,6 +158,14 @@ func UpdateData(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, util.APIResponse{Code: http.StatusInternalServerError, Message: "修改导航信息失败", Data: err.Error()})
 		return
 	}
+	forgetRedirect(data.ID)
+	sitecache.ForgetHome()
+	// 换了地址的导航不再沿用旧地址的失败次数；管理员设置的显示状态优先于链接检查的自动隐藏和恢复
+	if urlChanged || c.PostForm("is_show") != "" {
+		if err := mydb.Tables.NavHealth.Reset(data.ID); err != nil {
+			log.ErrorLogger.Printf("重置导航检查状态失败,id=%d: %v", data.ID, err)
+		}
+	}
 
 	c.JSON(http.StatusOK, util.APIResponse{Code: http.StatusOK, Message: "导航信息修改成功"})
 }
@@ -176,6 +192,8 @@ func DeleteData(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, util.APIResponse{Code: http.StatusInternalServerError, Message: "删除导航信息失败", Data: err.Error()})
 		return
 	}
+	forgetRedirect(data.ID)
+	sitecache.ForgetHome()
 
 	c.JSON(http.StatusOK, util.APIResponse{Code: http.StatusOK, Message: "导航信息删除成功"})
 }
@@ -1,9 +1,12 @@
 package nav
 
 import (
+	"errors"
 	"nav-web-site/app/api/v1/admin"
+	"nav-web-site/app/sitecache"
 	"nav-web-site/mydb"
 	"nav-web-site/util"
+	"nav-web-site/util/classtree"
 	"nav-web-site/util/log"
 	"net/http"
 	"strconv"
@@ -53,6 +56,10 @@ func AddClass(c *gin.Context) {
 	class.Description = c.PostForm("description")
 	class.Create_time = util.GetTimestamp(10)
 	class.Update_time = util.GetTimestamp(10)
+	if err := checkClassParent(0, class.Parent_id); err != nil {
+		c.JSON(http.StatusBadRequest, util.APIResponse{Code: http.StatusBadRequest, Message: "父级分类无效", Data: err.Error()})
+		return
+	}
 
 	id, rowsAffected, err := class.Insert([]mydb.StructNavClass{class})
 	if err != nil {
@@ -60,6 +67,7 @@ func AddClass(c *gin.Context) {
 		return
 	}
 	log.InfoLogger.Printf("导航分类添加成功,id=%d,添加记录数:%d", id, rowsAffected)
+	sitecache.ForgetHome()
 
 	c.JSON(http.StatusOK, util.APIResponse{Code: http.StatusOK, Message: "导航分类添加成功"})
 }
@@ -109,6 +117,10 @@ func UpdateClass(c *gin.Context) {
 	}
 	if parentID := c.PostForm("parent_id"); parentID != "" {
 		class.Parent_id, _ = strconv.Atoi(parentID)
+		if err := checkClassParent(class.ID, class.Parent_id); err != nil {
+			c.JSON(http.StatusBadRequest, util.APIResponse{Code: http.StatusBadRequest, Message: "父级分类无效", Data: err.Error()})
+			return
+		}
 	}
 	if sort := c.PostForm("sort"); sort != "" {
 		class.Sort, _ = strconv.Atoi(sort)
@@ -138,20 +150,30 @@ func UpdateClass(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, util.APIResponse{Code: http.StatusInternalServerError, Message: "修改导航分类失败", Data: err.Error()})
 		return
 	}
+	sitecache.ForgetHome()
 
 	c.JSON(http.StatusOK, util.APIResponse{Code: http.StatusOK, Message: "导航分类修改成功"})
 }
 
 // DeleteClass 删除导航分类
 // @Summary 删除导航分类
-// @Description 根据导航分类ID删除导航分类
+// @Description 根据导航分类ID删除导航分类，分类下有子分类或导航时拒绝删除，cascade=true 时连同子分类和其中的导航一起删除
 // @Tags nav
 // @Produce application/json
+// @Param LoginToken header string true "认证Token"
 // @Param id path string true "导航分类ID"
+// @Param cascade query bool false "是否级联删除子分类和导航"
 // @Success 200 {object} util.APIResponse{code=int,message=string}
+// @Failure 409 {object} util.APIResponse{code=int,message=string,data=string}
 // @Failure 500 {object} util.APIResponse{code=int,message=string,data=string}
 // @Router /nav/deleteClass/{id} [delete]
 func DeleteClass(c *gin.Context) {
+	loginToken := c.GetHeader("LoginToken")
+	if _, err := admin.GetAdminIDFromToken(loginToken); err != nil {
+		c.JSON(http.StatusUnauthorized, util.APIResponse{Code: http.StatusUnauthorized, Message: "无权限删除导航分类", Data: err.Error()})
+		return
+	}
+
 	classID := c.Param("id")
 	class, err := mydb.Tables.NavClass.Find(mydb.QueryParams{Condition: "id=" + classID})
 	if err != nil {
@@ -159,11 +181,18 @@ func DeleteClass(c *gin.Context) {
 		return
 	}
 
-	_, _, err = class.Delete("id=" + strconv.Itoa(class.ID))
+	cascade, _ := strconv.ParseBool(c.Query("cascade"))
+	classCount, navCount, err := deleteClassTree(class.ID, cascade)
+	if errors.Is(err, classtree.ErrNotEmpty) {
+		c.JSON(http.StatusConflict, util.APIResponse{Code: http.StatusConflict, Message: "分类下还有子分类或导航", Data: err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, util.APIResponse{Code: http.StatusInternalServerError, Message: "删除导航分类失败", Data: err.Error()})
 		return
 	}
+	log.InfoLogger.Printf("导航分类删除成功,id=%d,删除分类数:%d,删除导航数:%d", class.ID, classCount, navCount)
+	sitecache.ForgetHome()
 
 	c.JSON(http.StatusOK, util.APIResponse{Code: http.StatusOK, Message: "导航分类删除成功"})
 }
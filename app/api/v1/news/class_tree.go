@@ -0,0 +1,148 @@
+package news
+
+import (
+	"fmt"
+	"nav-web-site/app/sitecache"
+	"nav-web-site/mydb"
+	"nav-web-site/util"
+	"nav-web-site/util/classtree"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ClassNode 新闻分类树的一个节点
+type ClassNode struct {
+	mydb.StructNewsClass
+	Children []*ClassNode `json:"Children"`
+}
+
+// GetClassTree 获取新闻分类树
+// @Summary 获取新闻分类树
+// @Description 按父级分类组织成树，同级按 Sort 从小到大排列；父级不存在的分类放在顶级
+// @Tags news
+// @Produce application/json
+// @Param only_show query bool false "只返回启用且显示的分类，隐藏分类的子分类也不返回"
+// @Success 200 {object} util.APIResponse{code=int,message=string,data=[]ClassNode} "获取新闻分类树成功"
+// @Failure 500 {object} util.APIResponse{code=int,message=string,data=interface{}} "获取新闻分类树失败"
+// @Router /news/getClassTree [get]
+func GetClassTree(c *gin.Context) {
+	onlyShow, _ := strconv.ParseBool(c.Query("only_show"))
+	classes, err := allClasses()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, util.APIResponse{Code: http.StatusInternalServerError, Message: "获取新闻分类树失败", Data: err.Error()})
+		return
+	}
+
+	byID := make(map[int]mydb.StructNewsClass, len(classes))
+	for _, class := range classes {
+		byID[class.ID] = class
+	}
+	children := classtree.Children(classItems(classes))
+	var build func(parent int) []*ClassNode
+	build = func(parent int) []*ClassNode {
+		nodes := []*ClassNode{}
+		for _, id := range children[parent] {
+			class := byID[id]
+			if onlyShow && (class.Status != 1 || !class.Is_show) {
+				continue
+			}
+			nodes = append(nodes, &ClassNode{StructNewsClass: class, Children: build(id)})
+		}
+		return nodes
+	}
+
+	c.JSON(http.StatusOK, util.APIResponse{Code: http.StatusOK, Message: "获取新闻分类树成功", Data: build(0)})
+}
+
+// GetClassPath 获取新闻分类的路径（面包屑）
+// @Summary 获取新闻分类的路径
+// @Description 从顶级分类到该分类依次排列，用于面包屑导航
+// @Tags news
+// @Produce application/json
+// @Param id path int true "新闻分类ID"
+// @Success 200 {object} util.APIResponse{code=int,message=string,data=[]mydb.StructNewsClass} "获取新闻分类路径成功"
+// @Failure 404 {object} util.APIResponse{code=int,message=string,data=interface{}} "获取新闻分类路径失败"
+// @Router /news/getClassPath/{id} [get]
+func GetClassPath(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	classes, err := allClasses()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, util.APIResponse{Code: http.StatusInternalServerError, Message: "获取新闻分类路径失败", Data: err.Error()})
+		return
+	}
+	ids, err := classtree.Path(classItems(classes), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, util.APIResponse{Code: http.StatusNotFound, Message: "获取新闻分类路径失败", Data: err.Error()})
+		return
+	}
+	byID := make(map[int]mydb.StructNewsClass, len(classes))
+	for _, class := range classes {
+		byID[class.ID] = class
+	}
+	path := make([]mydb.StructNewsClass, 0, len(ids))
+	for _, classID := range ids {
+		path = append(path, byID[classID])
+	}
+	c.JSON(http.StatusOK, util.APIResponse{Code: http.StatusOK, Message: "获取新闻分类路径成功", Data: path})
+}
+
+// allClasses 查询全部新闻分类，没有分类时返回空列表
+func allClasses() ([]mydb.StructNewsClass, error) {
+	classes, code, err := mydb.Tables.NewsClass.Select(mydb.QueryParams{})
+	if err != nil && code != 200 {
+		return nil, err
+	}
+	return classes, nil
+}
+
+// checkClassParent 检查分类 id（新分类为0）的父级能否设为 parentID
+func checkClassParent(id int, parentID int) error {
+	classes, err := allClasses()
+	if err != nil {
+		return err
+	}
+	return classtree.CheckParent(classItems(classes), id, parentID)
+}
+
+// deleteClassTree 删除分类。cascade 为 false 时分类下有子分类或新闻就返回 classtree.ErrNotEmpty；
+// 为 true 时在一个事务里连同所有子孙分类和其中的新闻（含正文）一起删除，并整理新闻所在的故事聚合。返回删除的分类数和新闻数
+func deleteClassTree(id int, cascade bool) (int, int, error) {
+	classes, err := allClasses()
+	if err != nil {
+		return 0, 0, err
+	}
+	descendants := classtree.Descendants(classItems(classes), id)
+	ids := append([]int{id}, descendants...)
+	idList := make([]string, 0, len(ids))
+	for _, classID := range ids {
+		idList = append(idList, strconv.Itoa(classID))
+	}
+	classCondition := "class_id IN (" + strings.Join(idList, ",") + ")"
+
+	newsCount, err := mydb.GenericCount(mydb.Tables.News.GetTableName(), classCondition, "", "")
+	if err != nil {
+		return 0, 0, err
+	}
+	if !cascade && (len(descendants) > 0 || newsCount > 0) {
+		return 0, 0, fmt.Errorf("%w: %d 个子分类，%d 条新闻", classtree.ErrNotEmpty, len(descendants), newsCount)
+	}
+
+	deleted, newsCount, err := mydb.Tables.NewsClass.DeleteTree(ids)
+	if err != nil {
+		return 0, 0, err
+	}
+	// 分类的订阅地址和上级分类的订阅内容都变了，不论有没有删除新闻都要清缓存
+	sitecache.ForgetSyndication()
+	return deleted, newsCount, nil
+}
+
+func classItems(classes []mydb.StructNewsClass) []classtree.Item {
+	items := make([]classtree.Item, 0, len(classes))
+	for _, class := range classes {
+		items = append(items, classtree.Item{ID: class.ID, Parent_id: class.Parent_id, Sort: class.Sort})
+	}
+	return items
+}
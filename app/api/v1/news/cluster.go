@@ -0,0 +1,204 @@
+package news
+
+import (
+	"fmt"
+	"nav-web-site/app/api/v1/admin"
+	"nav-web-site/config"
+	"nav-web-site/mydb"
+	"nav-web-site/util"
+	"nav-web-site/util/log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ClusterNews 故事聚合里的一条新闻
+type ClusterNews struct {
+	ID          int    `json:"id"`
+	Title       string `json:"title"`
+	Source      string `json:"source"`
+	Url         string `json:"url"`
+	Create_time int64  `json:"create_time"`
+}
+
+// ClusterItem 故事聚合及其包含的新闻
+type ClusterItem struct {
+	mydb.StructNewsCluster
+	NewsCount int           `json:"news_count"`
+	News      []ClusterNews `json:"news"`
+}
+
+// ClusterListData 故事聚合列表
+type ClusterListData struct {
+	Total    int           `json:"total"`
+	Page     int           `json:"page"`
+	PageSize int           `json:"page_size"`
+	List     []ClusterItem `json:"list"`
+}
+
+// GetClusterList 获取故事聚合列表
+// @Summary 获取故事聚合列表
+// @Description 获取近似重复检测归并出的故事聚合，每个聚合带上其中的新闻
+// @Tags news
+// @Produce application/json
+// @Param LoginToken header string true "认证Token"
+// @Param page query int false "页码"
+// @Param page_size query int false "每页数量"
+// @Success 200 {object} util.APIResponse{code=int,message=string,data=ClusterListData} "获取故事聚合列表成功"
+// @Failure 500 {object} util.APIResponse{code=int,message=string,data=interface{}} "获取故事聚合列表失败"
+// @Router /news/cluster/list [get]
+func GetClusterList(c *gin.Context) {
+	loginToken := c.GetHeader("LoginToken")
+	if _, err := admin.GetAdminIDFromToken(loginToken); err != nil {
+		c.JSON(http.StatusUnauthorized, util.APIResponse{Code: http.StatusUnauthorized, Message: "无权限进行此操作", Data: err.Error()})
+		return
+	}
+
+	page, err := strconv.Atoi(c.Query("page"))
+	if err != nil || page <= 0 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(c.Query("page_size"))
+	if err != nil || pageSize <= 0 {
+		pageSize = 20
+	}
+
+	total, err := mydb.GenericCount(mydb.Tables.NewsCluster.GetTableName(), "", config.Config.MySQL.TablePrefix, "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, util.APIResponse{Code: http.StatusInternalServerError, Message: "获取故事聚合列表失败", Data: err.Error()})
+		return
+	}
+
+	clusters, code, err := mydb.Tables.NewsCluster.Select(mydb.QueryParams{
+		OrderBy:  "update_time DESC, id DESC",
+		Limit:    pageSize,
+		Page:     page,
+		PageSize: pageSize,
+	})
+	if err != nil && code != 200 {
+		c.JSON(http.StatusInternalServerError, util.APIResponse{Code: http.StatusInternalServerError, Message: "获取故事聚合列表失败", Data: err.Error()})
+		return
+	}
+
+	data := ClusterListData{Total: total, Page: page, PageSize: pageSize, List: []ClusterItem{}}
+	if len(clusters) == 0 {
+		c.JSON(http.StatusOK, util.APIResponse{Code: http.StatusOK, Message: "获取故事聚合列表成功", Data: data})
+		return
+	}
+
+	clusterIDs := make([]string, 0, len(clusters))
+	for _, cluster := range clusters {
+		clusterIDs = append(clusterIDs, strconv.Itoa(cluster.ID))
+	}
+	newsList, code, err := mydb.Tables.News.Select(mydb.QueryParams{
+		Condition: fmt.Sprintf("cluster_id IN (%s)", strings.Join(clusterIDs, ",")),
+		OrderBy:   "create_time ASC",
+	})
+	if err != nil && code != 200 {
+		c.JSON(http.StatusInternalServerError, util.APIResponse{Code: http.StatusInternalServerError, Message: "获取聚合新闻失败", Data: err.Error()})
+		return
+	}
+
+	members := make(map[int][]ClusterNews)
+	for _, news := range newsList {
+		members[news.Cluster_id] = append(members[news.Cluster_id], ClusterNews{
+			ID:          news.ID,
+			Title:       news.Title,
+			Source:      news.Source,
+			Url:         news.Url,
+			Create_time: news.Create_time,
+		})
+	}
+	for _, cluster := range clusters {
+		item := ClusterItem{StructNewsCluster: cluster, News: members[cluster.ID]}
+		if item.News == nil {
+			item.News = []ClusterNews{}
+		}
+		item.NewsCount = len(item.News)
+		data.List = append(data.List, item)
+	}
+
+	c.JSON(http.StatusOK, util.APIResponse{Code: http.StatusOK, Message: "获取故事聚合列表成功", Data: data})
+}
+
+// MergeClusters 合并故事聚合
+// @Summary 合并故事聚合
+// @Description 把若干聚合里的新闻全部并入目标聚合，并删除被合并的聚合
+// @Tags news
+// @Accept application/x-www-form-urlencoded
+// @Produce application/json
+// @Param LoginToken header string true "认证Token"
+// @Param target_id formData int true "目标聚合ID"
+// @Param source_ids formData string true "被合并的聚合ID，多个用逗号分隔"
+// @Success 200 {object} util.APIResponse{code=int,message=string,data=interface{}} "故事聚合合并成功"
+// @Failure 400 {object} util.APIResponse{code=int,message=string,data=interface{}} "请求参数错误，或目标聚合、被合并的聚合不存在"
+// @Failure 500 {object} util.APIResponse{code=int,message=string,data=interface{}} "合并故事聚合失败"
+// @Router /news/cluster/merge [post]
+func MergeClusters(c *gin.Context) {
+	loginToken := c.GetHeader("LoginToken")
+	if _, err := admin.GetAdminIDFromToken(loginToken); err != nil {
+		c.JSON(http.StatusUnauthorized, util.APIResponse{Code: http.StatusUnauthorized, Message: "无权限进行此操作", Data: err.Error()})
+		return
+	}
+
+	targetID, err := strconv.Atoi(c.PostForm("target_id"))
+	if err != nil || targetID <= 0 {
+		c.JSON(http.StatusBadRequest, util.APIResponse{Code: http.StatusBadRequest, Message: "无效的目标聚合ID", Data: "null"})
+		return
+	}
+	var sourceIDs []string
+	var sourceInts []int
+	seen := make(map[int]bool)
+	for _, field := range strings.Split(c.PostForm("source_ids"), ",") {
+		sourceID, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || sourceID <= 0 {
+			continue
+		}
+		if sourceID != targetID && !seen[sourceID] {
+			seen[sourceID] = true
+			sourceIDs = append(sourceIDs, strconv.Itoa(sourceID))
+			sourceInts = append(sourceInts, sourceID)
+		}
+	}
+	if len(sourceIDs) == 0 {
+		c.JSON(http.StatusBadRequest, util.APIResponse{Code: http.StatusBadRequest, Message: "没有需要合并的聚合", Data: "null"})
+		return
+	}
+
+	target, err := mydb.Tables.NewsCluster.Find(mydb.QueryParams{Condition: "id=" + strconv.Itoa(targetID)})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, util.APIResponse{Code: http.StatusBadRequest, Message: "目标聚合不存在", Data: err.Error()})
+		return
+	}
+	// 被合并的聚合必须都存在，否则会把不属于任何现存聚合的新闻也移过来
+	sources, code, err := mydb.Tables.NewsCluster.Select(mydb.QueryParams{Condition: fmt.Sprintf("id IN (%s)", strings.Join(sourceIDs, ","))})
+	if err != nil && code != 200 {
+		c.JSON(http.StatusInternalServerError, util.APIResponse{Code: http.StatusInternalServerError, Message: "获取被合并的聚合失败", Data: err.Error()})
+		return
+	}
+	for _, source := range sources {
+		delete(seen, source.ID)
+	}
+	if len(seen) > 0 {
+		var missing []string
+		for _, sourceID := range sourceIDs {
+			if id, _ := strconv.Atoi(sourceID); seen[id] {
+				missing = append(missing, sourceID)
+			}
+		}
+		c.JSON(http.StatusBadRequest, util.APIResponse{Code: http.StatusBadRequest, Message: "被合并的聚合不存在", Data: strings.Join(missing, ",")})
+		return
+	}
+
+	// 移动新闻和删除被合并的聚合在同一个事务里，失败时不会留下没有聚合的新闻或空聚合
+	moved, err := target.Merge(target.ID, sourceInts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, util.APIResponse{Code: http.StatusInternalServerError, Message: "合并故事聚合失败", Data: err.Error()})
+		return
+	}
+	log.InfoLogger.Printf("故事聚合合并成功,目标聚合id=%d,被合并聚合:%s,移动新闻%d条", target.ID, strings.Join(sourceIDs, ","), moved)
+
+	c.JSON(http.StatusOK, util.APIResponse{Code: http.StatusOK, Message: "故事聚合合并成功", Data: map[string]interface{}{"moved": moved}})
+}
@@ -0,0 +1,248 @@
+package news
+
+import (
+	"context"
+	"fmt"
+	"nav-web-site/app/api/v1/admin"
+	"nav-web-site/app/webcrawler"
+	"nav-web-site/mydb"
+	"nav-web-site/util"
+	"nav-web-site/util/log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AddFeed 添加新闻订阅源
+// @Summary 添加新闻订阅源
+// @Description 添加 RSS/Atom/JSON Feed 订阅，抓取到的新闻存入指定的新闻分类
+// @Tags news
+// @Accept application/x-www-form-urlencoded
+// @Produce application/json
+// @Param LoginToken header string true "认证Token"
+// @Param name formData string true "订阅源名称"
+// @Param url formData string true "订阅地址"
+// @Param class_id formData int true "新闻分类ID"
+// @Param is_enable formData bool false "是否启用，默认启用"
+// @Success 200 {object} util.APIResponse{code=int,message=string,data=interface{}} "订阅源添加成功"
+// @Failure 400 {object} util.APIResponse{code=int,message=string,data=interface{}} "请求参数错误"
+// @Failure 500 {object} util.APIResponse{code=int,message=string,data=interface{}} "添加订阅源失败"
+// @Router /news/feed/add [post]
+func AddFeed(c *gin.Context) {
+	loginToken := c.GetHeader("LoginToken")
+	adminID, err := admin.GetAdminIDFromToken(loginToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, util.APIResponse{Code: http.StatusUnauthorized, Message: "无权限添加订阅源", Data: err.Error()})
+		return
+	}
+
+	feed := mydb.Tables.NewsFeed.DefaultData()
+	feed.Admin_id = adminID
+	feed.Name = c.PostForm("name")
+	feed.Url = c.PostForm("url")
+	feed.Class_id, err = feedClassID(c.PostForm("class_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, util.APIResponse{Code: http.StatusBadRequest, Message: "无效的新闻分类", Data: err.Error()})
+		return
+	}
+	if isEnable := c.PostForm("is_enable"); isEnable != "" {
+		feed.Is_enable, _ = strconv.ParseBool(isEnable)
+	}
+	if !isValidFeedURL(feed.Url) {
+		c.JSON(http.StatusBadRequest, util.APIResponse{Code: http.StatusBadRequest, Message: "无效的订阅地址", Data: "null"})
+		return
+	}
+
+	count, ids, err := feed.Insert([]mydb.StructNewsFeed{feed})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, util.APIResponse{Code: http.StatusInternalServerError, Message: "添加订阅源失败", Data: err.Error()})
+		return
+	}
+	log.InfoLogger.Printf("订阅源添加成功,url=%s,id=%v,添加记录数:%d", feed.Url, ids, count)
+
+	c.JSON(http.StatusOK, util.APIResponse{Code: http.StatusOK, Message: "订阅源添加成功", Data: "ok"})
+}
+
+// UpdateFeed 修改新闻订阅源
+// @Summary 修改新闻订阅源
+// @Description 根据订阅源ID修改订阅地址、新闻分类或启用状态
+// @Tags news
+// @Accept application/x-www-form-urlencoded
+// @Produce application/json
+// @Param LoginToken header string true "认证Token"
+// @Param id path int true "订阅源ID"
+// @Param name formData string false "订阅源名称"
+// @Param url formData string false "订阅地址"
+// @Param class_id formData int false "新闻分类ID"
+// @Param is_enable formData bool false "是否启用"
+// @Success 200 {object} util.APIResponse{code=int,message=string,data=interface{}} "订阅源修改成功"
+// @Failure 400 {object} util.APIResponse{code=int,message=string,data=interface{}} "请求参数错误"
+// @Failure 500 {object} util.APIResponse{code=int,message=string,data=interface{}} "修改订阅源失败"
+// @Router /news/feed/update/{id} [put]
+func UpdateFeed(c *gin.Context) {
+	loginToken := c.GetHeader("LoginToken")
+	if _, err := admin.GetAdminIDFromToken(loginToken); err != nil {
+		c.JSON(http.StatusUnauthorized, util.APIResponse{Code: http.StatusUnauthorized, Message: "未授权操作", Data: err.Error()})
+		return
+	}
+
+	feedID, _ := strconv.Atoi(c.Param("id"))
+	feed, err := mydb.Tables.NewsFeed.Find(mydb.QueryParams{Condition: "id=" + strconv.Itoa(feedID)})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, util.APIResponse{Code: http.StatusInternalServerError, Message: "获取订阅源信息失败", Data: err.Error()})
+		return
+	}
+
+	if name := c.PostForm("name"); name != "" {
+		feed.Name = name
+	}
+	if feedURL := c.PostForm("url"); feedURL != "" {
+		if !isValidFeedURL(feedURL) {
+			c.JSON(http.StatusBadRequest, util.APIResponse{Code: http.StatusBadRequest, Message: "无效的订阅地址", Data: "null"})
+			return
+		}
+		feed.Url = feedURL
+	}
+	if classID := c.PostForm("class_id"); classID != "" {
+		feed.Class_id, err = feedClassID(classID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, util.APIResponse{Code: http.StatusBadRequest, Message: "无效的新闻分类", Data: err.Error()})
+			return
+		}
+	}
+	if isEnable := c.PostForm("is_enable"); isEnable != "" {
+		feed.Is_enable, _ = strconv.ParseBool(isEnable)
+	}
+	feed.Update_time = util.GetTimestamp(10)
+
+	_, _, err = feed.Update([]mydb.StructNewsFeed{feed}, "id="+strconv.Itoa(feed.ID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, util.APIResponse{Code: http.StatusInternalServerError, Message: "修改订阅源失败", Data: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, util.APIResponse{Code: http.StatusOK, Message: "订阅源修改成功", Data: "ok"})
+}
+
+// DeleteFeed 删除新闻订阅源
+// @Summary 删除新闻订阅源
+// @Description 根据订阅源ID删除订阅源，已抓取的新闻不受影响
+// @Tags news
+// @Produce application/json
+// @Param LoginToken header string true "认证Token"
+// @Param id path int true "订阅源ID"
+// @Success 200 {object} util.APIResponse{code=int,message=string,data=interface{}} "订阅源删除成功"
+// @Failure 500 {object} util.APIResponse{code=int,message=string,data=interface{}} "删除订阅源失败"
+// @Router /news/feed/delete/{id} [delete]
+func DeleteFeed(c *gin.Context) {
+	loginToken := c.GetHeader("LoginToken")
+	if _, err := admin.GetAdminIDFromToken(loginToken); err != nil {
+		c.JSON(http.StatusUnauthorized, util.APIResponse{Code: http.StatusUnauthorized, Message: "未授权操作", Data: err.Error()})
+		return
+	}
+
+	feedID, _ := strconv.Atoi(c.Param("id"))
+	feed, err := mydb.Tables.NewsFeed.Find(mydb.QueryParams{Condition: "id=" + strconv.Itoa(feedID)})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, util.APIResponse{Code: http.StatusInternalServerError, Message: "获取订阅源信息失败", Data: err.Error()})
+		return
+	}
+
+	_, _, err = feed.Delete("id=" + strconv.Itoa(feed.ID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, util.APIResponse{Code: http.StatusInternalServerError, Message: "删除订阅源失败", Data: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, util.APIResponse{Code: http.StatusOK, Message: "订阅源删除成功"})
+}
+
+// GetFeedList 获取新闻订阅源列表
+// @Summary 获取新闻订阅源列表
+// @Description 获取所有订阅源及其最近一次抓取结果
+// @Tags news
+// @Produce application/json
+// @Param LoginToken header string true "认证Token"
+// @Success 200 {object} util.APIResponse{code=int,message=string,data=[]mydb.StructNewsFeed} "获取订阅源列表成功"
+// @Failure 500 {object} util.APIResponse{code=int,message=string,data=interface{}} "获取订阅源列表失败"
+// @Router /news/feed/list [get]
+func GetFeedList(c *gin.Context) {
+	loginToken := c.GetHeader("LoginToken")
+	if _, err := admin.GetAdminIDFromToken(loginToken); err != nil {
+		c.JSON(http.StatusUnauthorized, util.APIResponse{Code: http.StatusUnauthorized, Message: "无权限进行此操作", Data: err.Error()})
+		return
+	}
+
+	feeds, code, err := mydb.Tables.NewsFeed.Select(mydb.QueryParams{OrderBy: "id DESC"})
+	if err != nil && code != 200 {
+		c.JSON(http.StatusInternalServerError, util.APIResponse{Code: http.StatusInternalServerError, Message: "获取订阅源列表失败", Data: err.Error()})
+		return
+	}
+	if feeds == nil {
+		feeds = []mydb.StructNewsFeed{}
+	}
+
+	c.JSON(http.StatusOK, util.APIResponse{Code: http.StatusOK, Message: "获取订阅源列表成功", Data: feeds})
+}
+
+// FetchFeed 立即抓取新闻订阅源
+// @Summary 立即抓取新闻订阅源
+// @Description 立即抓取指定订阅源并入库，返回抓取统计
+// @Tags news
+// @Produce application/json
+// @Param LoginToken header string true "认证Token"
+// @Param id path int true "订阅源ID"
+// @Success 200 {object} util.APIResponse{code=int,message=string,data=webcrawler.Result} "抓取完成"
+// @Failure 500 {object} util.APIResponse{code=int,message=string,data=interface{}} "抓取失败"
+// @Router /news/feed/fetch/{id} [post]
+func FetchFeed(c *gin.Context) {
+	loginToken := c.GetHeader("LoginToken")
+	if _, err := admin.GetAdminIDFromToken(loginToken); err != nil {
+		c.JSON(http.StatusUnauthorized, util.APIResponse{Code: http.StatusUnauthorized, Message: "无权限进行此操作", Data: err.Error()})
+		return
+	}
+
+	feedID, err := strconv.Atoi(c.Param("id"))
+	if err != nil || feedID <= 0 {
+		c.JSON(http.StatusBadRequest, util.APIResponse{Code: http.StatusBadRequest, Message: "无效的订阅源ID", Data: "null"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 2*time.Minute)
+	defer cancel()
+	result, err := webcrawler.RunSource(ctx, webcrawler.FeedSource{FeedID: feedID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, util.APIResponse{Code: http.StatusInternalServerError, Message: "抓取失败", Data: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, util.APIResponse{Code: http.StatusOK, Message: "抓取完成", Data: result})
+}
+
+// isValidFeedURL 订阅地址必须是 http/https 绝对地址
+func isValidFeedURL(feedURL string) bool {
+	u, err := url.Parse(feedURL)
+	if err != nil {
+		return false
+	}
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// feedClassID 解析订阅源的新闻分类ID，分类必须存在
+func feedClassID(value string) (int, error) {
+	classID, err := strconv.Atoi(value)
+	if err != nil || classID <= 0 {
+		return 0, fmt.Errorf("新闻分类ID格式错误: %q", value)
+	}
+	count, err := mydb.GenericCount(mydb.Tables.NewsClass.GetTableName(), "id="+strconv.Itoa(classID), "", "")
+	if err != nil {
+		return 0, err
+	}
+	if count == 0 {
+		return 0, fmt.Errorf("新闻分类不存在: %d", classID)
+	}
+	return classID, nil
+}
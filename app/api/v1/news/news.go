@@ -5,9 +5,12 @@ import (
 	"strconv"
 
 	"nav-web-site/app/api/v1/admin"
+	"nav-web-site/app/sitecache"
+	"nav-web-site/app/webcrawler"
 	"nav-web-site/mydb"
 	"nav-web-site/util"
 	"nav-web-site/util/log"
+	"nav-web-site/util/sanitize"
 
 	"github.com/gin-gonic/gin"
 )
@@ -70,8 +73,10 @@ func AddNews(c *gin.Context) {
 	news.Is_hot, _ = strconv.ParseBool(c.PostForm("is_hot"))
 	news.Is_headline, _ = strconv.ParseBool(c.PostForm("is_headline"))
 	news.Is_recommended, _ = strconv.ParseBool(c.PostForm("is_recommended"))
-	news.Content = c.PostForm("content")
+	news.Content = cleanNewsContent(c, c.PostForm("content"))
+	news.Imgurl = webcrawler.RehostImage(c.Request.Context(), news.Imgurl)
 	news.Create_time = util.GetTimestamp(10)
+	webcrawler.CheckNearDuplicate(&news, false)
 
 	id, rowsAffected, err := mydb.Tables.News.Insert([]mydb.StructNews{news})
 	if err != nil {
@@ -79,6 +84,7 @@ func AddNews(c *gin.Context) {
 		return
 	}
 	log.InfoLogger.Printf("新闻添加成功,标题=“%s”,id=%d,添加记录数:%d", news.Title, id, rowsAffected)
+	sitecache.ForgetSyndication()
 
 	c.JSON(http.StatusOK, util.APIResponse{Code: http.StatusOK, Message: "新闻添加成功", Data: "ok"})
 }
@@ -185,14 +191,18 @@ func UpdateNews(c *gin.Context) {
 		news.Is_recommended, _ = strconv.ParseBool(c.PostForm("is_recommended"))
 	}
 	if c.PostForm("content") != "" {
-		news.Content = c.PostForm("content")
+		news.Content = cleanNewsContent(c, c.PostForm("content"))
 	}
+	webcrawler.FingerprintNews(&news)
+	news.Update_time = util.GetTimestamp(10)
 	_, _, err = news.Update([]mydb.StructNews{news}, "id="+strconv.Itoa(news.ID))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, util.APIResponse{Code: http.StatusInternalServerError, Message: "修改信息失败", Data: err.Error()})
 		return
 	}
 
+	sitecache.ForgetSyndication()
+
 	c.JSON(http.StatusOK, util.APIResponse{Code: http.StatusOK, Message: "新闻编辑成功", Data: "ok"})
 }
 
@@ -281,5 +291,13 @@ func DeleteNews(c *gin.Context) {
 		return
 	}
 
+	sitecache.ForgetSyndication()
+
 	c.JSON(http.StatusOK, util.APIResponse{Code: http.StatusOK, Message: "新闻删除成功"})
 }
+
+// cleanNewsContent 后台提交的正文和抓取的一样按白名单清洗，开启图片转存时把外链图片转存到上传目录
+func cleanNewsContent(c *gin.Context, content string) string {
+	content = sanitize.HTML(content, "")
+	return webcrawler.RehostImages(c.Request.Context(), content)
+}
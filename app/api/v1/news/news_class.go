@@ -1,9 +1,12 @@
 package news
 
 import (
+	"errors"
 	"nav-web-site/app/api/v1/admin"
+	"nav-web-site/app/sitecache"
 	"nav-web-site/mydb"
 	"nav-web-site/util"
+	"nav-web-site/util/classtree"
 	"nav-web-site/util/log"
 	"net/http"
 	"strconv"
@@ -55,6 +58,10 @@ func AddClass(c *gin.Context) {
 	class.Status, _ = strconv.Atoi(c.PostForm("status"))
 	class.Create_time = util.GetTimestamp(10)
 	class.Update_time = util.GetTimestamp(10)
+	if err := checkClassParent(0, class.Parent_id); err != nil {
+		c.JSON(http.StatusBadRequest, util.APIResponse{Code: http.StatusBadRequest, Message: "父级分类无效", Data: err.Error()})
+		return
+	}
 
 	id, rowsAffected, err := class.Insert([]mydb.StructNewsClass{class})
 	if err != nil {
@@ -112,6 +119,10 @@ func UpdateClass(c *gin.Context) {
 	}
 	if parentID := c.PostForm("parent_id"); parentID != "" {
 		class.Parent_id, _ = strconv.Atoi(parentID)
+		if err := checkClassParent(class.ID, class.Parent_id); err != nil {
+			c.JSON(http.StatusBadRequest, util.APIResponse{Code: http.StatusBadRequest, Message: "父级分类无效", Data: err.Error()})
+			return
+		}
 	}
 	if sort := c.PostForm("sort"); sort != "" {
 		class.Sort, _ = strconv.Atoi(sort)
@@ -144,6 +155,8 @@ func UpdateClass(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, util.APIResponse{Code: http.StatusInternalServerError, Message: "修改新闻分类失败", Data: err.Error()})
 		return
 	}
+	// 分类名称、层级和显示状态都会出现在订阅内容里
+	sitecache.ForgetSyndication()
 
 	c.JSON(http.StatusOK, util.APIResponse{Code: http.StatusOK, Message: "新闻分类修改成功"})
 }
@@ -204,14 +217,23 @@ func GetClassDetail(c *gin.Context) {
 
 // DeleteNewsClass 删除新闻分类
 // @Summary 删除新闻分类
-// @Description 根据新闻分类ID删除新闻分类
+// @Description 根据新闻分类ID删除新闻分类，分类下有子分类或新闻时拒绝删除，cascade=true 时连同子分类和其中的新闻一起删除
 // @Tags news
 // @Produce application/json
+// @Param LoginToken header string true "认证Token"
 // @Param id path string true "新闻分类ID"
+// @Param cascade query bool false "是否级联删除子分类和新闻"
 // @Success 200 {object} util.APIResponse{code=int,message=string,data=interface{}} "新闻分类删除成功"
+// @Failure 409 {object} util.APIResponse{code=int,message=string,data=interface{}} "分类下还有子分类或新闻"
 // @Failure 500 {object} util.APIResponse{code=int,message=string,data=interface{}} "删除新闻分类失败"
 // @Router /news/deleteClass/{id} [delete]
 func DeleteClass(c *gin.Context) {
+	loginToken := c.GetHeader("LoginToken")
+	if _, err := admin.GetAdminIDFromToken(loginToken); err != nil {
+		c.JSON(http.StatusUnauthorized, util.APIResponse{Code: http.StatusUnauthorized, Message: "无权限删除新闻分类", Data: err.Error()})
+		return
+	}
+
 	classID := c.Param("id")
 	class, err := mydb.Tables.NewsClass.Find(mydb.QueryParams{Condition: "id=" + classID})
 	if err != nil {
@@ -219,11 +241,17 @@ func DeleteClass(c *gin.Context) {
 		return
 	}
 
-	_, _, err = class.Delete("id=" + strconv.Itoa(class.ID))
+	cascade, _ := strconv.ParseBool(c.Query("cascade"))
+	classCount, newsCount, err := deleteClassTree(class.ID, cascade)
+	if errors.Is(err, classtree.ErrNotEmpty) {
+		c.JSON(http.StatusConflict, util.APIResponse{Code: http.StatusConflict, Message: "分类下还有子分类或新闻", Data: err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, util.APIResponse{Code: http.StatusInternalServerError, Message: "删除新闻分类失败", Data: err.Error()})
 		return
 	}
+	log.InfoLogger.Printf("新闻分类删除成功,id=%d,删除分类数:%d,删除新闻数:%d", class.ID, classCount, newsCount)
 
 	c.JSON(http.StatusOK, util.APIResponse{Code: http.StatusOK, Message: "新闻分类删除成功"})
 }
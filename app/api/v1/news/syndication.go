@@ -0,0 +1,605 @@
+package news
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"nav-web-site/app/sitecache"
+	"nav-web-site/config"
+	"nav-web-site/mydb"
+	"nav-web-site/util"
+	"nav-web-site/util/log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const syndicationCacheKeyPrefix = "news_syndication_"
+
+// 支持输出的订阅格式
+const (
+	syndicationRSS  = "rss"
+	syndicationAtom = "atom"
+	syndicationJSON = "json"
+)
+
+// errSyndicationClassNotFound 分类不存在或已隐藏
+var errSyndicationClassNotFound = fmt.Errorf("新闻分类不存在")
+
+// errSyndicationNoSiteURL 没有配置站点地址。订阅里的链接都是绝对地址，不能按请求的 Host 推断，
+// 否则伪造 Host 的请求可以让缓存里的订阅指向别的站点
+var errSyndicationNoSiteURL = fmt.Errorf("没有配置站点地址 feed.site_url")
+
+// syndicationExtensions 地址扩展名对应的订阅格式
+var syndicationExtensions = map[string]string{
+	".xml":  syndicationRSS,
+	".rss":  syndicationRSS,
+	".atom": syndicationAtom,
+	".json": syndicationJSON,
+}
+
+// syndicationContentTypes 订阅格式对应的 Content-Type
+var syndicationContentTypes = map[string]string{
+	syndicationRSS:  "application/rss+xml; charset=utf-8",
+	syndicationAtom: "application/atom+xml; charset=utf-8",
+	syndicationJSON: "application/feed+json; charset=utf-8",
+}
+
+// renderedFeed 渲染好的订阅内容，缓存在内存里
+type renderedFeed struct {
+	Body         []byte
+	ETag         string
+	LastModified time.Time
+}
+
+// feedChannel 渲染订阅需要的频道信息和新闻列表
+type feedChannel struct {
+	Title       string
+	Description string
+	Link        string // 站点地址
+	SelfLink    string // 订阅自身的地址
+	Updated     time.Time
+	Items       []feedEntry
+}
+
+// feedEntry 渲染订阅需要的单条新闻
+type feedEntry struct {
+	ID          string
+	Title       string
+	Link        string
+	Summary     string
+	Content     string
+	Author      string
+	Image       string
+	Keywords    []string
+	PublishTime time.Time
+	UpdateTime  time.Time // 最近一次修改的时间，没有修改过时等于发布时间
+}
+
+// GetNewsRSS 全部新闻的 RSS 订阅
+// @Summary 新闻RSS订阅
+// @Description 输出最新显示中的新闻（含正文）的 RSS 2.0 订阅，支持 If-None-Match / If-Modified-Since
+// @Tags feed
+// @Produce application/rss+xml
+// @Success 200 {string} string "RSS 2.0"
+// @Success 304 {string} string "未修改"
+// @Router /feed/news.xml [get]
+func GetNewsRSS(c *gin.Context) {
+	serveNewsSyndication(c, syndicationRSS, 0)
+}
+
+// GetNewsAtom 全部新闻的 Atom 订阅
+// @Summary 新闻Atom订阅
+// @Description 输出最新显示中的新闻（含正文）的 Atom 订阅，支持 If-None-Match / If-Modified-Since
+// @Tags feed
+// @Produce application/atom+xml
+// @Success 200 {string} string "Atom 1.0"
+// @Success 304 {string} string "未修改"
+// @Router /feed/news.atom [get]
+func GetNewsAtom(c *gin.Context) {
+	serveNewsSyndication(c, syndicationAtom, 0)
+}
+
+// GetNewsJSONFeed 全部新闻的 JSON Feed 订阅
+// @Summary 新闻JSON Feed订阅
+// @Description 输出最新显示中的新闻（含正文）的 JSON Feed 1.1 订阅，支持 If-None-Match / If-Modified-Since
+// @Tags feed
+// @Produce application/feed+json
+// @Success 200 {string} string "JSON Feed 1.1"
+// @Success 304 {string} string "未修改"
+// @Router /feed/news.json [get]
+func GetNewsJSONFeed(c *gin.Context) {
+	serveNewsSyndication(c, syndicationJSON, 0)
+}
+
+// GetClassSyndication 按新闻分类输出订阅，格式由扩展名决定：.xml/.rss、.atom、.json
+// @Summary 新闻分类订阅
+// @Description 输出指定新闻分类最新显示中的新闻订阅，如 /feed/news/3.atom
+// @Tags feed
+// @Produce application/rss+xml,application/atom+xml,application/feed+json
+// @Param file path string true "分类ID加扩展名，如 3.atom"
+// @Success 200 {string} string "订阅内容"
+// @Success 304 {string} string "未修改"
+// @Failure 404 {object} util.APIResponse{code=int,message=string,data=interface{}} "订阅不存在"
+// @Router /feed/news/{file} [get]
+func GetClassSyndication(c *gin.Context) {
+	file := c.Param("file")
+	dot := strings.LastIndex(file, ".")
+	if dot <= 0 {
+		c.JSON(http.StatusNotFound, util.APIResponse{Code: http.StatusNotFound, Message: "订阅不存在", Data: "null"})
+		return
+	}
+	format, ok := syndicationExtensions[strings.ToLower(file[dot:])]
+	classID, err := strconv.Atoi(file[:dot])
+	if !ok || err != nil || classID <= 0 {
+		c.JSON(http.StatusNotFound, util.APIResponse{Code: http.StatusNotFound, Message: "订阅不存在", Data: "null"})
+		return
+	}
+	serveNewsSyndication(c, format, classID)
+}
+
+// serveNewsSyndication 输出订阅内容，渲染结果按格式和分类缓存，
+// 用内容哈希作 ETag、新闻最近一次发布或修改的时间作 Last-Modified，命中时由 http.ServeContent 返回 304
+func serveNewsSyndication(c *gin.Context, format string, classID int) {
+	feed, err := loadNewsSyndication(c.Request.Context(), format, classID)
+	if err != nil {
+		if err == errSyndicationClassNotFound {
+			c.JSON(http.StatusNotFound, util.APIResponse{Code: http.StatusNotFound, Message: "新闻分类不存在", Data: "null"})
+			return
+		}
+		if err == errSyndicationNoSiteURL {
+			c.JSON(http.StatusServiceUnavailable, util.APIResponse{Code: http.StatusServiceUnavailable, Message: "订阅未启用", Data: err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, util.APIResponse{Code: http.StatusInternalServerError, Message: "生成订阅失败", Data: err.Error()})
+		return
+	}
+
+	c.Header("ETag", feed.ETag)
+	c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", int(syndicationCacheTTL().Seconds())))
+	c.Header("Content-Type", syndicationContentTypes[format])
+	http.ServeContent(c.Writer, c.Request, "", feed.LastModified, bytes.NewReader(feed.Body))
+}
+
+// loadNewsSyndication 先取缓存，没有再查库渲染。
+// 缓存key由版本号、格式和分类组成，请求头不影响缓存内容；新闻或分类变化后 sitecache.ForgetSyndication 把版本号加一，
+// 所有实例的旧缓存不再命中。取不到版本号（Redis 不可用）时不使用缓存
+func loadNewsSyndication(ctx context.Context, format string, classID int) (renderedFeed, error) {
+	siteURL := syndicationSiteURL()
+	if siteURL == "" {
+		return renderedFeed{}, errSyndicationNoSiteURL
+	}
+	cacheKey := ""
+	if version, err := sitecache.SyndicationVersion(ctx); err == nil {
+		cacheKey = fmt.Sprintf("%s%d_%s_%d", syndicationCacheKeyPrefix, version, format, classID)
+		if cacheValue, found := util.C.Get(cacheKey); found {
+			if cached, ok := cacheValue.(renderedFeed); ok {
+				return cached, nil
+			}
+		}
+	} else {
+		log.ErrorLogger.Printf("读取订阅缓存版本号失败,不使用缓存: %v", err)
+	}
+
+	channel, err := buildFeedChannel(siteURL, syndicationPath(format, classID), classID)
+	if err != nil {
+		return renderedFeed{}, err
+	}
+
+	var body []byte
+	switch format {
+	case syndicationAtom:
+		body, err = renderAtom(channel)
+	case syndicationJSON:
+		body, err = renderJSONFeed(channel)
+	default:
+		body, err = renderRSS(channel)
+	}
+	if err != nil {
+		return renderedFeed{}, util.WrapError(err, "渲染订阅失败:")
+	}
+
+	sum := sha256.Sum256(body)
+	feed := renderedFeed{
+		Body:         body,
+		ETag:         `"` + hex.EncodeToString(sum[:16]) + `"`,
+		LastModified: channel.Updated,
+	}
+	if cacheKey != "" {
+		util.C.Set(cacheKey, feed, syndicationCacheTTL())
+	}
+	return feed, nil
+}
+
+// syndicationCacheTTL 订阅内容的缓存时间
+func syndicationCacheTTL() time.Duration {
+	if config.Config.Feed.CacheTTL > 0 {
+		return time.Duration(config.Config.Feed.CacheTTL) * time.Second
+	}
+	return 10 * time.Minute
+}
+
+// buildFeedChannel 查询最新显示中的新闻及其正文
+func buildFeedChannel(siteURL string, selfPath string, classID int) (feedChannel, error) {
+	channel := feedChannel{
+		Title:       config.Config.Feed.Title,
+		Description: config.Config.Feed.Description,
+		Link:        siteURL + "/",
+		SelfLink:    siteURL + selfPath,
+	}
+	if channel.Title == "" {
+		channel.Title = strings.TrimPrefix(strings.TrimPrefix(siteURL, "https://"), "http://")
+	}
+
+	condition := "is_show=1"
+	if classID > 0 {
+		class, err := mydb.Tables.NewsClass.Find(mydb.QueryParams{Condition: "id=" + strconv.Itoa(classID)})
+		if err != nil || !class.Is_show {
+			return channel, errSyndicationClassNotFound
+		}
+		channel.Title = channel.Title + " - " + class.Name
+		if class.Description != "" {
+			channel.Description = class.Description
+		}
+		condition = fmt.Sprintf("%s AND class_id=%d", condition, classID)
+	}
+	if channel.Description == "" {
+		channel.Description = channel.Title
+	}
+
+	limit := config.Config.Feed.Limit
+	if limit <= 0 {
+		limit = 20
+	}
+	newsList, code, err := mydb.Tables.News.Select(mydb.QueryParams{
+		Condition: condition,
+		OrderBy:   "create_time DESC, id DESC",
+		Limit:     limit,
+	})
+	if err != nil && code != 200 {
+		return channel, err
+	}
+	if err := mydb.Tables.News.LoadContents(newsList); err != nil {
+		return channel, err
+	}
+
+	for _, news := range newsList {
+		entry := feedEntry{
+			ID:          fmt.Sprintf("%s/news/%d", siteURL, news.ID),
+			Title:       news.Title,
+			Link:        newsArticleURL(siteURL, news),
+			Summary:     news.Description,
+			Content:     news.Content,
+			Author:      news.Author,
+			Image:       absoluteSiteURL(siteURL, news.Imgurl),
+			PublishTime: time.Unix(news.Create_time, 0).UTC(),
+			UpdateTime:  time.Unix(max(news.Create_time, news.Update_time), 0).UTC(),
+		}
+		for _, keyword := range strings.FieldsFunc(news.Keywords, func(r rune) bool { return r == ',' || r == '，' }) {
+			if keyword = strings.TrimSpace(keyword); keyword != "" {
+				entry.Keywords = append(entry.Keywords, keyword)
+			}
+		}
+		if entry.UpdateTime.After(channel.Updated) {
+			channel.Updated = entry.UpdateTime
+		}
+		channel.Items = append(channel.Items, entry)
+	}
+	if channel.Updated.IsZero() {
+		channel.Updated = time.Unix(0, 0).UTC()
+	}
+	return channel, nil
+}
+
+// newsArticleURL 新闻的链接，优先使用原文链接
+func newsArticleURL(siteURL string, news mydb.StructNews) string {
+	if news.Url != "" {
+		return absoluteSiteURL(siteURL, news.Url)
+	}
+	if config.Config.Feed.ArticleURL != "" {
+		return absoluteSiteURL(siteURL, fmt.Sprintf(config.Config.Feed.ArticleURL, news.ID))
+	}
+	return fmt.Sprintf("%s/api/v1/news/detail/%d", siteURL, news.ID)
+}
+
+// absoluteSiteURL 站内相对地址补全成绝对地址
+func absoluteSiteURL(siteURL string, link string) string {
+	if link == "" || strings.Contains(link, "://") {
+		return link
+	}
+	if strings.HasPrefix(link, "//") {
+		return "https:" + link
+	}
+	if !strings.HasPrefix(link, "/") {
+		link = "/" + link
+	}
+	return siteURL + link
+}
+
+// syndicationPath 订阅自身的地址，按格式和分类生成，不用请求里的路径，避免 3.atom、03.ATOM 共用缓存时自身地址不一致
+func syndicationPath(format string, classID int) string {
+	ext := map[string]string{syndicationRSS: ".xml", syndicationAtom: ".atom", syndicationJSON: ".json"}[format]
+	if classID > 0 {
+		return fmt.Sprintf("/feed/news/%d%s", classID, ext)
+	}
+	return "/feed/news" + ext
+}
+
+// syndicationSiteURL 配置的站点地址，去掉末尾的 /，没有配置时为空
+func syndicationSiteURL() string {
+	return strings.TrimRight(strings.TrimSpace(config.Config.Feed.SiteURL), "/")
+}
+
+type rssDocument struct {
+	XMLName   xml.Name   `xml:"rss"`
+	Version   string     `xml:"version,attr"`
+	ContentNS string     `xml:"xmlns:content,attr"`
+	DCNS      string     `xml:"xmlns:dc,attr"`
+	AtomNS    string     `xml:"xmlns:atom,attr"`
+	Channel   rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string        `xml:"title"`
+	Link          string        `xml:"link"`
+	Description   string        `xml:"description"`
+	AtomLink      rssAtomLink   `xml:"atom:link"`
+	LastBuildDate string        `xml:"lastBuildDate"`
+	Items         []rssFeedItem `xml:"item"`
+}
+
+type rssAtomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type rssFeedItem struct {
+	Title       string        `xml:"title"`
+	Link        string        `xml:"link"`
+	GUID        rssGUID       `xml:"guid"`
+	Description string        `xml:"description"`
+	Content     *rssCDATA     `xml:"content:encoded,omitempty"`
+	Creator     string        `xml:"dc:creator,omitempty"` // RSS 的 author 必须是邮箱，作者名字放在 dc:creator
+	Categories  []string      `xml:"category"`
+	Enclosure   *rssEnclosure `xml:"enclosure,omitempty"`
+	PubDate     string        `xml:"pubDate"`
+}
+
+type rssGUID struct {
+	IsPermaLink string `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssCDATA struct {
+	Value string `xml:",cdata"`
+}
+
+type rssEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Length string `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+// renderRSS 渲染 RSS 2.0，正文放在 content:encoded
+func renderRSS(channel feedChannel) ([]byte, error) {
+	doc := rssDocument{
+		Version:   "2.0",
+		ContentNS: "http://purl.org/rss/1.0/modules/content/",
+		DCNS:      "http://purl.org/dc/elements/1.1/",
+		AtomNS:    "http://www.w3.org/2005/Atom",
+		Channel: rssChannel{
+			Title:         channel.Title,
+			Link:          channel.Link,
+			Description:   channel.Description,
+			AtomLink:      rssAtomLink{Href: channel.SelfLink, Rel: "self", Type: syndicationMediaType(syndicationRSS)},
+			LastBuildDate: channel.Updated.Format(time.RFC1123Z),
+		},
+	}
+	for _, entry := range channel.Items {
+		item := rssFeedItem{
+			Title:       entry.Title,
+			Link:        entry.Link,
+			GUID:        rssGUID{IsPermaLink: "false", Value: entry.ID},
+			Description: entry.Summary,
+			Creator:     entry.Author,
+			Categories:  entry.Keywords,
+			PubDate:     entry.PublishTime.Format(time.RFC1123Z),
+		}
+		if entry.Content != "" {
+			item.Content = &rssCDATA{Value: entry.Content}
+		}
+		if entry.Image != "" {
+			item.Enclosure = &rssEnclosure{URL: entry.Image, Length: "0", Type: imageMediaType(entry.Image)}
+		}
+		doc.Channel.Items = append(doc.Channel.Items, item)
+	}
+	return marshalFeedXML(doc)
+}
+
+type atomDocument struct {
+	XMLName  xml.Name        `xml:"http://www.w3.org/2005/Atom feed"`
+	Title    string          `xml:"title"`
+	Subtitle string          `xml:"subtitle,omitempty"`
+	ID       string          `xml:"id"`
+	Updated  string          `xml:"updated"`
+	Links    []atomFeedLink  `xml:"link"`
+	Entries  []atomFeedEntry `xml:"entry"`
+}
+
+type atomFeedLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomFeedEntry struct {
+	Title      string             `xml:"title"`
+	ID         string             `xml:"id"`
+	Updated    string             `xml:"updated"`
+	Published  string             `xml:"published"`
+	Links      []atomFeedLink     `xml:"link"`
+	Author     *atomFeedAuthor    `xml:"author,omitempty"`
+	Categories []atomFeedCategory `xml:"category"`
+	Summary    string             `xml:"summary,omitempty"`
+	Content    *atomFeedContent   `xml:"content,omitempty"`
+}
+
+type atomFeedAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomFeedCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomFeedContent struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+// renderAtom 渲染 Atom 1.0，正文以 type="html" 放在 content
+func renderAtom(channel feedChannel) ([]byte, error) {
+	doc := atomDocument{
+		Title:    channel.Title,
+		Subtitle: channel.Description,
+		ID:       channel.SelfLink,
+		Updated:  channel.Updated.Format(time.RFC3339),
+		Links: []atomFeedLink{
+			{Href: channel.Link, Rel: "alternate", Type: "text/html"},
+			{Href: channel.SelfLink, Rel: "self", Type: syndicationMediaType(syndicationAtom)},
+		},
+	}
+	for _, item := range channel.Items {
+		entry := atomFeedEntry{
+			Title:     item.Title,
+			ID:        item.ID,
+			Updated:   item.UpdateTime.Format(time.RFC3339),
+			Published: item.PublishTime.Format(time.RFC3339),
+			Links:     []atomFeedLink{{Href: item.Link, Rel: "alternate", Type: "text/html"}},
+			Summary:   item.Summary,
+		}
+		if item.Image != "" {
+			entry.Links = append(entry.Links, atomFeedLink{Href: item.Image, Rel: "enclosure", Type: imageMediaType(item.Image)})
+		}
+		if item.Author != "" {
+			entry.Author = &atomFeedAuthor{Name: item.Author}
+		}
+		for _, keyword := range item.Keywords {
+			entry.Categories = append(entry.Categories, atomFeedCategory{Term: keyword})
+		}
+		if item.Content != "" {
+			entry.Content = &atomFeedContent{Type: "html", Value: item.Content}
+		}
+		doc.Entries = append(doc.Entries, entry)
+	}
+	return marshalFeedXML(doc)
+}
+
+type jsonFeedDocument struct {
+	Version     string               `json:"version"`
+	Title       string               `json:"title"`
+	HomePageURL string               `json:"home_page_url"`
+	FeedURL     string               `json:"feed_url"`
+	Description string               `json:"description,omitempty"`
+	Items       []jsonFeedOutputItem `json:"items"`
+}
+
+type jsonFeedOutputItem struct {
+	ID            string                 `json:"id"`
+	URL           string                 `json:"url"`
+	Title         string                 `json:"title"`
+	ContentHTML   string                 `json:"content_html,omitempty"`
+	Summary       string                 `json:"summary,omitempty"`
+	Image         string                 `json:"image,omitempty"`
+	DatePublished string                 `json:"date_published"`
+	DateModified  string                 `json:"date_modified,omitempty"`
+	Authors       []jsonFeedOutputAuthor `json:"authors,omitempty"`
+	Tags          []string               `json:"tags,omitempty"`
+}
+
+type jsonFeedOutputAuthor struct {
+	Name string `json:"name"`
+}
+
+// renderJSONFeed 渲染 JSON Feed 1.1
+func renderJSONFeed(channel feedChannel) ([]byte, error) {
+	doc := jsonFeedDocument{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       channel.Title,
+		HomePageURL: channel.Link,
+		FeedURL:     channel.SelfLink,
+		Description: channel.Description,
+		Items:       []jsonFeedOutputItem{},
+	}
+	for _, entry := range channel.Items {
+		item := jsonFeedOutputItem{
+			ID:            entry.ID,
+			URL:           entry.Link,
+			Title:         entry.Title,
+			ContentHTML:   entry.Content,
+			Summary:       entry.Summary,
+			Image:         entry.Image,
+			DatePublished: entry.PublishTime.Format(time.RFC3339),
+			Tags:          entry.Keywords,
+		}
+		if entry.UpdateTime.After(entry.PublishTime) {
+			item.DateModified = entry.UpdateTime.Format(time.RFC3339)
+		}
+		if item.ContentHTML == "" {
+			// JSON Feed 要求 content_html 和 content_text 至少有一个
+			item.ContentHTML = entry.Summary
+		}
+		if entry.Author != "" {
+			item.Authors = []jsonFeedOutputAuthor{{Name: entry.Author}}
+		}
+		doc.Items = append(doc.Items, item)
+	}
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(doc); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// marshalFeedXML 序列化 XML 并加上声明头
+func marshalFeedXML(doc interface{}) ([]byte, error) {
+	body, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), body...), nil
+}
+
+// syndicationMediaType 去掉 charset 的订阅 MIME 类型，用于 self 链接
+func syndicationMediaType(format string) string {
+	return strings.SplitN(syndicationContentTypes[format], ";", 2)[0]
+}
+
+// imageMediaType 按图片扩展名猜测 MIME 类型，站内 /images/{hash} 没有扩展名时按 image/jpeg 处理
+func imageMediaType(imageURL string) string {
+	lower := strings.ToLower(imageURL)
+	if i := strings.IndexAny(lower, "?#"); i >= 0 {
+		lower = lower[:i]
+	}
+	switch {
+	case strings.HasSuffix(lower, ".png"):
+		return "image/png"
+	case strings.HasSuffix(lower, ".gif"):
+		return "image/gif"
+	case strings.HasSuffix(lower, ".webp"):
+		return "image/webp"
+	case strings.HasSuffix(lower, ".svg"):
+		return "image/svg+xml"
+	}
+	return "image/jpeg"
+}
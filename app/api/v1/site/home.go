@@ -0,0 +1,247 @@
+package site
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"nav-web-site/app/sitecache"
+	"nav-web-site/mydb"
+	"nav-web-site/util"
+	"nav-web-site/util/classtree"
+	"nav-web-site/util/log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	homeCacheKey     = "site_home"      // 首页数据的缓存key前缀，放在 Redis 里，多个实例共用，后面带上 sitecache 的版本号
+	homeCacheTTL     = 10 * time.Minute // 缓存时间，热门链接按点击数排列，最多这么久更新一次
+	recommendedLimit = 20
+	hotLimit         = 20
+	hotDays          = 7
+)
+
+// HomeLink 首页上的一个导航链接
+type HomeLink struct {
+	ID          int    `json:"ID"`
+	Class_id    int    `json:"Class_id"`
+	Title       string `json:"Title"`
+	Subtitle    string `json:"Subtitle"`
+	Url         string `json:"Url"`
+	Go_url      string `json:"Go_url"` // 经过 /go/{id} 跳转并计数的地址
+	Description string `json:"Description"`
+	Icon        string `json:"Icon"`
+	Views       int    `json:"Views"`
+}
+
+// HomeClass 首页上的一个分类和它的链接、子分类
+type HomeClass struct {
+	ID           int          `json:"ID"`
+	Name         string       `json:"Name"`
+	Icon         string       `json:"Icon"`
+	Description  string       `json:"Description"`
+	Is_recommend bool         `json:"Is_recommend"`
+	Is_hot       bool         `json:"Is_hot"`
+	Links        []HomeLink   `json:"Links"`
+	Children     []*HomeClass `json:"Children"`
+}
+
+// HomeData 首页数据
+type HomeData struct {
+	Classes     []*HomeClass `json:"Classes"`     // 显示的分类树，每个分类带自己的链接
+	Recommended []HomeLink   `json:"Recommended"` // 推荐的链接
+	Hot         []HomeLink   `json:"Hot"`         // 最近7天点击最多的链接，还没有点击数据时按浏览量
+	Build_time  int64        `json:"Build_time"`  // 生成时间
+}
+
+// GetHome 获取首页数据
+// @Summary 获取首页数据
+// @Description 一次返回显示中的分类树（每个分类带显示中的链接，按 Sort 排列）、推荐链接和热门链接，结果缓存，导航或分类变化时刷新
+// @Tags site
+// @Produce application/json
+// @Success 200 {object} util.APIResponse{code=int,message=string,data=HomeData}
+// @Failure 500 {object} util.APIResponse{code=int,message=string,data=string}
+// @Router /site/home [get]
+func GetHome(c *gin.Context) {
+	ctx := c.Request.Context()
+	// 数据变化时 sitecache.ForgetHome 把版本号加一，之前开始生成的数据只会写到旧版本的key里
+	cacheKey := ""
+	if version, err := sitecache.HomeVersion(ctx); err == nil {
+		cacheKey = fmt.Sprintf("%s:%d", homeCacheKey, version)
+	} else {
+		log.ErrorLogger.Printf("读取首页缓存版本号失败,不使用缓存: %v", err)
+	}
+	if cacheKey != "" {
+		if cached, err := mydb.RedisClient.Get(ctx, cacheKey).Bytes(); err == nil {
+			c.Header("X-Cache", "HIT")
+			c.Data(http.StatusOK, "application/json; charset=utf-8", cached)
+			return
+		} else if !errors.Is(err, redis.Nil) {
+			log.ErrorLogger.Printf("读取首页缓存失败: %v", err)
+		}
+	}
+
+	data, err := BuildHome()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, util.APIResponse{Code: http.StatusInternalServerError, Message: "获取首页数据失败", Data: err.Error()})
+		return
+	}
+	body, err := json.Marshal(util.APIResponse{Code: http.StatusOK, Message: "获取首页数据成功", Data: data})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, util.APIResponse{Code: http.StatusInternalServerError, Message: "获取首页数据失败", Data: err.Error()})
+		return
+	}
+	if cacheKey != "" {
+		if err := mydb.RedisClient.Set(ctx, cacheKey, body, homeCacheTTL).Err(); err != nil {
+			log.ErrorLogger.Printf("保存首页缓存失败: %v", err)
+		}
+	}
+	c.Header("X-Cache", "MISS")
+	c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+}
+
+// BuildHome 查询数据库生成首页数据。只包含启用且显示的分类和链接，隐藏分类下的子分类和链接也不显示
+func BuildHome() (HomeData, error) {
+	data := HomeData{Classes: []*HomeClass{}, Recommended: []HomeLink{}, Hot: []HomeLink{}, Build_time: util.GetTimestamp(10)}
+
+	classes, code, err := mydb.Tables.NavClass.Select(mydb.QueryParams{})
+	if err != nil && code != 200 {
+		return data, err
+	}
+	navs, code, err := mydb.Tables.Nav.Select(mydb.QueryParams{Condition: "status=1 AND is_show=1"})
+	if err != nil && code != 200 {
+		return data, err
+	}
+
+	linksByClass := make(map[int][]HomeLink)
+	for _, nav := range sortedNavs(navs) {
+		linksByClass[nav.Class_id] = append(linksByClass[nav.Class_id], homeLink(nav))
+	}
+
+	classByID := make(map[int]mydb.StructNavClass, len(classes))
+	items := make([]classtree.Item, 0, len(classes))
+	for _, class := range classes {
+		classByID[class.ID] = class
+		items = append(items, classtree.Item{ID: class.ID, Parent_id: class.Parent_id, Sort: class.Sort})
+	}
+	children := classtree.Children(items)
+	visible := make(map[int]bool)
+	var build func(parent int) []*HomeClass
+	build = func(parent int) []*HomeClass {
+		nodes := []*HomeClass{}
+		for _, id := range children[parent] {
+			class := classByID[id]
+			if class.Status != 1 || !class.Is_show {
+				continue
+			}
+			visible[id] = true
+			links := linksByClass[id]
+			if links == nil {
+				links = []HomeLink{}
+			}
+			nodes = append(nodes, &HomeClass{
+				ID:           class.ID,
+				Name:         class.Name,
+				Icon:         class.Icon,
+				Description:  class.Description,
+				Is_recommend: class.Is_recommend,
+				Is_hot:       class.Is_hot,
+				Links:        links,
+				Children:     build(id),
+			})
+		}
+		return nodes
+	}
+	data.Classes = build(0)
+
+	// 推荐和热门只取显示中的分类里的链接
+	var shown []mydb.StructNav
+	for _, nav := range navs {
+		if visible[nav.Class_id] {
+			shown = append(shown, nav)
+		}
+	}
+	for _, nav := range sortedNavs(shown) {
+		if nav.Is_recommend && len(data.Recommended) < recommendedLimit {
+			data.Recommended = append(data.Recommended, homeLink(nav))
+		}
+	}
+	hot, err := hotLinks(shown)
+	if err != nil {
+		return data, err
+	}
+	data.Hot = hot
+	return data, nil
+}
+
+// hotLinks 按最近 hotDays 天的点击数取热门链接，点击数据不够时按浏览量补足
+func hotLinks(navs []mydb.StructNav) ([]HomeLink, error) {
+	navByID := make(map[int]mydb.StructNav, len(navs))
+	for _, nav := range navs {
+		navByID[nav.ID] = nav
+	}
+	dateNumber := func(t time.Time) int {
+		n, _ := strconv.Atoi(t.Format("20060102"))
+		return n
+	}
+	now := time.Now()
+	totals, err := mydb.Tables.NavClickDaily.Totals(dateNumber(now.AddDate(0, 0, 1-hotDays)), dateNumber(now))
+	if err != nil {
+		return nil, err
+	}
+
+	hot := []HomeLink{}
+	added := make(map[int]bool)
+	for _, total := range totals {
+		if nav, ok := navByID[total.Nav_id]; ok && len(hot) < hotLimit {
+			hot = append(hot, homeLink(nav))
+			added[nav.ID] = true
+		}
+	}
+	if len(hot) < hotLimit {
+		byViews := append([]mydb.StructNav(nil), navs...)
+		sort.SliceStable(byViews, func(i, j int) bool {
+			return byViews[i].Views > byViews[j].Views
+		})
+		for _, nav := range byViews {
+			if len(hot) >= hotLimit {
+				break
+			}
+			if !added[nav.ID] && nav.Views > 0 {
+				hot = append(hot, homeLink(nav))
+			}
+		}
+	}
+	return hot, nil
+}
+
+// sortedNavs 按 Sort 从小到大、再按 id 排列，和分类的顺序规则一致
+func sortedNavs(navs []mydb.StructNav) []mydb.StructNav {
+	sorted := append([]mydb.StructNav(nil), navs...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Sort != sorted[j].Sort {
+			return sorted[i].Sort < sorted[j].Sort
+		}
+		return sorted[i].ID < sorted[j].ID
+	})
+	return sorted
+}
+
+func homeLink(nav mydb.StructNav) HomeLink {
+	return HomeLink{
+		ID:          nav.ID,
+		Class_id:    nav.Class_id,
+		Title:       nav.Title,
+		Subtitle:    nav.Subtitle,
+		Url:         nav.Url,
+		Go_url:      fmt.Sprintf("/go/%d", nav.ID),
+		Description: nav.Description,
+		Icon:        nav.Icon,
+		Views:       nav.Views,
+	}
+}
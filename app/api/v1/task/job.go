@@ -0,0 +1,192 @@
+package task
+
+import (
+	"errors"
+	"fmt"
+	"nav-web-site/app/api/v1/admin"
+	"nav-web-site/app/queue"
+	"nav-web-site/config"
+	"nav-web-site/mydb"
+	"nav-web-site/util"
+	"nav-web-site/util/log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JobListData 队列任务列表
+type JobListData struct {
+	Total    int              `json:"total"`
+	Page     int              `json:"page"`
+	PageSize int              `json:"page_size"`
+	List     []mydb.StructJob `json:"list"`
+}
+
+// EnqueueJob 队列任务入队
+// @Summary 队列任务入队
+// @Description 把任务放进后台队列，由空闲的工作协程执行，失败按退避时间重试，次数用完进入死信。job_type=task 时 payload 形如 {"type":"news163"}
+// @Tags admin
+// @Accept application/x-www-form-urlencoded
+// @Produce application/json
+// @Param LoginToken header string true "认证Token"
+// @Param job_type formData string true "任务类型"
+// @Param payload formData string false "JSON参数"
+// @Param max_attempts formData int false "最多执行次数"
+// @Param delay formData int false "延迟执行的秒数"
+// @Success 202 {object} util.APIResponse{code=int,message=string,data=mydb.StructJob} "任务已入队"
+// @Failure 400 {object} util.APIResponse{code=int,message=string,data=interface{}} "请求参数错误"
+// @Failure 500 {object} util.APIResponse{code=int,message=string,data=interface{}} "任务入队失败"
+// @Router /admin/jobs/enqueue [post]
+func EnqueueJob(c *gin.Context) {
+	loginToken := c.GetHeader("LoginToken")
+	adminID, err := admin.GetAdminIDFromToken(loginToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, util.APIResponse{Code: http.StatusUnauthorized, Message: "无权限进行此操作", Data: err.Error()})
+		return
+	}
+
+	jobType := strings.TrimSpace(c.PostForm("job_type"))
+	maxAttempts, _ := strconv.Atoi(c.PostForm("max_attempts"))
+	delay, _ := strconv.Atoi(c.PostForm("delay"))
+	if maxAttempts < 0 || delay < 0 {
+		c.JSON(http.StatusBadRequest, util.APIResponse{Code: http.StatusBadRequest, Message: "请求参数错误", Data: "null"})
+		return
+	}
+
+	job, err := queue.Enqueue(jobType, c.PostForm("payload"), queue.Options{
+		MaxAttempts: maxAttempts,
+		Delay:       time.Duration(delay) * time.Second,
+	})
+	if errors.Is(err, queue.ErrUnknownJob) {
+		c.JSON(http.StatusBadRequest, util.APIResponse{Code: http.StatusBadRequest, Message: "未知的任务类型", Data: queue.Types()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, util.APIResponse{Code: http.StatusInternalServerError, Message: "任务入队失败", Data: err.Error()})
+		return
+	}
+	log.InfoLogger.Printf("管理员提交队列任务,管理员id=%d,任务id=%d,类型=%s", adminID, job.ID, job.Job_type)
+
+	c.JSON(http.StatusAccepted, util.APIResponse{Code: http.StatusAccepted, Message: "任务已入队", Data: job})
+}
+
+// GetJob 查询队列任务状态
+// @Summary 查询队列任务状态
+// @Description 根据任务ID查询状态、执行次数和最近的错误
+// @Tags admin
+// @Produce application/json
+// @Param LoginToken header string true "认证Token"
+// @Param id path int true "任务ID"
+// @Success 200 {object} util.APIResponse{code=int,message=string,data=mydb.StructJob} "获取任务状态成功"
+// @Failure 404 {object} util.APIResponse{code=int,message=string,data=interface{}} "任务不存在"
+// @Router /admin/jobs/{id} [get]
+func GetJob(c *gin.Context) {
+	loginToken := c.GetHeader("LoginToken")
+	if _, err := admin.GetAdminIDFromToken(loginToken); err != nil {
+		c.JSON(http.StatusUnauthorized, util.APIResponse{Code: http.StatusUnauthorized, Message: "无权限进行此操作", Data: err.Error()})
+		return
+	}
+
+	jobID, _ := strconv.Atoi(c.Param("id"))
+	job, err := queue.Get(jobID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, util.APIResponse{Code: http.StatusNotFound, Message: "任务不存在", Data: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, util.APIResponse{Code: http.StatusOK, Message: "获取任务状态成功", Data: job})
+}
+
+// GetJobList 获取队列任务列表
+// @Summary 获取队列任务列表
+// @Description 按ID倒序列出队列任务，status=dead 即死信队列
+// @Tags admin
+// @Produce application/json
+// @Param LoginToken header string true "认证Token"
+// @Param job_type query string false "任务类型"
+// @Param status query string false "状态：queued、running、succeeded、dead"
+// @Param page query int false "页码"
+// @Param page_size query int false "每页数量"
+// @Success 200 {object} util.APIResponse{code=int,message=string,data=JobListData} "获取任务列表成功"
+// @Failure 500 {object} util.APIResponse{code=int,message=string,data=interface{}} "获取任务列表失败"
+// @Router /admin/jobs/list [get]
+func GetJobList(c *gin.Context) {
+	loginToken := c.GetHeader("LoginToken")
+	if _, err := admin.GetAdminIDFromToken(loginToken); err != nil {
+		c.JSON(http.StatusUnauthorized, util.APIResponse{Code: http.StatusUnauthorized, Message: "无权限进行此操作", Data: err.Error()})
+		return
+	}
+
+	page, err := strconv.Atoi(c.Query("page"))
+	if err != nil || page <= 0 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(c.Query("page_size"))
+	if err != nil || pageSize <= 0 {
+		pageSize = 20
+	}
+
+	var conditions []string
+	if jobType := c.Query("job_type"); jobType != "" {
+		conditions = append(conditions, fmt.Sprintf("job_type='%s'", mydb.EscapeString(jobType)))
+	}
+	if status := c.Query("status"); status != "" {
+		conditions = append(conditions, fmt.Sprintf("status='%s'", mydb.EscapeString(status)))
+	}
+	condition := strings.Join(conditions, " AND ")
+
+	total, err := mydb.GenericCount(mydb.Tables.Job.GetTableName(), condition, config.Config.MySQL.TablePrefix, "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, util.APIResponse{Code: http.StatusInternalServerError, Message: "获取任务列表失败", Data: err.Error()})
+		return
+	}
+
+	jobs, code, err := mydb.Tables.Job.Select(mydb.QueryParams{
+		Condition: condition,
+		OrderBy:   "id DESC",
+		Limit:     pageSize,
+		Page:      page,
+		PageSize:  pageSize,
+	})
+	if err != nil && code != 200 {
+		c.JSON(http.StatusInternalServerError, util.APIResponse{Code: http.StatusInternalServerError, Message: "获取任务列表失败", Data: err.Error()})
+		return
+	}
+	if jobs == nil {
+		jobs = []mydb.StructJob{}
+	}
+
+	c.JSON(http.StatusOK, util.APIResponse{Code: http.StatusOK, Message: "获取任务列表成功", Data: JobListData{Total: total, Page: page, PageSize: pageSize, List: jobs}})
+}
+
+// RetryJob 死信任务重新入队
+// @Summary 死信任务重新入队
+// @Description 把重试耗尽的任务放回队列，执行次数清零
+// @Tags admin
+// @Produce application/json
+// @Param LoginToken header string true "认证Token"
+// @Param id path int true "任务ID"
+// @Success 200 {object} util.APIResponse{code=int,message=string,data=mydb.StructJob} "任务已重新入队"
+// @Failure 400 {object} util.APIResponse{code=int,message=string,data=interface{}} "任务不能重新入队"
+// @Router /admin/jobs/{id}/retry [post]
+func RetryJob(c *gin.Context) {
+	loginToken := c.GetHeader("LoginToken")
+	adminID, err := admin.GetAdminIDFromToken(loginToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, util.APIResponse{Code: http.StatusUnauthorized, Message: "无权限进行此操作", Data: err.Error()})
+		return
+	}
+
+	jobID, _ := strconv.Atoi(c.Param("id"))
+	job, err := queue.Retry(jobID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, util.APIResponse{Code: http.StatusBadRequest, Message: "任务不能重新入队", Data: err.Error()})
+		return
+	}
+	log.InfoLogger.Printf("管理员重新入队死信任务,管理员id=%d,任务id=%d", adminID, job.ID)
+
+	c.JSON(http.StatusOK, util.APIResponse{Code: http.StatusOK, Message: "任务已重新入队", Data: job})
+}
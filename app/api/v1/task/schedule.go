@@ -0,0 +1,248 @@
+package task
+
+import (
+	"fmt"
+	"nav-web-site/app/api/v1/admin"
+	"nav-web-site/app/tasks"
+	"nav-web-site/mydb"
+	"nav-web-site/util"
+	"nav-web-site/util/log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ScheduledTaskItem 计划任务及其下一次执行时间
+type ScheduledTaskItem struct {
+	mydb.StructScheduledTask
+	Next_run_time int64 `json:"next_run_time"` // 下一次执行时间，未在调度时为0
+}
+
+// AddScheduledTask 添加计划任务
+// @Summary 添加计划任务
+// @Description 添加计划任务，启用的任务立即加入调度，不需要重启服务，多个实例时通知所有实例重新调度
+// @Tags admin
+// @Accept application/x-www-form-urlencoded
+// @Produce application/json
+// @Param LoginToken header string true "认证Token"
+// @Param name formData string true "任务名称"
+// @Param task_type formData string true "任务类型，如 upload_gc、news163"
+// @Param cron_expr formData string true "cron表达式，如 0 3 * * * 或 @every 30m"
+// @Param timezone formData string false "时区，如 Asia/Shanghai"
+// @Param params formData string false "传给任务的JSON参数"
+// @Param is_enable formData bool false "是否启用，默认启用"
+// @Success 200 {object} util.APIResponse{code=int,message=string,data=mydb.StructScheduledTask} "计划任务添加成功"
+// @Failure 400 {object} util.APIResponse{code=int,message=string,data=interface{}} "请求参数错误"
+// @Failure 500 {object} util.APIResponse{code=int,message=string,data=interface{}} "添加计划任务失败"
+// @Router /admin/tasks/schedule/add [post]
+func AddScheduledTask(c *gin.Context) {
+	loginToken := c.GetHeader("LoginToken")
+	adminID, err := admin.GetAdminIDFromToken(loginToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, util.APIResponse{Code: http.StatusUnauthorized, Message: "无权限进行此操作", Data: err.Error()})
+		return
+	}
+
+	task := mydb.Tables.ScheduledTask.DefaultData()
+	task.Name = strings.TrimSpace(c.PostForm("name"))
+	task.Task_type = strings.TrimSpace(c.PostForm("task_type"))
+	task.Cron_expr = strings.TrimSpace(c.PostForm("cron_expr"))
+	task.Timezone = strings.TrimSpace(c.PostForm("timezone"))
+	if params := strings.TrimSpace(c.PostForm("params")); params != "" {
+		task.Params = params
+	}
+	if isEnable := c.PostForm("is_enable"); isEnable != "" {
+		task.Is_enable, _ = strconv.ParseBool(isEnable)
+	}
+	if err := tasks.Validate(task); err != nil {
+		c.JSON(http.StatusBadRequest, util.APIResponse{Code: http.StatusBadRequest, Message: "请求参数错误", Data: err.Error()})
+		return
+	}
+
+	condition := fmt.Sprintf("name='%s'", mydb.EscapeString(task.Name))
+	if _, err := mydb.Tables.ScheduledTask.Find(mydb.QueryParams{Condition: condition}); err == nil {
+		c.JSON(http.StatusBadRequest, util.APIResponse{Code: http.StatusBadRequest, Message: "任务名称已存在", Data: "null"})
+		return
+	}
+
+	_, ids, err := task.Insert([]mydb.StructScheduledTask{task})
+	if err != nil || len(ids) == 0 {
+		c.JSON(http.StatusInternalServerError, util.APIResponse{Code: http.StatusInternalServerError, Message: "添加计划任务失败", Data: fmt.Sprint(err)})
+		return
+	}
+	task.ID = int(ids[0])
+	if err := tasks.Schedule(task); err != nil {
+		log.ErrorLogger.Printf("调度计划任务失败,id=%d: %v", task.ID, err)
+	}
+	// 本实例立即生效，其他实例收到通知后重新调度
+	tasks.PublishReload()
+	log.InfoLogger.Printf("计划任务添加成功,管理员id=%d,任务id=%d,类型=%s,cron=%s", adminID, task.ID, task.Task_type, tasks.Spec(task))
+
+	c.JSON(http.StatusOK, util.APIResponse{Code: http.StatusOK, Message: "计划任务添加成功", Data: task})
+}
+
+// UpdateScheduledTask 修改计划任务
+// @Summary 修改计划任务
+// @Description 修改计划任务的设置，只修改传入的字段。修改后所有实例立即按新设置重新调度，is_enable=false 时暂停。配置文件里的任务（source=config）只能修改参数和启用状态
+// @Tags admin
+// @Accept application/x-www-form-urlencoded
+// @Produce application/json
+// @Param LoginToken header string true "认证Token"
+// @Param id path int true "计划任务ID"
+// @Param name formData string false "任务名称"
+// @Param task_type formData string false "任务类型"
+// @Param cron_expr formData string false "cron表达式"
+// @Param timezone formData string false "时区，传 local 表示改回服务器本地时区"
+// @Param params formData string false "传给任务的JSON参数"
+// @Param is_enable formData bool false "是否启用"
+// @Success 200 {object} util.APIResponse{code=int,message=string,data=mydb.StructScheduledTask} "计划任务修改成功"
+// @Failure 400 {object} util.APIResponse{code=int,message=string,data=interface{}} "请求参数错误"
+// @Failure 500 {object} util.APIResponse{code=int,message=string,data=interface{}} "修改计划任务失败"
+// @Router /admin/tasks/schedule/update/{id} [put]
+func UpdateScheduledTask(c *gin.Context) {
+	loginToken := c.GetHeader("LoginToken")
+	adminID, err := admin.GetAdminIDFromToken(loginToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, util.APIResponse{Code: http.StatusUnauthorized, Message: "无权限进行此操作", Data: err.Error()})
+		return
+	}
+
+	taskID, _ := strconv.Atoi(c.Param("id"))
+	task, err := mydb.Tables.ScheduledTask.Find(mydb.QueryParams{Condition: "id=" + strconv.Itoa(taskID)})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, util.APIResponse{Code: http.StatusBadRequest, Message: "计划任务不存在", Data: err.Error()})
+		return
+	}
+	original := task
+
+	if name := strings.TrimSpace(c.PostForm("name")); name != "" && name != task.Name {
+		condition := fmt.Sprintf("name='%s'", mydb.EscapeString(name))
+		if _, err := mydb.Tables.ScheduledTask.Find(mydb.QueryParams{Condition: condition}); err == nil {
+			c.JSON(http.StatusBadRequest, util.APIResponse{Code: http.StatusBadRequest, Message: "任务名称已存在", Data: "null"})
+			return
+		}
+		task.Name = name
+	}
+	if taskType := strings.TrimSpace(c.PostForm("task_type")); taskType != "" {
+		task.Task_type = taskType
+	}
+	if cronExpr := strings.TrimSpace(c.PostForm("cron_expr")); cronExpr != "" {
+		task.Cron_expr = cronExpr
+	}
+	if timezone := strings.TrimSpace(c.PostForm("timezone")); timezone == "local" {
+		task.Timezone = ""
+	} else if timezone != "" {
+		task.Timezone = timezone
+	}
+	if params := strings.TrimSpace(c.PostForm("params")); params != "" {
+		task.Params = params
+	}
+	if isEnable := c.PostForm("is_enable"); isEnable != "" {
+		task.Is_enable, _ = strconv.ParseBool(isEnable)
+	}
+	// 配置文件里的任务下次同步时会改回配置的值，不允许在后台修改
+	if original.Source == tasks.SourceConfig && (task.Name != original.Name || task.Task_type != original.Task_type ||
+		task.Cron_expr != original.Cron_expr || task.Timezone != original.Timezone) {
+		c.JSON(http.StatusBadRequest, util.APIResponse{Code: http.StatusBadRequest, Message: "配置文件中的计划任务只能修改参数和启用状态，其他设置请修改配置文件", Data: "null"})
+		return
+	}
+	if err := tasks.Validate(task); err != nil {
+		c.JSON(http.StatusBadRequest, util.APIResponse{Code: http.StatusBadRequest, Message: "请求参数错误", Data: err.Error()})
+		return
+	}
+	task.Update_time = util.GetTimestamp(10)
+
+	if _, _, err := task.Update([]mydb.StructScheduledTask{task}, "id="+strconv.Itoa(task.ID)); err != nil {
+		c.JSON(http.StatusInternalServerError, util.APIResponse{Code: http.StatusInternalServerError, Message: "修改计划任务失败", Data: err.Error()})
+		return
+	}
+	if err := tasks.Schedule(task); err != nil {
+		log.ErrorLogger.Printf("调度计划任务失败,id=%d: %v", task.ID, err)
+	}
+	// 本实例立即生效，其他实例收到通知后重新调度
+	tasks.PublishReload()
+	log.InfoLogger.Printf("计划任务修改成功,管理员id=%d,任务id=%d,cron=%s,启用=%t", adminID, task.ID, tasks.Spec(task), task.Is_enable)
+
+	c.JSON(http.StatusOK, util.APIResponse{Code: http.StatusOK, Message: "计划任务修改成功", Data: task})
+}
+
+// DeleteScheduledTask 删除计划任务
+// @Summary 删除计划任务
+// @Description 删除计划任务并立即从所有实例的调度中移除，已有的执行记录保留。配置文件里的任务（source=config）不能删除，可以停用
+// @Tags admin
+// @Produce application/json
+// @Param LoginToken header string true "认证Token"
+// @Param id path int true "计划任务ID"
+// @Success 200 {object} util.APIResponse{code=int,message=string,data=interface{}} "计划任务删除成功"
+// @Failure 400 {object} util.APIResponse{code=int,message=string,data=interface{}} "配置文件中的计划任务不能删除"
+// @Failure 500 {object} util.APIResponse{code=int,message=string,data=interface{}} "删除计划任务失败"
+// @Router /admin/tasks/schedule/delete/{id} [delete]
+func DeleteScheduledTask(c *gin.Context) {
+	loginToken := c.GetHeader("LoginToken")
+	adminID, err := admin.GetAdminIDFromToken(loginToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, util.APIResponse{Code: http.StatusUnauthorized, Message: "无权限进行此操作", Data: err.Error()})
+		return
+	}
+
+	taskID, _ := strconv.Atoi(c.Param("id"))
+	task, err := mydb.Tables.ScheduledTask.Find(mydb.QueryParams{Condition: "id=" + strconv.Itoa(taskID)})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, util.APIResponse{Code: http.StatusInternalServerError, Message: "获取计划任务失败", Data: err.Error()})
+		return
+	}
+	// 删除后下次同步又会从配置文件写回来
+	if task.Source == tasks.SourceConfig {
+		c.JSON(http.StatusBadRequest, util.APIResponse{Code: http.StatusBadRequest, Message: "配置文件中的计划任务不能删除，请从配置文件中去掉或停用", Data: "null"})
+		return
+	}
+
+	if _, _, err := task.Delete("id=" + strconv.Itoa(task.ID)); err != nil {
+		c.JSON(http.StatusInternalServerError, util.APIResponse{Code: http.StatusInternalServerError, Message: "删除计划任务失败", Data: err.Error()})
+		return
+	}
+	tasks.Unschedule(task.ID)
+	tasks.PublishReload()
+	log.InfoLogger.Printf("计划任务删除成功,管理员id=%d,任务id=%d,名称=%s", adminID, task.ID, task.Name)
+
+	c.JSON(http.StatusOK, util.APIResponse{Code: http.StatusOK, Message: "计划任务删除成功"})
+}
+
+// GetScheduledTaskList 获取计划任务列表
+// @Summary 获取计划任务列表
+// @Description 获取全部计划任务及下一次执行时间，同时返回可用的任务类型
+// @Tags admin
+// @Produce application/json
+// @Param LoginToken header string true "认证Token"
+// @Success 200 {object} util.APIResponse{code=int,message=string,data=interface{}} "获取计划任务列表成功"
+// @Failure 500 {object} util.APIResponse{code=int,message=string,data=interface{}} "获取计划任务列表失败"
+// @Router /admin/tasks/schedule/list [get]
+func GetScheduledTaskList(c *gin.Context) {
+	loginToken := c.GetHeader("LoginToken")
+	if _, err := admin.GetAdminIDFromToken(loginToken); err != nil {
+		c.JSON(http.StatusUnauthorized, util.APIResponse{Code: http.StatusUnauthorized, Message: "无权限进行此操作", Data: err.Error()})
+		return
+	}
+
+	list, code, err := mydb.Tables.ScheduledTask.Select(mydb.QueryParams{OrderBy: "id ASC"})
+	if err != nil && code != 200 {
+		c.JSON(http.StatusInternalServerError, util.APIResponse{Code: http.StatusInternalServerError, Message: "获取计划任务列表失败", Data: err.Error()})
+		return
+	}
+
+	items := make([]ScheduledTaskItem, 0, len(list))
+	for _, task := range list {
+		item := ScheduledTaskItem{StructScheduledTask: task}
+		if next, ok := tasks.NextRun(task.ID); ok {
+			item.Next_run_time = next.Unix()
+		}
+		items = append(items, item)
+	}
+
+	c.JSON(http.StatusOK, util.APIResponse{Code: http.StatusOK, Message: "获取计划任务列表成功", Data: map[string]interface{}{
+		"list":  items,
+		"types": tasks.Types(),
+	}})
+}
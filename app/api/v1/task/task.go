@@ -0,0 +1,133 @@
+package task
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"nav-web-site/app/api/v1/admin"
+	"nav-web-site/app/tasks"
+	"nav-web-site/config"
+	"nav-web-site/mydb"
+	"nav-web-site/util"
+	"nav-web-site/util/log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TaskRunListData 任务执行记录列表
+type TaskRunListData struct {
+	Total    int                  `json:"total"`
+	Page     int                  `json:"page"`
+	PageSize int                  `json:"page_size"`
+	List     []mydb.StructTaskRun `json:"list"`
+}
+
+// GetTaskRuns 获取任务执行记录
+// @Summary 获取任务执行记录
+// @Description 按开始时间倒序列出计划任务和手动触发任务的执行记录，可按任务类型和状态筛选
+// @Tags admin
+// @Produce application/json
+// @Param LoginToken header string true "认证Token"
+// @Param task_type query string false "任务类型"
+// @Param status query string false "执行状态：running、success、failed"
+// @Param page query int false "页码"
+// @Param page_size query int false "每页数量"
+// @Success 200 {object} util.APIResponse{code=int,message=string,data=TaskRunListData} "获取任务执行记录成功"
+// @Failure 500 {object} util.APIResponse{code=int,message=string,data=interface{}} "获取任务执行记录失败"
+// @Router /admin/tasks/runs [get]
+func GetTaskRuns(c *gin.Context) {
+	loginToken := c.GetHeader("LoginToken")
+	if _, err := admin.GetAdminIDFromToken(loginToken); err != nil {
+		c.JSON(http.StatusUnauthorized, util.APIResponse{Code: http.StatusUnauthorized, Message: "无权限进行此操作", Data: err.Error()})
+		return
+	}
+
+	page, err := strconv.Atoi(c.Query("page"))
+	if err != nil || page <= 0 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(c.Query("page_size"))
+	if err != nil || pageSize <= 0 {
+		pageSize = 20
+	}
+
+	var conditions []string
+	if taskType := c.Query("task_type"); taskType != "" {
+		conditions = append(conditions, fmt.Sprintf("task_type='%s'", mydb.EscapeString(taskType)))
+	}
+	if status := c.Query("status"); status != "" {
+		conditions = append(conditions, fmt.Sprintf("status='%s'", mydb.EscapeString(status)))
+	}
+	condition := strings.Join(conditions, " AND ")
+
+	total, err := mydb.GenericCount(mydb.Tables.TaskRun.GetTableName(), condition, config.Config.MySQL.TablePrefix, "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, util.APIResponse{Code: http.StatusInternalServerError, Message: "获取任务执行记录失败", Data: err.Error()})
+		return
+	}
+
+	runs, code, err := mydb.Tables.TaskRun.Select(mydb.QueryParams{
+		Condition: condition,
+		OrderBy:   "id DESC",
+		Limit:     pageSize,
+		Page:      page,
+		PageSize:  pageSize,
+	})
+	if err != nil && code != 200 {
+		c.JSON(http.StatusInternalServerError, util.APIResponse{Code: http.StatusInternalServerError, Message: "获取任务执行记录失败", Data: err.Error()})
+		return
+	}
+	if runs == nil {
+		runs = []mydb.StructTaskRun{}
+	}
+
+	c.JSON(http.StatusOK, util.APIResponse{Code: http.StatusOK, Message: "获取任务执行记录成功", Data: TaskRunListData{Total: total, Page: page, PageSize: pageSize, List: runs}})
+}
+
+// RunTask 立即执行任务
+// @Summary 立即执行任务
+// @Description 在后台立即执行指定类型的任务（计划任务或新闻源名称），返回本次执行记录，结果通过执行记录查看
+// @Tags admin
+// @Produce application/json
+// @Param LoginToken header string true "认证Token"
+// @Param type path string true "任务类型，如 upload_gc、news163"
+// @Param params formData string false "传给任务的JSON参数"
+// @Success 202 {object} util.APIResponse{code=int,message=string,data=mydb.StructTaskRun} "任务已开始执行"
+// @Failure 400 {object} util.APIResponse{code=int,message=string,data=interface{}} "请求参数错误"
+// @Failure 404 {object} util.APIResponse{code=int,message=string,data=interface{}} "未知的任务类型"
+// @Failure 409 {object} util.APIResponse{code=int,message=string,data=interface{}} "任务正在执行"
+// @Router /admin/tasks/{type}/run [post]
+func RunTask(c *gin.Context) {
+	loginToken := c.GetHeader("LoginToken")
+	adminID, err := admin.GetAdminIDFromToken(loginToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, util.APIResponse{Code: http.StatusUnauthorized, Message: "无权限进行此操作", Data: err.Error()})
+		return
+	}
+
+	taskType := c.Param("type")
+	params := c.PostForm("params")
+	if params != "" && !json.Valid([]byte(params)) {
+		c.JSON(http.StatusBadRequest, util.APIResponse{Code: http.StatusBadRequest, Message: "参数必须是JSON", Data: "null"})
+		return
+	}
+	run, err := tasks.Start(tasks.Context(), taskType, tasks.TriggerManual, params)
+	if errors.Is(err, tasks.ErrUnknownTask) {
+		c.JSON(http.StatusNotFound, util.APIResponse{Code: http.StatusNotFound, Message: "未知的任务类型", Data: tasks.Types()})
+		return
+	}
+	if errors.Is(err, tasks.ErrTaskRunning) {
+		c.JSON(http.StatusConflict, util.APIResponse{Code: http.StatusConflict, Message: "任务正在执行", Data: "null"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, util.APIResponse{Code: http.StatusInternalServerError, Message: "执行任务失败", Data: err.Error()})
+		return
+	}
+	log.InfoLogger.Printf("管理员手动执行任务,管理员id=%d,任务=%s,执行记录id=%d", adminID, taskType, run.ID)
+
+	c.JSON(http.StatusAccepted, util.APIResponse{Code: http.StatusAccepted, Message: "任务已开始执行", Data: run})
+}
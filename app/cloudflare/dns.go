@@ -2,17 +2,21 @@ package cloudflare
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"net/http"
+	"net/url"
+	"strconv"
 )
 
 const cloudflareAPIURL = "https://api.cloudflare.com/client/v4"
 
 // DNSRecord 表示一个DNS记录
 type DNSRecord struct {
-	ID      string `json:"ID"`
+	ID      string `json:"id,omitempty"`
 	Type    string `json:"type"`
 	Name    string `json:"name"`
 	Content string `json:"content"`
@@ -24,183 +28,149 @@ type Zone struct {
 	Name string `json:"name"`
 }
 
-type ListZonesResponse struct {
-	Success bool          `json:"success"`
-	Errors  []interface{} `json:"errors"`
-	Result  []Zone        `json:"result"`
-}
+// ErrZoneNotFound 账号下没有该域名的 zone
+var ErrZoneNotFound = errors.New("no zones found")
 
 // CloudflareResponse 表示Cloudflare API的响应
 type CloudflareResponse struct {
-	Success bool            `json:"success"`
-	Errors  []interface{}   `json:"errors"`
-	Result  json.RawMessage `json:"result"`
+	Success    bool            `json:"success"`
+	Errors     []interface{}   `json:"errors"`
+	Result     json.RawMessage `json:"result"`
+	ResultInfo struct {
+		Page       int `json:"page"`
+		TotalPages int `json:"total_pages"`
+	} `json:"result_info"`
 }
 
-// EmptyFunction 是一个空函数，用于在其他包中调用
-func EmptyFunction() {
-	// 这里什么都不做
+// Client Cloudflare API 客户端，BaseURL 和 HTTPClient 为空时使用官方地址和默认客户端
+type Client struct {
+	APIToken   string
+	BaseURL    string
+	HTTPClient *http.Client
 }
 
-// AddDNSRecord 添加DNS记录
-func AddDNSRecord(apiKey, domain string, record DNSRecord) error {
-	zoneID, err := GetZoneID(apiKey, domain)
-	if err != nil {
-		return err
-	}
-	url := fmt.Sprintf("%s/zones/%s/dns_records", cloudflareAPIURL, zoneID)
-	return sendRequest("POST", url, apiKey, record)
+// NewClient 创建使用 API Token 认证的客户端
+func NewClient(apiToken string) *Client {
+	return &Client{APIToken: apiToken}
 }
 
-// UpdateDNSRecord 更新DNS记录
-func UpdateDNSRecord(apiKey, domain, recordID string, record DNSRecord) error {
-	zoneID, err := GetZoneID(apiKey, domain)
-	if err != nil {
-		return err
+// GetZone 按域名精确查询托管域，找不到时返回错误
+func (c *Client) GetZone(ctx context.Context, domain string) (Zone, error) {
+	var zones []Zone
+	if _, err := c.do(ctx, http.MethodGet, "/zones?name="+url.QueryEscape(domain), nil, &zones); err != nil {
+		return Zone{}, err
 	}
-	url := fmt.Sprintf("%s/zones/%s/dns_records/%s", cloudflareAPIURL, zoneID, recordID)
-	return sendRequest("PUT", url, apiKey, record)
-}
-
-// DeleteDNSRecord 删除DNS记录
-func DeleteDNSRecord(apiKey, domain, recordID string) error {
-	zoneID, err := GetZoneID(apiKey, domain)
-	if err != nil {
-		return err
+	if len(zones) == 0 {
+		return Zone{}, fmt.Errorf("%w for domain: %s", ErrZoneNotFound, domain)
 	}
-	url := fmt.Sprintf("%s/zones/%s/dns_records/%s", cloudflareAPIURL, zoneID, recordID)
-	return sendRequest("DELETE", url, apiKey, nil)
+	return zones[0], nil
 }
 
-// GetZoneID 获取zoneID
-func GetZoneID(apiKey, domain string) (string, error) {
-	url := fmt.Sprintf("%s/zones?name=%s", cloudflareAPIURL, domain)
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return "", fmt.Errorf("failed to create request: %v", err)
-	}
-
-	req.Header.Set("Authorization", "Bearer "+apiKey)
-	req.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("failed to send request: %v", err)
-	}
-	defer resp.Body.Close()
-
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("failed to read response body: %v", err)
-	}
-
-	var listZonesResp ListZonesResponse
-	if err := json.Unmarshal(body, &listZonesResp); err != nil {
-		return "", fmt.Errorf("failed to unmarshal response: %v", err)
-	}
-
-	if !listZonesResp.Success {
-		return "", fmt.Errorf("request failed: %v", listZonesResp.Errors)
-	}
-
-	if len(listZonesResp.Result) == 0 {
-		return "", fmt.Errorf("no zones found for domain: %s", domain)
+// ListZones 列出账号下的全部托管域
+func (c *Client) ListZones(ctx context.Context) ([]Zone, error) {
+	var zones []Zone
+	for page := 1; ; page++ {
+		var pageZones []Zone
+		resp, err := c.do(ctx, http.MethodGet, fmt.Sprintf("/zones?per_page=50&page=%d", page), nil, &pageZones)
+		if err != nil {
+			return nil, err
+		}
+		zones = append(zones, pageZones...)
+		if page >= resp.ResultInfo.TotalPages {
+			return zones, nil
+		}
 	}
-
-	return listZonesResp.Result[0].ID, nil
 }
 
-// GetDNSRecordID 获取DNS记录ID
-func GetDNSRecordID(apiKey, domain string, record DNSRecord) (string, error) {
-	zoneID, err := GetZoneID(apiKey, domain)
-	if err != nil {
-		return "", err
-	}
-	url := fmt.Sprintf("%s/zones/%s/dns_records?type=%s&name=%s&content=%s", cloudflareAPIURL, zoneID, record.Type, record.Name, record.Content)
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return "", fmt.Errorf("failed to create request: %v", err)
-	}
-
-	req.Header.Set("Authorization", "Bearer "+apiKey)
-	req.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("failed to send request: %v", err)
+// ListDNSRecords 列出托管域的全部记录，query 可以带 type、name 等筛选条件
+func (c *Client) ListDNSRecords(ctx context.Context, zoneID string, query url.Values) ([]DNSRecord, error) {
+	if query == nil {
+		query = url.Values{}
+	}
+	query.Set("per_page", "100")
+	var records []DNSRecord
+	for page := 1; ; page++ {
+		query.Set("page", strconv.Itoa(page))
+		var pageRecords []DNSRecord
+		resp, err := c.do(ctx, http.MethodGet, fmt.Sprintf("/zones/%s/dns_records?%s", zoneID, query.Encode()), nil, &pageRecords)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, pageRecords...)
+		if page >= resp.ResultInfo.TotalPages {
+			return records, nil
+		}
 	}
-	defer resp.Body.Close()
+}
 
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("failed to read response body: %v", err)
-	}
+// AddDNSRecord 添加DNS记录
+func (c *Client) AddDNSRecord(ctx context.Context, zoneID string, record DNSRecord) (DNSRecord, error) {
+	var created DNSRecord
+	_, err := c.do(ctx, http.MethodPost, fmt.Sprintf("/zones/%s/dns_records", zoneID), record, &created)
+	return created, err
+}
 
-	var cloudflareResp CloudflareResponse
-	if err := json.Unmarshal(body, &cloudflareResp); err != nil {
-		return "", fmt.Errorf("failed to unmarshal response: %v", err)
-	}
+// UpdateDNSRecord 更新DNS记录
+func (c *Client) UpdateDNSRecord(ctx context.Context, zoneID, recordID string, record DNSRecord) error {
+	record.ID = ""
+	_, err := c.do(ctx, http.MethodPut, fmt.Sprintf("/zones/%s/dns_records/%s", zoneID, recordID), record, nil)
+	return err
+}
 
-	if !cloudflareResp.Success {
-		return "", fmt.Errorf("API request failed: %v", cloudflareResp.Errors)
-	}
+// DeleteDNSRecord 删除DNS记录
+func (c *Client) DeleteDNSRecord(ctx context.Context, zoneID, recordID string) error {
+	_, err := c.do(ctx, http.MethodDelete, fmt.Sprintf("/zones/%s/dns_records/%s", zoneID, recordID), nil, nil)
+	return err
+}
 
-	var dnsRecords []DNSRecord
-	if err := json.Unmarshal(cloudflareResp.Result, &dnsRecords); err != nil {
-		return "", fmt.Errorf("failed to unmarshal DNS records: %v", err)
+// do 发送请求并检查 success，result 不为空时把响应的 result 解析进去
+func (c *Client) do(ctx context.Context, method, path string, data interface{}, result interface{}) (*CloudflareResponse, error) {
+	baseURL := c.BaseURL
+	if baseURL == "" {
+		baseURL = cloudflareAPIURL
 	}
-
-	if len(dnsRecords) == 0 {
-		return "", nil
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
 	}
 
-	return dnsRecords[0].ID, nil
-}
-
-// sendRequest 发送HTTP请求
-func sendRequest(method, url, apiKey string, data interface{}) error {
-	client := &http.Client{}
-	var req *http.Request
-	var err error
-
+	var body io.Reader
 	if data != nil {
 		jsonData, err := json.Marshal(data)
 		if err != nil {
-			return fmt.Errorf("failed to marshal data: %v", err)
+			return nil, fmt.Errorf("failed to marshal data: %v", err)
 		}
-		req, err = http.NewRequest(method, url, bytes.NewBuffer(jsonData))
-	} else {
-		req, err = http.NewRequest(method, url, nil)
+		body = bytes.NewReader(jsonData)
 	}
-
+	req, err := http.NewRequestWithContext(ctx, method, baseURL+path, body)
 	if err != nil {
-		return fmt.Errorf("failed to create request: %v", err)
+		return nil, fmt.Errorf("failed to create request: %v", err)
 	}
-
-	req.Header.Set("Authorization", "Bearer "+apiKey)
+	req.Header.Set("Authorization", "Bearer "+c.APIToken)
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := client.Do(req)
+	resp, err := httpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to send request: %v", err)
+		return nil, fmt.Errorf("failed to send request: %v", err)
 	}
 	defer resp.Body.Close()
 
-	body, err := ioutil.ReadAll(resp.Body)
+	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return fmt.Errorf("failed to read response body: %v", err)
+		return nil, fmt.Errorf("failed to read response body: %v", err)
 	}
 
 	var cloudflareResp CloudflareResponse
-	if err := json.Unmarshal(body, &cloudflareResp); err != nil {
-		return fmt.Errorf("failed to unmarshal response: %v", err)
+	if err := json.Unmarshal(respBody, &cloudflareResp); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %v", err)
 	}
-
 	if !cloudflareResp.Success {
-		return fmt.Errorf("request failed: %v", cloudflareResp.Errors)
+		return &cloudflareResp, fmt.Errorf("request failed: %v", cloudflareResp.Errors)
 	}
-
-	return nil
+	if result != nil && len(cloudflareResp.Result) > 0 {
+		if err := json.Unmarshal(cloudflareResp.Result, result); err != nil {
+			return &cloudflareResp, fmt.Errorf("failed to unmarshal result: %v", err)
+		}
+	}
+	return &cloudflareResp, nil
 }
@@ -0,0 +1,281 @@
+// Package ddns 动态域名：检测本机的公网地址，变化时更新 A/AAAA 记录，并把每次变化写入 ddns_history
+package ddns
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"nav-web-site/app/dns"
+	"nav-web-site/config"
+	"nav-web-site/mydb"
+	"nav-web-site/util"
+	"nav-web-site/util/log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	StatusSuccess = "success"
+	StatusFailed  = "failed"
+)
+
+var (
+	defaultIPv4Resolvers = []string{"https://api.ipify.org", "https://ipv4.icanhazip.com", "https://4.ipw.cn"}
+	defaultIPv6Resolvers = []string{"https://api6.ipify.org", "https://ipv6.icanhazip.com", "https://6.ipw.cn"}
+
+	verifiedMu sync.Mutex
+	verified   = make(map[string]time.Time) // 记录最近一次和服务商上的记录核对过的时间，按类型和域名
+)
+
+// verifyInterval 地址没变时和服务商上的记录核对的间隔，0 表示不核对
+func verifyInterval() time.Duration {
+	switch interval := config.Config.DDNS.VerifyInterval; {
+	case interval < 0:
+		return 0
+	case interval == 0:
+		return time.Hour
+	default:
+		return time.Duration(interval) * time.Minute
+	}
+}
+
+// verifyDue 是否该和服务商上的记录核对了。本进程还没核对过的记录（如刚启动）也要核对
+func verifyDue(record config.DDNSRecord) bool {
+	interval := verifyInterval()
+	if interval == 0 {
+		return false
+	}
+	verifiedMu.Lock()
+	defer verifiedMu.Unlock()
+	last, ok := verified[record.Type+" "+record.Name]
+	return !ok || time.Since(last) >= interval
+}
+
+// markVerified 记下核对的时间
+func markVerified(record config.DDNSRecord) {
+	verifiedMu.Lock()
+	defer verifiedMu.Unlock()
+	verified[record.Type+" "+record.Name] = time.Now()
+}
+
+// Params 计划任务的参数，records 为空时使用配置里的 ddns.records
+type Params struct {
+	Records []config.DDNSRecord `json:"records"`
+}
+
+// Result 一次检查的计数
+type Result struct {
+	Checked int // 检查的记录数
+	Updated int // 已更新的记录数
+	Skipped int // 地址没有变化的记录数
+	Failed  int // 检测地址或更新失败的记录数
+}
+
+// Run 检测公网地址并更新地址变化了的记录，单条记录失败不影响其他记录
+func Run(ctx context.Context, params json.RawMessage) (Result, error) {
+	var p Params
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &p); err != nil {
+			return Result{}, fmt.Errorf("参数格式错误: %v", err)
+		}
+	}
+	records := p.Records
+	if len(records) == 0 {
+		records = config.Config.DDNS.Records
+	}
+	if len(records) == 0 {
+		return Result{}, fmt.Errorf("没有配置需要更新的记录")
+	}
+
+	var result Result
+	var lastErr error
+	addresses := make(map[string]string) // 本次检测到的地址，按记录类型缓存
+	for _, record := range records {
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+		record.Name = dns.Normalize(record.Name)
+		record.Type = strings.ToUpper(record.Type)
+		if record.Type == "" {
+			record.Type = "A"
+		}
+		result.Checked++
+
+		ip, ok := addresses[record.Type]
+		if !ok {
+			var err error
+			ip, err = PublicIP(ctx, record.Type)
+			if err != nil {
+				result.Failed++
+				lastErr = err
+				log.ErrorLogger.Printf("检测公网地址失败,类型=%s: %v", record.Type, err)
+				continue
+			}
+			addresses[record.Type] = ip
+		}
+
+		updated, err := update(ctx, record, ip)
+		switch {
+		case err != nil:
+			result.Failed++
+			lastErr = err
+			log.ErrorLogger.Printf("更新动态域名失败,%s %s: %v", record.Type, record.Name, err)
+		case updated:
+			result.Updated++
+		default:
+			result.Skipped++
+		}
+	}
+	if result.Failed > 0 {
+		return result, fmt.Errorf("%d 条记录更新失败: %v", result.Failed, lastErr)
+	}
+	return result, nil
+}
+
+// update 地址和上次已知的不同时更新记录，返回是否发生了变化。记录已经是这个地址时只保存为已知地址。
+// 地址没变时按 ddns.verify_interval 定期和服务商上的记录核对，记录在服务商那边被改掉时改回来
+func update(ctx context.Context, record config.DDNSRecord, ip string) (bool, error) {
+	last, err := lastKnown(record.Name, record.Type)
+	unchanged := err == nil && last.New_ip == ip
+	if unchanged && !verifyDue(record) {
+		return false, nil
+	}
+
+	history := mydb.StructDDNSHistory{
+		Name:        record.Name,
+		Record_type: record.Type,
+		Old_ip:      last.New_ip,
+		New_ip:      ip,
+		Status:      StatusSuccess,
+		Create_time: util.GetTimestamp(10),
+	}
+	changed, err := apply(ctx, record, ip, &history)
+	if err == nil {
+		markVerified(record)
+		if unchanged && !changed {
+			// 核对一致，已知地址没变，不用再记一次
+			return false, nil
+		}
+	} else {
+		history.Status = StatusFailed
+		history.Error = util.Truncate(err.Error(), 1024)
+	}
+	if _, _, insertErr := history.Insert([]mydb.StructDDNSHistory{history}); insertErr != nil {
+		log.ErrorLogger.Printf("保存动态域名记录失败,%s: %v", record.Name, insertErr)
+	}
+	if err == nil && changed && unchanged {
+		log.InfoLogger.Printf("动态域名在服务商上被改成了其他地址,已改回,%s %s: %s -> %s", record.Type, record.Name, history.Old_ip, ip)
+	} else if err == nil && changed {
+		log.InfoLogger.Printf("动态域名已更新,%s %s: %s -> %s", record.Type, record.Name, history.Old_ip, ip)
+	}
+	return changed, err
+}
+
+// apply 把记录改成 ip，记录不存在时新增，返回是否改动了服务商上的记录
+func apply(ctx context.Context, record config.DDNSRecord, ip string, history *mydb.StructDDNSHistory) (bool, error) {
+	provider, err := dns.ForDomain(ctx, record.Provider, record.Name)
+	if err != nil {
+		return false, err
+	}
+	history.Provider = provider.Name()
+	zone, err := provider.FindZone(ctx, record.Name)
+	if err != nil {
+		return false, err
+	}
+	existing, err := provider.List(ctx, zone, dns.Record{Type: record.Type, Name: record.Name})
+	if err != nil {
+		return false, err
+	}
+
+	if len(existing) == 0 {
+		_, err := provider.Create(ctx, zone, dns.Record{Type: record.Type, Name: record.Name, Content: ip, TTL: record.TTL})
+		return err == nil, err
+	}
+	// 改动前的地址以服务商上的记录为准，记录在服务商那边被改过时和上次已知的地址不同
+	current := existing[0]
+	history.Old_ip = current.Content
+	if current.Content == ip {
+		return false, nil
+	}
+	current.Content = ip
+	if record.TTL > 0 {
+		current.TTL = record.TTL
+	}
+	return true, provider.Update(ctx, zone, current)
+}
+
+// lastKnown 最近一次成功保存的地址
+func lastKnown(name, recordType string) (mydb.StructDDNSHistory, error) {
+	return mydb.Tables.DDNSHistory.Find(mydb.QueryParams{
+		Condition: fmt.Sprintf("name='%s' AND record_type='%s' AND status='%s'",
+			mydb.EscapeString(name), mydb.EscapeString(recordType), StatusSuccess),
+		OrderBy: "id DESC",
+		Limit:   1,
+	})
+}
+
+// PublicIP 依次请求配置的查询地址，返回第一个合法的公网地址。A 记录只走 IPv4 连接，AAAA 只走 IPv6
+func PublicIP(ctx context.Context, recordType string) (string, error) {
+	network, resolvers := "tcp4", config.Config.DDNS.IPv4Resolvers
+	if len(resolvers) == 0 {
+		resolvers = defaultIPv4Resolvers
+	}
+	if recordType == "AAAA" {
+		network, resolvers = "tcp6", config.Config.DDNS.IPv6Resolvers
+		if len(resolvers) == 0 {
+			resolvers = defaultIPv6Resolvers
+		}
+	} else if recordType != "A" {
+		return "", fmt.Errorf("unsupported record type: %s", recordType)
+	}
+
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = func(ctx context.Context, _, addr string) (net.Conn, error) {
+		return dialer.DialContext(ctx, network, addr)
+	}
+	client := &http.Client{Transport: transport, Timeout: 10 * time.Second}
+	defer transport.CloseIdleConnections()
+
+	var lastErr error
+	for _, resolver := range resolvers {
+		ip, err := queryIP(ctx, client, resolver)
+		if err == nil && (recordType == "AAAA") != (ip.To4() != nil) && ip.IsGlobalUnicast() && !ip.IsPrivate() {
+			return ip.String(), nil
+		}
+		if err == nil {
+			err = fmt.Errorf("%s 返回的不是公网 %s 地址: %s", resolver, recordType, ip)
+		}
+		lastErr = err
+	}
+	return "", lastErr
+}
+
+// queryIP 请求查询地址，响应是纯文本的 IP
+func queryIP(ctx context.Context, client *http.Client, resolver string) (net.IP, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, resolver, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %s", resolver, resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 256))
+	if err != nil {
+		return nil, err
+	}
+	ip := net.ParseIP(strings.TrimSpace(string(body)))
+	if ip == nil {
+		return nil, fmt.Errorf("%s 返回的不是 IP 地址", resolver)
+	}
+	return ip, nil
+}
@@ -0,0 +1,118 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"nav-web-site/app/aliyun"
+	"strings"
+)
+
+// Aliyun 通过阿里云云解析 API 管理记录
+type Aliyun struct {
+	Client *aliyun.Client
+}
+
+// NewAliyun 创建使用 AccessKey 认证的阿里云服务商
+func NewAliyun(accessKeyID, accessKeySecret string) *Aliyun {
+	return &Aliyun{Client: aliyun.NewClient(accessKeyID, accessKeySecret)}
+}
+
+func (p *Aliyun) Name() string {
+	return "aliyun"
+}
+
+func (p *Aliyun) Zones(ctx context.Context) ([]Zone, error) {
+	items, err := p.Client.ListDomains(ctx)
+	if err != nil {
+		return nil, err
+	}
+	zones := make([]Zone, 0, len(items))
+	for _, item := range items {
+		zones = append(zones, Zone{ID: item.DomainId, Name: item.DomainName})
+	}
+	return zones, nil
+}
+
+func (p *Aliyun) FindZone(ctx context.Context, fqdn string) (Zone, error) {
+	var lastErr error
+	for _, candidate := range Candidates(fqdn) {
+		domainID, err := p.Client.GetDomain(ctx, candidate)
+		if err == nil {
+			return Zone{ID: domainID, Name: candidate}, nil
+		}
+		if ctx.Err() != nil {
+			return Zone{}, ctx.Err()
+		}
+		var apiErr *aliyun.APIError
+		if !errors.As(err, &apiErr) || !strings.HasPrefix(apiErr.Code, "InvalidDomainName") {
+			lastErr = err
+		}
+	}
+	if lastErr != nil {
+		return Zone{}, errors.Join(ErrZoneNotFound, lastErr)
+	}
+	return Zone{}, ErrZoneNotFound
+}
+
+func (p *Aliyun) List(ctx context.Context, zone Zone, filter Record) ([]Record, error) {
+	query := aliyun.DNSRecord{Type: strings.ToUpper(filter.Type)}
+	if filter.Name != "" {
+		query.Name = relativeName(filter.Name, zone.Name)
+	}
+	items, err := p.Client.ListDNSRecords(ctx, zone.Name, query)
+	if err != nil {
+		return nil, err
+	}
+	var records []Record
+	for _, item := range items {
+		record := Record{ID: item.RecordId, Type: item.Type, Name: absoluteName(item.Name, zone.Name), Content: item.Content, TTL: item.TTL}
+		// RRKeyWord 是模糊匹配，这里再精确筛选
+		if matches(record, filter) {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+func (p *Aliyun) Create(ctx context.Context, zone Zone, record Record) (Record, error) {
+	recordID, err := p.Client.AddDNSRecord(ctx, zone.Name, p.toRecord(zone, record))
+	if err != nil {
+		return record, err
+	}
+	record.ID = recordID
+	return record, nil
+}
+
+func (p *Aliyun) Update(ctx context.Context, zone Zone, record Record) error {
+	return p.Client.UpdateDNSRecord(ctx, record.ID, p.toRecord(zone, record))
+}
+
+func (p *Aliyun) Delete(ctx context.Context, zone Zone, recordID string) error {
+	return p.Client.DeleteDNSRecord(ctx, recordID)
+}
+
+// toRecord 转成阿里云的记录，TTL 为 0 时用阿里云的默认值 600
+func (p *Aliyun) toRecord(zone Zone, record Record) aliyun.DNSRecord {
+	ttl := record.TTL
+	if ttl <= 0 {
+		ttl = 600
+	}
+	return aliyun.DNSRecord{Type: strings.ToUpper(record.Type), Name: relativeName(record.Name, zone.Name), Content: record.Content, TTL: ttl}
+}
+
+// relativeName 完整域名转成相对托管域的主机记录，托管域本身为 @
+func relativeName(name, zone string) string {
+	name, zone = Normalize(name), Normalize(zone)
+	if name == zone {
+		return "@"
+	}
+	return strings.TrimSuffix(name, "."+zone)
+}
+
+// absoluteName 主机记录转成完整域名
+func absoluteName(rr, zone string) string {
+	if rr == "@" || rr == "" {
+		return Normalize(zone)
+	}
+	return Normalize(rr + "." + zone)
+}
@@ -0,0 +1,148 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"nav-web-site/app/aliyun"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+)
+
+// fakeAliyun 模拟阿里云云解析 API：example.com 是托管域，其余域名返回 InvalidDomainName.NoExist
+type fakeAliyun struct {
+	mu       sync.Mutex
+	requests []url.Values
+}
+
+func (f *fakeAliyun) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	f.mu.Lock()
+	f.requests = append(f.requests, query)
+	f.mu.Unlock()
+
+	switch query.Get("Action") {
+	case "DescribeDomainInfo":
+		if query.Get("DomainName") != "example.com" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"Code":"InvalidDomainName.NoExist","Message":"The specified domain name does not exist."}`))
+			return
+		}
+		w.Write([]byte(`{"DomainId":"d-1","DomainName":"example.com"}`))
+	case "DescribeDomainRecords":
+		// RRKeyWord 是模糊匹配，www 也会匹配到 www2
+		w.Write([]byte(`{"TotalCount":3,"DomainRecords":{"Record":[
+			{"RecordId":"1","RR":"www","Type":"A","Value":"1.2.3.4","TTL":600},
+			{"RecordId":"2","RR":"www2","Type":"A","Value":"1.2.3.5","TTL":600},
+			{"RecordId":"3","RR":"@","Type":"TXT","Value":"v=spf1 -all","TTL":600}]}}`))
+	case "AddDomainRecord":
+		w.Write([]byte(`{"RequestId":"req","RecordId":"new-1"}`))
+	default:
+		w.Write([]byte(`{"RequestId":"req"}`))
+	}
+}
+
+func (f *fakeAliyun) last() url.Values {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.requests[len(f.requests)-1]
+}
+
+func newFakeAliyun(t *testing.T) (*Aliyun, *fakeAliyun) {
+	t.Helper()
+	fake := &fakeAliyun{}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	provider := NewAliyun("id", "secret")
+	provider.Client.BaseURL = server.URL
+	provider.Client.HTTPClient = server.Client()
+	return provider, fake
+}
+
+func TestAliyunFindZone(t *testing.T) {
+	provider, _ := newFakeAliyun(t)
+	ctx := context.Background()
+
+	zone, err := provider.FindZone(ctx, "_acme-challenge.www.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if zone.ID != "d-1" || zone.Name != "example.com" {
+		t.Errorf("FindZone = %+v", zone)
+	}
+
+	// 不存在的域名只返回 ErrZoneNotFound，不带上 InvalidDomainName 错误
+	_, err = provider.FindZone(ctx, "www.example.org")
+	if !errors.Is(err, ErrZoneNotFound) {
+		t.Errorf("FindZone 错误 = %v, want ErrZoneNotFound", err)
+	}
+	var apiErr *aliyun.APIError
+	if errors.As(err, &apiErr) {
+		t.Errorf("InvalidDomainName 不应作为错误返回: %v", err)
+	}
+}
+
+func TestAliyunList(t *testing.T) {
+	provider, fake := newFakeAliyun(t)
+	zone := Zone{ID: "d-1", Name: "example.com"}
+
+	records, err := provider.List(context.Background(), zone, Record{Type: "a", Name: "WWW.example.com."})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0] != (Record{ID: "1", Type: "A", Name: "www.example.com", Content: "1.2.3.4", TTL: 600}) {
+		t.Errorf("List = %+v, want 只有 www 的 A 记录", records)
+	}
+	if query := fake.last(); query.Get("RRKeyWord") != "www" || query.Get("TypeKeyWord") != "A" {
+		t.Errorf("查询参数 = %v, want RRKeyWord=www TypeKeyWord=A", query)
+	}
+
+	// 托管域本身的记录转成完整域名
+	records, err = provider.List(context.Background(), zone, Record{Type: "TXT", Name: "example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].Name != "example.com" {
+		t.Errorf("List = %+v, want @ 记录", records)
+	}
+	if query := fake.last(); query.Get("RRKeyWord") != "@" {
+		t.Errorf("RRKeyWord = %q, want @", query.Get("RRKeyWord"))
+	}
+}
+
+func TestAliyunCreateUpdateDelete(t *testing.T) {
+	provider, fake := newFakeAliyun(t)
+	ctx := context.Background()
+	zone := Zone{ID: "d-1", Name: "example.com"}
+
+	created, err := provider.Create(ctx, zone, Record{Type: "txt", Name: "_acme-challenge.example.com", Content: "token"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.ID != "new-1" {
+		t.Errorf("Create 返回的 ID = %q", created.ID)
+	}
+	query := fake.last()
+	if query.Get("Action") != "AddDomainRecord" || query.Get("DomainName") != "example.com" ||
+		query.Get("RR") != "_acme-challenge" || query.Get("Type") != "TXT" || query.Get("Value") != "token" || query.Get("TTL") != "600" {
+		t.Errorf("AddDomainRecord 参数 = %v", query)
+	}
+
+	if err := provider.Update(ctx, zone, Record{ID: "1", Type: "A", Name: "example.com", Content: "9.9.9.9", TTL: 120}); err != nil {
+		t.Fatal(err)
+	}
+	query = fake.last()
+	if query.Get("Action") != "UpdateDomainRecord" || query.Get("RecordId") != "1" ||
+		query.Get("RR") != "@" || query.Get("Value") != "9.9.9.9" || query.Get("TTL") != "120" {
+		t.Errorf("UpdateDomainRecord 参数 = %v", query)
+	}
+
+	if err := provider.Delete(ctx, zone, "1"); err != nil {
+		t.Fatal(err)
+	}
+	if query := fake.last(); query.Get("Action") != "DeleteDomainRecord" || query.Get("RecordId") != "1" {
+		t.Errorf("DeleteDomainRecord 参数 = %v", query)
+	}
+}
@@ -0,0 +1,103 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"nav-web-site/app/cloudflare"
+	"net/url"
+	"strings"
+)
+
+// Cloudflare 通过 Cloudflare API 管理记录
+type Cloudflare struct {
+	Client *cloudflare.Client
+}
+
+// NewCloudflare 创建使用 API Token 认证的 Cloudflare 服务商
+func NewCloudflare(apiToken string) *Cloudflare {
+	return &Cloudflare{Client: cloudflare.NewClient(apiToken)}
+}
+
+func (p *Cloudflare) Name() string {
+	return "cloudflare"
+}
+
+func (p *Cloudflare) Zones(ctx context.Context) ([]Zone, error) {
+	items, err := p.Client.ListZones(ctx)
+	if err != nil {
+		return nil, err
+	}
+	zones := make([]Zone, 0, len(items))
+	for _, item := range items {
+		zones = append(zones, Zone{ID: item.ID, Name: item.Name})
+	}
+	return zones, nil
+}
+
+func (p *Cloudflare) FindZone(ctx context.Context, fqdn string) (Zone, error) {
+	var lastErr error
+	for _, candidate := range Candidates(fqdn) {
+		zone, err := p.Client.GetZone(ctx, candidate)
+		if err == nil {
+			return Zone{ID: zone.ID, Name: zone.Name}, nil
+		}
+		if ctx.Err() != nil {
+			return Zone{}, ctx.Err()
+		}
+		if !errors.Is(err, cloudflare.ErrZoneNotFound) {
+			lastErr = err
+		}
+	}
+	if lastErr != nil {
+		return Zone{}, errors.Join(ErrZoneNotFound, lastErr)
+	}
+	return Zone{}, ErrZoneNotFound
+}
+
+func (p *Cloudflare) List(ctx context.Context, zone Zone, filter Record) ([]Record, error) {
+	query := url.Values{}
+	if filter.Type != "" {
+		query.Set("type", strings.ToUpper(filter.Type))
+	}
+	if filter.Name != "" {
+		query.Set("name", Normalize(filter.Name))
+	}
+	items, err := p.Client.ListDNSRecords(ctx, zone.ID, query)
+	if err != nil {
+		return nil, err
+	}
+	var records []Record
+	for _, item := range items {
+		record := Record{ID: item.ID, Type: item.Type, Name: item.Name, Content: item.Content, TTL: item.TTL}
+		if matches(record, filter) {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+func (p *Cloudflare) Create(ctx context.Context, zone Zone, record Record) (Record, error) {
+	created, err := p.Client.AddDNSRecord(ctx, zone.ID, p.toRecord(record))
+	if err != nil {
+		return record, err
+	}
+	record.ID = created.ID
+	return record, nil
+}
+
+func (p *Cloudflare) Update(ctx context.Context, zone Zone, record Record) error {
+	return p.Client.UpdateDNSRecord(ctx, zone.ID, record.ID, p.toRecord(record))
+}
+
+func (p *Cloudflare) Delete(ctx context.Context, zone Zone, recordID string) error {
+	return p.Client.DeleteDNSRecord(ctx, zone.ID, recordID)
+}
+
+// toRecord 转成 Cloudflare 的记录，TTL 为 0 时用 1（自动）
+func (p *Cloudflare) toRecord(record Record) cloudflare.DNSRecord {
+	ttl := record.TTL
+	if ttl <= 0 {
+		ttl = 1
+	}
+	return cloudflare.DNSRecord{Type: strings.ToUpper(record.Type), Name: Normalize(record.Name), Content: record.Content, TTL: ttl}
+}
@@ -0,0 +1,182 @@
+package dns
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+)
+
+// cfRequest 记录 Cloudflare 收到的一次请求
+type cfRequest struct {
+	Method string
+	Path   string
+	Query  url.Values
+	Body   map[string]interface{}
+}
+
+// fakeCloudflare 模拟 Cloudflare API：example.com 是托管域 z-1，记录列表分两页返回
+type fakeCloudflare struct {
+	mu       sync.Mutex
+	requests []cfRequest
+}
+
+func (f *fakeCloudflare) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	request := cfRequest{Method: r.Method, Path: r.URL.Path, Query: r.URL.Query()}
+	if body, _ := io.ReadAll(r.Body); len(body) > 0 {
+		json.Unmarshal(body, &request.Body)
+	}
+	f.mu.Lock()
+	f.requests = append(f.requests, request)
+	f.mu.Unlock()
+
+	if r.Header.Get("Authorization") != "Bearer token" {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"success":false,"errors":[{"code":9109,"message":"Invalid access token"}]}`))
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/zones":
+		if request.Query.Get("name") == "example.com" {
+			w.Write([]byte(`{"success":true,"result":[{"id":"z-1","name":"example.com"}],"result_info":{"page":1,"total_pages":1}}`))
+		} else {
+			w.Write([]byte(`{"success":true,"result":[],"result_info":{"page":1,"total_pages":0}}`))
+		}
+	case r.Method == http.MethodGet && r.URL.Path == "/zones/z-1/dns_records":
+		if request.Query.Get("page") == "1" {
+			w.Write([]byte(`{"success":true,"result":[{"id":"r-1","type":"A","name":"www.example.com","content":"1.2.3.4","ttl":1}],"result_info":{"page":1,"total_pages":2}}`))
+		} else {
+			w.Write([]byte(`{"success":true,"result":[{"id":"r-2","type":"A","name":"www.example.com","content":"1.2.3.5","ttl":300}],"result_info":{"page":2,"total_pages":2}}`))
+		}
+	case r.Method == http.MethodPost && r.URL.Path == "/zones/z-1/dns_records":
+		created := request.Body
+		created["id"] = "r-new"
+		body, _ := json.Marshal(map[string]interface{}{"success": true, "result": created})
+		w.Write(body)
+	case (r.Method == http.MethodPut || r.Method == http.MethodDelete) && r.URL.Path == "/zones/z-1/dns_records/r-1":
+		w.Write([]byte(`{"success":true,"result":{"id":"r-1"}}`))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"success":false,"errors":[{"code":7003,"message":"Could not route"}]}`))
+	}
+}
+
+func (f *fakeCloudflare) last() cfRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.requests[len(f.requests)-1]
+}
+
+func newFakeCloudflare(t *testing.T, token string) (*Cloudflare, *fakeCloudflare) {
+	t.Helper()
+	fake := &fakeCloudflare{}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	provider := NewCloudflare(token)
+	provider.Client.BaseURL = server.URL
+	provider.Client.HTTPClient = server.Client()
+	return provider, fake
+}
+
+func TestCloudflareFindZone(t *testing.T) {
+	provider, fake := newFakeCloudflare(t, "token")
+	ctx := context.Background()
+
+	zone, err := provider.FindZone(ctx, "_acme-challenge.www.example.com.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if zone != (Zone{ID: "z-1", Name: "example.com"}) {
+		t.Errorf("FindZone = %+v", zone)
+	}
+	// 从完整域名开始逐级向上查
+	if len(fake.requests) != 3 || fake.requests[0].Query.Get("name") != "_acme-challenge.www.example.com" {
+		t.Errorf("查询次数 = %d, 第一次 = %v", len(fake.requests), fake.requests[0].Query)
+	}
+
+	if _, err := provider.FindZone(ctx, "www.example.org"); !errors.Is(err, ErrZoneNotFound) {
+		t.Errorf("FindZone 错误 = %v, want ErrZoneNotFound", err)
+	}
+}
+
+func TestCloudflareFindZoneAuthError(t *testing.T) {
+	provider, _ := newFakeCloudflare(t, "wrong")
+	_, err := provider.FindZone(context.Background(), "www.example.com")
+	if !errors.Is(err, ErrZoneNotFound) || err.Error() == ErrZoneNotFound.Error() {
+		t.Errorf("认证失败时应同时带上 API 的错误: %v", err)
+	}
+}
+
+func TestCloudflareList(t *testing.T) {
+	provider, fake := newFakeCloudflare(t, "token")
+	zone := Zone{ID: "z-1", Name: "example.com"}
+
+	records, err := provider.List(context.Background(), zone, Record{Type: "a", Name: "WWW.example.com."})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Record{
+		{ID: "r-1", Type: "A", Name: "www.example.com", Content: "1.2.3.4", TTL: 1},
+		{ID: "r-2", Type: "A", Name: "www.example.com", Content: "1.2.3.5", TTL: 300},
+	}
+	if len(records) != len(want) || records[0] != want[0] || records[1] != want[1] {
+		t.Errorf("List = %+v, want 两页的记录 %+v", records, want)
+	}
+	if query := fake.last().Query; query.Get("type") != "A" || query.Get("name") != "www.example.com" || query.Get("page") != "2" {
+		t.Errorf("查询参数 = %v", query)
+	}
+
+	records, err = provider.List(context.Background(), zone, Record{Content: "1.2.3.5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].ID != "r-2" {
+		t.Errorf("按内容筛选 = %+v", records)
+	}
+}
+
+func TestCloudflareCreateUpdateDelete(t *testing.T) {
+	provider, fake := newFakeCloudflare(t, "token")
+	ctx := context.Background()
+	zone := Zone{ID: "z-1", Name: "example.com"}
+
+	created, err := provider.Create(ctx, zone, Record{Type: "txt", Name: "_acme-challenge.example.com.", Content: "token"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.ID != "r-new" {
+		t.Errorf("Create 返回的 ID = %q", created.ID)
+	}
+	body := fake.last().Body
+	if body["type"] != "TXT" || body["name"] != "_acme-challenge.example.com" || body["content"] != "token" || body["ttl"] != float64(1) {
+		t.Errorf("创建请求 = %v, want TTL 为 1（自动）", body)
+	}
+
+	if err := provider.Update(ctx, zone, Record{ID: "r-1", Type: "A", Name: "www.example.com", Content: "9.9.9.9", TTL: 120}); err != nil {
+		t.Fatal(err)
+	}
+	request := fake.last()
+	if request.Method != http.MethodPut || request.Body["content"] != "9.9.9.9" || request.Body["ttl"] != float64(120) {
+		t.Errorf("修改请求 = %+v", request)
+	}
+	if _, ok := request.Body["id"]; ok {
+		t.Errorf("修改请求不应带 id: %v", request.Body)
+	}
+
+	if err := provider.Delete(ctx, zone, "r-1"); err != nil {
+		t.Fatal(err)
+	}
+	if request := fake.last(); request.Method != http.MethodDelete || request.Path != "/zones/z-1/dns_records/r-1" {
+		t.Errorf("删除请求 = %+v", request)
+	}
+
+	if err := provider.Delete(ctx, zone, "missing"); err == nil {
+		t.Error("删除不存在的记录应返回错误")
+	}
+}
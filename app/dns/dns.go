@@ -0,0 +1,181 @@
+// Package dns 统一管理各 DNS 服务商的解析记录，证书验证、DDNS 等都通过 Provider 操作记录
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"nav-web-site/config"
+	"net"
+	"strings"
+)
+
+// ErrZoneNotFound 服务商账号下没有包含该域名的托管域
+var ErrZoneNotFound = errors.New("未找到域名所在的托管域")
+
+// Zone 服务商的托管域（Cloudflare 的 zone、阿里云的域名）
+type Zone struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// Record 一条解析记录，Name 是完整域名（不带结尾的点），不是相对托管域的主机记录
+type Record struct {
+	ID      string `json:"id" yaml:"-"`
+	Type    string `json:"type" yaml:"type"`
+	Name    string `json:"name" yaml:"name"`
+	Content string `json:"content" yaml:"content"`
+	TTL     int    `json:"ttl" yaml:"ttl"`
+}
+
+// Provider DNS 服务商
+type Provider interface {
+	// Name 服务商名称，和配置里的名称一致，如 cloudflare、aliyun
+	Name() string
+	// Zones 列出账号下的全部托管域
+	Zones(ctx context.Context) ([]Zone, error)
+	// FindZone 查找 fqdn 所在的托管域，从 fqdn 本身开始逐级向上匹配
+	FindZone(ctx context.Context, fqdn string) (Zone, error)
+	// List 列出托管域的记录，filter 的 Type、Name、Content 不为空时只返回完全匹配的记录
+	List(ctx context.Context, zone Zone, filter Record) ([]Record, error)
+	// Create 添加记录，返回带 ID 的记录
+	Create(ctx context.Context, zone Zone, record Record) (Record, error)
+	// Update 按 record.ID 修改记录
+	Update(ctx context.Context, zone Zone, record Record) error
+	// Delete 删除记录
+	Delete(ctx context.Context, zone Zone, recordID string) error
+}
+
+// nameServerSuffixes 各服务商权威 DNS 服务器的域名后缀，用来按 NS 记录判断域名托管在哪里
+var nameServerSuffixes = map[string][]string{
+	"cloudflare": {".ns.cloudflare.com"},
+	"aliyun":     {".alidns.com", ".hichina.com"},
+}
+
+// lookupNS 查询 NS 记录，可替换以便离线调试
+var lookupNS = net.DefaultResolver.LookupNS
+
+// New 按名称创建服务商，凭据来自配置的 dns 一节
+func New(name string) (Provider, error) {
+	switch name {
+	case "cloudflare":
+		if config.Config.DNS.Cloudflare.APIToken == "" {
+			return nil, fmt.Errorf("未配置 dns.cloudflare.api_token")
+		}
+		return NewCloudflare(config.Config.DNS.Cloudflare.APIToken), nil
+	case "aliyun":
+		if config.Config.DNS.Aliyun.AccessKeyID == "" || config.Config.DNS.Aliyun.AccessKeySecret == "" {
+			return nil, fmt.Errorf("未配置 dns.aliyun.access_key_id 或 access_key_secret")
+		}
+		return NewAliyun(config.Config.DNS.Aliyun.AccessKeyID, config.Config.DNS.Aliyun.AccessKeySecret), nil
+	default:
+		return nil, fmt.Errorf("unsupported DNS provider: %s", name)
+	}
+}
+
+// Configured 返回所有已配置凭据的服务商
+func Configured() []Provider {
+	var providers []Provider
+	for _, name := range []string{"cloudflare", "aliyun"} {
+		if provider, err := New(name); err == nil {
+			providers = append(providers, provider)
+		}
+	}
+	return providers
+}
+
+// ForDomain 返回管理该域名的服务商，name 为空或 auto 时按 NS 记录自动判断
+func ForDomain(ctx context.Context, name, domain string) (Provider, error) {
+	if name == "" || name == "auto" {
+		detected, err := Detect(ctx, domain)
+		if err != nil {
+			return nil, err
+		}
+		name = detected
+	}
+	return New(name)
+}
+
+// Resolve 找到托管域 zoneName 所在的服务商和托管域，providerName 为空或 auto 时按 NS 记录判断
+func Resolve(ctx context.Context, providerName, zoneName string) (Provider, Zone, error) {
+	zoneName = Normalize(zoneName)
+	if zoneName == "" {
+		return nil, Zone{}, fmt.Errorf("托管域不能为空")
+	}
+	provider, err := ForDomain(ctx, providerName, zoneName)
+	if err != nil {
+		return nil, Zone{}, err
+	}
+	zone, err := provider.FindZone(ctx, zoneName)
+	if err != nil {
+		return nil, Zone{}, err
+	}
+	if Normalize(zone.Name) != zoneName {
+		return nil, Zone{}, fmt.Errorf("%s 不是托管域，它属于 %s", zoneName, zone.Name)
+	}
+	return provider, zone, nil
+}
+
+// Qualify 把主机记录（如 nav、@）转成托管域下的完整域名，已经是完整域名的原样返回
+func Qualify(name, zone string) string {
+	name, zone = Normalize(name), Normalize(zone)
+	if name == "" || name == "@" || name == zone {
+		return zone
+	}
+	if strings.HasSuffix(name, "."+zone) {
+		return name
+	}
+	return name + "." + zone
+}
+
+// Detect 按域名（或其最近一级有 NS 记录的父域名）的 NS 记录判断托管在哪个服务商
+func Detect(ctx context.Context, domain string) (string, error) {
+	for _, candidate := range Candidates(domain) {
+		nameServers, err := lookupNS(ctx, candidate)
+		if err != nil || len(nameServers) == 0 {
+			continue
+		}
+		for _, ns := range nameServers {
+			host := strings.ToLower(strings.TrimSuffix(ns.Host, "."))
+			for provider, suffixes := range nameServerSuffixes {
+				for _, suffix := range suffixes {
+					if strings.HasSuffix(host, suffix) {
+						return provider, nil
+					}
+				}
+			}
+		}
+		return "", fmt.Errorf("无法识别 %s 的 DNS 服务商，NS: %s", candidate, nameServers[0].Host)
+	}
+	return "", fmt.Errorf("查询不到 %s 的 NS 记录", domain)
+}
+
+// Candidates 返回域名本身和各级父域名（不含顶级域），用来逐级查找托管域，
+// 如 _acme-challenge.www.example.com 返回它自己、www.example.com、example.com
+func Candidates(domain string) []string {
+	labels := strings.Split(Normalize(domain), ".")
+	var candidates []string
+	for i := 0; i < len(labels)-1; i++ {
+		candidates = append(candidates, strings.Join(labels[i:], "."))
+	}
+	return candidates
+}
+
+// Normalize 去掉结尾的点并转成小写
+func Normalize(domain string) string {
+	return strings.ToLower(strings.TrimSuffix(strings.TrimSpace(domain), "."))
+}
+
+// matches 判断记录是否符合筛选条件
+func matches(record Record, filter Record) bool {
+	if filter.Type != "" && !strings.EqualFold(record.Type, filter.Type) {
+		return false
+	}
+	if filter.Name != "" && Normalize(record.Name) != Normalize(filter.Name) {
+		return false
+	}
+	if filter.Content != "" && record.Content != filter.Content {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,103 @@
+package dns
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeNS 用固定的 NS 记录替换 lookupNS，测试结束后恢复
+func fakeNS(t *testing.T, records map[string][]string) *[]string {
+	t.Helper()
+	var queried []string
+	saved := lookupNS
+	lookupNS = func(_ context.Context, name string) ([]*net.NS, error) {
+		queried = append(queried, name)
+		hosts, ok := records[name]
+		if !ok {
+			return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+		}
+		var result []*net.NS
+		for _, host := range hosts {
+			result = append(result, &net.NS{Host: host})
+		}
+		return result, nil
+	}
+	t.Cleanup(func() { lookupNS = saved })
+	return &queried
+}
+
+func TestDetect(t *testing.T) {
+	queried := fakeNS(t, map[string][]string{
+		"example.com": {"ada.NS.Cloudflare.com."},
+		"example.cn":  {"dns9.hichina.com."},
+		"example.net": {"ns1.alidns.com."},
+		"example.org": {"ns1.example-dns.org."},
+	})
+	ctx := context.Background()
+
+	tests := []struct {
+		domain string
+		want   string
+	}{
+		{"_acme-challenge.www.example.com", "cloudflare"},
+		{"example.cn", "aliyun"},
+		{"nav.example.net.", "aliyun"},
+	}
+	for _, tt := range tests {
+		got, err := Detect(ctx, tt.domain)
+		if err != nil || got != tt.want {
+			t.Errorf("Detect(%q) = %q, %v, want %q", tt.domain, got, err, tt.want)
+		}
+	}
+
+	// 逐级向上查到有 NS 记录的父域名为止
+	*queried = nil
+	Detect(ctx, "a.b.example.com")
+	if want := []string{"a.b.example.com", "b.example.com", "example.com"}; !reflect.DeepEqual(*queried, want) {
+		t.Errorf("查询顺序 = %v, want %v", *queried, want)
+	}
+
+	if _, err := Detect(ctx, "www.example.org"); err == nil || !strings.Contains(err.Error(), "ns1.example-dns.org") {
+		t.Errorf("不认识的 NS 应返回带 NS 的错误: %v", err)
+	}
+	if _, err := Detect(ctx, "www.unknown.io"); err == nil {
+		t.Error("查不到 NS 时应返回错误")
+	}
+}
+
+func TestForDomainAuto(t *testing.T) {
+	fakeNS(t, map[string][]string{"example.com": {"ns1.alidns.com."}})
+	// 自动判断出 aliyun，但没有配置凭据
+	_, err := ForDomain(context.Background(), "auto", "www.example.com")
+	if err == nil || !strings.Contains(err.Error(), "dns.aliyun") {
+		t.Errorf("ForDomain 错误 = %v, want 未配置阿里云凭据", err)
+	}
+	if _, err := ForDomain(context.Background(), "route53", "www.example.com"); err == nil {
+		t.Error("不支持的服务商应返回错误")
+	}
+}
+
+func TestCandidatesAndQualify(t *testing.T) {
+	if got, want := Candidates("_acme-challenge.WWW.example.com."), []string{"_acme-challenge.www.example.com", "www.example.com", "example.com"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Candidates = %v, want %v", got, want)
+	}
+	if got := Candidates("com"); got != nil {
+		t.Errorf("顶级域不应有候选: %v", got)
+	}
+
+	tests := []struct{ name, zone, want string }{
+		{"@", "example.com", "example.com"},
+		{"", "example.com.", "example.com"},
+		{"nav", "example.com", "nav.example.com"},
+		{"nav.example.com.", "example.com", "nav.example.com"},
+		{"example.com", "example.com", "example.com"},
+	}
+	for _, tt := range tests {
+		if got := Qualify(tt.name, tt.zone); got != tt.want {
+			t.Errorf("Qualify(%q, %q) = %q, want %q", tt.name, tt.zone, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+)
+
+// DesiredState YAML 里声明的期望记录，如：
+//
+//	zones:
+//	  - zone: example.com
+//	    provider: cloudflare # 可选，为空时按 NS 记录判断
+//	    prune: false         # 是否删除没有声明的记录（NS、SOA 除外）
+//	    records:
+//	      - {name: nav, type: A, content: 1.2.3.4, ttl: 600}
+type DesiredState struct {
+	Zones []DesiredZone `yaml:"zones"`
+}
+
+// DesiredZone 一个托管域的期望记录
+type DesiredZone struct {
+	Zone     string   `yaml:"zone"`
+	Provider string   `yaml:"provider"`
+	Prune    bool     `yaml:"prune"`
+	Records  []Record `yaml:"records"`
+}
+
+// Change 同步时对一条记录的改动，Before 为改动前的记录，After 为改动后的记录
+type Change struct {
+	Action   string  `json:"action"`
+	Provider string  `json:"provider"`
+	Zone     string  `json:"zone"`
+	Before   *Record `json:"before,omitempty"`
+	After    *Record `json:"after,omitempty"`
+
+	provider Provider
+	zone     Zone
+}
+
+// ParseDesiredState 解析 YAML 并检查每条记录，主机记录统一转成完整域名
+func ParseDesiredState(data []byte) (DesiredState, error) {
+	var state DesiredState
+	if err := yaml.Unmarshal(data, &state); err != nil {
+		return state, fmt.Errorf("YAML 格式错误: %v", err)
+	}
+	if len(state.Zones) == 0 {
+		return state, fmt.Errorf("没有声明任何托管域")
+	}
+	for i := range state.Zones {
+		zone := &state.Zones[i]
+		zone.Zone = Normalize(zone.Zone)
+		if zone.Zone == "" {
+			return state, fmt.Errorf("第%d个托管域没有填写 zone", i+1)
+		}
+		for j := range zone.Records {
+			record := &zone.Records[j]
+			record.Type = strings.ToUpper(strings.TrimSpace(record.Type))
+			record.Name = Qualify(record.Name, zone.Zone)
+			if record.Type == "" || record.Content == "" {
+				return state, fmt.Errorf("%s 的第%d条记录缺少 type 或 content", zone.Zone, j+1)
+			}
+		}
+	}
+	return state, nil
+}
+
+// Plan 对比期望记录和服务商上的现有记录，返回需要的改动，不做任何修改
+func Plan(ctx context.Context, state DesiredState) ([]Change, error) {
+	var changes []Change
+	for _, desired := range state.Zones {
+		provider, zone, err := Resolve(ctx, desired.Provider, desired.Zone)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", desired.Zone, err)
+		}
+		existing, err := provider.List(ctx, zone, Record{})
+		if err != nil {
+			return nil, fmt.Errorf("%s: 获取现有记录失败: %v", desired.Zone, err)
+		}
+		for _, change := range diff(desired, existing) {
+			change.Provider = provider.Name()
+			change.Zone = zone.Name
+			change.provider = provider
+			change.zone = zone
+			changes = append(changes, change)
+		}
+	}
+	return changes, nil
+}
+
+// diff 按名称和类型分组比较：值相同的记录保留（TTL 不同时更新），多出的期望值优先改写多余的现有记录，
+// 剩下的新增；多余的现有记录删除。同一名称和类型重复声明的值只算一次（以第一次的 TTL 为准）。
+// 没有声明的名称和类型只在 prune 时删除，NS 和 SOA 记录不删除
+func diff(desired DesiredZone, existing []Record) []Change {
+	key := func(record Record) string {
+		return strings.ToUpper(record.Type) + " " + Normalize(record.Name)
+	}
+	current := make(map[string][]Record)
+	for _, record := range existing {
+		current[key(record)] = append(current[key(record)], record)
+	}
+
+	var changes []Change
+	declared := make(map[string]bool)
+	var order []string
+	wanted := make(map[string][]Record)
+	seen := make(map[string]bool)
+	for _, record := range desired.Records {
+		k := key(record)
+		if !declared[k] {
+			declared[k] = true
+			order = append(order, k)
+		}
+		if seen[k+" "+record.Content] {
+			continue
+		}
+		seen[k+" "+record.Content] = true
+		wanted[k] = append(wanted[k], record)
+	}
+
+	for _, k := range order {
+		var missing []Record
+		remaining := current[k]
+		for _, want := range wanted[k] {
+			matched := -1
+			for i, have := range remaining {
+				if have.Content == want.Content {
+					matched = i
+					break
+				}
+			}
+			if matched < 0 {
+				missing = append(missing, want)
+				continue
+			}
+			have := remaining[matched]
+			remaining = append(remaining[:matched:matched], remaining[matched+1:]...)
+			if want.TTL > 0 && want.TTL != have.TTL {
+				after := want
+				after.ID = have.ID
+				changes = append(changes, Change{Action: ActionUpdate, Before: &have, After: &after})
+			}
+		}
+		for _, want := range missing {
+			after := want
+			if len(remaining) > 0 {
+				before := remaining[0]
+				remaining = remaining[1:]
+				after.ID = before.ID
+				if after.TTL == 0 {
+					after.TTL = before.TTL
+				}
+				changes = append(changes, Change{Action: ActionUpdate, Before: &before, After: &after})
+				continue
+			}
+			changes = append(changes, Change{Action: ActionCreate, After: &after})
+		}
+		for i := range remaining {
+			changes = append(changes, Change{Action: ActionDelete, Before: &remaining[i]})
+		}
+	}
+
+	if desired.Prune {
+		for i, record := range existing {
+			if recordType := strings.ToUpper(record.Type); declared[key(record)] || recordType == "NS" || recordType == "SOA" {
+				continue
+			}
+			changes = append(changes, Change{Action: ActionDelete, Before: &existing[i]})
+		}
+	}
+	return changes
+}
+
+// Apply 执行改动，新增成功后 After 带上记录ID
+func (c *Change) Apply(ctx context.Context) error {
+	if c.provider == nil {
+		return fmt.Errorf("改动不是由 Plan 生成的")
+	}
+	switch c.Action {
+	case ActionCreate:
+		created, err := c.provider.Create(ctx, c.zone, *c.After)
+		if err != nil {
+			return err
+		}
+		c.After = &created
+		return nil
+	case ActionUpdate:
+		return c.provider.Update(ctx, c.zone, *c.After)
+	case ActionDelete:
+		return c.provider.Delete(ctx, c.zone, c.Before.ID)
+	default:
+		return fmt.Errorf("未知的改动类型: %s", c.Action)
+	}
+}
@@ -0,0 +1,156 @@
+package dns
+
+import (
+	"reflect"
+	"testing"
+)
+
+// changeSummary 把改动转成便于比较的形式：动作、改动前和改动后的记录
+type changeSummary struct {
+	Action string
+	Before Record
+	After  Record
+}
+
+func summarize(changes []Change) []changeSummary {
+	result := []changeSummary{}
+	for _, change := range changes {
+		summary := changeSummary{Action: change.Action}
+		if change.Before != nil {
+			summary.Before = *change.Before
+		}
+		if change.After != nil {
+			summary.After = *change.After
+		}
+		result = append(result, summary)
+	}
+	return result
+}
+
+func TestDiff(t *testing.T) {
+	www1 := Record{ID: "1", Type: "A", Name: "www.example.com", Content: "1.1.1.1", TTL: 600}
+	www2 := Record{ID: "2", Type: "A", Name: "www.example.com", Content: "2.2.2.2", TTL: 600}
+	txt := Record{ID: "3", Type: "TXT", Name: "example.com", Content: "v=spf1 -all", TTL: 600}
+	ns := Record{ID: "4", Type: "NS", Name: "example.com", Content: "ns1.example-dns.com", TTL: 86400}
+	soa := Record{ID: "5", Type: "soa", Name: "example.com", Content: "ns1.example-dns.com. admin.example.com.", TTL: 86400}
+
+	tests := []struct {
+		name     string
+		desired  DesiredZone
+		existing []Record
+		want     []changeSummary
+	}{
+		{
+			name:     "完全一致时没有改动",
+			desired:  DesiredZone{Records: []Record{{Type: "A", Name: "www.example.com", Content: "1.1.1.1", TTL: 600}}},
+			existing: []Record{www1},
+			want:     []changeSummary{},
+		},
+		{
+			name: "名称大小写、结尾的点和类型大小写不影响匹配，没填 TTL 时不比较 TTL",
+			desired: DesiredZone{Records: []Record{
+				{Type: "a", Name: "WWW.example.com.", Content: "1.1.1.1"},
+			}},
+			existing: []Record{www1},
+			want:     []changeSummary{},
+		},
+		{
+			name:     "只有 TTL 不同时更新",
+			desired:  DesiredZone{Records: []Record{{Type: "A", Name: "www.example.com", Content: "1.1.1.1", TTL: 60}}},
+			existing: []Record{www1},
+			want: []changeSummary{
+				{Action: ActionUpdate, Before: www1, After: Record{ID: "1", Type: "A", Name: "www.example.com", Content: "1.1.1.1", TTL: 60}},
+			},
+		},
+		{
+			name:     "值不同时改写现有记录，没填 TTL 时沿用原来的",
+			desired:  DesiredZone{Records: []Record{{Type: "A", Name: "www.example.com", Content: "3.3.3.3"}}},
+			existing: []Record{www1},
+			want: []changeSummary{
+				{Action: ActionUpdate, Before: www1, After: Record{ID: "1", Type: "A", Name: "www.example.com", Content: "3.3.3.3", TTL: 600}},
+			},
+		},
+		{
+			name: "值相同的保留，新值改写多余的记录",
+			desired: DesiredZone{Records: []Record{
+				{Type: "A", Name: "www.example.com", Content: "2.2.2.2", TTL: 600},
+				{Type: "A", Name: "www.example.com", Content: "3.3.3.3", TTL: 600},
+			}},
+			existing: []Record{www1, www2},
+			want: []changeSummary{
+				{Action: ActionUpdate, Before: www1, After: Record{ID: "1", Type: "A", Name: "www.example.com", Content: "3.3.3.3", TTL: 600}},
+			},
+		},
+		{
+			name: "没有可改写的记录时新增",
+			desired: DesiredZone{Records: []Record{
+				{Type: "A", Name: "www.example.com", Content: "1.1.1.1", TTL: 600},
+				{Type: "A", Name: "www.example.com", Content: "3.3.3.3", TTL: 600},
+			}},
+			existing: []Record{www1},
+			want: []changeSummary{
+				{Action: ActionCreate, After: Record{Type: "A", Name: "www.example.com", Content: "3.3.3.3", TTL: 600}},
+			},
+		},
+		{
+			name:     "声明的名称和类型下多余的记录删除",
+			desired:  DesiredZone{Records: []Record{{Type: "A", Name: "www.example.com", Content: "1.1.1.1", TTL: 600}}},
+			existing: []Record{www1, www2},
+			want: []changeSummary{
+				{Action: ActionDelete, Before: www2},
+			},
+		},
+		{
+			name: "重复声明的值只算一次",
+			desired: DesiredZone{Records: []Record{
+				{Type: "A", Name: "www.example.com", Content: "1.1.1.1", TTL: 600},
+				{Type: "A", Name: "www.example.com", Content: "1.1.1.1", TTL: 300},
+			}},
+			existing: []Record{www1, www2},
+			want: []changeSummary{
+				{Action: ActionDelete, Before: www2},
+			},
+		},
+		{
+			name: "重复声明的新值只新增一条",
+			desired: DesiredZone{Records: []Record{
+				{Type: "A", Name: "new.example.com", Content: "1.1.1.1"},
+				{Type: "A", Name: "new.example.com", Content: "1.1.1.1"},
+			}},
+			existing: nil,
+			want: []changeSummary{
+				{Action: ActionCreate, After: Record{Type: "A", Name: "new.example.com", Content: "1.1.1.1"}},
+			},
+		},
+		{
+			name:     "不 prune 时没有声明的记录保留",
+			desired:  DesiredZone{Records: []Record{{Type: "A", Name: "www.example.com", Content: "1.1.1.1", TTL: 600}}},
+			existing: []Record{www1, txt, ns},
+			want:     []changeSummary{},
+		},
+		{
+			name:     "prune 时删除没有声明的记录，NS 和 SOA 除外",
+			desired:  DesiredZone{Prune: true, Records: []Record{{Type: "A", Name: "www.example.com", Content: "1.1.1.1", TTL: 600}}},
+			existing: []Record{ns, www1, txt, soa},
+			want: []changeSummary{
+				{Action: ActionDelete, Before: txt},
+			},
+		},
+		{
+			name:     "prune 时声明的名称和类型下多余的记录只删除一次",
+			desired:  DesiredZone{Prune: true, Records: []Record{{Type: "A", Name: "www.example.com", Content: "1.1.1.1", TTL: 600}}},
+			existing: []Record{www1, www2},
+			want: []changeSummary{
+				{Action: ActionDelete, Before: www2},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := summarize(diff(tt.desired, tt.existing))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("diff() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
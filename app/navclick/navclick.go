@@ -0,0 +1,222 @@
+// Package navclick 统计导航链接的点击：/go/{id} 跳转时先在 Redis 里计数，定时批量写回 nav.views 和 nav_click_daily 表，
+// 避免每次点击都写数据库
+package navclick
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"nav-web-site/config"
+	"nav-web-site/mydb"
+	"nav-web-site/util/log"
+	"nav-web-site/util/redislock"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	pendingKey           = "nav_click_pending" // 待写回的点击数，field 为 "导航id:日期"
+	flushLockKey         = "nav_click_flush_lock"
+	flushLockTTL         = time.Minute
+	defaultFlushInterval = time.Minute
+	dateLayout           = "20060102"
+)
+
+// botKeywords User-Agent 含有这些关键词（小写）时视为爬虫，只跳转不计数
+var botKeywords = []string{
+	"bot", "spider", "crawl", "slurp", "curl", "wget", "python", "go-http-client", "java/",
+	"okhttp", "httpclient", "scrapy", "headless", "phantomjs", "preview", "monitor",
+}
+
+// takeScript 取出一项待写回的点击数并从哈希里删除，写回前先取出，之后的点击重新累计，不会被写回两次
+var takeScript = redis.NewScript(`
+local value = redis.call("HGET", KEYS[1], ARGV[1])
+if value then
+	redis.call("HDEL", KEYS[1], ARGV[1])
+end
+return value`)
+
+var (
+	memMu      sync.Mutex
+	memPending = make(map[string]int64) // Redis 不可用时暂存在内存里的点击数
+
+	lifecycleMu sync.Mutex
+	stopFlush   context.CancelFunc
+	flushDone   chan struct{}
+)
+
+func flushInterval() time.Duration {
+	if config.Config.NavClick.FlushInterval > 0 {
+		return time.Duration(config.Config.NavClick.FlushInterval) * time.Second
+	}
+	return defaultFlushInterval
+}
+
+// IsBot 按 User-Agent 判断是否是爬虫或脚本，空 User-Agent 也算
+func IsBot(userAgent string) bool {
+	userAgent = strings.ToLower(strings.TrimSpace(userAgent))
+	if userAgent == "" {
+		return true
+	}
+	for _, keyword := range botKeywords {
+		if strings.Contains(userAgent, keyword) {
+			return true
+		}
+	}
+	for _, keyword := range config.Config.NavClick.BotUserAgents {
+		if keyword = strings.ToLower(strings.TrimSpace(keyword)); keyword != "" && strings.Contains(userAgent, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// Record 记一次点击，Redis 写入失败时记在内存里
+func Record(ctx context.Context, navID int) {
+	field := fmt.Sprintf("%d:%s", navID, time.Now().Format(dateLayout))
+	if err := mydb.RedisClient.HIncrBy(ctx, pendingKey, field, 1).Err(); err != nil {
+		log.ErrorLogger.Printf("记录导航点击失败,改为暂存在内存,nav_id=%d: %v", navID, err)
+		memMu.Lock()
+		memPending[field]++
+		memMu.Unlock()
+	}
+}
+
+// Start 启动定时写回
+func Start() {
+	lifecycleMu.Lock()
+	defer lifecycleMu.Unlock()
+	if stopFlush != nil {
+		return
+	}
+	var ctx context.Context
+	ctx, stopFlush = context.WithCancel(context.Background())
+	flushDone = make(chan struct{})
+
+	go func() {
+		defer close(flushDone)
+		ticker := time.NewTicker(flushInterval())
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := Flush(ctx); err != nil && ctx.Err() == nil {
+					log.ErrorLogger.Printf("导航点击数写回失败: %v", err)
+				}
+			}
+		}
+	}()
+	log.InfoLogger.Printf("Nav click flusher started, interval %s", flushInterval())
+}
+
+// Stop 停止定时写回，并在退出前把剩余的点击数写回一次
+func Stop(ctx context.Context) error {
+	lifecycleMu.Lock()
+	defer lifecycleMu.Unlock()
+	if stopFlush == nil {
+		return nil
+	}
+	stopFlush()
+	select {
+	case <-flushDone:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	stopFlush = nil
+	_, err := Flush(ctx)
+	return err
+}
+
+// Flush 把暂存的点击数写回数据库，返回写回的条数。多个实例同时写回时只有取得锁的实例执行。
+// 每项点击数先从 Redis 取出再写入数据库，写入失败时放回；取出后进程退出会少记这部分点击，但不会重复累加
+func Flush(ctx context.Context) (int, error) {
+	flushed := flushMemory()
+
+	lock, err := redislock.Acquire(ctx, mydb.RedisClient, flushLockKey, flushLockTTL)
+	if errors.Is(err, redislock.ErrNotAcquired) {
+		return flushed, nil
+	}
+	if err != nil {
+		return flushed, err
+	}
+	defer lock.Release(context.Background())
+	ctx = lock.Hold(ctx)
+
+	fields, err := mydb.RedisClient.HKeys(ctx, pendingKey).Result()
+	if err != nil {
+		return flushed, err
+	}
+	for _, field := range fields {
+		// 写回较慢时锁可能过期被其他实例取得，这时停止，剩下的交给持有锁的实例
+		if err := lock.Check(ctx); err != nil {
+			return flushed, err
+		}
+		value, err := takeScript.Run(ctx, mydb.RedisClient, []string{pendingKey}, field).Text()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			return flushed, err
+		}
+		clicks, _ := strconv.ParseInt(value, 10, 64)
+		if clicks <= 0 {
+			continue
+		}
+		if err := apply(field, clicks); err != nil {
+			log.ErrorLogger.Printf("写回导航点击数失败,%s=%d: %v", field, clicks, err)
+			restore(field, clicks)
+			continue
+		}
+		flushed++
+	}
+	return flushed, nil
+}
+
+// restore 把写回失败的点击数放回 Redis 等下次写回，Redis 也不可用时放在内存里
+func restore(field string, clicks int64) {
+	if err := mydb.RedisClient.HIncrBy(context.Background(), pendingKey, field, clicks).Err(); err != nil {
+		log.ErrorLogger.Printf("放回导航点击数失败,改为暂存在内存,%s=%d: %v", field, clicks, err)
+		memMu.Lock()
+		memPending[field] += clicks
+		memMu.Unlock()
+	}
+}
+
+// flushMemory 写回内存里暂存的点击数，失败的放回去等下次
+func flushMemory() int {
+	memMu.Lock()
+	pending := memPending
+	memPending = make(map[string]int64)
+	memMu.Unlock()
+
+	flushed := 0
+	for field, clicks := range pending {
+		if err := apply(field, clicks); err != nil {
+			log.ErrorLogger.Printf("写回导航点击数失败,%s=%d: %v", field, clicks, err)
+			memMu.Lock()
+			memPending[field] += clicks
+			memMu.Unlock()
+			continue
+		}
+		flushed++
+	}
+	return flushed
+}
+
+// apply 把一项点击数累加到当天的统计和导航的浏览量，两者在同一个事务里写入
+func apply(field string, clicks int64) error {
+	idPart, datePart, ok := strings.Cut(field, ":")
+	navID, idErr := strconv.Atoi(idPart)
+	date, dateErr := strconv.Atoi(datePart)
+	if !ok || idErr != nil || dateErr != nil {
+		log.ErrorLogger.Printf("忽略无效的导航点击记录: %s", field)
+		return nil
+	}
+	return mydb.Tables.NavClickDaily.AddClicks(navID, date, clicks)
+}
@@ -0,0 +1,265 @@
+// Package navhealth 检查导航链接是否可以访问，结果保存在 nav_health 表，连续失败的导航自动隐藏
+package navhealth
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"nav-web-site/app/sitecache"
+	"nav-web-site/config"
+	"nav-web-site/mydb"
+	"nav-web-site/util"
+	"nav-web-site/util/log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	maxRedirects = 10
+	maxBodySize  = 64 * 1024 // 读取的响应体上限，只为了让连接可以复用
+)
+
+// Result 一次检查的计数
+type Result struct {
+	Checked   int // 检查的链接数
+	Broken    int // 本次失败的链接数
+	Hidden    int // 本次自动隐藏的导航数
+	Recovered int // 本次恢复显示的导航数
+}
+
+// Probe 一个链接的检查结果
+type Probe struct {
+	StatusCode  int
+	Latency     time.Duration
+	RedirectURL string
+	TLSExpire   time.Time
+	Err         error
+}
+
+// OK 请求成功且不是死链。401、403、405、429 多是站点拒绝爬虫或限流，站点本身还在，算作正常
+func (p Probe) OK() bool {
+	if p.Err != nil {
+		return false
+	}
+	switch p.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusMethodNotAllowed, http.StatusTooManyRequests:
+		return true
+	}
+	return p.StatusCode < 400
+}
+
+func concurrency() int {
+	if config.Config.NavHealth.Concurrency > 0 {
+		return config.Config.NavHealth.Concurrency
+	}
+	return 8
+}
+
+func timeout() time.Duration {
+	if config.Config.NavHealth.Timeout > 0 {
+		return time.Duration(config.Config.NavHealth.Timeout) * time.Second
+	}
+	return 15 * time.Second
+}
+
+// failThreshold 连续失败多少次后隐藏，0 表示不自动隐藏
+func failThreshold() int {
+	switch threshold := config.Config.NavHealth.FailThreshold; {
+	case threshold < 0:
+		return 0
+	case threshold == 0:
+		return 3
+	default:
+		return threshold
+	}
+}
+
+// Run 并发检查所有启用的导航链接
+func Run(ctx context.Context) (Result, error) {
+	navs, code, err := mydb.Tables.Nav.Select(mydb.QueryParams{Condition: "status=1"})
+	if err != nil && code != 200 {
+		return Result{}, err
+	}
+	healths, code, err := mydb.Tables.NavHealth.Select(mydb.QueryParams{})
+	if err != nil && code != 200 {
+		return Result{}, err
+	}
+	previous := make(map[int]mydb.StructNavHealth, len(healths))
+	for _, health := range healths {
+		previous[health.Nav_id] = health
+	}
+
+	client := newClient()
+	defer client.CloseIdleConnections()
+
+	var (
+		result Result
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		slots  = make(chan struct{}, concurrency())
+	)
+	for _, nav := range navs {
+		if nav.Url == "" {
+			continue
+		}
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return result, ctx.Err()
+		}
+		wg.Add(1)
+		go func(nav mydb.StructNav) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			probe := Check(ctx, client, nav.Url)
+			if ctx.Err() != nil {
+				return
+			}
+			hidden, recovered := record(nav, previous[nav.ID], probe)
+
+			mu.Lock()
+			defer mu.Unlock()
+			result.Checked++
+			if !probe.OK() {
+				result.Broken++
+			}
+			if hidden {
+				result.Hidden++
+			}
+			if recovered {
+				result.Recovered++
+			}
+		}(nav)
+	}
+	wg.Wait()
+	if result.Hidden > 0 || result.Recovered > 0 {
+		sitecache.ForgetHome()
+	}
+	log.InfoLogger.Printf("导航链接检查完成: %+v", result)
+	return result, ctx.Err()
+}
+
+// newClient 创建检查用的客户端，记录跳转但不超过 maxRedirects 次
+func newClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = 2
+	return &http.Client{
+		Transport: transport,
+		Timeout:   timeout(),
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			return nil
+		},
+	}
+}
+
+// Check 请求链接，记录状态码、耗时、最终地址和证书到期时间
+func Check(ctx context.Context, client *http.Client, url string) Probe {
+	var probe Probe
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		probe.Err = err
+		return probe
+	}
+	userAgent := config.Config.Crawler.UserAgent
+	if userAgent == "" {
+		userAgent = "Mozilla/5.0 (compatible; NavWebSiteBot/1.0)"
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	started := time.Now()
+	resp, err := client.Do(req)
+	probe.Latency = time.Since(started)
+	if err != nil {
+		probe.Err = err
+		return probe
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxBodySize))
+
+	probe.StatusCode = resp.StatusCode
+	if final := resp.Request.URL.String(); final != url {
+		probe.RedirectURL = final
+	}
+	probe.TLSExpire = tlsExpire(resp.TLS)
+	return probe
+}
+
+// tlsExpire 最终地址的证书到期时间，http 链接返回零值
+func tlsExpire(state *tls.ConnectionState) time.Time {
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return time.Time{}
+	}
+	return state.PeerCertificates[0].NotAfter
+}
+
+// record 保存检查结果，连续失败达到阈值时隐藏导航，被自动隐藏的导航恢复后重新显示。
+// 导航换了地址时从头计数；自动隐藏后又被管理员设为显示的，不再当作自动隐藏，失败次数也从头计数
+func record(nav mydb.StructNav, health mydb.StructNavHealth, probe Probe) (hidden bool, recovered bool) {
+	now := util.GetTimestamp(10)
+	exists := health.ID > 0
+	if exists && (health.Url != nav.Url || (health.Auto_hidden && nav.Is_show)) {
+		health.Fail_count = 0
+		health.Auto_hidden = false
+	}
+	health.Nav_id = nav.ID
+	health.Url = nav.Url
+	health.Status_code = probe.StatusCode
+	health.Latency_ms = probe.Latency.Milliseconds()
+	health.Redirect_url = probe.RedirectURL
+	health.Tls_expire_time = 0
+	if !probe.TLSExpire.IsZero() {
+		health.Tls_expire_time = probe.TLSExpire.Unix()
+	}
+	health.Error = ""
+	health.Check_time = now
+	health.Is_ok = probe.OK()
+
+	if health.Is_ok {
+		health.Fail_count = 0
+		health.Last_ok_time = now
+		if health.Auto_hidden {
+			if err := nav.SetShow(nav.ID, true); err != nil {
+				log.ErrorLogger.Printf("恢复显示导航失败,id=%d: %v", nav.ID, err)
+			} else {
+				health.Auto_hidden = false
+				recovered = true
+				log.InfoLogger.Printf("导航链接已恢复,重新显示,id=%d,url=%s", nav.ID, nav.Url)
+			}
+		}
+	} else {
+		health.Fail_count++
+		if probe.Err != nil {
+			health.Error = util.Truncate(probe.Err.Error(), 1024)
+		} else {
+			health.Error = fmt.Sprintf("HTTP %d", probe.StatusCode)
+		}
+		if threshold := failThreshold(); threshold > 0 && health.Fail_count >= threshold && nav.Is_show {
+			if err := nav.SetShow(nav.ID, false); err != nil {
+				log.ErrorLogger.Printf("自动隐藏导航失败,id=%d: %v", nav.ID, err)
+			} else {
+				health.Auto_hidden = true
+				hidden = true
+				log.InfoLogger.Printf("导航链接连续%d次无法访问,已自动隐藏,id=%d,url=%s", health.Fail_count, nav.ID, nav.Url)
+			}
+		}
+	}
+
+	var err error
+	if exists {
+		_, _, err = health.Update([]mydb.StructNavHealth{health}, fmt.Sprintf("id=%d", health.ID))
+	} else {
+		_, _, err = health.Insert([]mydb.StructNavHealth{health})
+	}
+	if err != nil {
+		log.ErrorLogger.Printf("保存导航检查结果失败,id=%d: %v", nav.ID, err)
+	}
+	return hidden, recovered
+}
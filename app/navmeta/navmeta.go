@@ -0,0 +1,270 @@
+// Package navmeta 抓取导航链接的网页信息（标题、描述、关键词、OpenGraph、图标），用于添加导航时自动填写
+package navmeta
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"nav-web-site/app/api/upload"
+	"nav-web-site/config"
+	"nav-web-site/util"
+	"nav-web-site/util/log"
+	"nav-web-site/util/safehttp"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html/charset"
+)
+
+const (
+	fetchTimeout    = 15 * time.Second
+	maxPageSize     = 2 * 1024 * 1024 // 网页内容上限，title 和 meta 都在 head 里，不需要读完整个页面
+	maxIconSize     = 512 * 1024
+	maxIconAttempts = 4 // 最多尝试下载的图标数
+	maxIconEdge     = 256
+	defaultUA       = "Mozilla/5.0 (compatible; NavWebSiteBot/1.0)"
+)
+
+// Metadata 网页信息和建议填写的导航字段
+type Metadata struct {
+	URL           string   `json:"url"`            // 跳转后的最终地址
+	Title         string   `json:"title"`          // <title>，没有时用 og:title
+	SiteName      string   `json:"site_name"`      // og:site_name
+	Description   string   `json:"description"`    // meta description，没有时用 og:description
+	Keywords      string   `json:"keywords"`       // meta keywords
+	Image         string   `json:"image"`          // og:image
+	Icon          string   `json:"icon"`           // 转存后的站内地址 /images/{hash}，转存失败时为空
+	IconURL       string   `json:"icon_url"`       // 图标的原始地址
+	IconCandidate []string `json:"icon_candidate"` // 页面声明的图标，按优先级排列
+}
+
+// iconLink 页面里声明的一个图标
+type iconLink struct {
+	href string
+	size int
+}
+
+func userAgent() string {
+	if config.Config.Crawler.UserAgent != "" {
+		return config.Config.Crawler.UserAgent
+	}
+	return defaultUA
+}
+
+// Fetch 抓取网页解析信息，并把最合适的图标下载到上传目录。图标失败不影响返回的其他信息。
+// 链接或跳转后的地址解析到内网、本机或链路本地地址时返回 safehttp.ErrBlockedAddress
+func Fetch(ctx context.Context, pageURL string, adminID int) (Metadata, error) {
+	var meta Metadata
+	u, err := url.Parse(strings.TrimSpace(pageURL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return meta, fmt.Errorf("无效的链接地址: %s", pageURL)
+	}
+
+	// 地址由后台填写，网页和图标都只允许访问公网地址，跳转到内网地址同样拒绝，抓取结果会原样返回给调用方
+	client := safehttp.NewClient(fetchTimeout)
+	body, contentType, finalURL, err := get(ctx, client, u.String(), "text/html,application/xhtml+xml", maxPageSize)
+	if err != nil {
+		return meta, err
+	}
+	reader, err := charset.NewReader(bytes.NewReader(body), contentType)
+	if err != nil {
+		return meta, fmt.Errorf("识别网页编码失败: %v", err)
+	}
+	doc, err := goquery.NewDocumentFromReader(reader)
+	if err != nil {
+		return meta, fmt.Errorf("解析网页失败: %v", err)
+	}
+
+	meta = Parse(doc, finalURL)
+	for i, iconURL := range meta.IconCandidate {
+		if i >= maxIconAttempts || ctx.Err() != nil {
+			break
+		}
+		data, _, _, err := get(ctx, client, iconURL, "image/avif,image/webp,image/png,image/x-icon,image/*;q=0.8", maxIconSize)
+		if err != nil {
+			log.InfoLogger.Printf("下载图标失败,url=%s: %v", iconURL, err)
+			continue
+		}
+		file, err := upload.SaveImageBytes(data, adminID)
+		if err != nil {
+			log.InfoLogger.Printf("转存图标失败,url=%s: %v", iconURL, err)
+			continue
+		}
+		meta.Icon = "/images/" + file.Hash
+		meta.IconURL = iconURL
+		break
+	}
+	return meta, nil
+}
+
+// Parse 从网页中取出标题、描述、关键词、OpenGraph 和图标，相对地址按 pageURL 转成绝对地址
+func Parse(doc *goquery.Document, pageURL string) Metadata {
+	meta := Metadata{URL: pageURL}
+	values := make(map[string]string)
+	doc.Find("meta").Each(func(_ int, s *goquery.Selection) {
+		key, ok := s.Attr("property")
+		if !ok {
+			key, _ = s.Attr("name")
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		content := clean(s.AttrOr("content", ""))
+		if key == "" || content == "" {
+			return
+		}
+		if _, exists := values[key]; !exists {
+			values[key] = content
+		}
+	})
+
+	meta.Title = clean(doc.Find("title").First().Text())
+	if meta.Title == "" {
+		meta.Title = values["og:title"]
+	}
+	meta.SiteName = values["og:site_name"]
+	meta.Description = values["description"]
+	if meta.Description == "" {
+		meta.Description = values["og:description"]
+	}
+	meta.Keywords = normalizeKeywords(values["keywords"])
+	meta.Image = resolve(pageURL, values["og:image"])
+
+	meta.Title = util.TruncateRunes(meta.Title, 255)
+	meta.Description = util.TruncateRunes(meta.Description, 500)
+	meta.Keywords = util.TruncateRunes(meta.Keywords, 255)
+	meta.IconCandidate = iconCandidates(doc, pageURL)
+	return meta
+}
+
+// iconCandidates 按尺寸从大到小排列页面声明的图标（超过 maxIconEdge 的按 maxIconEdge 算），
+// 没有写尺寸的 apple-touch-icon 按 180 算，普通图标按 16 算，最后补上站点根目录的 /favicon.ico。
+// 上传目录不支持 SVG，所以跳过 SVG 图标
+func iconCandidates(doc *goquery.Document, pageURL string) []string {
+	var links []iconLink
+	doc.Find("link[rel][href]").Each(func(_ int, s *goquery.Selection) {
+		rel := strings.Fields(strings.ToLower(s.AttrOr("rel", "")))
+		isIcon, apple := false, false
+		for _, token := range rel {
+			switch token {
+			case "icon":
+				isIcon = true
+			case "apple-touch-icon", "apple-touch-icon-precomposed":
+				isIcon, apple = true, true
+			}
+		}
+		href := resolve(pageURL, s.AttrOr("href", ""))
+		if !isIcon || href == "" {
+			return
+		}
+		iconType := strings.ToLower(s.AttrOr("type", ""))
+		if strings.Contains(iconType, "svg") || strings.HasSuffix(strings.ToLower(strings.SplitN(href, "?", 2)[0]), ".svg") {
+			return
+		}
+		size := parseSize(s.AttrOr("sizes", ""))
+		if size == 0 {
+			size = 16
+			if apple {
+				size = 180
+			}
+		}
+		links = append(links, iconLink{href: href, size: min(size, maxIconEdge)})
+	})
+	sort.SliceStable(links, func(i, j int) bool {
+		return links[i].size > links[j].size
+	})
+
+	seen := make(map[string]bool)
+	var candidates []string
+	for _, link := range links {
+		if !seen[link.href] {
+			seen[link.href] = true
+			candidates = append(candidates, link.href)
+		}
+	}
+	if favicon := resolve(pageURL, "/favicon.ico"); favicon != "" && !seen[favicon] {
+		candidates = append(candidates, favicon)
+	}
+	return candidates
+}
+
+// parseSize 解析 sizes 属性（如 "32x32 64x64"），返回最大的边长，"any" 或无法解析时返回 0
+func parseSize(sizes string) int {
+	largest := 0
+	for _, size := range strings.Fields(strings.ToLower(sizes)) {
+		width, _, ok := strings.Cut(size, "x")
+		if !ok {
+			continue
+		}
+		if n, err := strconv.Atoi(width); err == nil && n > largest {
+			largest = n
+		}
+	}
+	return largest
+}
+
+// get 发起 GET 请求，返回不超过 limit 字节的响应体、Content-Type 和跳转后的地址
+func get(ctx context.Context, client *http.Client, target string, accept string, limit int64) ([]byte, string, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, "", "", err
+	}
+	req.Header.Set("User-Agent", userAgent())
+	req.Header.Set("Accept", accept)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", "", fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, limit))
+	if err != nil {
+		return nil, "", "", err
+	}
+	return body, resp.Header.Get("Content-Type"), resp.Request.URL.String(), nil
+}
+
+// resolve 把相对地址转成绝对地址，只保留 http/https 地址
+func resolve(baseURL string, ref string) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return ""
+	}
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return ""
+	}
+	u, err := base.Parse(ref)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+	return u.String()
+}
+
+// normalizeKeywords 统一关键词分隔符为英文逗号并去掉重复项
+func normalizeKeywords(keywords string) string {
+	fields := strings.FieldsFunc(keywords, func(r rune) bool {
+		return r == ',' || r == '，' || r == '、' || r == ';' || r == '；' || r == '|'
+	})
+	seen := make(map[string]bool)
+	var result []string
+	for _, field := range fields {
+		field = strings.TrimSpace(field)
+		if field != "" && !seen[field] {
+			seen[field] = true
+			result = append(result, field)
+		}
+	}
+	return strings.Join(result, ",")
+}
+
+// clean 合并连续的空白字符
+func clean(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
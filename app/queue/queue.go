@@ -0,0 +1,463 @@
+// Package queue 后台任务队列：任务持久化在 job 表，Redis 列表只负责尽快通知空闲的工作协程，
+// Redis 不可用或重试延迟到期时由数据库轮询补上。失败按指数退避重试，次数用完进入死信
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"nav-web-site/config"
+	"nav-web-site/mydb"
+	"nav-web-site/util"
+	"nav-web-site/util/log"
+	"runtime"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusDead      = "dead"
+
+	readyKey = "job_queue" // Redis 列表，存放可以立即执行的任务id
+	deadKey  = "job_dead"  // Redis 列表，存放最近进入死信的任务id，供监控使用
+	deadKeep = 1000        // 死信列表最多保留的id数
+
+	defaultWorkers           = 4
+	defaultPollInterval      = 5 * time.Second
+	defaultMaxAttempts       = 5
+	defaultRetryBaseDelay    = 10 * time.Second
+	defaultVisibilityTimeout = 10 * time.Minute
+	maxRetryDelay            = time.Hour
+	maxErrorSize             = 1024
+	popTimeout               = 5 * time.Second
+)
+
+// ErrUnknownJob 任务类型没有注册
+var ErrUnknownJob = errors.New("未知的任务类型")
+
+// Handler 队列任务的执行函数，返回错误时按退避时间重试
+type Handler func(ctx context.Context, payload json.RawMessage) error
+
+// Options 入队选项
+type Options struct {
+	MaxAttempts int           // 最多执行次数，为0时用配置 queue.max_attempts
+	Delay       time.Duration // 延迟执行的时间，run_at 按秒保存，必须是整秒
+}
+
+var (
+	handlers   = make(map[string]Handler)
+	handlersMu sync.RWMutex
+
+	ids         chan int
+	fetchCtx    context.Context // 停止后不再取新任务
+	stopFetch   context.CancelFunc
+	jobCtx      context.Context // 停止等待超时后取消执行中的任务
+	cancelJobs  context.CancelFunc
+	workers     sync.WaitGroup
+	lifecycleMu sync.Mutex
+)
+
+// Register 注册任务类型，类型重复时后注册的覆盖先注册的
+func Register(jobType string, handler Handler) {
+	handlersMu.Lock()
+	defer handlersMu.Unlock()
+	handlers[jobType] = handler
+}
+
+func lookup(jobType string) (Handler, bool) {
+	handlersMu.RLock()
+	defer handlersMu.RUnlock()
+	handler, ok := handlers[jobType]
+	return handler, ok
+}
+
+// Types 返回所有已注册的任务类型
+func Types() []string {
+	handlersMu.RLock()
+	defer handlersMu.RUnlock()
+	types := make([]string, 0, len(handlers))
+	for jobType := range handlers {
+		types = append(types, jobType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Enqueue 任务入队：先写入 job 表，再把id推进 Redis 列表通知工作协程，推送失败时等数据库轮询取走
+func Enqueue(jobType string, payload string, opts Options) (mydb.StructJob, error) {
+	if _, ok := lookup(jobType); !ok {
+		return mydb.StructJob{}, ErrUnknownJob
+	}
+	if payload == "" {
+		payload = "{}"
+	}
+	if !json.Valid([]byte(payload)) {
+		return mydb.StructJob{}, errors.New("任务参数必须是JSON")
+	}
+	if opts.Delay < 0 || opts.Delay%time.Second != 0 {
+		return mydb.StructJob{}, errors.New("延迟时间必须是不小于0的整秒数")
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = config.Config.Queue.MaxAttempts
+		if opts.MaxAttempts <= 0 {
+			opts.MaxAttempts = defaultMaxAttempts
+		}
+	}
+
+	now := util.GetTimestamp(10)
+	job := mydb.StructJob{
+		Job_type:     jobType,
+		Payload:      payload,
+		Status:       StatusQueued,
+		Max_attempts: opts.MaxAttempts,
+		Run_at:       now + int64(opts.Delay/time.Second),
+		Create_time:  now,
+		Update_time:  now,
+	}
+	_, insertedIDs, err := job.Insert([]mydb.StructJob{job})
+	if err != nil {
+		return job, util.WrapError(err, "任务入队失败:")
+	}
+	if len(insertedIDs) == 0 {
+		return job, errors.New("任务入队失败")
+	}
+	job.ID = int(insertedIDs[0])
+	if opts.Delay <= 0 {
+		notify(job.ID)
+	}
+	log.InfoLogger.Printf("任务入队,id=%d,类型=%s", job.ID, job.Job_type)
+	return job, nil
+}
+
+// Get 查询任务状态
+func Get(id int) (mydb.StructJob, error) {
+	return mydb.Tables.Job.Find(mydb.QueryParams{Condition: fmt.Sprintf("id=%d", id)})
+}
+
+// Retry 把死信任务重新放回队列，执行次数清零
+func Retry(id int) (mydb.StructJob, error) {
+	job, err := Get(id)
+	if err != nil {
+		return job, err
+	}
+	if job.Status != StatusDead {
+		return job, fmt.Errorf("只有死信任务可以重新入队，当前状态: %s", job.Status)
+	}
+	now := util.GetTimestamp(10)
+	job.Status = StatusQueued
+	job.Attempts = 0
+	job.Run_at = now
+	job.Finish_time = 0
+	job.Update_time = now
+	if _, _, err := job.Update([]mydb.StructJob{job}, fmt.Sprintf("id=%d AND status='%s'", job.ID, StatusDead)); err != nil {
+		return job, util.WrapError(err, "任务重新入队失败:")
+	}
+	notify(job.ID)
+	return job, nil
+}
+
+// notify 把任务id推进 Redis 列表
+func notify(id int) {
+	if err := mydb.RedisClient.LPush(mydb.Ctx, readyKey, id).Err(); err != nil {
+		log.ErrorLogger.Printf("推送任务到Redis失败,等待数据库轮询,id=%d: %v", id, err)
+	}
+}
+
+// Start 启动工作协程、Redis 消费协程和数据库轮询协程
+func Start() {
+	lifecycleMu.Lock()
+	defer lifecycleMu.Unlock()
+	if stopFetch != nil {
+		return
+	}
+
+	count := config.Config.Queue.Workers
+	if count <= 0 {
+		count = defaultWorkers
+	}
+	ids = make(chan int)
+	fetchCtx, stopFetch = context.WithCancel(context.Background())
+	jobCtx, cancelJobs = context.WithCancel(context.Background())
+
+	for i := 0; i < count; i++ {
+		workers.Add(1)
+		go work()
+	}
+	go consumeRedis()
+	go pollDatabase(count)
+	log.InfoLogger.Printf("Job queue started with %d workers", count)
+}
+
+// Stop 停止取新任务，等待执行中的任务结束；ctx 到期时取消执行中的任务，它们会在下次启动后重试
+func Stop(ctx context.Context) error {
+	lifecycleMu.Lock()
+	defer lifecycleMu.Unlock()
+	if stopFetch == nil {
+		return nil
+	}
+	stopFetch()
+
+	done := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		cancelJobs()
+		return nil
+	case <-ctx.Done():
+		cancelJobs()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// dispatch 把任务id交给空闲的工作协程，停止时放弃（任务仍在表里排队）
+func dispatch(id int) bool {
+	select {
+	case ids <- id:
+		return true
+	case <-fetchCtx.Done():
+		return false
+	}
+}
+
+// consumeRedis 阻塞读取 Redis 列表里的任务id，Redis 出错时暂停一个轮询间隔再试
+func consumeRedis() {
+	for fetchCtx.Err() == nil {
+		result, err := mydb.RedisClient.BRPop(fetchCtx, popTimeout, readyKey).Result()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			if fetchCtx.Err() != nil {
+				return
+			}
+			log.ErrorLogger.Printf("读取Redis任务队列失败: %v", err)
+			sleep(fetchCtx, pollInterval())
+			continue
+		}
+		// BRPop 返回 [key, value]
+		id, err := strconv.Atoi(result[1])
+		if err != nil {
+			continue
+		}
+		if !dispatch(id) {
+			return
+		}
+	}
+}
+
+// pollDatabase 定期把执行超时的任务放回队列，并取出到期的排队任务，保证 Redis 丢消息时任务也会执行
+func pollDatabase(batch int) {
+	ticker := time.NewTicker(pollInterval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-fetchCtx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		staleBefore := util.GetTimestamp(10) - int64(visibilityTimeout()/time.Second)
+		count, dead, err := mydb.Tables.Job.RequeueStale(staleBefore)
+		if err != nil {
+			log.ErrorLogger.Printf("放回超时任务失败: %v", err)
+		}
+		if count > 0 {
+			log.InfoLogger.Printf("%d个执行超时的任务已放回队列", count)
+		}
+		for _, id := range dead {
+			log.ErrorLogger.Printf("任务执行超时且重试次数已用完,进入死信,id=%d", id)
+			recordDead(id)
+		}
+
+		jobs, code, err := mydb.Tables.Job.Select(mydb.QueryParams{
+			Condition: fmt.Sprintf("status='%s' AND run_at<=%d", StatusQueued, util.GetTimestamp(10)),
+			OrderBy:   "run_at ASC, id ASC",
+			Limit:     batch,
+		})
+		if err != nil && code != 200 {
+			log.ErrorLogger.Printf("查询排队任务失败: %v", err)
+			continue
+		}
+		for _, job := range jobs {
+			if !dispatch(job.ID) {
+				return
+			}
+		}
+	}
+}
+
+// work 工作协程，逐个执行分到的任务
+func work() {
+	defer workers.Done()
+	for {
+		select {
+		case <-fetchCtx.Done():
+			return
+		case id := <-ids:
+			process(id)
+		}
+	}
+}
+
+// process 认领并执行任务，按结果标记成功、安排重试或进入死信
+func process(id int) {
+	claimed, err := mydb.Tables.Job.Claim(id)
+	if err != nil {
+		log.ErrorLogger.Printf("认领任务失败,id=%d: %v", id, err)
+		return
+	}
+	if !claimed {
+		// 已被其他协程或实例认领，或还没到重试时间
+		return
+	}
+	job, err := Get(id)
+	if err != nil {
+		log.ErrorLogger.Printf("读取任务失败,id=%d: %v", id, err)
+		return
+	}
+
+	stopHeartbeat := heartbeat(job.ID)
+	err = run(job)
+	stopHeartbeat()
+
+	now := util.GetTimestamp(10)
+	job.Update_time = now
+	switch {
+	case err == nil:
+		job.Status = StatusSucceeded
+		job.Finish_time = now
+		job.Last_error = ""
+		log.InfoLogger.Printf("任务执行成功,id=%d,类型=%s,第%d次", job.ID, job.Job_type, job.Attempts)
+	case job.Attempts >= job.Max_attempts || errors.Is(err, ErrUnknownJob):
+		job.Status = StatusDead
+		job.Finish_time = now
+		job.Last_error = util.Truncate(err.Error(), maxErrorSize)
+		log.ErrorLogger.Printf("任务重试耗尽进入死信,id=%d,类型=%s,第%d次: %v", job.ID, job.Job_type, job.Attempts, err)
+	default:
+		delay := retryDelay(job.Attempts)
+		job.Status = StatusQueued
+		job.Run_at = now + int64(delay/time.Second)
+		job.Last_error = util.Truncate(err.Error(), maxErrorSize)
+		log.ErrorLogger.Printf("任务执行失败,%s后重试,id=%d,类型=%s,第%d次: %v", delay, job.ID, job.Job_type, job.Attempts, err)
+	}
+
+	// 心跳中断超过可见性超时后任务会被放回队列并由其他协程重新认领，attempts 随之增加，
+	// 这时本次结果已经过期，不能覆盖正在进行的执行
+	count, _, err := job.Update([]mydb.StructJob{job}, fmt.Sprintf("id=%d AND status='%s' AND attempts=%d", job.ID, StatusRunning, job.Attempts))
+	if err != nil {
+		log.ErrorLogger.Printf("更新任务状态失败,id=%d: %v", job.ID, err)
+		return
+	}
+	if count == 0 {
+		log.ErrorLogger.Printf("任务执行超时后已被重新放回队列,丢弃本次结果,id=%d,第%d次", job.ID, job.Attempts)
+		return
+	}
+	if job.Status == StatusDead {
+		recordDead(job.ID)
+	}
+}
+
+// recordDead 把进入死信的任务id记到 Redis 列表，供监控使用
+func recordDead(id int) {
+	pipe := mydb.RedisClient.TxPipeline()
+	pipe.LPush(mydb.Ctx, deadKey, id)
+	pipe.LTrim(mydb.Ctx, deadKey, 0, deadKeep-1)
+	if _, err := pipe.Exec(mydb.Ctx); err != nil {
+		log.ErrorLogger.Printf("记录死信任务到Redis失败,id=%d: %v", id, err)
+	}
+}
+
+// run 调用任务的执行函数，panic 转成错误
+func run(job mydb.StructJob) (err error) {
+	handler, ok := lookup(job.Job_type)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownJob, job.Job_type)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			buf := make([]byte, 1<<16)
+			stack := string(buf[:runtime.Stack(buf, false)])
+			log.ErrorLogger.Printf("Job %d panicked: %v\nStack trace:\n%s", job.ID, r, stack)
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return handler(jobCtx, json.RawMessage(job.Payload))
+}
+
+// heartbeat 执行期间定期刷新任务的更新时间，返回停止函数
+func heartbeat(id int) func() {
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(visibilityTimeout() / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if err := mydb.Tables.Job.Touch(id); err != nil {
+					log.ErrorLogger.Printf("刷新任务心跳失败,id=%d: %v", id, err)
+				}
+			}
+		}
+	}()
+	return func() {
+		close(stop)
+		<-done
+	}
+}
+
+// retryDelay 第 attempt 次失败后的退避时间：基础时间每次翻倍，最长1小时，加上最多20%的随机抖动
+func retryDelay(attempt int) time.Duration {
+	base := defaultRetryBaseDelay
+	if config.Config.Queue.RetryBaseDelay > 0 {
+		base = time.Duration(config.Config.Queue.RetryBaseDelay) * time.Second
+	}
+	delay := base
+	for i := 1; i < attempt && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+	return delay + time.Duration(rand.Int63n(int64(delay)/5+1))
+}
+
+func pollInterval() time.Duration {
+	if config.Config.Queue.PollInterval > 0 {
+		return time.Duration(config.Config.Queue.PollInterval) * time.Second
+	}
+	return defaultPollInterval
+}
+
+func visibilityTimeout() time.Duration {
+	if config.Config.Queue.VisibilityTimeout > 0 {
+		return time.Duration(config.Config.Queue.VisibilityTimeout) * time.Second
+	}
+	return defaultVisibilityTimeout
+}
+
+// sleep 等待一段时间，ctx 取消时提前返回
+func sleep(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
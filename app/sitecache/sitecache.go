@@ -0,0 +1,55 @@
+// Package sitecache 公开页面缓存的失效：渲染结果的缓存key带上版本号，数据变化时把版本号加一，
+// 版本号保存在 Redis 里，所有实例的旧缓存同时失效。后台接口、新闻抓取和队列任务写完数据后调用这里的 Forget 系列函数，
+// 不需要依赖各个接口包
+package sitecache
+
+import (
+	"context"
+	"errors"
+	"nav-web-site/mydb"
+	"nav-web-site/util/log"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	syndicationVersionKey = "news_syndication_version" // 新闻订阅缓存的版本号
+	homeVersionKey        = "site_home_version"        // 首页缓存的版本号
+)
+
+// SyndicationVersion 新闻订阅缓存当前的版本号，作为缓存key的一部分。Redis 不可用时返回错误，这时不应使用缓存
+func SyndicationVersion(ctx context.Context) (int64, error) {
+	return version(ctx, syndicationVersionKey)
+}
+
+// ForgetSyndication 新闻或新闻分类变化后调用，使所有实例的新闻订阅缓存失效
+func ForgetSyndication() {
+	bump(syndicationVersionKey)
+}
+
+// HomeVersion 首页缓存当前的版本号，作为缓存key的一部分。Redis 不可用时返回错误，这时不应使用缓存
+func HomeVersion(ctx context.Context) (int64, error) {
+	return version(ctx, homeVersionKey)
+}
+
+// ForgetHome 导航或分类变化后调用，使首页缓存失效。
+// 版本号变化前开始生成的首页数据写回的是旧版本的key，不会被之后的请求读到
+func ForgetHome() {
+	bump(homeVersionKey)
+}
+
+// version 读取版本号，还没有设置过时为0
+func version(ctx context.Context, key string) (int64, error) {
+	value, err := mydb.RedisClient.Get(ctx, key).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	return value, err
+}
+
+// bump 版本号加一
+func bump(key string) {
+	if err := mydb.RedisClient.Incr(context.Background(), key).Err(); err != nil {
+		log.ErrorLogger.Printf("更新缓存版本号失败,key=%s: %v", key, err)
+	}
+}
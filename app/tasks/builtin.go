@@ -0,0 +1,75 @@
+package tasks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"nav-web-site/app/api/upload"
+	"nav-web-site/app/api/v1/certificate"
+	"nav-web-site/app/ddns"
+	"nav-web-site/app/navhealth"
+	"nav-web-site/app/queue"
+	"nav-web-site/app/webcrawler"
+)
+
+// TaskPayload 队列任务 task 的参数：在后台执行一次指定类型的任务
+type TaskPayload struct {
+	Type   string          `json:"type"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+func init() {
+	// 清理未被引用的上传文件
+	Register("upload_gc", func(ctx context.Context, _ json.RawMessage) (Stats, error) {
+		removed, err := upload.CleanOrphanFiles()
+		return Stats{Fetched: len(removed)}, err
+	})
+
+	// 续期证书清单里快到期的证书，续期失败或快到期时发送告警
+	Register("cert_renew", func(ctx context.Context, _ json.RawMessage) (Stats, error) {
+		renewed, failed, err := certificate.RenewExpiring(ctx)
+		return Stats{Inserted: renewed, Failed: failed}, err
+	})
+
+	// 公网地址变化时更新动态域名，参数可带 records 覆盖配置的 ddns.records
+	Register("ddns", func(ctx context.Context, params json.RawMessage) (Stats, error) {
+		result, err := ddns.Run(ctx, params)
+		return Stats{Fetched: result.Checked, Inserted: result.Updated, Skipped: result.Skipped, Failed: result.Failed}, err
+	})
+
+	// 检查导航链接，连续失败的自动隐藏。计数：Fetched 检查数、Failed 失败数、Skipped 隐藏数、Inserted 恢复显示数
+	Register("nav_health", func(ctx context.Context, _ json.RawMessage) (Stats, error) {
+		result, err := navhealth.Run(ctx)
+		return Stats{Fetched: result.Checked, Failed: result.Broken, Skipped: result.Hidden, Inserted: result.Recovered}, err
+	})
+
+	// 通过队列执行任务，如把抓取交给空闲的实例；同类任务正在执行时按退避时间重试
+	queue.Register("task", func(ctx context.Context, payload json.RawMessage) error {
+		var p TaskPayload
+		if err := json.Unmarshal(payload, &p); err != nil {
+			return err
+		}
+		if p.Type == "" {
+			return errors.New("缺少任务类型")
+		}
+		_, err := Execute(ctx, p.Type, TriggerQueue, string(p.Params))
+		if errors.Is(err, ErrUnknownTask) {
+			// 重试也不会成功，直接进入死信
+			return fmt.Errorf("%w: %s", queue.ErrUnknownJob, p.Type)
+		}
+		return err
+	})
+
+	// 转存新闻里的外链图片，content.rehost_async 开启时抓取的新闻入库后交给队列执行
+	queue.Register(webcrawler.RehostImagesJob, func(ctx context.Context, payload json.RawMessage) error {
+		var p webcrawler.RehostImagesPayload
+		if err := json.Unmarshal(payload, &p); err != nil {
+			return err
+		}
+		if p.News_id <= 0 {
+			return fmt.Errorf("%w: 缺少新闻id", queue.ErrUnknownJob)
+		}
+		return webcrawler.RehostNewsImages(ctx, p.News_id)
+	})
+}
@@ -0,0 +1,66 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"nav-web-site/config"
+	"nav-web-site/mydb"
+	"nav-web-site/util"
+	"nav-web-site/util/redislock"
+	"os"
+	"time"
+)
+
+const (
+	defaultLockTTL = 60 * time.Second
+	tickTTL        = 10 * time.Minute // 定时触发的认领标记保留时间，远大于各实例触发时间的偏差
+)
+
+type lockContextKey struct{}
+
+// lockTTL 任务锁的有效期，持有期间每隔三分之一有效期续期一次
+func lockTTL() time.Duration {
+	if config.Config.TaskLock.TTL > 0 {
+		return time.Duration(config.Config.TaskLock.TTL) * time.Second
+	}
+	return defaultLockTTL
+}
+
+// lockTask 取得任务类型的分布式锁，其他实例正在执行时返回 ErrTaskRunning
+func lockTask(ctx context.Context, taskType string) (*redislock.Lock, error) {
+	lock, err := redislock.Acquire(ctx, mydb.RedisClient, "task_lock:"+taskType, lockTTL())
+	if errors.Is(err, redislock.ErrNotAcquired) {
+		return nil, ErrTaskRunning
+	}
+	if err != nil {
+		return nil, util.WrapError(err, "获取任务锁失败:")
+	}
+	return lock, nil
+}
+
+// hold 开始为锁续期，返回的 context 在锁丢失时取消，并带上锁供 CheckLock 校验
+func hold(ctx context.Context, lock *redislock.Lock) context.Context {
+	return context.WithValue(lock.Hold(ctx), lockContextKey{}, lock)
+}
+
+// CheckLock 确认当前执行仍持有锁，长时间运行的任务在提交结果前调用，锁已丢失时应放弃提交。
+// 新闻源的抓取通过 webcrawler.WithCommitCheck 在每条新闻入库前调用
+func CheckLock(ctx context.Context) error {
+	lock, ok := ctx.Value(lockContextKey{}).(*redislock.Lock)
+	if !ok {
+		return nil
+	}
+	if err := context.Cause(ctx); errors.Is(err, redislock.ErrLockLost) {
+		return err
+	}
+	return lock.Check(ctx)
+}
+
+// claimTick 认领计划任务的一次定时触发：各实例的调度器触发同一次计划时，只有一个实例认领成功。
+// scheduled 是 scheduledTime 算出的计划时间，和实际开始执行的时间无关，直接作为认领的key
+func claimTick(ctx context.Context, name string, scheduled time.Time) (bool, error) {
+	key := fmt.Sprintf("task_tick:%s:%d", name, scheduled.Unix())
+	hostname, _ := os.Hostname()
+	return mydb.RedisClient.SetNX(ctx, key, hostname, tickTTL).Result()
+}
@@ -0,0 +1,376 @@
+package tasks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"nav-web-site/config"
+	"nav-web-site/mydb"
+	"nav-web-site/util"
+	"nav-web-site/util/log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/robfig/cron/v3"
+)
+
+const (
+	SourceConfig = "config" // 配置文件同步的计划任务
+	SourceAdmin  = "admin"  // 后台添加的计划任务
+
+	reloadChannel      = "scheduled_task_reload"  // 计划任务变化后通知所有实例重新调度的频道
+	reloadVersionKey   = "scheduled_task_version" // 计划任务的版本号，每次变化加一，实例漏收通知时靠轮询它补上
+	reloadPollInterval = 30 * time.Second
+	cancelGrace        = 5 * time.Second // 取消正在执行的任务后，等它们写完执行记录的时间
+)
+
+var (
+	scheduler   *cron.Cron
+	entries     = make(map[int]cron.EntryID) // 计划任务id对应的调度条目
+	schedulerMu sync.Mutex
+
+	stopWatch     context.CancelFunc // 停止监听重新调度的通知
+	loadedVersion int64              // 本实例最近一次重新调度时的版本号
+
+	runCtx, cancelRuns = context.WithCancel(context.Background()) // 定时和手动执行的任务都从它派生，停止调度器时取消
+)
+
+// Context 定时和手动执行任务使用的 context，StopScheduler 等待超时后取消，任务在关闭数据库连接之前退出
+func Context() context.Context {
+	return runCtx
+}
+
+// StartScheduler 把配置文件里的计划任务同步进 scheduled_task 表，按表里启用的任务启动调度器，
+// 配置文件热更新后新增的任务也会同步进来
+func StartScheduler() {
+	schedulerMu.Lock()
+	scheduler = cron.New()
+	schedulerMu.Unlock()
+
+	if SyncConfigTasks() {
+		PublishReload()
+	}
+	reloadVersion(mydb.Ctx)
+	scheduler.Start()
+	log.InfoLogger.Println("Scheduled tasks started")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	schedulerMu.Lock()
+	stopWatch = cancel
+	schedulerMu.Unlock()
+	go watchReload(ctx)
+
+	config.OnReload(func() {
+		if SyncConfigTasks() {
+			PublishReload()
+		}
+		Reload()
+	})
+}
+
+// PublishReload 计划任务在数据库里变化后调用：版本号加一并通知所有实例（包括本实例）按 scheduled_task 表重新调度
+func PublishReload() {
+	if err := mydb.RedisClient.Incr(mydb.Ctx, reloadVersionKey).Err(); err != nil {
+		log.ErrorLogger.Printf("更新计划任务版本号失败: %v", err)
+	}
+	if err := mydb.RedisClient.Publish(mydb.Ctx, reloadChannel, "reload").Err(); err != nil {
+		log.ErrorLogger.Printf("发送重新调度通知失败: %v", err)
+	}
+}
+
+// watchReload 收到重新调度的通知时重新调度；订阅断开期间漏掉的通知，由定时比较版本号补上
+func watchReload(ctx context.Context) {
+	pubsub := mydb.RedisClient.Subscribe(ctx, reloadChannel)
+	defer pubsub.Close()
+	messages := pubsub.Channel()
+	ticker := time.NewTicker(reloadPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case _, ok := <-messages:
+			if !ok {
+				return
+			}
+			reloadVersion(ctx)
+		case <-ticker.C:
+			version, err := mydb.RedisClient.Get(ctx, reloadVersionKey).Int64()
+			if err != nil && !errors.Is(err, redis.Nil) {
+				continue
+			}
+			schedulerMu.Lock()
+			changed := version != loadedVersion
+			schedulerMu.Unlock()
+			if changed {
+				log.InfoLogger.Printf("计划任务版本号变化(%d),重新调度", version)
+				reloadVersion(ctx)
+			}
+		}
+	}
+}
+
+// reloadVersion 记下当前版本号后重新调度。先取版本号再读表，读表之后的变化会让版本号再变，下次轮询时还会重新调度
+func reloadVersion(ctx context.Context) {
+	version, err := mydb.RedisClient.Get(ctx, reloadVersionKey).Int64()
+	if err == nil || errors.Is(err, redis.Nil) {
+		schedulerMu.Lock()
+		loadedVersion = version
+		schedulerMu.Unlock()
+	}
+	Reload()
+}
+
+// StopScheduler 停止定时触发，等待正在执行的任务（包括手动和队列触发的）结束。
+// ctx 到期时取消任务的 context，再最多等 cancelGrace 让任务写完执行记录，之后才能关闭数据库连接
+func StopScheduler(ctx context.Context) error {
+	schedulerMu.Lock()
+	if stopWatch != nil {
+		stopWatch()
+	}
+	if scheduler != nil {
+		scheduler.Stop()
+	}
+	schedulerMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		inflight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		cancelRuns()
+		log.InfoLogger.Println("Scheduled tasks stopped")
+		return nil
+	case <-ctx.Done():
+	}
+
+	cancelRuns()
+	select {
+	case <-done:
+		log.InfoLogger.Println("Scheduled tasks cancelled")
+	case <-time.After(cancelGrace):
+	}
+	return ctx.Err()
+}
+
+// SyncConfigTasks 把配置文件里的任务按名称（即任务类型）写进 scheduled_task 表，来源记为 config。
+// 配置文件里的任务以配置为准：表里已有同名任务而 cron 表达式或时区和配置不同时，改成配置里的值，
+// 后台不能修改这类任务的名称、类型、cron 表达式和时区，也不能删除；参数和启用状态以表为准。
+// 从配置文件里去掉的任务改回 admin 来源，之后可以在后台修改或删除。表里有任务被新增或修改时返回 true
+func SyncConfigTasks() bool {
+	changed := false
+	configured := make(map[string]bool)
+	for _, taskConfig := range config.Config.Tasks {
+		if taskConfig.Type == "" || taskConfig.Schedule == "" {
+			continue
+		}
+		configured[taskConfig.Type] = true
+		condition := fmt.Sprintf("name='%s'", mydb.EscapeString(taskConfig.Type))
+		if existing, err := mydb.Tables.ScheduledTask.Find(mydb.QueryParams{Condition: condition}); err == nil {
+			if existing.Cron_expr == taskConfig.Schedule && existing.Timezone == taskConfig.Timezone && existing.Source == SourceConfig {
+				continue
+			}
+			log.InfoLogger.Printf("配置文件中的计划任务有变化,任务=%s,cron=%s -> %s,时区=%q -> %q,来源=%s",
+				existing.Name, existing.Cron_expr, taskConfig.Schedule, existing.Timezone, taskConfig.Timezone, existing.Source)
+			existing.Cron_expr = taskConfig.Schedule
+			existing.Timezone = taskConfig.Timezone
+			existing.Source = SourceConfig
+			existing.Update_time = util.GetTimestamp(10)
+			if _, _, err := existing.Update([]mydb.StructScheduledTask{existing}, "id="+strconv.Itoa(existing.ID)); err != nil {
+				log.ErrorLogger.Printf("同步配置文件中的计划任务失败,任务=%s: %v", taskConfig.Type, err)
+				continue
+			}
+			changed = true
+			continue
+		}
+
+		task := mydb.Tables.ScheduledTask.DefaultData()
+		task.Name = taskConfig.Type
+		task.Task_type = taskConfig.Type
+		task.Cron_expr = taskConfig.Schedule
+		task.Timezone = taskConfig.Timezone
+		task.Source = SourceConfig
+		if _, _, err := task.Insert([]mydb.StructScheduledTask{task}); err != nil {
+			log.ErrorLogger.Printf("同步配置文件中的计划任务失败,任务=%s: %v", taskConfig.Type, err)
+			continue
+		}
+		changed = true
+		log.InfoLogger.Printf("配置文件中的计划任务已写入数据库,任务=%s,cron=%s", task.Task_type, task.Cron_expr)
+	}
+
+	list, code, err := mydb.Tables.ScheduledTask.Select(mydb.QueryParams{Condition: fmt.Sprintf("source='%s'", SourceConfig)})
+	if err != nil && code != 200 {
+		log.ErrorLogger.Printf("读取配置文件来源的计划任务失败: %v", err)
+		return changed
+	}
+	for _, task := range list {
+		if configured[task.Name] {
+			continue
+		}
+		task.Source = SourceAdmin
+		task.Update_time = util.GetTimestamp(10)
+		if _, _, err := task.Update([]mydb.StructScheduledTask{task}, "id="+strconv.Itoa(task.ID)); err != nil {
+			log.ErrorLogger.Printf("更新计划任务来源失败,任务=%s: %v", task.Name, err)
+			continue
+		}
+		changed = true
+		log.InfoLogger.Printf("计划任务已从配置文件中去掉,改为后台管理,任务=%s", task.Name)
+	}
+	return changed
+}
+
+// Reload 清空调度器后按 scheduled_task 表重新调度，读表失败时退回直接调度配置文件里的任务
+func Reload() {
+	list, code, err := mydb.Tables.ScheduledTask.Select(mydb.QueryParams{Condition: "is_enable=1"})
+	if err != nil && code != 200 {
+		log.ErrorLogger.Printf("读取计划任务失败,改用配置文件中的任务: %v", err)
+		list = nil
+		for i, taskConfig := range config.Config.Tasks {
+			list = append(list, mydb.StructScheduledTask{
+				ID:        -(i + 1),
+				Name:      taskConfig.Type,
+				Task_type: taskConfig.Type,
+				Cron_expr: taskConfig.Schedule,
+				Timezone:  taskConfig.Timezone,
+				Is_enable: true,
+			})
+		}
+	}
+
+	schedulerMu.Lock()
+	for id, entryID := range entries {
+		scheduler.Remove(entryID)
+		delete(entries, id)
+	}
+	schedulerMu.Unlock()
+
+	for _, task := range list {
+		if err := Schedule(task); err != nil {
+			log.ErrorLogger.Printf("Error scheduling task %s: %v", task.Name, err)
+		}
+	}
+}
+
+// Schedule 按计划任务的最新设置调度：先移除旧的调度条目，启用的任务再按新的 cron 表达式加入
+func Schedule(task mydb.StructScheduledTask) error {
+	schedulerMu.Lock()
+	defer schedulerMu.Unlock()
+	if scheduler == nil {
+		return errors.New("调度器未启动")
+	}
+
+	if entryID, ok := entries[task.ID]; ok {
+		scheduler.Remove(entryID)
+		delete(entries, task.ID)
+	}
+	if !task.Is_enable {
+		return nil
+	}
+
+	schedule, err := cron.ParseStandard(Spec(task))
+	if err != nil {
+		return util.WrapError(err, "调度任务失败:")
+	}
+	entryID := scheduler.Schedule(schedule, cron.FuncJob(func() {
+		// 多个实例同时触发时只有认领到这次触发的实例执行，按计划时间认领，和实际开始执行的时间无关
+		claimed, err := claimTick(runCtx, task.Name, scheduledTime(schedule, time.Now()))
+		if err != nil {
+			log.ErrorLogger.Printf("Error claiming scheduled task %s: %v", task.Name, err)
+			return
+		}
+		if !claimed {
+			log.InfoLogger.Printf("Scheduled task %s already claimed by another instance, skipped", task.Name)
+			return
+		}
+		log.InfoLogger.Printf("Executing scheduled task %s", task.Name)
+		_, err = Execute(runCtx, task.Task_type, TriggerCron, task.Params)
+		if errors.Is(err, ErrTaskRunning) {
+			log.InfoLogger.Printf("Task %s is still running on this or another instance, skipped", task.Task_type)
+		} else if err != nil {
+			log.ErrorLogger.Printf("Error executing task %s: %v", task.Task_type, err)
+		}
+	}))
+	entries[task.ID] = entryID
+	log.InfoLogger.Printf("Scheduling task %s (%s) with schedule %s", task.Name, task.Task_type, Spec(task))
+	return nil
+}
+
+// scheduledTime 这次触发的计划时间，各实例对同一次触发算出的时间相同。
+// cron 表达式的触发时间在整分钟上，相邻两次至少隔一分钟，往前一分钟再取下一次触发时间就是这次的计划时间；
+// @every 的触发时刻取决于各实例的启动时间，按间隔分段，同一段里的触发算同一次
+func scheduledTime(schedule cron.Schedule, now time.Time) time.Time {
+	switch s := schedule.(type) {
+	case *cron.SpecSchedule:
+		if tick := s.Next(now.Add(-time.Minute)); !tick.After(now) {
+			return tick
+		}
+	case cron.ConstantDelaySchedule:
+		return now.Truncate(s.Delay)
+	}
+	return now.Truncate(time.Minute)
+}
+
+// Unschedule 从调度器移除计划任务
+func Unschedule(taskID int) {
+	schedulerMu.Lock()
+	defer schedulerMu.Unlock()
+	if entryID, ok := entries[taskID]; ok {
+		scheduler.Remove(entryID)
+		delete(entries, taskID)
+	}
+}
+
+// NextRun 计划任务下一次执行的时间，任务没有在调度时返回 false
+func NextRun(taskID int) (time.Time, bool) {
+	schedulerMu.Lock()
+	defer schedulerMu.Unlock()
+	entryID, ok := entries[taskID]
+	if !ok {
+		return time.Time{}, false
+	}
+	entry := scheduler.Entry(entryID)
+	return entry.Next, entry.Valid() && !entry.Next.IsZero()
+}
+
+// Spec 带时区的 cron 表达式，如 CRON_TZ=Asia/Shanghai 0 3 * * *
+func Spec(task mydb.StructScheduledTask) string {
+	if task.Timezone == "" {
+		return task.Cron_expr
+	}
+	return "CRON_TZ=" + task.Timezone + " " + task.Cron_expr
+}
+
+// Validate 检查计划任务的任务类型、时区、cron 表达式和 JSON 参数
+func Validate(task mydb.StructScheduledTask) error {
+	if strings.TrimSpace(task.Name) == "" {
+		return errors.New("任务名称不能为空")
+	}
+	if _, ok := Lookup(task.Task_type); !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownTask, task.Task_type)
+	}
+	if strings.HasPrefix(strings.TrimSpace(task.Cron_expr), "CRON_TZ=") || strings.HasPrefix(strings.TrimSpace(task.Cron_expr), "TZ=") {
+		return errors.New("时区请通过 timezone 设置")
+	}
+	if task.Timezone != "" {
+		if _, err := time.LoadLocation(task.Timezone); err != nil {
+			return fmt.Errorf("无效的时区: %s", task.Timezone)
+		}
+	}
+	if _, err := cron.ParseStandard(Spec(task)); err != nil {
+		return fmt.Errorf("无效的cron表达式: %v", err)
+	}
+	if task.Params != "" {
+		var params map[string]interface{}
+		if err := json.Unmarshal([]byte(task.Params), &params); err != nil {
+			return errors.New("参数必须是JSON对象")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package tasks
+
+import (
+	"testing"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+func TestScheduledTime(t *testing.T) {
+	parse := func(spec string) cron.Schedule {
+		t.Helper()
+		schedule, err := cron.ParseStandard(spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return schedule
+	}
+	at := func(value string) time.Time {
+		t.Helper()
+		tm, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tm
+	}
+
+	tests := []struct {
+		name string
+		spec string
+		now  string
+		want string
+	}{
+		{"cron 按时触发", "*/5 * * * *", "2024-01-31T03:05:00Z", "2024-01-31T03:05:00Z"},
+		{"cron 延迟触发", "*/5 * * * *", "2024-01-31T03:05:40.5Z", "2024-01-31T03:05:00Z"},
+		{"带时区的 cron", "CRON_TZ=Asia/Shanghai 0 3 * * *", "2024-01-30T19:00:02Z", "2024-01-30T19:00:00Z"},
+		// 不同时间启动的实例在同一个间隔里触发，认领同一个计划时间
+		{"@every 段首", "@every 30s", "2024-01-31T03:05:30.2Z", "2024-01-31T03:05:30Z"},
+		{"@every 段尾", "@every 30s", "2024-01-31T03:05:59.9Z", "2024-01-31T03:05:30Z"},
+		{"@every 下一段", "@every 30s", "2024-01-31T03:06:00.1Z", "2024-01-31T03:06:00Z"},
+		{"@every 长间隔", "@every 1h", "2024-01-31T03:59:00Z", "2024-01-31T03:00:00Z"},
+	}
+	for _, tt := range tests {
+		got := scheduledTime(parse(tt.spec), at(tt.now))
+		if want := at(tt.want); !got.Equal(want) {
+			t.Errorf("%s: scheduledTime(%q, %s) = %s, want %s", tt.name, tt.spec, tt.now, got.UTC(), want)
+		}
+	}
+}
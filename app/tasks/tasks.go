@@ -0,0 +1,243 @@
+// Package tasks 计划任务的注册和执行，每次执行都记录到 task_run 表
+package tasks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"nav-web-site/app/webcrawler"
+	"nav-web-site/mydb"
+	"nav-web-site/util"
+	"nav-web-site/util/log"
+	"nav-web-site/util/redislock"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	TriggerCron   = "cron"   // 定时触发
+	TriggerManual = "manual" // 后台手动触发
+	TriggerQueue  = "queue"  // 由队列任务 task 触发
+
+	StatusRunning = "running"
+	StatusSuccess = "success"
+	StatusFailed  = "failed"
+
+	maxErrorSize = 1024     // 错误信息最多保存的字节数
+	maxStackSize = 4 * 1024 // 调用栈最多保存的字节数
+)
+
+// ErrUnknownTask 任务类型未注册，也不是新闻源
+var ErrUnknownTask = errors.New("未知的任务类型")
+
+// ErrTaskRunning 同一类型的任务正在执行
+var ErrTaskRunning = errors.New("任务正在执行")
+
+// Stats 任务执行的计数，含义由任务自己决定，如抓取任务的抓取、入库、跳过、失败条数
+type Stats struct {
+	Fetched  int
+	Inserted int
+	Skipped  int
+	Failed   int
+}
+
+// Handler 任务的执行函数，params 是计划任务配置的 JSON 参数，没有配置时为 {}
+type Handler func(ctx context.Context, params json.RawMessage) (Stats, error)
+
+var (
+	handlers   = make(map[string]Handler)
+	handlersMu sync.RWMutex
+
+	running   = make(map[string]bool)
+	runningMu sync.Mutex
+
+	inflight sync.WaitGroup // 正在执行的任务，停止时等待它们结束
+)
+
+// Register 注册任务，类型重复时后注册的覆盖先注册的
+func Register(taskType string, handler Handler) {
+	handlersMu.Lock()
+	defer handlersMu.Unlock()
+	handlers[taskType] = handler
+}
+
+// Lookup 按类型获取任务，没有注册时和同名的新闻源对应，执行该新闻源的抓取（如 news163）
+func Lookup(taskType string) (Handler, bool) {
+	handlersMu.RLock()
+	handler, ok := handlers[taskType]
+	handlersMu.RUnlock()
+	if ok {
+		return handler, true
+	}
+	if _, ok := webcrawler.GetSource(taskType); ok {
+		return func(ctx context.Context, _ json.RawMessage) (Stats, error) {
+			// 每条新闻入库前确认仍持有任务锁，锁丢失后不再写入
+			result, err := webcrawler.Run(webcrawler.WithCommitCheck(ctx, CheckLock), taskType)
+			return Stats(result), err
+		}, true
+	}
+	return nil, false
+}
+
+// Types 返回所有可执行的任务类型，包括新闻源
+func Types() []string {
+	handlersMu.RLock()
+	defer handlersMu.RUnlock()
+	types := make([]string, 0, len(handlers))
+	for taskType := range handlers {
+		types = append(types, taskType)
+	}
+	for _, name := range webcrawler.Names() {
+		if _, ok := handlers[name]; !ok {
+			types = append(types, name)
+		}
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Execute 同步执行任务并记录执行结果，返回执行记录
+func Execute(ctx context.Context, taskType string, trigger string, params string) (mydb.StructTaskRun, error) {
+	handler, lock, err := prepare(ctx, taskType)
+	if err != nil {
+		return mydb.StructTaskRun{}, err
+	}
+	defer cleanup(taskType, lock)
+
+	run := begin(taskType, trigger)
+	return finish(hold(ctx, lock), run, handler, params), nil
+}
+
+// Start 加锁并登记执行记录后在后台执行任务，立即返回状态为 running 的执行记录
+func Start(ctx context.Context, taskType string, trigger string, params string) (mydb.StructTaskRun, error) {
+	handler, lock, err := prepare(ctx, taskType)
+	if err != nil {
+		return mydb.StructTaskRun{}, err
+	}
+
+	run := begin(taskType, trigger)
+	go func() {
+		defer cleanup(taskType, lock)
+		finish(hold(ctx, lock), run, handler, params)
+	}()
+	return run, nil
+}
+
+// prepare 查找任务并加锁：同一类型的任务在本进程和整个集群里都只能有一个在执行
+func prepare(ctx context.Context, taskType string) (Handler, *redislock.Lock, error) {
+	handler, ok := Lookup(taskType)
+	if !ok {
+		return nil, nil, ErrUnknownTask
+	}
+	if !acquire(taskType) {
+		return nil, nil, ErrTaskRunning
+	}
+	lock, err := lockTask(ctx, taskType)
+	if err != nil {
+		release(taskType)
+		return nil, nil, err
+	}
+	inflight.Add(1)
+	return handler, lock, nil
+}
+
+// cleanup 释放分布式锁和本进程的执行标记
+func cleanup(taskType string, lock *redislock.Lock) {
+	if err := lock.Release(mydb.Ctx); err != nil {
+		log.ErrorLogger.Printf("释放任务锁失败,任务=%s: %v", taskType, err)
+	}
+	release(taskType)
+	inflight.Done()
+}
+
+// acquire 标记任务开始执行，同一类型的任务已在本进程执行时返回 false
+func acquire(taskType string) bool {
+	runningMu.Lock()
+	defer runningMu.Unlock()
+	if running[taskType] {
+		return false
+	}
+	running[taskType] = true
+	return true
+}
+
+func release(taskType string) {
+	runningMu.Lock()
+	defer runningMu.Unlock()
+	delete(running, taskType)
+}
+
+// begin 插入一条 running 状态的执行记录，插入失败时只记日志，任务照常执行
+func begin(taskType string, trigger string) mydb.StructTaskRun {
+	run := mydb.StructTaskRun{
+		Task_type:    taskType,
+		Trigger_type: trigger,
+		Status:       StatusRunning,
+		Start_time:   util.GetTimestamp(10),
+	}
+	_, ids, err := run.Insert([]mydb.StructTaskRun{run})
+	if err != nil || len(ids) == 0 {
+		log.ErrorLogger.Printf("记录任务执行失败,任务=%s: %v", taskType, err)
+		return run
+	}
+	run.ID = int(ids[0])
+	return run
+}
+
+// finish 执行任务，把计数、错误和 panic 时的调用栈写回执行记录
+func finish(ctx context.Context, run mydb.StructTaskRun, handler Handler, params string) mydb.StructTaskRun {
+	log.InfoLogger.Printf("Starting task execution for %s (%s)", run.Task_type, run.Trigger_type)
+	started := time.Now()
+	if params == "" {
+		params = "{}"
+	}
+	stats, stack, err := invoke(ctx, handler, json.RawMessage(params))
+	if errors.Is(context.Cause(ctx), redislock.ErrLockLost) {
+		// 执行期间锁被其他实例取得，本次结果可能和其他实例重复，记为失败
+		if err == nil {
+			err = redislock.ErrLockLost
+		} else {
+			err = fmt.Errorf("%w: %v", redislock.ErrLockLost, err)
+		}
+	}
+
+	run.End_time = util.GetTimestamp(10)
+	run.Duration_ms = time.Since(started).Milliseconds()
+	run.Fetched = stats.Fetched
+	run.Inserted = stats.Inserted
+	run.Skipped = stats.Skipped
+	run.Failed = stats.Failed
+	run.Stack = util.Truncate(stack, maxStackSize)
+	if err != nil {
+		run.Status = StatusFailed
+		run.Error = util.Truncate(err.Error(), maxErrorSize)
+		log.ErrorLogger.Printf("Task %s failed: %v", run.Task_type, err)
+	} else {
+		run.Status = StatusSuccess
+		log.InfoLogger.Printf("Task %s finished in %dms: %+v", run.Task_type, run.Duration_ms, stats)
+	}
+
+	if run.ID > 0 {
+		if _, _, err := run.Update([]mydb.StructTaskRun{run}, fmt.Sprintf("id=%d", run.ID)); err != nil {
+			log.ErrorLogger.Printf("更新任务执行记录失败,id=%d: %v", run.ID, err)
+		}
+	}
+	return run
+}
+
+// invoke 调用任务，panic 时转成错误并返回调用栈
+func invoke(ctx context.Context, handler Handler, params json.RawMessage) (stats Stats, stack string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			buf := make([]byte, 1<<16)
+			stack = string(buf[:runtime.Stack(buf, false)])
+			err = fmt.Errorf("panic: %v", r)
+			log.ErrorLogger.Printf("Task panicked: %v\nStack trace:\n%s", r, stack)
+		}
+	}()
+	stats, err = handler(ctx, params)
+	return stats, "", err
+}
@@ -0,0 +1,188 @@
+package webcrawler
+
+import (
+	"fmt"
+	"nav-web-site/config"
+	"nav-web-site/mydb"
+	"nav-web-site/util"
+	"nav-web-site/util/log"
+	"nav-web-site/util/simhash"
+	"sync"
+	"time"
+)
+
+const (
+	dedupeModeCluster = "cluster"
+	dedupeModeSkip    = "skip"
+	dedupeModeOff     = "off"
+
+	defaultDedupeThreshold  = 6
+	defaultDedupeWindowDays = 3
+
+	nearIndexRebuild = time.Hour // 指纹索引的重建间隔，重建时去掉时间窗口外、已删除和指纹被修改过的旧条目
+)
+
+// nearIndex 最近入库新闻的指纹索引，每次检测只查询上次之后新增的新闻，不再把整个时间窗口的指纹都查一遍。
+// 其他实例入库的新闻同样按 id 增量加进来；索引只用来找候选，候选的指纹和聚合以数据库为准
+var nearIndex struct {
+	sync.Mutex
+	index     *simhash.Index
+	threshold int
+	lastID    int       // 已加入索引的最大新闻 id
+	built     time.Time // 最近一次重建的时间
+}
+
+// dedupeMode 近似重复的处理方式，默认归入故事聚合
+func dedupeMode() string {
+	switch config.Config.Dedupe.Mode {
+	case dedupeModeSkip, dedupeModeOff:
+		return config.Config.Dedupe.Mode
+	}
+	return dedupeModeCluster
+}
+
+// FingerprintNews 计算新闻标题和正文的 SimHash 写入 Simhash 字段，没有正文时用描述代替
+func FingerprintNews(news *mydb.StructNews) {
+	text := htmlToText(news.Content)
+	if text == "" {
+		text = news.Description
+	}
+	news.Simhash = int64(simhash.Fingerprint(news.Title, text))
+}
+
+// FindNearDuplicate 在最近入库的新闻里找和 news 指纹最接近的一条，距离超过阈值时返回 false
+func FindNearDuplicate(news mydb.StructNews) (mydb.NewsFingerprint, bool, error) {
+	var best mydb.NewsFingerprint
+	if news.Simhash == 0 {
+		return best, false, nil
+	}
+
+	threshold := config.Config.Dedupe.Threshold
+	if threshold <= 0 {
+		threshold = defaultDedupeThreshold
+	}
+	windowDays := config.Config.Dedupe.WindowDays
+	if windowDays <= 0 {
+		windowDays = defaultDedupeWindowDays
+	}
+
+	since := util.GetTimestamp(10) - int64(windowDays)*86400
+	candidates, err := nearCandidates(uint64(news.Simhash), threshold, since)
+	if err != nil {
+		return best, false, err
+	}
+	fingerprints, err := mydb.Tables.News.FingerprintsByID(candidates, since)
+	if err != nil {
+		return best, false, err
+	}
+
+	bestDistance := threshold + 1
+	for _, fingerprint := range fingerprints {
+		if fingerprint.ID == news.ID {
+			continue
+		}
+		distance := simhash.Distance(uint64(news.Simhash), uint64(fingerprint.Simhash))
+		if distance < bestDistance || (distance == bestDistance && fingerprint.ID < best.ID) {
+			best, bestDistance = fingerprint, distance
+		}
+	}
+	return best, bestDistance <= threshold, nil
+}
+
+// nearCandidates 从指纹索引里找距离不超过 threshold 的新闻 id。
+// 索引还没建立、阈值变了或超过重建间隔时按时间窗口重建，否则只加入上次之后新增的新闻
+func nearCandidates(fingerprint uint64, threshold int, since int64) ([]int, error) {
+	nearIndex.Lock()
+	defer nearIndex.Unlock()
+
+	var (
+		fingerprints []mydb.NewsFingerprint
+		err          error
+	)
+	rebuild := nearIndex.index == nil || nearIndex.threshold != threshold || time.Since(nearIndex.built) > nearIndexRebuild
+	if rebuild {
+		fingerprints, err = mydb.Tables.News.Fingerprints(since)
+	} else {
+		fingerprints, err = mydb.Tables.News.FingerprintsAfter(nearIndex.lastID, since)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if rebuild {
+		nearIndex.index = simhash.NewIndex(threshold)
+		nearIndex.threshold = threshold
+		nearIndex.lastID = 0
+		nearIndex.built = time.Now()
+	}
+	for _, item := range fingerprints {
+		nearIndex.index.Add(item.ID, uint64(item.Simhash))
+		nearIndex.lastID = max(nearIndex.lastID, item.ID)
+	}
+	return nearIndex.index.Near(fingerprint), nil
+}
+
+// JoinCluster 把新闻归入匹配到的新闻所在的故事聚合，匹配的新闻还没有聚合时以它为代表新建一个
+func JoinCluster(news *mydb.StructNews, match mydb.NewsFingerprint) error {
+	if match.Cluster_id > 0 {
+		news.Cluster_id = match.Cluster_id
+		return nil
+	}
+
+	lead, err := mydb.Tables.News.Find(fmt.Sprintf("id=%d", match.ID))
+	if err != nil {
+		return util.WrapError(err, "查询相似新闻失败:")
+	}
+	now := util.GetTimestamp(10)
+	cluster := mydb.StructNewsCluster{
+		Title:        lead.Title,
+		Lead_news_id: lead.ID,
+		Create_time:  now,
+		Update_time:  now,
+	}
+	var clusterID int
+	_, ids, err := cluster.Insert([]mydb.StructNewsCluster{cluster})
+	if err == nil && len(ids) > 0 {
+		clusterID = int(ids[0])
+	} else {
+		// 代表新闻已经有聚合时（并发入库），GenericInsert 按 Lead_news_id 查重跳过这一行并返回错误，重新查一次
+		existing, findErr := cluster.Find(mydb.QueryParams{Condition: fmt.Sprintf("lead_news_id=%d", lead.ID)})
+		if findErr != nil {
+			return util.WrapError(fmt.Errorf("%v; %v", err, findErr), "创建故事聚合失败:")
+		}
+		clusterID = existing.ID
+	}
+
+	if err := mydb.Tables.News.SetCluster([]int{lead.ID}, clusterID); err != nil {
+		return err
+	}
+	news.Cluster_id = clusterID
+	return nil
+}
+
+// CheckNearDuplicate 入库前的近似重复检测：计算指纹，找到相似新闻时按配置归入故事聚合，
+// allowSkip 为 true 且配置为 skip 时返回 true 表示应跳过入库（后台手动添加的新闻不跳过）
+func CheckNearDuplicate(news *mydb.StructNews, allowSkip bool) bool {
+	mode := dedupeMode()
+	if mode == dedupeModeOff {
+		return false
+	}
+
+	FingerprintNews(news)
+	match, found, err := FindNearDuplicate(*news)
+	if err != nil {
+		log.ErrorLogger.Printf("近似重复检测失败,标题=“%s”: %v", news.Title, err)
+		return false
+	}
+	if !found {
+		return false
+	}
+
+	if mode == dedupeModeSkip && allowSkip {
+		log.InfoLogger.Printf("发现近似重复新闻,跳过,标题=“%s”,相似新闻id=%d", news.Title, match.ID)
+		return true
+	}
+	if err := JoinCluster(news, match); err != nil {
+		log.ErrorLogger.Printf("归入故事聚合失败,标题=“%s”: %v", news.Title, err)
+	}
+	return false
+}
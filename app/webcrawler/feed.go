@@ -0,0 +1,572 @@
+package webcrawler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"nav-web-site/mydb"
+	"nav-web-site/util"
+	"nav-web-site/util/log"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/net/html/charset"
+)
+
+/*
+RSS 2.0 / RSS 1.0(RDF) / Atom / JSON Feed 订阅源
+订阅地址和存入的新闻分类保存在 news_feed 表，由后台接口维护
+*/
+
+// RSS 1.0 的命名空间
+const nsRSS1 = "http://purl.org/rss/1.0/"
+
+// FeedSource 订阅源新闻源，FeedID 为0时抓取所有启用的订阅
+type FeedSource struct {
+	FeedID int
+}
+
+func init() {
+	Register(FeedSource{})
+}
+
+// Name 新闻源名称
+func (FeedSource) Name() string {
+	return "feeds"
+}
+
+// FetchList 抓取订阅，订阅里的条目已带完整内容，直接作为 Entry 附在列表项上
+func (s FeedSource) FetchList(ctx context.Context) ([]ListItem, error) {
+	condition := "is_enable=1"
+	if s.FeedID > 0 {
+		condition = "id=" + strconv.Itoa(s.FeedID)
+	}
+	feeds, code, err := mydb.Tables.NewsFeed.Select(mydb.QueryParams{Condition: condition})
+	if err != nil {
+		if code == 200 {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var items []ListItem
+	for _, feed := range feeds {
+		articles, err := fetchFeed(ctx, feed)
+		feed.Last_fetch_time = util.GetTimestamp(10)
+		if errors.Is(err, ErrNotModified) {
+			feed.Last_status = "ok: 未更新"
+		} else if err != nil {
+			feed.Last_status = truncateRunes("error: "+err.Error(), 250)
+			log.ErrorLogger.Printf("[feeds] 抓取订阅失败,id=%d,url=%s: %v", feed.ID, feed.Url, err)
+		} else {
+			feed.Last_status = fmt.Sprintf("ok: %d条", len(articles))
+		}
+		if _, _, err := feed.Update([]mydb.StructNewsFeed{feed}, "id="+strconv.Itoa(feed.ID)); err != nil {
+			log.ErrorLogger.Printf("[feeds] 更新订阅状态失败,id=%d: %v", feed.ID, err)
+		}
+
+		for i := range articles {
+			article := articles[i]
+			items = append(items, ListItem{Title: article.Title, Url: article.Url, Imgurl: article.Imgurl, Entry: &article})
+		}
+	}
+	return items, nil
+}
+
+// FetchArticle 订阅条目已带内容时直接返回；只有摘要的订阅再请求原文页提取正文，取不到时仍用摘要
+func (s FeedSource) FetchArticle(ctx context.Context, item ListItem) (Article, error) {
+	if item.Entry == nil {
+		return Article{}, fmt.Errorf("订阅条目缺少内容: %s", item.Url)
+	}
+	article := *item.Entry
+	if article.Url == "" || utf8.RuneCountInString(htmlToText(article.Content)) >= minArticleTextLength {
+		return article, nil
+	}
+
+	doc, err := fetchDocument(ctx, article.Url)
+	if err != nil {
+		log.ErrorLogger.Printf("[feeds] 抓取原文失败,使用订阅摘要,url=%s: %v", article.Url, err)
+		return article, nil
+	}
+	if content := ExtractContent(doc); content != "" {
+		article.Content = content
+	}
+	return article, nil
+}
+
+// ToNews 映射成新闻，新闻分类取自订阅配置
+func (s FeedSource) ToNews(article Article) mydb.StructNews {
+	return NewsFromArticle(article, 0)
+}
+
+// fetchFeed 抓取并解析单个订阅
+func fetchFeed(ctx context.Context, feed mydb.StructNewsFeed) ([]Article, error) {
+	body, contentType, err := fetchConditional(ctx, feed.Url, "application/rss+xml, application/atom+xml, application/feed+json, application/xml;q=0.9, */*;q=0.8")
+	if err != nil {
+		return nil, err
+	}
+	feedTitle, articles, err := ParseFeed(body, contentType, feed.Url)
+	if err != nil {
+		// 解析失败时不保留 ETag，下次重新取完整内容
+		forgetValidators(feed.Url)
+		return nil, err
+	}
+
+	if feedTitle == "" {
+		feedTitle = feed.Name
+	}
+	for i := range articles {
+		articles[i].ClassID = feed.Class_id
+		if articles[i].Source == "" {
+			articles[i].Source = feedTitle
+		}
+	}
+	return articles, nil
+}
+
+// ParseFeed 自动识别 RSS 2.0 / RSS 1.0 / Atom / JSON Feed 并解析，返回订阅标题和条目
+func ParseFeed(body []byte, contentType string, feedURL string) (string, []Article, error) {
+	body = bytes.TrimPrefix(bytes.TrimSpace(body), []byte("\xef\xbb\xbf"))
+	if len(body) == 0 {
+		return "", nil, fmt.Errorf("订阅内容为空")
+	}
+	if body[0] == '{' {
+		return parseJSONFeed(body, feedURL)
+	}
+
+	root, err := xmlRootName(body)
+	if err != nil {
+		return "", nil, err
+	}
+	switch root {
+	case "rss":
+		return parseRSSFeed(body, feedURL)
+	case "RDF":
+		return parseRDFFeed(body, feedURL)
+	case "feed":
+		return parseAtomFeed(body, feedURL)
+	default:
+		return "", nil, fmt.Errorf("无法识别的订阅格式: <%s> (%s)", root, contentType)
+	}
+}
+
+// RSS 2.0
+type rssFeed struct {
+	Channel struct {
+		Title string    `xml:"title"`
+		Items []rssItem `xml:"item"`
+	} `xml:"channel"`
+}
+
+// RSS 1.0，条目和 channel 平级
+type rdfFeed struct {
+	Channel struct {
+		Title string `xml:"title"`
+	} `xml:"channel"`
+	Items []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Titles          []feedText     `xml:"title"`
+	Links           []feedText     `xml:"link"`
+	Guid            string         `xml:"guid"`
+	Descriptions    []feedText     `xml:"description"`
+	ContentEncoded  string         `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	Author          string         `xml:"author"`
+	Creator         string         `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	PubDate         string         `xml:"pubDate"`
+	Date            string         `xml:"http://purl.org/dc/elements/1.1/ date"`
+	Categories      []string       `xml:"category"`
+	Enclosures      []feedMedia    `xml:"enclosure"`
+	MediaContents   []feedMedia    `xml:"http://search.yahoo.com/mrss/ content"`
+	MediaThumbnails []feedMedia    `xml:"http://search.yahoo.com/mrss/ thumbnail"`
+	MediaGroups     []feedMediaSet `xml:"http://search.yahoo.com/mrss/ group"`
+}
+
+// 不带命名空间的标签名会匹配任意命名空间的同名元素（如 atom:link、media:title），
+// 所以 RSS 条目的 title/link/description 先全部收下，再挑出 RSS 自己的那个
+type feedText struct {
+	XMLName xml.Name
+	Text    string `xml:",chardata"`
+}
+
+// rssText 返回不带命名空间（RSS 2.0）或 RSS 1.0 命名空间的元素文本
+func rssText(texts []feedText) string {
+	for _, t := range texts {
+		if t.XMLName.Space == "" || t.XMLName.Space == nsRSS1 {
+			return strings.TrimSpace(t.Text)
+		}
+	}
+	return ""
+}
+
+// enclosure / media:content / media:thumbnail / atom:link 共用
+type feedMedia struct {
+	Url    string `xml:"url,attr"`
+	Href   string `xml:"href,attr"`
+	Rel    string `xml:"rel,attr"`
+	Type   string `xml:"type,attr"`
+	Medium string `xml:"medium,attr"`
+}
+
+type feedMediaSet struct {
+	Contents   []feedMedia `xml:"http://search.yahoo.com/mrss/ content"`
+	Thumbnails []feedMedia `xml:"http://search.yahoo.com/mrss/ thumbnail"`
+}
+
+// Atom
+type atomFeed struct {
+	Title   string      `xml:"http://www.w3.org/2005/Atom title"`
+	Entries []atomEntry `xml:"http://www.w3.org/2005/Atom entry"`
+}
+
+type atomEntry struct {
+	Title           atomText       `xml:"http://www.w3.org/2005/Atom title"`
+	ID              string         `xml:"http://www.w3.org/2005/Atom id"`
+	Links           []feedMedia    `xml:"http://www.w3.org/2005/Atom link"`
+	Summary         atomText       `xml:"http://www.w3.org/2005/Atom summary"`
+	Content         atomText       `xml:"http://www.w3.org/2005/Atom content"`
+	Published       string         `xml:"http://www.w3.org/2005/Atom published"`
+	Updated         string         `xml:"http://www.w3.org/2005/Atom updated"`
+	Authors         []atomPerson   `xml:"http://www.w3.org/2005/Atom author"`
+	Categories      []atomCategory `xml:"http://www.w3.org/2005/Atom category"`
+	MediaContents   []feedMedia    `xml:"http://search.yahoo.com/mrss/ content"`
+	MediaThumbnails []feedMedia    `xml:"http://search.yahoo.com/mrss/ thumbnail"`
+	MediaGroups     []feedMediaSet `xml:"http://search.yahoo.com/mrss/ group"`
+}
+
+type atomText struct {
+	Type     string `xml:"type,attr"`
+	Text     string `xml:",chardata"`
+	InnerXML string `xml:",innerxml"`
+}
+
+// String type="xhtml" 时内容是内嵌的XHTML，其余情况取文本（html 类型的文本本身就是HTML）
+func (t atomText) String() string {
+	if t.Type == "xhtml" {
+		return strings.TrimSpace(t.InnerXML)
+	}
+	return strings.TrimSpace(t.Text)
+}
+
+type atomPerson struct {
+	Name string `xml:"http://www.w3.org/2005/Atom name"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+// JSON Feed 1.0 / 1.1
+type jsonFeed struct {
+	Title string         `json:"title"`
+	Items []jsonFeedItem `json:"items"`
+}
+
+type jsonFeedItem struct {
+	ID            string           `json:"id"`
+	Url           string           `json:"url"`
+	ExternalUrl   string           `json:"external_url"`
+	Title         string           `json:"title"`
+	ContentHtml   string           `json:"content_html"`
+	ContentText   string           `json:"content_text"`
+	Summary       string           `json:"summary"`
+	Image         string           `json:"image"`
+	BannerImage   string           `json:"banner_image"`
+	DatePublished string           `json:"date_published"`
+	Tags          []string         `json:"tags"`
+	Author        *jsonFeedAuthor  `json:"author"`
+	Authors       []jsonFeedAuthor `json:"authors"`
+	Attachments   []struct {
+		Url      string `json:"url"`
+		MimeType string `json:"mime_type"`
+	} `json:"attachments"`
+}
+
+type jsonFeedAuthor struct {
+	Name string `json:"name"`
+}
+
+func parseRSSFeed(body []byte, feedURL string) (string, []Article, error) {
+	var feed rssFeed
+	if err := decodeXML(body, &feed); err != nil {
+		return "", nil, err
+	}
+	return strings.TrimSpace(feed.Channel.Title), rssItemsToArticles(feed.Channel.Items, feedURL), nil
+}
+
+func parseRDFFeed(body []byte, feedURL string) (string, []Article, error) {
+	var feed rdfFeed
+	if err := decodeXML(body, &feed); err != nil {
+		return "", nil, err
+	}
+	return strings.TrimSpace(feed.Channel.Title), rssItemsToArticles(feed.Items, feedURL), nil
+}
+
+func rssItemsToArticles(items []rssItem, feedURL string) []Article {
+	var articles []Article
+	for _, item := range items {
+		link := rssText(item.Links)
+		if link == "" && strings.HasPrefix(strings.TrimSpace(item.Guid), "http") {
+			link = strings.TrimSpace(item.Guid)
+		}
+		description := rssText(item.Descriptions)
+		content := strings.TrimSpace(item.ContentEncoded)
+		if content == "" {
+			content = description
+		}
+		author := strings.TrimSpace(item.Creator)
+		if author == "" {
+			author = strings.TrimSpace(item.Author)
+		}
+		published := item.PubDate
+		if published == "" {
+			published = item.Date
+		}
+
+		media := append([]feedMedia{}, item.Enclosures...)
+		media = append(media, item.MediaContents...)
+		media = append(media, item.MediaThumbnails...)
+		for _, group := range item.MediaGroups {
+			media = append(media, group.Contents...)
+			media = append(media, group.Thumbnails...)
+		}
+
+		article := Article{
+			Url:         resolveURL(feedURL, link),
+			Title:       htmlToText(rssText(item.Titles)),
+			Description: truncateRunes(htmlToText(description), 200),
+			Keywords:    strings.Join(item.Categories, ","),
+			Author:      author,
+			Content:     content,
+			PublishTime: parseFeedTime(published),
+		}
+		article.Imgurl = pickFeedImage(media, content, article.Url)
+		if article.Title != "" {
+			articles = append(articles, article)
+		}
+	}
+	return articles
+}
+
+func parseAtomFeed(body []byte, feedURL string) (string, []Article, error) {
+	var feed atomFeed
+	if err := decodeXML(body, &feed); err != nil {
+		return "", nil, err
+	}
+
+	var articles []Article
+	for _, entry := range feed.Entries {
+		var link string
+		var media []feedMedia
+		for _, l := range entry.Links {
+			switch l.Rel {
+			case "", "alternate":
+				if link == "" {
+					link = l.Href
+				}
+			case "enclosure":
+				media = append(media, feedMedia{Url: l.Href, Type: l.Type})
+			}
+		}
+		if link == "" && strings.HasPrefix(entry.ID, "http") {
+			link = entry.ID
+		}
+		media = append(media, entry.MediaContents...)
+		media = append(media, entry.MediaThumbnails...)
+		for _, group := range entry.MediaGroups {
+			media = append(media, group.Contents...)
+			media = append(media, group.Thumbnails...)
+		}
+
+		content := entry.Content.String()
+		if content == "" {
+			content = entry.Summary.String()
+		}
+		published := entry.Published
+		if published == "" {
+			published = entry.Updated
+		}
+		var authors, keywords []string
+		for _, author := range entry.Authors {
+			authors = append(authors, strings.TrimSpace(author.Name))
+		}
+		for _, category := range entry.Categories {
+			keywords = append(keywords, category.Term)
+		}
+
+		article := Article{
+			Url:         resolveURL(feedURL, link),
+			Title:       strings.TrimSpace(htmlToText(entry.Title.String())),
+			Description: truncateRunes(htmlToText(entry.Summary.String()), 200),
+			Keywords:    strings.Join(keywords, ","),
+			Author:      strings.Join(authors, ","),
+			Content:     content,
+			PublishTime: parseFeedTime(published),
+		}
+		article.Imgurl = pickFeedImage(media, content, article.Url)
+		if article.Title != "" {
+			articles = append(articles, article)
+		}
+	}
+	return strings.TrimSpace(feed.Title), articles, nil
+}
+
+func parseJSONFeed(body []byte, feedURL string) (string, []Article, error) {
+	var feed jsonFeed
+	if err := json.Unmarshal(body, &feed); err != nil {
+		return "", nil, fmt.Errorf("failed to parse json feed: %v", err)
+	}
+
+	var articles []Article
+	for _, item := range feed.Items {
+		link := item.Url
+		if link == "" {
+			link = item.ExternalUrl
+		}
+		content := item.ContentHtml
+		if content == "" && item.ContentText != "" {
+			content = "<p>" + strings.ReplaceAll(html.EscapeString(item.ContentText), "\n\n", "</p><p>") + "</p>"
+		}
+		var authors []string
+		if item.Author != nil && item.Author.Name != "" {
+			authors = append(authors, item.Author.Name)
+		}
+		for _, author := range item.Authors {
+			authors = append(authors, author.Name)
+		}
+
+		var media []feedMedia
+		if item.Image != "" {
+			media = append(media, feedMedia{Url: item.Image, Medium: "image"})
+		}
+		if item.BannerImage != "" {
+			media = append(media, feedMedia{Url: item.BannerImage, Medium: "image"})
+		}
+		for _, attachment := range item.Attachments {
+			media = append(media, feedMedia{Url: attachment.Url, Type: attachment.MimeType})
+		}
+
+		description := item.Summary
+		if description == "" {
+			description = item.ContentText
+		}
+		article := Article{
+			Url:         resolveURL(feedURL, link),
+			Title:       strings.TrimSpace(item.Title),
+			Description: truncateRunes(htmlToText(description), 200),
+			Keywords:    strings.Join(item.Tags, ","),
+			Author:      strings.Join(authors, ","),
+			Content:     content,
+			PublishTime: parseFeedTime(item.DatePublished),
+		}
+		article.Imgurl = pickFeedImage(media, content, article.Url)
+		if article.Title != "" {
+			articles = append(articles, article)
+		}
+	}
+	return strings.TrimSpace(feed.Title), articles, nil
+}
+
+// decodeXML 解析XML，非 UTF-8 编码（如GBK）按声明的编码转换
+func decodeXML(body []byte, v interface{}) error {
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	decoder.CharsetReader = charset.NewReaderLabel
+	decoder.Strict = false
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("failed to parse xml feed: %v", err)
+	}
+	return nil
+}
+
+// xmlRootName 返回XML根元素的名称
+func xmlRootName(body []byte) (string, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	decoder.CharsetReader = charset.NewReaderLabel
+	decoder.Strict = false
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return "", fmt.Errorf("订阅内容不是有效的XML")
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to parse xml feed: %v", err)
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			return start.Name.Local, nil
+		}
+	}
+}
+
+// pickFeedImage 依次从 enclosure/media 标签和正文第一张图片里选出封面图
+func pickFeedImage(media []feedMedia, content string, baseURL string) string {
+	for _, m := range media {
+		imageURL := m.Url
+		if imageURL == "" {
+			imageURL = m.Href
+		}
+		if imageURL != "" && isImageMedia(m, imageURL) {
+			return resolveURL(baseURL, imageURL)
+		}
+	}
+	if content != "" {
+		if doc, err := parseDocument(strings.NewReader(content), "text/html; charset=utf-8"); err == nil {
+			if src, ok := doc.Find("img[src]").First().Attr("src"); ok {
+				return resolveURL(baseURL, src)
+			}
+		}
+	}
+	return ""
+}
+
+// isImageMedia 判断 enclosure/media 是否是图片
+func isImageMedia(m feedMedia, imageURL string) bool {
+	if m.Medium == "image" || strings.HasPrefix(m.Type, "image/") {
+		return true
+	}
+	if m.Type != "" || m.Medium != "" {
+		return false
+	}
+	switch strings.ToLower(path.Ext(strings.SplitN(imageURL, "?", 2)[0])) {
+	case ".jpg", ".jpeg", ".png", ".gif", ".webp":
+		return true
+	}
+	return false
+}
+
+// feedTimeLayouts 订阅里常见的时间格式
+var feedTimeLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC3339,
+	time.RFC3339Nano,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"Mon, 02 Jan 2006 15:04 -0700",
+	"2 Jan 2006 15:04:05 -0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// parseFeedTime 解析订阅里的发布时间，解析失败返回0
+func parseFeedTime(value string) int64 {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	for _, layout := range feedTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t.Unix()
+		}
+	}
+	return 0
+}
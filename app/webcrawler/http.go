@@ -0,0 +1,244 @@
+package webcrawler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"nav-web-site/config"
+	"nav-web-site/mydb"
+	"nav-web-site/util/log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html/charset"
+)
+
+const (
+	defaultUserAgent   = "Mozilla/5.0 (compatible; NavWebSiteBot/1.0)"
+	defaultMaxBodySize = 5 << 20
+)
+
+var (
+	// ErrNotModified 条件请求命中，内容自上次抓取后没有变化
+	ErrNotModified = errors.New("not modified")
+	// ErrDisallowed robots.txt 禁止抓取该地址
+	ErrDisallowed = errors.New("disallowed by robots.txt")
+)
+
+// retryableError 可以重试的错误：网络错误、429、5xx
+type retryableError struct {
+	err error
+}
+
+func (e *retryableError) Error() string { return e.err.Error() }
+
+func (e *retryableError) Unwrap() error { return e.err }
+
+// newHTTPClient 按配置创建抓取用的 http.Client
+func newHTTPClient() *http.Client {
+	timeout := config.Config.Crawler.Timeout
+	if timeout <= 0 {
+		timeout = 15
+	}
+	return &http.Client{Timeout: time.Duration(timeout) * time.Second}
+}
+
+// userAgent 返回配置的 User-Agent
+func userAgent() string {
+	if config.Config.Crawler.UserAgent != "" {
+		return config.Config.Crawler.UserAgent
+	}
+	return defaultUserAgent
+}
+
+// fetchDocument 抓取网页并按页面声明的编码转成 UTF-8 后解析
+func fetchDocument(ctx context.Context, pageURL string) (*goquery.Document, error) {
+	body, contentType, err := fetchBytes(ctx, pageURL, "text/html,application/xhtml+xml")
+	if err != nil {
+		return nil, err
+	}
+	return parseDocument(bytes.NewReader(body), contentType)
+}
+
+// fetchBytes 抓取地址内容，返回响应体和 Content-Type
+func fetchBytes(ctx context.Context, pageURL string, accept string) ([]byte, string, error) {
+	return fetch(ctx, pageURL, accept, false)
+}
+
+// fetchConditional 带上次保存的 ETag/Last-Modified 发条件请求，内容没变时返回 ErrNotModified
+func fetchConditional(ctx context.Context, pageURL string, accept string) ([]byte, string, error) {
+	return fetch(ctx, pageURL, accept, true)
+}
+
+// fetch 抓取的核心流程：检查 robots.txt，按主机限速，网络错误、429、5xx 时退避重试，
+// 限制响应大小，并把本次的状态码、ETag、Last-Modified 记到 crawl_state 表
+func fetch(ctx context.Context, pageURL string, accept string, conditional bool) ([]byte, string, error) {
+	u, err := url.Parse(pageURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, "", fmt.Errorf("invalid url: %s", pageURL)
+	}
+
+	var crawlDelay time.Duration
+	if !config.Config.Crawler.IgnoreRobots {
+		robots := robotsFor(ctx, u)
+		if !robots.allowed(u) {
+			return nil, "", fmt.Errorf("%w: %s", ErrDisallowed, pageURL)
+		}
+		crawlDelay = robots.crawlDelay
+	}
+
+	state := loadCrawlState(pageURL)
+	for attempt := 0; ; attempt++ {
+		if err := waitForHost(ctx, u.Host, crawlDelay); err != nil {
+			return nil, "", err
+		}
+
+		body, contentType, retryAfter, err := fetchOnce(ctx, pageURL, accept, conditional, &state)
+		if err == nil || errors.Is(err, ErrNotModified) {
+			state.Fail_count = 0
+			state.Last_error = ""
+			saveCrawlState(state)
+			return body, contentType, err
+		}
+
+		var retryErr *retryableError
+		if !errors.As(err, &retryErr) || attempt >= maxRetries() || ctx.Err() != nil {
+			state.Fail_count++
+			state.Last_error = err.Error()
+			saveCrawlState(state)
+			return nil, "", err
+		}
+
+		delay := retryDelay(attempt)
+		if retryAfter > delay {
+			delay = retryAfter
+		}
+		log.InfoLogger.Printf("抓取失败,%v后第%d次重试,url=%s: %v", delay, attempt+1, pageURL, err)
+		if err := sleepContext(ctx, delay); err != nil {
+			return nil, "", err
+		}
+	}
+}
+
+// fetchOnce 发送一次请求，可重试的错误包装成 retryableError，同时返回服务端要求的 Retry-After
+func fetchOnce(ctx context.Context, pageURL string, accept string, conditional bool, state *mydb.StructCrawlState) ([]byte, string, time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL, nil)
+	if err != nil {
+		return nil, "", 0, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("User-Agent", userAgent())
+	req.Header.Set("Accept", accept)
+	if conditional {
+		if state.Etag != "" {
+			req.Header.Set("If-None-Match", state.Etag)
+		}
+		if state.Last_modified != "" {
+			req.Header.Set("If-Modified-Since", state.Last_modified)
+		}
+	}
+
+	resp, err := newHTTPClient().Do(req)
+	if err != nil {
+		state.Status_code = 0
+		if ctx.Err() != nil {
+			return nil, "", 0, ctx.Err()
+		}
+		return nil, "", 0, &retryableError{fmt.Errorf("failed to send request: %v", err)}
+	}
+	defer resp.Body.Close()
+	state.Status_code = resp.StatusCode
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && conditional:
+		return nil, "", 0, ErrNotModified
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		retryAfter := parseRetryAfter(resp.Header.Get("Retry-After"))
+		err := fmt.Errorf("unexpected status %d for %s", resp.StatusCode, pageURL)
+		if retryAfter > maxRetryAfter {
+			return nil, "", 0, err
+		}
+		return nil, "", retryAfter, &retryableError{err}
+	case resp.StatusCode != http.StatusOK:
+		return nil, "", 0, fmt.Errorf("unexpected status %d for %s", resp.StatusCode, pageURL)
+	}
+
+	body, truncated, err := readLimited(resp, maxBodySize())
+	if err != nil {
+		return nil, "", 0, &retryableError{fmt.Errorf("failed to read response body: %v", err)}
+	}
+	if truncated {
+		return nil, "", 0, fmt.Errorf("response body exceeds %d bytes: %s", maxBodySize(), pageURL)
+	}
+
+	state.Etag = resp.Header.Get("ETag")
+	state.Last_modified = resp.Header.Get("Last-Modified")
+	state.Content_length = int64(len(body))
+	return body, resp.Header.Get("Content-Type"), 0, nil
+}
+
+// readLimited 最多读取 limit 字节，超过时 truncated 为 true
+func readLimited(resp *http.Response, limit int64) ([]byte, bool, error) {
+	if resp.ContentLength > limit {
+		return nil, true, nil
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if int64(len(body)) > limit {
+		return body[:limit], true, err
+	}
+	return body, false, err
+}
+
+// maxBodySize 单个响应的最大字节数
+func maxBodySize() int64 {
+	if config.Config.Crawler.MaxBodySize > 0 {
+		return config.Config.Crawler.MaxBodySize
+	}
+	return defaultMaxBodySize
+}
+
+// parseDocument 把网页内容转成 UTF-8 后交给 goquery 解析
+func parseDocument(body io.Reader, contentType string) (*goquery.Document, error) {
+	reader, err := charset.NewReader(body, contentType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to detect charset: %v", err)
+	}
+	doc, err := goquery.NewDocumentFromReader(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse html: %v", err)
+	}
+	return doc, nil
+}
+
+// resolveURL 把页面里的相对地址转成绝对地址
+func resolveURL(baseURL string, ref string) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return ""
+	}
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return ref
+	}
+	resolved, err := base.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return resolved.String()
+}
+
+// htmlToText 提取HTML片段中的纯文本
+func htmlToText(fragment string) string {
+	if fragment == "" {
+		return ""
+	}
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(fragment))
+	if err != nil {
+		return ""
+	}
+	return strings.Join(strings.Fields(doc.Text()), " ")
+}
@@ -0,0 +1,179 @@
+package webcrawler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"nav-web-site/app/api/upload"
+	"nav-web-site/app/queue"
+	"nav-web-site/app/sitecache"
+	"nav-web-site/config"
+	"nav-web-site/mydb"
+	"nav-web-site/util"
+	"nav-web-site/util/log"
+	"nav-web-site/util/safehttp"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const defaultMaxRehostImages = 20
+
+// RehostImagesJob 转存新闻图片的队列任务类型
+const RehostImagesJob = "rehost_images"
+
+// RehostImagesPayload 队列任务 rehost_images 的参数
+type RehostImagesPayload struct {
+	News_id int `json:"news_id"`
+}
+
+// RehostImages 开启 content.rehost_images 时，把正文里的外链图片下载到上传目录并改成站内地址 /images/{hash}，
+// 单张图片失败时保留原地址
+func RehostImages(ctx context.Context, content string) string {
+	if !config.Config.Content.RehostImages {
+		return content
+	}
+	return rehostContent(ctx, content)
+}
+
+// RehostNewsImages 转存已入库新闻的正文和封面里的外链图片，有变化时更新新闻。
+// 队列任务 rehost_images 调用，新闻已删除时不再处理
+func RehostNewsImages(ctx context.Context, newsID int) error {
+	news, err := mydb.Tables.News.Find(fmt.Sprintf("id=%d", newsID))
+	if err != nil {
+		log.InfoLogger.Printf("新闻不存在,不再转存图片,id=%d: %v", newsID, err)
+		return nil
+	}
+	content := rehostContent(ctx, news.Content)
+	imgurl := rehostImage(ctx, news.Imgurl)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if content == news.Content && imgurl == news.Imgurl {
+		return nil
+	}
+	news.Content = content
+	news.Imgurl = imgurl
+	news.Update_time = util.GetTimestamp(10)
+	if _, _, err := news.Update([]mydb.StructNews{news}, fmt.Sprintf("id=%d", news.ID)); err != nil {
+		return util.WrapError(err, "更新新闻图片失败:")
+	}
+	sitecache.ForgetSyndication()
+	return nil
+}
+
+// enqueueRehost 把新闻的图片转存交给队列，入队失败时只记日志，新闻保留外链图片
+func enqueueRehost(newsID int) {
+	payload, _ := json.Marshal(RehostImagesPayload{News_id: newsID})
+	if _, err := queue.Enqueue(RehostImagesJob, string(payload), queue.Options{}); err != nil {
+		log.ErrorLogger.Printf("转存图片任务入队失败,新闻id=%d: %v", newsID, err)
+	}
+}
+
+// rehostContent 把正文里的外链图片转存到上传目录，最多转存 content.max_rehost_images 张
+func rehostContent(ctx context.Context, content string) string {
+	if content == "" {
+		return content
+	}
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(content))
+	if err != nil {
+		return content
+	}
+
+	limit := config.Config.Content.MaxRehostImages
+	if limit <= 0 {
+		limit = defaultMaxRehostImages
+	}
+	rehosted := make(map[string]string)
+	changed := false
+	doc.Find("img[src]").EachWithBreak(func(_ int, img *goquery.Selection) bool {
+		src, _ := img.Attr("src")
+		local, ok := rehosted[src]
+		if !ok {
+			if len(rehosted) >= limit {
+				return false
+			}
+			local = rehostImage(ctx, src)
+			rehosted[src] = local
+		}
+		if local != src {
+			img.SetAttr("src", local)
+			changed = true
+		}
+		return ctx.Err() == nil
+	})
+	if !changed {
+		return content
+	}
+
+	rewritten, err := doc.Find("body").Html()
+	if err != nil {
+		return content
+	}
+	return rewritten
+}
+
+// RehostImage 开启 content.rehost_images 时转存单张图片（如新闻封面），返回站内地址
+func RehostImage(ctx context.Context, imageURL string) string {
+	if !config.Config.Content.RehostImages {
+		return imageURL
+	}
+	return rehostImage(ctx, imageURL)
+}
+
+// rehostImage 下载外链图片存进上传目录，站内地址和失败时原样返回
+func rehostImage(ctx context.Context, imageURL string) string {
+	if !strings.HasPrefix(imageURL, "http://") && !strings.HasPrefix(imageURL, "https://") {
+		return imageURL
+	}
+	body, err := downloadImage(ctx, imageURL)
+	if err != nil {
+		log.ErrorLogger.Printf("下载图片失败,url=%s: %v", imageURL, err)
+		return imageURL
+	}
+	file, err := upload.SaveImageBytes(body, 0)
+	if err != nil {
+		log.ErrorLogger.Printf("转存图片失败,url=%s: %v", imageURL, err)
+		return imageURL
+	}
+	return "/images/" + file.Hash
+}
+
+// downloadImage 下载图片。图片地址可能来自后台编辑的正文，不走抓取流程（不记 crawl_state、不看 robots.txt），
+// 用只能访问公网地址的 client，防止借转存图片访问内网
+func downloadImage(ctx context.Context, imageURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, imageURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("User-Agent", userAgent())
+	req.Header.Set("Accept", "image/avif,image/webp,image/png,image/jpeg,image/gif,*/*;q=0.8")
+
+	resp, err := imageClient().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d for %s", resp.StatusCode, imageURL)
+	}
+	body, truncated, err := readLimited(resp, maxBodySize())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %v", err)
+	}
+	if truncated {
+		return nil, fmt.Errorf("response body exceeds %d bytes: %s", maxBodySize(), imageURL)
+	}
+	return body, nil
+}
+
+// imageClient 下载图片用的 http.Client，超时和抓取一致
+func imageClient() *http.Client {
+	timeout := config.Config.Crawler.Timeout
+	if timeout <= 0 {
+		timeout = 15
+	}
+	return safehttp.NewClient(time.Duration(timeout) * time.Second)
+}
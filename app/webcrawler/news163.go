@@ -0,0 +1,176 @@
+package webcrawler
+
+import (
+	"context"
+	"fmt"
+	"nav-web-site/mydb"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+/*
+网易新闻（163.com）头条
+*/
+
+const news163ListURL = "https://news.163.com/"
+
+var (
+	// 网易新闻的文章页地址，如 https://www.163.com/news/article/JC8Q4E4R000189FH.html
+	news163ArticleRegexp = regexp.MustCompile(`^https?://www\.163\.com/(news|dy)/article/[0-9A-Za-z]+\.html`)
+	news163TimeRegexp    = regexp.MustCompile(`\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}`)
+	news163SourceRegexp  = regexp.MustCompile(`来源[:：]\s*(\S+)`)
+	news163AuthorRegexp  = regexp.MustCompile(`责任编辑[:：]\s*([^\s_]+)`)
+)
+
+// News163 网易新闻头条
+type News163 struct{}
+
+func init() {
+	Register(News163{})
+}
+
+// Name 新闻源名称
+func (News163) Name() string {
+	return "news163"
+}
+
+// FetchList 抓取网易新闻首页上的文章列表
+func (s News163) FetchList(ctx context.Context) ([]ListItem, error) {
+	doc, err := fetchDocument(ctx, news163ListURL)
+	if err != nil {
+		return nil, err
+	}
+	return parseNews163List(doc, news163ListURL), nil
+}
+
+// FetchArticle 抓取网易新闻文章页
+func (s News163) FetchArticle(ctx context.Context, item ListItem) (Article, error) {
+	doc, err := fetchDocument(ctx, item.Url)
+	if err != nil {
+		return Article{}, err
+	}
+	article := parseNews163Article(doc, item.Url)
+	if article.Title == "" {
+		article.Title = item.Title
+	}
+	if article.Imgurl == "" {
+		article.Imgurl = item.Imgurl
+	}
+	if article.Title == "" || article.Content == "" {
+		return article, fmt.Errorf("文章页缺少标题或正文: %s", item.Url)
+	}
+	return article, nil
+}
+
+// ToNews 映射成新闻
+func (s News163) ToNews(article Article) mydb.StructNews {
+	news := NewsFromArticle(article, sourceConfig(s.Name()).ClassID)
+	if news.Source == "" {
+		news.Source = "网易新闻"
+	}
+	return news
+}
+
+// parseNews163List 从列表页里找出所有文章链接，按链接去重。
+// 图文列表里同一篇文章常有两个链接，一个只包着封面图、一个是标题，先后顺序不固定，按链接合并标题和封面图
+func parseNews163List(doc *goquery.Document, pageURL string) []ListItem {
+	var items []ListItem
+	index := make(map[string]int)
+	doc.Find("a[href]").Each(func(_ int, a *goquery.Selection) {
+		href, _ := a.Attr("href")
+		link := resolveURL(pageURL, href)
+		if !news163ArticleRegexp.MatchString(link) {
+			return
+		}
+		title := strings.TrimSpace(a.Text())
+		if title == "" {
+			title, _ = a.Attr("title")
+			title = strings.TrimSpace(title)
+		}
+		imgurl := ""
+		if src := news163ImageSrc(a.Find("img").First()); src != "" {
+			imgurl = resolveURL(pageURL, src)
+		}
+
+		i, ok := index[link]
+		if !ok {
+			index[link] = len(items)
+			items = append(items, ListItem{Title: title, Url: link, Imgurl: imgurl})
+			return
+		}
+		if items[i].Title == "" {
+			items[i].Title = title
+		}
+		if items[i].Imgurl == "" {
+			items[i].Imgurl = imgurl
+		}
+	})
+
+	// 只有图片、也没有 title 的链接取不到标题，不要
+	result := items[:0]
+	for _, item := range items {
+		if item.Title != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// news163ImageSrc 图片地址，懒加载的图片 src 是占位图，真实地址在 data-original 或 data-src 里
+func news163ImageSrc(img *goquery.Selection) string {
+	src := strings.TrimSpace(img.AttrOr("src", ""))
+	if src == "" || strings.HasPrefix(src, "data:") {
+		src = strings.TrimSpace(img.AttrOr("data-original", img.AttrOr("data-src", "")))
+	}
+	return src
+}
+
+// parseNews163Article 解析文章页
+func parseNews163Article(doc *goquery.Document, pageURL string) Article {
+	article := Article{Url: pageURL}
+
+	article.Title = strings.TrimSpace(doc.Find("h1.post_title").First().Text())
+	if article.Title == "" {
+		article.Title, _ = doc.Find(`meta[property="og:title"]`).Attr("content")
+	}
+	article.Description, _ = doc.Find(`meta[name="description"]`).Attr("content")
+	article.Keywords, _ = doc.Find(`meta[name="keywords"]`).Attr("content")
+	article.Description = strings.TrimSpace(article.Description)
+	article.Keywords = strings.TrimSpace(article.Keywords)
+
+	info := strings.Join(strings.Fields(doc.Find(".post_info").First().Text()), " ")
+	if match := news163TimeRegexp.FindString(info); match != "" {
+		if t, err := time.ParseInLocation("2006-01-02 15:04:05", match, time.Local); err == nil {
+			article.PublishTime = t.Unix()
+		}
+	}
+	if source := strings.TrimSpace(doc.Find(".post_info a").First().Text()); source != "" {
+		article.Source = source
+	} else if match := news163SourceRegexp.FindStringSubmatch(info); match != nil {
+		article.Source = match[1]
+	}
+	if match := news163AuthorRegexp.FindStringSubmatch(doc.Find(".post_author").Text()); match != nil {
+		article.Author = match[1]
+	}
+
+	body := doc.Find(".post_body").First()
+	body.Find("script, style, iframe").Remove()
+	if content, err := body.Html(); err == nil {
+		article.Content = strings.TrimSpace(content)
+	}
+	// 页面改版找不到 .post_body 时按通用规则提取正文
+	if article.Content == "" {
+		article.Content = ExtractContent(doc)
+	}
+
+	if image, ok := doc.Find(`meta[property="og:image"]`).Attr("content"); ok && image != "" {
+		article.Imgurl = resolveURL(pageURL, image)
+	} else if src, ok := body.Find("img").First().Attr("src"); ok {
+		article.Imgurl = resolveURL(pageURL, src)
+	}
+
+	return article
+}
@@ -0,0 +1,152 @@
+package webcrawler
+
+import (
+	"nav-web-site/config"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const news163ArticleURL = "https://www.163.com/news/article/JC8Q4E4R000189FH.html"
+
+func loadDocument(t *testing.T, name string) *goquery.Document {
+	t.Helper()
+	file, err := os.Open("testdata/" + name)
+	if err != nil {
+		t.Fatalf("打开测试页面失败: %v", err)
+	}
+	defer file.Close()
+	doc, err := goquery.NewDocumentFromReader(file)
+	if err != nil {
+		t.Fatalf("解析测试页面失败: %v", err)
+	}
+	return doc
+}
+
+func TestParseNews163List(t *testing.T) {
+	items := parseNews163List(loadDocument(t, "news163_list.html"), news163ListURL)
+
+	want := []ListItem{
+		// 同一链接出现多次时只保留一次，标题取第一次出现时的
+		{Title: "国内要闻：新能源汽车销量再创新高", Url: "https://www.163.com/news/article/JC8Q4E4R000189FH.html"},
+		// 封面图链接在标题链接前面：协议相对地址补全，标题和封面图合并到一项
+		{Title: "央行发布一月金融数据", Url: "https://www.163.com/dy/article/JC9A1B2C0534A4SC.html",
+			Imgurl: "https://cms-bucket.ws.126.net/2024/0131/cover.jpg?imageView&thumbnail=190y120"},
+		// 懒加载图片取 data-original
+		{Title: "春运首日铁路发送旅客超千万人次", Url: "https://www.163.com/dy/article/JCBB5F6G0514R9P4.html",
+			Imgurl: "https://cms-bucket.ws.126.net/2024/0131/lazy.png"},
+		// 封面图链接在标题链接后面同样合并
+		{Title: "重复的链接只保留一次", Url: "https://www.163.com/dy/article/JCCC7H8I0512B07B.html",
+			Imgurl: "https://cms-bucket.ws.126.net/2024/0131/after.jpg"},
+	}
+	if len(items) != len(want) {
+		t.Fatalf("列表项数量 = %d, want %d: %+v", len(items), len(want), items)
+	}
+	for i := range want {
+		if items[i].Title != want[i].Title || items[i].Url != want[i].Url || items[i].Imgurl != want[i].Imgurl {
+			t.Errorf("items[%d] = %+v, want %+v", i, items[i], want[i])
+		}
+	}
+}
+
+func TestParseNews163ListRelative(t *testing.T) {
+	// 相对链接按 www.163.com 的页面地址补全后才能匹配文章页地址
+	items := parseNews163List(loadDocument(t, "news163_list.html"), "https://www.163.com/")
+	var found bool
+	for _, item := range items {
+		if item.Url == "https://www.163.com/news/article/JCAB3D4E00019K82.html" {
+			found = true
+			if item.Title != "多地发布寒潮预警" {
+				t.Errorf("标题没有去掉首尾空白: %q", item.Title)
+			}
+		}
+		if strings.Contains(item.Url, "JCEMPTY") {
+			t.Errorf("没有标题的链接不应出现在列表里: %+v", item)
+		}
+	}
+	if !found {
+		t.Errorf("相对链接没有补全: %+v", items)
+	}
+}
+
+func TestParseNews163Article(t *testing.T) {
+	article := parseNews163Article(loadDocument(t, "news163_article.html"), news163ArticleURL)
+
+	if article.Url != news163ArticleURL {
+		t.Errorf("Url = %q", article.Url)
+	}
+	if article.Title != "新能源汽车销量再创新高" {
+		t.Errorf("Title = %q", article.Title)
+	}
+	if article.Description != "1月新能源汽车销量同比增长三成。" {
+		t.Errorf("Description = %q", article.Description)
+	}
+	if article.Keywords != "新能源,汽车,销量" {
+		t.Errorf("Keywords = %q", article.Keywords)
+	}
+	if article.Source != "中国新闻网" {
+		t.Errorf("Source = %q", article.Source)
+	}
+	if article.Author != "王明" {
+		t.Errorf("Author = %q", article.Author)
+	}
+	wantTime := time.Date(2024, 1, 31, 8, 30, 15, 0, time.Local).Unix()
+	if article.PublishTime != wantTime {
+		t.Errorf("PublishTime = %d, want %d", article.PublishTime, wantTime)
+	}
+	if article.Imgurl != "https://cms-bucket.ws.126.net/2024/0131/og.jpg" {
+		t.Errorf("Imgurl = %q", article.Imgurl)
+	}
+	if !strings.Contains(article.Content, "价格下降是销量增长的主要原因") {
+		t.Errorf("Content 缺少正文: %q", article.Content)
+	}
+	if strings.Contains(article.Content, "<script") || strings.Contains(article.Content, "<iframe") {
+		t.Errorf("Content 没有去掉脚本和 iframe: %q", article.Content)
+	}
+}
+
+func TestNews163ToNews(t *testing.T) {
+	saved := config.Config.Crawler.Sources
+	config.Config.Crawler.Sources = map[string]config.CrawlerSourceConfig{"news163": {ClassID: 7}}
+	defer func() { config.Config.Crawler.Sources = saved }()
+
+	article := parseNews163Article(loadDocument(t, "news163_article.html"), news163ArticleURL)
+	news := News163{}.ToNews(article)
+
+	if news.Class_id != 7 {
+		t.Errorf("Class_id = %d, want 新闻源配置的 7", news.Class_id)
+	}
+	if news.Title != article.Title || news.Url != article.Url || news.Imgurl != article.Imgurl {
+		t.Errorf("标题、链接或图片没有照搬: %+v", news)
+	}
+	if news.Description != article.Description || news.Keywords != article.Keywords {
+		t.Errorf("描述或关键词没有照搬: %+v", news)
+	}
+	if news.Author != "王明" || news.Source != "中国新闻网" {
+		t.Errorf("Author = %q, Source = %q", news.Author, news.Source)
+	}
+	if news.Create_time != article.PublishTime {
+		t.Errorf("Create_time = %d, want %d", news.Create_time, article.PublishTime)
+	}
+	if news.Status != 1 || !news.Is_show || news.Language != "cn" {
+		t.Errorf("状态字段不对: Status=%d Is_show=%v Language=%q", news.Status, news.Is_show, news.Language)
+	}
+	// 正文里的相对图片地址按文章地址补全
+	if !strings.Contains(news.Content, "https://www.163.com/2024/0131/body.jpg") {
+		t.Errorf("正文图片地址没有补全: %q", news.Content)
+	}
+
+	// 文章里指定了分类时优先于新闻源配置，取不到来源时使用默认来源
+	article.ClassID = 3
+	article.Source = ""
+	news = News163{}.ToNews(article)
+	if news.Class_id != 3 {
+		t.Errorf("Class_id = %d, want 文章指定的 3", news.Class_id)
+	}
+	if news.Source != "网易新闻" {
+		t.Errorf("Source = %q, want 网易新闻", news.Source)
+	}
+}
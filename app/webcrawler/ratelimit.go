@@ -0,0 +1,103 @@
+package webcrawler
+
+import (
+	"context"
+	"math/rand"
+	"nav-web-site/config"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultHostInterval   = time.Second
+	defaultMaxRetries     = 3
+	defaultRetryBaseDelay = 500 * time.Millisecond
+	maxRetryDelay         = 30 * time.Second
+	maxRetryAfter         = time.Minute // 服务端要求的 Retry-After 超过这个时长就不再等待重试
+)
+
+// hostSlots 每个主机下一次允许请求的时间
+var (
+	hostSlots   = make(map[string]time.Time)
+	hostSlotsMu sync.Mutex
+)
+
+// hostInterval 同一主机两次请求的最小间隔，robots.txt 的 Crawl-delay 更大时以其为准
+func hostInterval(crawlDelay time.Duration) time.Duration {
+	interval := defaultHostInterval
+	if config.Config.Crawler.HostInterval > 0 {
+		interval = time.Duration(config.Config.Crawler.HostInterval) * time.Millisecond
+	}
+	if crawlDelay > interval {
+		interval = crawlDelay
+	}
+	return interval
+}
+
+// waitForHost 预约主机的下一个请求时间并等待到那时，多个协程同时抓同一主机时依次排队
+func waitForHost(ctx context.Context, host string, crawlDelay time.Duration) error {
+	interval := hostInterval(crawlDelay)
+
+	hostSlotsMu.Lock()
+	now := time.Now()
+	slot := hostSlots[host]
+	if slot.Before(now) {
+		slot = now
+	}
+	hostSlots[host] = slot.Add(interval)
+	hostSlotsMu.Unlock()
+
+	return sleepContext(ctx, slot.Sub(now))
+}
+
+// retryDelay 第 attempt 次重试前的等待时间：指数退避加随机抖动，落在 [d/2, d) 之间
+func retryDelay(attempt int) time.Duration {
+	base := defaultRetryBaseDelay
+	if config.Config.Crawler.RetryBaseDelay > 0 {
+		base = time.Duration(config.Config.Crawler.RetryBaseDelay) * time.Millisecond
+	}
+	delay := base << uint(attempt)
+	if delay <= 0 || delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// maxRetries 最大重试次数
+func maxRetries() int {
+	if config.Config.Crawler.MaxRetries > 0 {
+		return config.Config.Crawler.MaxRetries
+	}
+	return defaultMaxRetries
+}
+
+// parseRetryAfter 解析 Retry-After 头，支持秒数和 HTTP 日期两种格式
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := time.Parse(time.RFC1123, value); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
+
+// sleepContext 等待指定时长，ctx 取消时提前返回
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
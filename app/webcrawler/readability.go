@@ -0,0 +1,144 @@
+package webcrawler
+
+import (
+	"math"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+var (
+	// 类名或id包含这些词的节点更可能是正文
+	positiveHintRegexp = regexp.MustCompile(`(?i)article|body|content|entry|main|page|post|text|blog|story|detail`)
+	// 类名或id包含这些词的节点基本不是正文
+	negativeHintRegexp = regexp.MustCompile(`(?i)comment|meta|footer|footnote|foot|sidebar|side|widget|nav|menu|breadcrumb|share|social|related|recommend|hot|rank|ad-|ads|advert|banner|sponsor|promo|popup|login|subscribe|copyright|tag`)
+	// 明显不是正文的节点，评分前直接去掉
+	unlikelyRegexp = regexp.MustCompile(`(?i)comment|footer|sidebar|share|social|related|recommend|advert|banner|sponsor|popup|breadcrumb`)
+)
+
+// minArticleTextLength 正文至少要有这么多字，否则认为没找到
+const minArticleTextLength = 140
+
+// ExtractContent 仿照 Readability 的思路从整页 HTML 中找出正文：
+// 去掉脚本、导航等噪声后，按段落文字量给父节点打分，类名/id 带 article、content 等加分，
+// 带 comment、sidebar 等减分，再按链接密度降权，返回得分最高节点的 HTML；找不到时返回空字符串
+func ExtractContent(doc *goquery.Document) string {
+	body := doc.Find("body").First()
+	if body.Length() == 0 {
+		return ""
+	}
+	body = body.Clone()
+
+	body.Find("script,style,noscript,iframe,form,nav,header,footer,aside,button,input,select,textarea,svg").Remove()
+	body.Find("*").Each(func(_ int, s *goquery.Selection) {
+		if goquery.NodeName(s) == "body" || goquery.NodeName(s) == "article" {
+			return
+		}
+		hint := nodeHint(s)
+		if hint != "" && unlikelyRegexp.MatchString(hint) && !positiveHintRegexp.MatchString(hint) {
+			s.Remove()
+		}
+	})
+
+	scores := make(map[*html.Node]float64)
+	var candidates []*goquery.Selection
+	addScore := func(s *goquery.Selection, score float64) {
+		if s.Length() == 0 {
+			return
+		}
+		node := s.Get(0)
+		if _, ok := scores[node]; !ok {
+			candidates = append(candidates, s)
+			scores[node] = classWeight(s)
+			if tag := goquery.NodeName(s); tag == "article" || tag == "main" {
+				scores[node] += 10
+			}
+		}
+		scores[node] += score
+	}
+
+	body.Find("p,pre,td,blockquote,section > div,article > div").Each(func(_ int, p *goquery.Selection) {
+		text := strings.TrimSpace(p.Text())
+		length := utf8.RuneCountInString(text)
+		if length < 20 {
+			return
+		}
+		// 基础分1，每个逗号加1，每100字加1，最多加3
+		score := 1 + float64(strings.Count(text, ",")+strings.Count(text, "，")+strings.Count(text, "。"))
+		score += math.Min(float64(length)/100, 3)
+
+		parent := p.Parent()
+		addScore(parent, score)
+		if grand := parent.Parent(); grand.Length() > 0 && goquery.NodeName(grand) != "html" {
+			addScore(grand, score/2)
+		}
+	})
+
+	var best *goquery.Selection
+	bestScore := 0.0
+	for _, candidate := range candidates {
+		score := scores[candidate.Get(0)] * (1 - linkDensity(candidate))
+		if score > bestScore {
+			best, bestScore = candidate, score
+		}
+	}
+	if best == nil || utf8.RuneCountInString(strings.TrimSpace(best.Text())) < minArticleTextLength {
+		return ""
+	}
+
+	// 正文节点内部再清一遍链接密度过高的块（如文中插入的推荐列表）
+	best.Find("div,ul,ol,table,section").Each(func(_ int, s *goquery.Selection) {
+		if s.Find("img").Length() > 0 {
+			return
+		}
+		textLength := utf8.RuneCountInString(strings.TrimSpace(s.Text()))
+		if linkDensity(s) > 0.5 || (textLength < 25 && s.Find("p").Length() == 0 && negativeHintRegexp.MatchString(nodeHint(s))) {
+			s.Remove()
+		}
+	})
+
+	content, err := best.Html()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(content)
+}
+
+// nodeHint 节点的 class 和 id
+func nodeHint(s *goquery.Selection) string {
+	class, _ := s.Attr("class")
+	id, _ := s.Attr("id")
+	return strings.TrimSpace(class + " " + id)
+}
+
+// classWeight 根据 class 和 id 给节点加减分
+func classWeight(s *goquery.Selection) float64 {
+	hint := nodeHint(s)
+	if hint == "" {
+		return 0
+	}
+	weight := 0.0
+	if positiveHintRegexp.MatchString(hint) {
+		weight += 25
+	}
+	if negativeHintRegexp.MatchString(hint) {
+		weight -= 25
+	}
+	return weight
+}
+
+// linkDensity 链接文字占全部文字的比例
+func linkDensity(s *goquery.Selection) float64 {
+	textLength := utf8.RuneCountInString(strings.TrimSpace(s.Text()))
+	if textLength == 0 {
+		return 0
+	}
+	linkLength := 0
+	s.Find("a").Each(func(_ int, a *goquery.Selection) {
+		linkLength += utf8.RuneCountInString(strings.TrimSpace(a.Text()))
+	})
+	return float64(linkLength) / float64(textLength)
+}
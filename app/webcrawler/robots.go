@@ -0,0 +1,245 @@
+package webcrawler
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"nav-web-site/config"
+	"nav-web-site/util/log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultRobotsAgent = "NavWebSiteBot"
+	robotsCacheTTL     = 24 * time.Hour
+	robotsRetryTTL     = 10 * time.Minute // robots.txt 暂时取不到时，隔这么久再试
+	robotsMaxSize      = 512 * 1024       // 超过的部分忽略
+)
+
+// robotsRule 一条 Allow/Disallow 规则
+type robotsRule struct {
+	allow   bool
+	pattern string
+}
+
+// robotsRules 某个主机上适用于本爬虫的规则
+type robotsRules struct {
+	rules       []robotsRule
+	crawlDelay  time.Duration
+	disallowAll bool // robots.txt 返回 5xx 或无法访问时，按规范暂时全部禁止
+	expires     time.Time
+}
+
+// robotsEntry 缓存项，每个主机单独加锁，避免并发时重复请求 robots.txt
+type robotsEntry struct {
+	mu    sync.Mutex
+	rules *robotsRules
+}
+
+var (
+	robotsCache   = make(map[string]*robotsEntry)
+	robotsCacheMu sync.Mutex
+)
+
+// robotsAgent 返回 robots.txt 里匹配的爬虫名称
+func robotsAgent() string {
+	if config.Config.Crawler.RobotsAgent != "" {
+		return config.Config.Crawler.RobotsAgent
+	}
+	return defaultRobotsAgent
+}
+
+// robotsFor 获取主机的 robots.txt 规则，结果按主机缓存
+func robotsFor(ctx context.Context, u *url.URL) *robotsRules {
+	origin := u.Scheme + "://" + u.Host
+
+	robotsCacheMu.Lock()
+	entry, ok := robotsCache[origin]
+	if !ok {
+		entry = &robotsEntry{}
+		robotsCache[origin] = entry
+	}
+	robotsCacheMu.Unlock()
+
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	if entry.rules != nil && time.Now().Before(entry.rules.expires) {
+		return entry.rules
+	}
+	entry.rules = fetchRobots(ctx, origin, u.Host)
+	return entry.rules
+}
+
+// fetchRobots 请求并解析 robots.txt：
+// 4xx 视为没有限制；5xx 或网络错误时暂时全部禁止，过一会儿再试
+func fetchRobots(ctx context.Context, origin string, host string) *robotsRules {
+	robotsURL := origin + "/robots.txt"
+	if err := waitForHost(ctx, host, 0); err != nil {
+		return &robotsRules{disallowAll: true, expires: time.Now()}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, robotsURL, nil)
+	if err != nil {
+		return &robotsRules{expires: time.Now().Add(robotsCacheTTL)}
+	}
+	req.Header.Set("User-Agent", userAgent())
+	req.Header.Set("Accept", "text/plain")
+
+	resp, err := newHTTPClient().Do(req)
+	if err != nil {
+		log.ErrorLogger.Printf("获取robots.txt失败,url=%s: %v", robotsURL, err)
+		return &robotsRules{disallowAll: true, expires: time.Now().Add(robotsRetryTTL)}
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 500:
+		log.ErrorLogger.Printf("获取robots.txt失败,url=%s,状态码=%d", robotsURL, resp.StatusCode)
+		return &robotsRules{disallowAll: true, expires: time.Now().Add(robotsRetryTTL)}
+	case resp.StatusCode >= 400:
+		return &robotsRules{expires: time.Now().Add(robotsCacheTTL)}
+	case resp.StatusCode != http.StatusOK:
+		return &robotsRules{expires: time.Now().Add(robotsCacheTTL)}
+	}
+
+	body, _, err := readLimited(resp, robotsMaxSize)
+	if err != nil && len(body) == 0 {
+		return &robotsRules{disallowAll: true, expires: time.Now().Add(robotsRetryTTL)}
+	}
+	rules := parseRobots(body, robotsAgent())
+	rules.expires = time.Now().Add(robotsCacheTTL)
+	return rules
+}
+
+// parseRobots 解析 robots.txt，选出名称最匹配 agent 的分组，没有时使用 * 分组
+func parseRobots(body []byte, agent string) *robotsRules {
+	type group struct {
+		agents     []string
+		rules      []robotsRule
+		crawlDelay time.Duration
+	}
+	var groups []*group
+	var current *group
+	lastWasAgent := false
+
+	agent = strings.ToLower(agent)
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			if !lastWasAgent || current == nil {
+				current = &group{}
+				groups = append(groups, current)
+			}
+			current.agents = append(current.agents, strings.ToLower(value))
+			lastWasAgent = true
+			continue
+		case "allow", "disallow":
+			if current != nil && value != "" {
+				current.rules = append(current.rules, robotsRule{allow: key == "allow", pattern: value})
+			}
+		case "crawl-delay":
+			if current != nil {
+				if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+					current.crawlDelay = time.Duration(seconds * float64(time.Second))
+				}
+			}
+		}
+		lastWasAgent = false
+	}
+
+	// 名称越长匹配越精确；同名的多个分组规则合并
+	rules := &robotsRules{}
+	bestLen := -1
+	for _, g := range groups {
+		for _, name := range g.agents {
+			matchLen := -1
+			if name == "*" {
+				matchLen = 0
+			} else if name != "" && strings.Contains(agent, name) {
+				matchLen = len(name)
+			}
+			if matchLen < 0 || matchLen < bestLen {
+				continue
+			}
+			if matchLen > bestLen {
+				rules = &robotsRules{}
+				bestLen = matchLen
+			}
+			rules.rules = append(rules.rules, g.rules...)
+			if g.crawlDelay > rules.crawlDelay {
+				rules.crawlDelay = g.crawlDelay
+			}
+			break
+		}
+	}
+	return rules
+}
+
+// allowed 判断路径是否允许抓取：匹配最长的规则生效，长度相同时 Allow 优先
+func (r *robotsRules) allowed(u *url.URL) bool {
+	if r.disallowAll {
+		return false
+	}
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	if path == "/robots.txt" {
+		return true
+	}
+
+	allow, bestLen := true, -1
+	for _, rule := range r.rules {
+		if !robotsMatch(rule.pattern, path) {
+			continue
+		}
+		if len(rule.pattern) > bestLen || (len(rule.pattern) == bestLen && rule.allow) {
+			allow, bestLen = rule.allow, len(rule.pattern)
+		}
+	}
+	return allow
+}
+
+// robotsMatch 按 robots.txt 的通配规则匹配：* 匹配任意字符，结尾的 $ 表示必须匹配到末尾
+func robotsMatch(pattern string, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	if anchored {
+		pattern = strings.TrimSuffix(pattern, "$")
+	}
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	pos := len(parts[0])
+	for i, part := range parts[1:] {
+		if i == len(parts)-2 && anchored {
+			return strings.HasSuffix(path[pos:], part)
+		}
+		idx := strings.Index(path[pos:], part)
+		if idx < 0 {
+			return false
+		}
+		pos += idx + len(part)
+	}
+	return !anchored || pos == len(path)
+}
@@ -0,0 +1,295 @@
+package webcrawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"nav-web-site/app/sitecache"
+	"nav-web-site/config"
+	"nav-web-site/mydb"
+	"nav-web-site/util"
+	"nav-web-site/util/log"
+	"nav-web-site/util/sanitize"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ListItem 新闻源列表页上的一条文章
+type ListItem struct {
+	Title  string
+	Url    string
+	Imgurl string
+	Entry  *Article // 列表里已带完整内容时（如RSS订阅）直接附上，不再请求文章页
+}
+
+// Article 从文章页抓取到的内容
+type Article struct {
+	Url         string
+	Title       string
+	Description string
+	Keywords    string
+	Author      string
+	Source      string
+	Imgurl      string
+	Content     string // 正文HTML
+	PublishTime int64  // 发布时间（10位时间戳），取不到时为0
+	ClassID     int    // 指定存入的新闻分类，为0时使用新闻源配置
+}
+
+// Source 新闻源，每个新闻源负责列表抓取、文章抓取以及映射成 mydb.StructNews
+type Source interface {
+	// Name 新闻源名称，同时也是计划任务的类型名
+	Name() string
+	// FetchList 抓取文章列表
+	FetchList(ctx context.Context) ([]ListItem, error)
+	// FetchArticle 抓取单篇文章
+	FetchArticle(ctx context.Context, item ListItem) (Article, error)
+	// ToNews 把文章映射成待入库的新闻
+	ToNews(article Article) mydb.StructNews
+}
+
+// Result 一次抓取的统计结果
+type Result struct {
+	Fetched  int // 成功抓取的文章数
+	Inserted int // 新入库的文章数
+	Skipped  int // 因重复跳过的文章数
+	Failed   int // 抓取或入库失败的文章数
+}
+
+var (
+	sources   = make(map[string]Source)
+	sourcesMu sync.RWMutex
+)
+
+// ErrCommitRejected 入库前的校验不通过（如计划任务的锁已丢失），本次抓取不再写入
+var ErrCommitRejected = errors.New("入库校验不通过")
+
+type commitCheckKey struct{}
+
+// WithCommitCheck 给抓取加上入库前的校验：每条新闻写库前调用 check，返回错误时放弃写入并停止本次抓取。
+// 计划任务用它确认仍持有任务锁，锁丢失后已由别的实例接手，不能再写入
+func WithCommitCheck(ctx context.Context, check func(context.Context) error) context.Context {
+	return context.WithValue(ctx, commitCheckKey{}, check)
+}
+
+// checkCommit 执行 ctx 上的入库前校验，没有设置时直接通过
+func checkCommit(ctx context.Context) error {
+	check, ok := ctx.Value(commitCheckKey{}).(func(context.Context) error)
+	if !ok {
+		return nil
+	}
+	if err := check(ctx); err != nil {
+		return fmt.Errorf("%w: %v", ErrCommitRejected, err)
+	}
+	return nil
+}
+
+// Register 注册新闻源，名称重复时后注册的覆盖先注册的
+func Register(source Source) {
+	sourcesMu.Lock()
+	defer sourcesMu.Unlock()
+	sources[source.Name()] = source
+}
+
+// GetSource 按名称获取新闻源
+func GetSource(name string) (Source, bool) {
+	sourcesMu.RLock()
+	defer sourcesMu.RUnlock()
+	source, ok := sources[name]
+	return source, ok
+}
+
+// Names 返回所有已注册的新闻源名称
+func Names() []string {
+	sourcesMu.RLock()
+	defer sourcesMu.RUnlock()
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Run 执行指定新闻源的抓取并入库
+func Run(ctx context.Context, name string) (Result, error) {
+	source, ok := GetSource(name)
+	if !ok {
+		return Result{}, fmt.Errorf("未注册的新闻源: %s", name)
+	}
+	return RunSource(ctx, source)
+}
+
+// RunSource 执行新闻源的抓取并入库，未注册的新闻源（如单个订阅）也可以直接执行
+func RunSource(ctx context.Context, source Source) (Result, error) {
+	var result Result
+	name := source.Name()
+
+	items, err := source.FetchList(ctx)
+	if errors.Is(err, ErrNotModified) {
+		log.InfoLogger.Printf("[%s] 文章列表未更新", name)
+		return result, nil
+	}
+	if err != nil {
+		return result, util.WrapError(err, "抓取文章列表失败:")
+	}
+
+	maxItems := sourceConfig(name).MaxItems
+	if maxItems <= 0 {
+		maxItems = 30
+	}
+	pageFetches := 0
+
+	for _, item := range items {
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+		// 只限制文章页的请求次数，列表里已带完整内容的不受限制
+		if item.Entry == nil && pageFetches >= maxItems {
+			continue
+		}
+
+		// 列表页已经能拿到链接的，先按链接查重，省掉一次文章请求
+		if item.Url != "" {
+			exists, err := newsExistsByUrl(item.Url)
+			if err != nil {
+				log.ErrorLogger.Printf("[%s] 查询新闻是否存在失败,url=%s: %v", name, item.Url, err)
+			} else if exists {
+				result.Skipped++
+				continue
+			}
+		}
+
+		if item.Entry == nil {
+			pageFetches++
+		}
+		article, err := source.FetchArticle(ctx, item)
+		if errors.Is(err, ErrDisallowed) {
+			result.Skipped++
+			log.InfoLogger.Printf("[%s] robots.txt 禁止抓取,跳过,url=%s", name, item.Url)
+			continue
+		}
+		if err != nil {
+			result.Failed++
+			log.ErrorLogger.Printf("[%s] 抓取文章失败,url=%s: %v", name, item.Url, err)
+			continue
+		}
+		result.Fetched++
+
+		stored, err := StoreNews(ctx, source.ToNews(article))
+		if errors.Is(err, ErrCommitRejected) {
+			log.ErrorLogger.Printf("[%s] 停止抓取,url=%s: %v", name, article.Url, err)
+			return result, err
+		}
+		if err != nil {
+			result.Failed++
+			log.ErrorLogger.Printf("[%s] 新闻入库失败,url=%s: %v", name, article.Url, err)
+			continue
+		}
+		if stored {
+			result.Inserted++
+		} else {
+			result.Skipped++
+		}
+	}
+
+	log.InfoLogger.Printf("[%s] 抓取完成,列表%d条,抓取%d条,入库%d条,跳过%d条,失败%d条",
+		name, len(items), result.Fetched, result.Inserted, result.Skipped, result.Failed)
+	return result, nil
+}
+
+// StoreNews 按 StructNews.GetUniqueFields 查重、再做近似重复检测后入库，已存在或被跳过时返回 false；
+// 开启图片转存时，入库前把正文和封面里的外链图片转存到上传目录（开启 content.rehost_async 时入库后交给队列转存）。写库前执行 WithCommitCheck 设置的校验，
+// 不通过时返回 ErrCommitRejected
+func StoreNews(ctx context.Context, news mydb.StructNews) (bool, error) {
+	exists, err := mydb.CheckExistingRecord(mydb.Db, news, news.GetUniqueFields(), news.GetTableName(), config.Config.MySQL.TablePrefix, "")
+	if err != nil {
+		return false, err
+	}
+	if exists {
+		return false, nil
+	}
+	if CheckNearDuplicate(&news, true) {
+		return false, nil
+	}
+
+	// 开启 content.rehost_async 时先按外链图片入库，入库后交给队列转存
+	rehostAsync := config.Config.Content.RehostImages && config.Config.Content.RehostAsync
+	if !rehostAsync {
+		news.Content = RehostImages(ctx, news.Content)
+		news.Imgurl = RehostImage(ctx, news.Imgurl)
+	}
+
+	// 转存图片可能花了不少时间，写库前再确认一次
+	if err := checkCommit(ctx); err != nil {
+		return false, err
+	}
+	_, ids, err := mydb.Tables.News.Insert([]mydb.StructNews{news})
+	if err != nil {
+		return false, err
+	}
+	sitecache.ForgetSyndication()
+	if rehostAsync && len(ids) > 0 {
+		enqueueRehost(int(ids[0]))
+	}
+	return true, nil
+}
+
+// NewsFromArticle 按通用规则把文章映射成新闻，各新闻源的 ToNews 可以在此基础上调整
+func NewsFromArticle(article Article, classID int) mydb.StructNews {
+	if article.ClassID > 0 {
+		classID = article.ClassID
+	}
+	createTime := article.PublishTime
+	if createTime == 0 {
+		createTime = util.GetTimestamp(10)
+	}
+
+	// 抓取到的正文一律按白名单清洗，相对地址按文章地址补全
+	content := sanitize.HTML(article.Content, article.Url)
+
+	description := article.Description
+	if description == "" {
+		description = truncateRunes(htmlToText(content), 120)
+	}
+	if description == "" {
+		description = article.Title
+	}
+
+	return mydb.StructNews{
+		Class_id:    classID,
+		Title:       article.Title,
+		Url:         article.Url,
+		Imgurl:      article.Imgurl,
+		Description: description,
+		Keywords:    article.Keywords,
+		Sort:        50,
+		Is_show:     true,
+		Status:      1,
+		Create_time: createTime,
+		Author:      article.Author,
+		Source:      article.Source,
+		Language:    "cn",
+		Content:     content,
+	}
+}
+
+// sourceConfig 获取新闻源的配置
+func sourceConfig(name string) config.CrawlerSourceConfig {
+	return config.Config.Crawler.Sources[name]
+}
+
+// newsExistsByUrl 判断链接对应的新闻是否已入库
+func newsExistsByUrl(url string) (bool, error) {
+	count, err := mydb.GenericCount("news", fmt.Sprintf("url='%s'", mydb.EscapeString(url)), config.Config.MySQL.TablePrefix, "")
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// truncateRunes 去掉首尾空白后按字符数截断字符串
+func truncateRunes(text string, max int) string {
+	return util.TruncateRunes(strings.TrimSpace(text), max)
+}
@@ -0,0 +1,54 @@
+package webcrawler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"nav-web-site/mydb"
+	"nav-web-site/util"
+	"nav-web-site/util/log"
+)
+
+// crawlStateKey URL 的哈希，crawl_state 表按它查找
+func crawlStateKey(pageURL string) string {
+	sum := sha256.Sum256([]byte(pageURL))
+	return hex.EncodeToString(sum[:])
+}
+
+// loadCrawlState 读取URL上次的抓取状态，没有记录时返回空状态
+func loadCrawlState(pageURL string) mydb.StructCrawlState {
+	key := crawlStateKey(pageURL)
+	state, err := mydb.Tables.CrawlState.Find(mydb.QueryParams{Condition: fmt.Sprintf("url_hash='%s'", key)})
+	if err != nil {
+		return mydb.StructCrawlState{Url_hash: key, Url: pageURL}
+	}
+	return state
+}
+
+// saveCrawlState 保存抓取状态，数据库出错只记日志，不影响抓取
+func saveCrawlState(state mydb.StructCrawlState) {
+	state.Last_fetch_time = util.GetTimestamp(10)
+	state.Last_error = truncateRunes(state.Last_error, 250)
+	if state.ID > 0 {
+		if _, _, err := state.Update([]mydb.StructCrawlState{state}, fmt.Sprintf("id=%d", state.ID)); err != nil {
+			log.ErrorLogger.Printf("更新抓取状态失败,url=%s: %v", state.Url, err)
+		}
+		return
+	}
+	state.Create_time = state.Last_fetch_time
+	if _, _, err := state.Insert([]mydb.StructCrawlState{state}); err != nil {
+		log.ErrorLogger.Printf("保存抓取状态失败,url=%s: %v", state.Url, err)
+	}
+}
+
+// forgetValidators 清掉URL保存的 ETag/Last-Modified，下次抓取时强制取完整内容。
+// 内容取到了却处理失败时调用，避免之后一直收到 304 而再也处理不到
+func forgetValidators(pageURL string) {
+	state := loadCrawlState(pageURL)
+	if state.ID == 0 || (state.Etag == "" && state.Last_modified == "") {
+		return
+	}
+	state.Etag = ""
+	state.Last_modified = ""
+	saveCrawlState(state)
+}
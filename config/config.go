@@ -2,6 +2,7 @@ package config
 
 import (
 	"nav-web-site/util/log"
+	"sync"
 
 	"github.com/fsnotify/fsnotify"
 	"github.com/spf13/viper"
@@ -12,11 +13,25 @@ var Config ConfigStruct // 全局配置变量
 
 // ConfigStruct 是应用程序的顶级配置结构
 type ConfigStruct struct {
-	Base    Base
-	MySQL   MySQLConfig
-	Redis   RedisConfig
-	BaseUrl BaseUrlConfig `mapstructure:"base_url"`
-	Tasks   []TaskConfig  `yaml:"tasks"`
+	Base      Base
+	Server    ServerConfig `mapstructure:"server"`
+	TLS       TLSConfig    `mapstructure:"tls"`
+	DNS       DNSConfig    `mapstructure:"dns"`
+	MySQL     MySQLConfig
+	Redis     RedisConfig
+	BaseUrl   BaseUrlConfig   `mapstructure:"base_url"`
+	Upload    UploadConfig    `mapstructure:"upload"`
+	Crawler   CrawlerConfig   `mapstructure:"crawler"`
+	Feed      FeedConfig      `mapstructure:"feed"`
+	Content   ContentConfig   `mapstructure:"content"`
+	Dedupe    DedupeConfig    `mapstructure:"dedupe"`
+	Tasks     []TaskConfig    `yaml:"tasks"`
+	TaskLock  TaskLockConfig  `mapstructure:"task_lock"`
+	Queue     QueueConfig     `mapstructure:"queue"`
+	Notify    NotifyConfig    `mapstructure:"notify"`
+	DDNS      DDNSConfig      `mapstructure:"ddns"`
+	NavHealth NavHealthConfig `mapstructure:"nav_health"`
+	NavClick  NavClickConfig  `mapstructure:"nav_click"`
 }
 
 type Base struct {
@@ -26,6 +41,47 @@ type Base struct {
 	AllowOrigins    []string //允许的前端域名
 }
 
+type ServerConfig struct {
+	Addr              string   `mapstructure:"addr"`                // 监听地址，默认 :8080
+	ReadHeaderTimeout int      `mapstructure:"read_header_timeout"` // 读取请求头的超时（秒），默认10
+	ReadTimeout       int      `mapstructure:"read_timeout"`        // 读取整个请求的超时（秒），上传大文件时需要调大，默认60
+	WriteTimeout      int      `mapstructure:"write_timeout"`       // 写响应的超时（秒），默认120
+	IdleTimeout       int      `mapstructure:"idle_timeout"`        // keep-alive 空闲连接的超时（秒），默认120
+	ShutdownTimeout   int      `mapstructure:"shutdown_timeout"`    // 收到退出信号后等待请求和任务结束的最长时间（秒），默认30
+	TrustedProxies    []string `mapstructure:"trusted_proxies"`     // 信任的反向代理地址或网段，只有来自这些地址的 X-Forwarded-For 才用来取访问者IP，为空时一律用连接的对端地址
+}
+
+type TLSConfig struct {
+	Enable          bool     `mapstructure:"enable"`           // 是否监听 HTTPS
+	Addr            string   `mapstructure:"addr"`             // HTTPS 监听地址，默认 :443
+	Domains         []string `mapstructure:"domains"`          // 需要证书的域名，每一项申请一个证书，可用逗号分隔多个域名（SAN），如 "example.com,*.example.com"
+	Email           string   `mapstructure:"email"`            // ACME 账户联系邮箱
+	DirectoryURL    string   `mapstructure:"directory_url"`    // ACME 服务目录地址，默认 Let's Encrypt 正式环境，填 staging 为测试环境，也可填 Pebble 的 https://localhost:14000/dir
+	AccountKey      string   `mapstructure:"account_key"`      // ACME 账户私钥文件，不存在时自动生成，默认 cert_dir 下的 acme_account.key
+	KeyType         string   `mapstructure:"key_type"`         // 证书私钥类型：ecdsa（P-256，默认）或 rsa（2048位）
+	CAFile          string   `mapstructure:"ca_file"`          // 额外信任的 CA 证书（PEM），用于访问 Pebble 等自签名的 ACME 服务
+	CertDir         string   `mapstructure:"cert_dir"`         // 证书和私钥的保存目录，默认 certs
+	DNSProvider     string   `mapstructure:"dns_provider"`     // DNS-01 验证用的 DNS 服务商：cloudflare、aliyun，为空或 auto 时按域名的 NS 记录判断，测试时可用 challtestsrv
+	ChallTestSrv    string   `mapstructure:"challtestsrv"`     // pebble-challtestsrv 的管理地址，默认 http://localhost:8055
+	PropagationWait int      `mapstructure:"propagation_wait"` // 添加 TXT 记录后等待生效的秒数，默认60（challtestsrv 为0）
+	RenewBefore     int      `mapstructure:"renew_before"`     // 证书剩余有效期少于这么多天时续期，默认30
+	WarnBefore      int      `mapstructure:"warn_before"`      // 证书剩余有效期少于这么多天且续期没有成功时发送告警，默认14
+}
+
+type DNSConfig struct {
+	Cloudflare CloudflareConfig `mapstructure:"cloudflare"`
+	Aliyun     AliyunConfig     `mapstructure:"aliyun"`
+}
+
+type CloudflareConfig struct {
+	APIToken string `mapstructure:"api_token"` // 需要 Zone.DNS 编辑权限
+}
+
+type AliyunConfig struct {
+	AccessKeyID     string `mapstructure:"access_key_id"`
+	AccessKeySecret string `mapstructure:"access_key_secret"`
+}
+
 type MySQLConfig struct {
 	Host            string
 	Port            int
@@ -47,9 +103,113 @@ type BaseUrlConfig struct {
 	AdPicUrl string `mapstructure:"ad_pic_url"`
 }
 
+type UploadConfig struct {
+	OrphanGraceHours int    `mapstructure:"orphan_grace_hours"` // 孤儿文件清理的宽限期（小时），上传不足该时长的文件不清理，默认24
+	SignSecret       string `mapstructure:"sign_secret"`        // 私有文件签名链接的HMAC密钥，为空时私有文件不可访问
+	SignTTL          int    `mapstructure:"sign_ttl"`           // 签名链接默认有效期（秒），默认3600
+}
+
+type CrawlerConfig struct {
+	UserAgent      string                         `mapstructure:"user_agent"`       // 抓取时使用的User-Agent
+	RobotsAgent    string                         `mapstructure:"robots_agent"`     // robots.txt 里匹配的爬虫名称，默认 NavWebSiteBot
+	IgnoreRobots   bool                           `mapstructure:"ignore_robots"`    // 是否忽略 robots.txt，只用于调试
+	Timeout        int                            `mapstructure:"timeout"`          // 单次请求超时（秒），默认15
+	HostInterval   int                            `mapstructure:"host_interval"`    // 同一主机两次请求的最小间隔（毫秒），默认1000，robots.txt 的 Crawl-delay 更大时以其为准
+	MaxRetries     int                            `mapstructure:"max_retries"`      // 网络错误、429、5xx 时的最大重试次数，默认3
+	RetryBaseDelay int                            `mapstructure:"retry_base_delay"` // 重试退避的基础时长（毫秒），默认500，每次翻倍并加随机抖动
+	MaxBodySize    int64                          `mapstructure:"max_body_size"`    // 单个响应的最大字节数，默认5MB
+	Sources        map[string]CrawlerSourceConfig `mapstructure:"sources"`          // 按新闻源名称配置，如 news163
+}
+
+type CrawlerSourceConfig struct {
+	ClassID  int `mapstructure:"class_id"`  // 抓取的新闻存入的新闻分类
+	MaxItems int `mapstructure:"max_items"` // 每次最多请求的文章页数，默认30
+}
+
+type FeedConfig struct {
+	SiteURL     string `mapstructure:"site_url"`    // 站点地址，如 https://nav.fandoc.org，订阅里的链接都以此为准，为空时不输出订阅
+	Title       string `mapstructure:"title"`       // 订阅输出的标题，为空时使用站点域名
+	Description string `mapstructure:"description"` // 订阅输出的描述
+	Limit       int    `mapstructure:"limit"`       // 输出的新闻条数，默认20
+	CacheTTL    int    `mapstructure:"cache_ttl"`   // 订阅内容缓存时间（秒），默认600
+	ArticleURL  string `mapstructure:"article_url"` // 站内文章地址模板，%d 替换为新闻ID，新闻没有原文链接时使用
+}
+
+type ContentConfig struct {
+	RehostImages    bool `mapstructure:"rehost_images"`     // 是否把新闻正文和封面里的外链图片转存到上传目录
+	MaxRehostImages int  `mapstructure:"max_rehost_images"` // 每篇新闻最多转存的图片数，默认20
+	RehostAsync     bool `mapstructure:"rehost_async"`      // 抓取的新闻先按外链图片入库，转存交给队列任务 rehost_images 执行
+}
+
+type DedupeConfig struct {
+	Mode       string `mapstructure:"mode"`        // 发现近似重复新闻时的处理：cluster=归入故事聚合（默认），skip=抓取时跳过，off=不检测
+	Threshold  int    `mapstructure:"threshold"`   // 标题和正文 SimHash 的汉明距离不超过该值视为近似重复，默认6
+	WindowDays int    `mapstructure:"window_days"` // 只和最近几天入库的新闻比较，默认3
+}
+
+type QueueConfig struct {
+	Workers           int `mapstructure:"workers"`            // 每个实例执行队列任务的协程数，默认4
+	PollInterval      int `mapstructure:"poll_interval"`      // 从数据库补捞到期任务的间隔（秒），Redis 不可用或重试延迟到期时靠它，默认5
+	MaxAttempts       int `mapstructure:"max_attempts"`       // 入队时未指定时的最多执行次数，默认5
+	RetryBaseDelay    int `mapstructure:"retry_base_delay"`   // 重试的基础退避时间（秒），每次翻倍，最长1小时，默认10
+	VisibilityTimeout int `mapstructure:"visibility_timeout"` // 执行中的任务超过这么久（秒）没有心跳就放回队列，默认600
+}
+
+type NavHealthConfig struct {
+	Concurrency   int `mapstructure:"concurrency"`    // 同时检查的链接数，默认8
+	Timeout       int `mapstructure:"timeout"`        // 单个链接的超时（秒），默认15
+	FailThreshold int `mapstructure:"fail_threshold"` // 连续失败这么多次后自动隐藏导航，恢复正常后自动显示，默认3，填-1不自动隐藏
+}
+
+type NavClickConfig struct {
+	FlushInterval int      `mapstructure:"flush_interval"`  // 点击数写回数据库的间隔（秒），默认60
+	BotUserAgents []string `mapstructure:"bot_user_agents"` // 额外的爬虫 User-Agent 关键词（不区分大小写），这些请求只跳转不计数
+}
+
+type DDNSConfig struct {
+	Records        []DDNSRecord `mapstructure:"records"`         // 需要跟随公网地址更新的记录，计划任务 ddns 的参数里没有 records 时使用
+	IPv4Resolvers  []string     `mapstructure:"ipv4_resolvers"`  // 查询公网 IPv4 的地址，返回纯文本 IP，依次尝试直到成功
+	IPv6Resolvers  []string     `mapstructure:"ipv6_resolvers"`  // 查询公网 IPv6 的地址
+	VerifyInterval int          `mapstructure:"verify_interval"` // 地址没变时，每隔多久（分钟）和服务商上的记录核对一次，默认60，-1 不核对
+}
+
+type DDNSRecord struct {
+	Name     string `mapstructure:"name" json:"name"`         // 完整域名，如 home.example.com
+	Type     string `mapstructure:"type" json:"type"`         // A（默认）或 AAAA
+	Provider string `mapstructure:"provider" json:"provider"` // DNS 服务商，为空时按 NS 记录判断
+	TTL      int    `mapstructure:"ttl" json:"ttl"`           // 为空时用服务商的默认值
+}
+
+type NotifyConfig struct {
+	Channels []NotifyChannel `mapstructure:"channels"` // 告警的通知渠道，如证书即将过期、续期失败
+}
+
+type NotifyChannel struct {
+	Type   string `mapstructure:"type"`   // dingtalk、wecom、feishu 机器人，或 webhook（POST JSON：title、text、time）
+	URL    string `mapstructure:"url"`    // 机器人或 webhook 地址
+	Secret string `mapstructure:"secret"` // 钉钉机器人的加签密钥，可选
+}
+
+type TaskLockConfig struct {
+	TTL int `mapstructure:"ttl"` // 任务分布式锁的有效期（秒），执行期间自动续期，实例崩溃后最多这么久锁才释放，默认60
+}
+
 type TaskConfig struct {
 	Type     string `yaml:"type"`
 	Schedule string `yaml:"schedule"`
+	Timezone string `yaml:"timezone"` // 可选，cron 表达式所用的时区，如 Asia/Shanghai，默认服务器本地时区
+}
+
+var (
+	reloadHooks   []func()
+	reloadHooksMu sync.Mutex
+)
+
+// OnReload 注册配置文件热更新后要执行的函数，如把新增的计划任务同步到调度器
+func OnReload(hook func()) {
+	reloadHooksMu.Lock()
+	defer reloadHooksMu.Unlock()
+	reloadHooks = append(reloadHooks, hook)
 }
 
 func InitConfig() {
@@ -83,5 +243,12 @@ func InitConfig() {
 			log.InfoLogger.Printf("Unable to decode into struct: %v", err)
 		}
 		log.InfoLogger.Printf("Reloaded Config: %v", Config)
+
+		reloadHooksMu.Lock()
+		hooks := append([]func(){}, reloadHooks...)
+		reloadHooksMu.Unlock()
+		for _, hook := range hooks {
+			hook()
+		}
 	})
 }
@@ -19,6 +19,7 @@ require (
 	github.com/swaggo/swag v1.16.3
 	golang.org/x/crypto v0.27.0
 	golang.org/x/net v0.29.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -74,5 +75,4 @@ require (
 	google.golang.org/protobuf v1.34.1 // indirect
 	gopkg.in/ini.v1 v1.67.0 // indirect
 	gopkg.in/yaml.v2 v2.4.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
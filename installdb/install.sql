@@ -80,3 +80,190 @@ CREATE TABLE ba_uploadfile (
 );
 
 
+
+-- 媒体库：记录上传者
+ALTER TABLE ba_uploadfile ADD COLUMN admin_id INT NOT NULL DEFAULT 0;
+ALTER TABLE ba_uploadfile ADD INDEX idx_upload_time (upload_time);
+
+-- 私有文件：只能通过签名链接访问
+ALTER TABLE ba_uploadfile ADD COLUMN is_private TINYINT(1) NOT NULL DEFAULT 0;
+
+-- 创建news_feed表：RSS/Atom/JSON Feed 订阅源
+CREATE TABLE ba_news_feed (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    admin_id INT NOT NULL DEFAULT 0,
+    name VARCHAR(255) NOT NULL,
+    url VARCHAR(512) NOT NULL,
+    class_id INT NOT NULL,
+    is_enable TINYINT(1) NOT NULL DEFAULT 1,
+    last_fetch_time BIGINT NOT NULL DEFAULT 0,
+    last_status VARCHAR(255) NOT NULL DEFAULT '',
+    create_time BIGINT NOT NULL DEFAULT 0,
+    update_time BIGINT NOT NULL DEFAULT 0,
+    UNIQUE KEY uk_url (url)
+);
+
+-- 创建crawl_state表：按URL记录抓取状态，用于条件请求
+CREATE TABLE ba_crawl_state (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    url_hash CHAR(64) NOT NULL,
+    url VARCHAR(2048) NOT NULL,
+    etag VARCHAR(255) NOT NULL DEFAULT '',
+    last_modified VARCHAR(64) NOT NULL DEFAULT '',
+    status_code INT NOT NULL DEFAULT 0,
+    content_length BIGINT NOT NULL DEFAULT 0,
+    fail_count INT NOT NULL DEFAULT 0,
+    last_error VARCHAR(255) NOT NULL DEFAULT '',
+    last_fetch_time BIGINT NOT NULL DEFAULT 0,
+    create_time BIGINT NOT NULL DEFAULT 0,
+    UNIQUE KEY uk_url_hash (url_hash)
+);
+
+-- 近似重复检测：新闻的 SimHash 指纹和所属故事聚合
+ALTER TABLE ba_news ADD COLUMN simhash BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE ba_news ADD COLUMN cluster_id INT NOT NULL DEFAULT 0;
+ALTER TABLE ba_news ADD INDEX idx_create_time (create_time);
+ALTER TABLE ba_news ADD INDEX idx_cluster_id (cluster_id);
+ALTER TABLE ba_news ADD COLUMN update_time BIGINT NOT NULL DEFAULT 0;
+
+-- 创建news_cluster表：故事聚合
+CREATE TABLE ba_news_cluster (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    title VARCHAR(255) NOT NULL,
+    lead_news_id INT NOT NULL,
+    create_time BIGINT NOT NULL DEFAULT 0,
+    update_time BIGINT NOT NULL DEFAULT 0,
+    UNIQUE KEY uk_lead_news_id (lead_news_id)
+);
+
+-- 创建task_run表：计划任务和手动任务的执行记录
+CREATE TABLE ba_task_run (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    task_type VARCHAR(64) NOT NULL,
+    trigger_type VARCHAR(16) NOT NULL DEFAULT '',
+    status VARCHAR(16) NOT NULL DEFAULT '',
+    start_time BIGINT NOT NULL DEFAULT 0,
+    end_time BIGINT NOT NULL DEFAULT 0,
+    duration_ms BIGINT NOT NULL DEFAULT 0,
+    fetched INT NOT NULL DEFAULT 0,
+    inserted INT NOT NULL DEFAULT 0,
+    skipped INT NOT NULL DEFAULT 0,
+    failed INT NOT NULL DEFAULT 0,
+    error TEXT NOT NULL,
+    stack TEXT NOT NULL,
+    INDEX idx_task_type (task_type),
+    INDEX idx_status (status)
+);
+
+-- 创建scheduled_task表：计划任务，启动时会把配置文件里的 tasks 同步进来
+CREATE TABLE ba_scheduled_task (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    name VARCHAR(64) NOT NULL,
+    task_type VARCHAR(64) NOT NULL,
+    cron_expr VARCHAR(128) NOT NULL,
+    timezone VARCHAR(64) NOT NULL DEFAULT '',
+    params TEXT NOT NULL,
+    is_enable TINYINT(1) NOT NULL DEFAULT 1,
+    create_time BIGINT NOT NULL DEFAULT 0,
+    update_time BIGINT NOT NULL DEFAULT 0,
+    UNIQUE KEY uk_name (name)
+);
+
+-- 计划任务的来源：config 配置文件同步、admin 后台添加
+ALTER TABLE ba_scheduled_task ADD COLUMN source VARCHAR(16) NOT NULL DEFAULT 'admin';
+
+-- 创建job表：后台任务队列，status=dead 为死信
+CREATE TABLE ba_job (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    job_type VARCHAR(64) NOT NULL,
+    payload TEXT NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'queued',
+    attempts INT NOT NULL DEFAULT 0,
+    max_attempts INT NOT NULL DEFAULT 5,
+    run_at BIGINT NOT NULL DEFAULT 0,
+    last_error VARCHAR(1024) NOT NULL DEFAULT '',
+    create_time BIGINT NOT NULL DEFAULT 0,
+    update_time BIGINT NOT NULL DEFAULT 0,
+    finish_time BIGINT NOT NULL DEFAULT 0,
+    INDEX idx_status_run_at (status, run_at)
+);
+
+-- 创建dns_audit表：解析记录改动的审计日志
+CREATE TABLE ba_dns_audit (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    admin_id INT NOT NULL DEFAULT 0,
+    source VARCHAR(16) NOT NULL DEFAULT '',
+    action VARCHAR(16) NOT NULL DEFAULT '',
+    provider VARCHAR(32) NOT NULL DEFAULT '',
+    zone VARCHAR(255) NOT NULL DEFAULT '',
+    record_id VARCHAR(64) NOT NULL DEFAULT '',
+    record_type VARCHAR(16) NOT NULL DEFAULT '',
+    record_name VARCHAR(255) NOT NULL DEFAULT '',
+    old_value TEXT NOT NULL,
+    new_value TEXT NOT NULL,
+    create_time BIGINT NOT NULL DEFAULT 0,
+    INDEX idx_zone (zone),
+    INDEX idx_create_time (create_time)
+);
+
+-- 创建certificate表：证书清单和最近一次续期的结果
+CREATE TABLE ba_certificate (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    domain VARCHAR(255) NOT NULL,
+    sans TEXT NOT NULL,
+    issuer VARCHAR(255) NOT NULL DEFAULT '',
+    not_before BIGINT NOT NULL DEFAULT 0,
+    not_after BIGINT NOT NULL DEFAULT 0,
+    cert_file VARCHAR(512) NOT NULL DEFAULT '',
+    key_file VARCHAR(512) NOT NULL DEFAULT '',
+    last_renew_time BIGINT NOT NULL DEFAULT 0,
+    last_renew_status VARCHAR(16) NOT NULL DEFAULT '',
+    last_renew_error VARCHAR(1024) NOT NULL DEFAULT '',
+    notify_time BIGINT NOT NULL DEFAULT 0,
+    create_time BIGINT NOT NULL DEFAULT 0,
+    update_time BIGINT NOT NULL DEFAULT 0,
+    UNIQUE KEY uk_domain (domain)
+);
+
+-- 创建ddns_history表：动态域名的地址变化记录
+CREATE TABLE ba_ddns_history (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    record_type VARCHAR(8) NOT NULL DEFAULT 'A',
+    provider VARCHAR(32) NOT NULL DEFAULT '',
+    old_ip VARCHAR(64) NOT NULL DEFAULT '',
+    new_ip VARCHAR(64) NOT NULL DEFAULT '',
+    status VARCHAR(16) NOT NULL DEFAULT '',
+    error VARCHAR(1024) NOT NULL DEFAULT '',
+    create_time BIGINT NOT NULL DEFAULT 0,
+    INDEX idx_name_type (name, record_type)
+);
+
+-- 创建nav_health表：导航链接的健康检查结果
+CREATE TABLE ba_nav_health (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    nav_id INT NOT NULL,
+    url VARCHAR(1024) NOT NULL DEFAULT '',
+    status_code INT NOT NULL DEFAULT 0,
+    latency_ms BIGINT NOT NULL DEFAULT 0,
+    redirect_url VARCHAR(1024) NOT NULL DEFAULT '',
+    tls_expire_time BIGINT NOT NULL DEFAULT 0,
+    error VARCHAR(1024) NOT NULL DEFAULT '',
+    is_ok TINYINT(1) NOT NULL DEFAULT 0,
+    fail_count INT NOT NULL DEFAULT 0,
+    auto_hidden TINYINT(1) NOT NULL DEFAULT 0,
+    last_ok_time BIGINT NOT NULL DEFAULT 0,
+    check_time BIGINT NOT NULL DEFAULT 0,
+    UNIQUE KEY uk_nav_id (nav_id)
+);
+
+-- 创建nav_click_daily表：导航链接每天的点击数
+CREATE TABLE ba_nav_click_daily (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    nav_id INT NOT NULL,
+    click_date INT NOT NULL,
+    clicks BIGINT NOT NULL DEFAULT 0,
+    update_time BIGINT NOT NULL DEFAULT 0,
+    UNIQUE KEY uk_nav_date (nav_id, click_date),
+    INDEX idx_click_date (click_date)
+);
@@ -0,0 +1,189 @@
+package mydb
+
+import (
+	"fmt"
+	"nav-web-site/config"
+	"nav-web-site/util"
+	"nav-web-site/util/log"
+)
+
+// StructCertificate 定义证书清单结构体，记录已签发证书的信息和最近一次续期的结果
+type StructCertificate struct {
+	ID                int    `db:"id"`                // id
+	Domain            string `db:"domain"`            // 申请证书的域名，如 nav.example.com、*.example.com
+	Sans              string `db:"sans"`              // 证书包含的全部域名，逗号分隔
+	Issuer            string `db:"issuer"`            // 签发机构
+	Not_before        int64  `db:"not_before"`        // 生效时间
+	Not_after         int64  `db:"not_after"`         // 到期时间
+	Cert_file         string `db:"cert_file"`         // 证书文件路径
+	Key_file          string `db:"key_file"`          // 私钥文件路径
+	Last_renew_time   int64  `db:"last_renew_time"`   // 最近一次申请或续期的时间
+	Last_renew_status string `db:"last_renew_status"` // 最近一次申请或续期的结果：success、failed
+	Last_renew_error  string `db:"last_renew_error"`  // 最近一次申请或续期失败的错误
+	Notify_time       int64  `db:"notify_time"`       // 最近一次发送到期告警的时间，避免重复告警
+	Create_time       int64  `db:"create_time"`       // 创建时间
+	Update_time       int64  `db:"update_time"`       // 更新时间
+}
+
+// 获取表名（不含前后缀）
+func (s *StructCertificate) GetTableName() string {
+	return "certificate"
+}
+
+// 获取插入数据时的必填字段
+func (s *StructCertificate) GetRequiredFields() []string {
+	return []string{
+		"Domain",
+		"Cert_file",
+		"Key_file",
+		"Create_time",
+	}
+}
+
+// 插入数据时查重的字段，一个域名只有一条
+func (s StructCertificate) GetUniqueFields() []string {
+	return []string{
+		"Domain",
+	}
+}
+
+// Find 方法根据条件查询单个 certificate 记录
+func (s *StructCertificate) Find(params QueryParams) (StructCertificate, error) {
+	var certificate StructCertificate
+	results, err := GenericSelect(Db, s.GetTableName(), params, config.Config.MySQL.TablePrefix, "")
+	if err != nil {
+		return certificate, util.WrapError(err, "Query failed(find):")
+	}
+
+	if len(results) > 0 {
+		certificate, err = s.mapResultToStructItem(results[0])
+		if err != nil {
+			return certificate, util.WrapError(err, "将结果映射到StructCertificate时出错:")
+		}
+	} else {
+		// 如果没有查询到数据，返回一个错误
+		return certificate, util.WrapError(fmt.Errorf("EmptyData"), "")
+	}
+	return certificate, nil
+}
+
+// Select 方法查询 certificate 表的数据
+func (s *StructCertificate) Select(params QueryParams) ([]StructCertificate, int, error) {
+	var list []StructCertificate
+	results, err := GenericSelect(Db, s.GetTableName(), params, config.Config.MySQL.TablePrefix, "")
+	if err != nil {
+		return list, 400, util.WrapError(err, "Query failed(select):")
+	}
+
+	if len(results) > 0 {
+		for _, result := range results {
+			item, err := s.mapResultToStructItem(result)
+			if err != nil {
+				log.ErrorLogger.Println("将结果映射到StructCertificate时出错:", err)
+				continue
+			}
+
+			list = append(list, item)
+		}
+	} else {
+		// 如果没有查询到数据，返回一个错误
+		return list, 200, util.WrapError(fmt.Errorf("EmptyData"), "")
+	}
+	return list, 0, nil
+}
+
+// Insert 方法插入新的 certificate 记录
+func (s *StructCertificate) Insert(datas []StructCertificate) (int, []int64, error) {
+	count, ids, err := GenericInsert(
+		s.GetTableName(),
+		datas,
+		s.GetRequiredFields(),
+		config.Config.MySQL.TablePrefix,
+		"",
+	)
+	if err != nil {
+		return 0, ids, err
+	}
+	return count, ids, nil
+}
+
+// Update 方法更新 certificate 记录
+func (s *StructCertificate) Update(datas []StructCertificate, condition string) (int, []int64, error) {
+	count, ids, err := GenericUpdate(
+		s.GetTableName(),
+		datas,
+		condition,
+		config.Config.MySQL.TablePrefix,
+		"",
+	)
+	if err != nil {
+		return 0, ids, err
+	}
+	return count, ids, nil
+}
+
+// mapResultToStructItem 将查询结果映射到结构体
+func (s *StructCertificate) mapResultToStructItem(result map[string]interface{}) (StructCertificate, error) {
+	var item StructCertificate
+	var ok bool
+
+	if id, ok := result["id"].(int64); ok {
+		item.ID = int(id)
+	} else {
+		return item, util.WrapError(fmt.Errorf("错误：无法将id转换为int64：%v", result["id"]), "")
+	}
+
+	if item.Domain, ok = result["domain"].(string); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将domain转换为string：%v", result["domain"]), "")
+	}
+
+	if item.Sans, ok = result["sans"].(string); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将sans转换为string：%v", result["sans"]), "")
+	}
+
+	if item.Issuer, ok = result["issuer"].(string); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将issuer转换为string：%v", result["issuer"]), "")
+	}
+
+	if item.Not_before, ok = result["not_before"].(int64); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将not_before转换为int64：%v", result["not_before"]), "")
+	}
+
+	if item.Not_after, ok = result["not_after"].(int64); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将not_after转换为int64：%v", result["not_after"]), "")
+	}
+
+	if item.Cert_file, ok = result["cert_file"].(string); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将cert_file转换为string：%v", result["cert_file"]), "")
+	}
+
+	if item.Key_file, ok = result["key_file"].(string); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将key_file转换为string：%v", result["key_file"]), "")
+	}
+
+	if item.Last_renew_time, ok = result["last_renew_time"].(int64); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将last_renew_time转换为int64：%v", result["last_renew_time"]), "")
+	}
+
+	if item.Last_renew_status, ok = result["last_renew_status"].(string); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将last_renew_status转换为string：%v", result["last_renew_status"]), "")
+	}
+
+	if item.Last_renew_error, ok = result["last_renew_error"].(string); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将last_renew_error转换为string：%v", result["last_renew_error"]), "")
+	}
+
+	if item.Notify_time, ok = result["notify_time"].(int64); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将notify_time转换为int64：%v", result["notify_time"]), "")
+	}
+
+	if item.Create_time, ok = result["create_time"].(int64); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将create_time转换为int64：%v", result["create_time"]), "")
+	}
+
+	if item.Update_time, ok = result["update_time"].(int64); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将update_time转换为int64：%v", result["update_time"]), "")
+	}
+
+	return item, nil
+}
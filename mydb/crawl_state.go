@@ -0,0 +1,194 @@
+package mydb
+
+import (
+	"fmt"
+	"nav-web-site/config"
+	"nav-web-site/util"
+	"nav-web-site/util/log"
+)
+
+// StructCrawlState 定义抓取状态结构体，按URL记录最近一次抓取的结果，用于条件请求
+type StructCrawlState struct {
+	ID              int    `db:"id"`              // id
+	Url_hash        string `db:"url_hash"`        // url 的 sha256，用于唯一索引
+	Url             string `db:"url"`             // 抓取地址
+	Etag            string `db:"etag"`            // 响应的 ETag
+	Last_modified   string `db:"last_modified"`   // 响应的 Last-Modified
+	Status_code     int    `db:"status_code"`     // 最近一次的HTTP状态码，网络错误时为0
+	Content_length  int64  `db:"content_length"`  // 最近一次成功抓取的内容大小
+	Fail_count      int    `db:"fail_count"`      // 连续失败次数
+	Last_error      string `db:"last_error"`      // 最近一次的错误信息
+	Last_fetch_time int64  `db:"last_fetch_time"` // 最近一次抓取时间
+	Create_time     int64  `db:"create_time"`     // 创建时间
+}
+
+// 获取表名（不含前后缀）
+func (s *StructCrawlState) GetTableName() string {
+	return "crawl_state"
+}
+
+// 获取插入数据时的必填字段
+func (s *StructCrawlState) GetRequiredFields() []string {
+	return []string{
+		"Url_hash",
+		"Url",
+	}
+}
+
+// 插入数据时查重的字段
+func (s StructCrawlState) GetUniqueFields() []string {
+	return []string{"Url_hash"}
+}
+
+// Find 方法根据条件查询单个 crawl_state 记录
+func (s *StructCrawlState) Find(params QueryParams) (StructCrawlState, error) {
+	var state StructCrawlState
+	results, err := GenericSelect(Db, s.GetTableName(), params, config.Config.MySQL.TablePrefix, "")
+	if err != nil {
+		return state, util.WrapError(err, "Query failed(find):")
+	}
+
+	if len(results) > 0 {
+		state, err = s.mapResultToStructItem(results[0])
+		if err != nil {
+			return state, util.WrapError(err, "将结果映射到StructCrawlState时出错:")
+		}
+	} else {
+		// 如果没有查询到数据，返回一个错误
+		return state, util.WrapError(fmt.Errorf("EmptyData"), "")
+	}
+	return state, nil
+}
+
+// Select 方法查询 crawl_state 表的数据
+func (s *StructCrawlState) Select(params QueryParams) ([]StructCrawlState, int, error) {
+	var list []StructCrawlState
+	results, err := GenericSelect(Db, s.GetTableName(), params, config.Config.MySQL.TablePrefix, "")
+	if err != nil {
+		return list, 400, util.WrapError(err, "Query failed(select):")
+	}
+
+	if len(results) > 0 {
+		for _, result := range results {
+			item, err := s.mapResultToStructItem(result)
+			if err != nil {
+				log.ErrorLogger.Println("将结果映射到StructCrawlState时出错:", err)
+				continue
+			}
+
+			list = append(list, item)
+		}
+	} else {
+		// 如果没有查询到数据，返回一个错误
+		return list, 200, util.WrapError(fmt.Errorf("EmptyData"), "")
+	}
+	return list, 0, nil
+}
+
+// Insert 方法插入新的 crawl_state 记录
+func (s *StructCrawlState) Insert(datas []StructCrawlState) (int, []int64, error) {
+	count, ids, err := GenericInsert(
+		s.GetTableName(),
+		datas,
+		s.GetRequiredFields(),
+		config.Config.MySQL.TablePrefix,
+		"",
+	)
+	if err != nil {
+		return 0, ids, err
+	}
+	return count, ids, nil
+}
+
+// Update 方法更新 crawl_state 记录
+func (s *StructCrawlState) Update(datas []StructCrawlState, condition string) (int, []int64, error) {
+	count, ids, err := GenericUpdate(
+		s.GetTableName(),
+		datas,
+		condition,
+		config.Config.MySQL.TablePrefix,
+		"",
+	)
+	if err != nil {
+		return 0, ids, err
+	}
+	return count, ids, nil
+}
+
+// Delete 方法删除 crawl_state 记录
+func (s *StructCrawlState) Delete(condition string) (int, []int64, error) {
+	count, ids, err := GenericDelete(
+		s.GetTableName(),
+		condition,
+		config.Config.MySQL.TablePrefix,
+		"",
+	)
+	if err != nil {
+		return 0, ids, err
+	}
+	return count, ids, nil
+}
+
+// mapResultToStructItem 将查询结果映射到结构体
+func (s *StructCrawlState) mapResultToStructItem(result map[string]interface{}) (StructCrawlState, error) {
+	var item StructCrawlState
+	var ok bool
+
+	if id, ok := result["id"].(int64); ok {
+		item.ID = int(id)
+	} else {
+		return item, util.WrapError(fmt.Errorf("错误：无法将id转换为int64：%v", result["id"]), "")
+	}
+
+	if item.Url_hash, ok = result["url_hash"].(string); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将url_hash转换为string：%v", result["url_hash"]), "")
+	}
+
+	if item.Url, ok = result["url"].(string); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将url转换为string：%v", result["url"]), "")
+	}
+
+	if item.Etag, ok = result["etag"].(string); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将etag转换为string：%v", result["etag"]), "")
+	}
+
+	if item.Last_modified, ok = result["last_modified"].(string); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将last_modified转换为string：%v", result["last_modified"]), "")
+	}
+
+	if statusCode, ok := result["status_code"].(int64); ok {
+		item.Status_code = int(statusCode)
+	} else {
+		return item, util.WrapError(fmt.Errorf("错误：无法将status_code转换为int64：%v", result["status_code"]), "")
+	}
+
+	if contentLength, ok := result["content_length"].(int64); ok {
+		item.Content_length = contentLength
+	} else {
+		return item, util.WrapError(fmt.Errorf("错误：无法将content_length转换为int64：%v", result["content_length"]), "")
+	}
+
+	if failCount, ok := result["fail_count"].(int64); ok {
+		item.Fail_count = int(failCount)
+	} else {
+		return item, util.WrapError(fmt.Errorf("错误：无法将fail_count转换为int64：%v", result["fail_count"]), "")
+	}
+
+	if item.Last_error, ok = result["last_error"].(string); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将last_error转换为string：%v", result["last_error"]), "")
+	}
+
+	if lastFetchTime, ok := result["last_fetch_time"].(int64); ok {
+		item.Last_fetch_time = lastFetchTime
+	} else {
+		return item, util.WrapError(fmt.Errorf("错误：无法将last_fetch_time转换为int64：%v", result["last_fetch_time"]), "")
+	}
+
+	if createTime, ok := result["create_time"].(int64); ok {
+		item.Create_time = createTime
+	} else {
+		return item, util.WrapError(fmt.Errorf("错误：无法将create_time转换为int64：%v", result["create_time"]), "")
+	}
+
+	return item, nil
+}
@@ -0,0 +1,148 @@
+package mydb
+
+import (
+	"fmt"
+	"nav-web-site/config"
+	"nav-web-site/util"
+	"nav-web-site/util/log"
+)
+
+// StructDDNSHistory 定义动态域名的地址变化记录，最近一条成功的记录就是上次已知的地址
+type StructDDNSHistory struct {
+	ID          int    `db:"id"`          // id
+	Name        string `db:"name"`        // 完整域名
+	Record_type string `db:"record_type"` // 记录类型：A、AAAA
+	Provider    string `db:"provider"`    // DNS 服务商
+	Old_ip      string `db:"old_ip"`      // 更新前解析到的地址，首次记录时为空
+	New_ip      string `db:"new_ip"`      // 检测到的公网地址
+	Status      string `db:"status"`      // 结果：success 已更新、failed 更新失败
+	Error       string `db:"error"`       // 失败时的错误
+	Create_time int64  `db:"create_time"` // 检测到变化的时间
+}
+
+// 获取表名（不含前后缀）
+func (s *StructDDNSHistory) GetTableName() string {
+	return "ddns_history"
+}
+
+// 获取插入数据时的必填字段
+func (s *StructDDNSHistory) GetRequiredFields() []string {
+	return []string{
+		"Name",
+		"Record_type",
+		"New_ip",
+		"Status",
+		"Create_time",
+	}
+}
+
+// 插入数据时查重的字段，变化记录不查重
+func (s StructDDNSHistory) GetUniqueFields() []string {
+	return []string{}
+}
+
+// Find 方法根据条件查询单个 ddns_history 记录
+func (s *StructDDNSHistory) Find(params QueryParams) (StructDDNSHistory, error) {
+	var history StructDDNSHistory
+	results, err := GenericSelect(Db, s.GetTableName(), params, config.Config.MySQL.TablePrefix, "")
+	if err != nil {
+		return history, util.WrapError(err, "Query failed(find):")
+	}
+
+	if len(results) > 0 {
+		history, err = s.mapResultToStructItem(results[0])
+		if err != nil {
+			return history, util.WrapError(err, "将结果映射到StructDDNSHistory时出错:")
+		}
+	} else {
+		// 如果没有查询到数据，返回一个错误
+		return history, util.WrapError(fmt.Errorf("EmptyData"), "")
+	}
+	return history, nil
+}
+
+// Select 方法查询 ddns_history 表的数据
+func (s *StructDDNSHistory) Select(params QueryParams) ([]StructDDNSHistory, int, error) {
+	var list []StructDDNSHistory
+	results, err := GenericSelect(Db, s.GetTableName(), params, config.Config.MySQL.TablePrefix, "")
+	if err != nil {
+		return list, 400, util.WrapError(err, "Query failed(select):")
+	}
+
+	if len(results) > 0 {
+		for _, result := range results {
+			item, err := s.mapResultToStructItem(result)
+			if err != nil {
+				log.ErrorLogger.Println("将结果映射到StructDDNSHistory时出错:", err)
+				continue
+			}
+
+			list = append(list, item)
+		}
+	} else {
+		// 如果没有查询到数据，返回一个错误
+		return list, 200, util.WrapError(fmt.Errorf("EmptyData"), "")
+	}
+	return list, 0, nil
+}
+
+// Insert 方法插入新的 ddns_history 记录
+func (s *StructDDNSHistory) Insert(datas []StructDDNSHistory) (int, []int64, error) {
+	count, ids, err := GenericInsert(
+		s.GetTableName(),
+		datas,
+		s.GetRequiredFields(),
+		config.Config.MySQL.TablePrefix,
+		"",
+	)
+	if err != nil {
+		return 0, ids, err
+	}
+	return count, ids, nil
+}
+
+// mapResultToStructItem 将查询结果映射到结构体
+func (s *StructDDNSHistory) mapResultToStructItem(result map[string]interface{}) (StructDDNSHistory, error) {
+	var item StructDDNSHistory
+	var ok bool
+
+	if id, ok := result["id"].(int64); ok {
+		item.ID = int(id)
+	} else {
+		return item, util.WrapError(fmt.Errorf("错误：无法将id转换为int64：%v", result["id"]), "")
+	}
+
+	if item.Name, ok = result["name"].(string); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将name转换为string：%v", result["name"]), "")
+	}
+
+	if item.Record_type, ok = result["record_type"].(string); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将record_type转换为string：%v", result["record_type"]), "")
+	}
+
+	if item.Provider, ok = result["provider"].(string); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将provider转换为string：%v", result["provider"]), "")
+	}
+
+	if item.Old_ip, ok = result["old_ip"].(string); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将old_ip转换为string：%v", result["old_ip"]), "")
+	}
+
+	if item.New_ip, ok = result["new_ip"].(string); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将new_ip转换为string：%v", result["new_ip"]), "")
+	}
+
+	if item.Status, ok = result["status"].(string); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将status转换为string：%v", result["status"]), "")
+	}
+
+	if item.Error, ok = result["error"].(string); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将error转换为string：%v", result["error"]), "")
+	}
+
+	if item.Create_time, ok = result["create_time"].(int64); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将create_time转换为int64：%v", result["create_time"]), "")
+	}
+
+	return item, nil
+}
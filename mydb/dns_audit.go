@@ -0,0 +1,145 @@
+package mydb
+
+import (
+	"fmt"
+	"nav-web-site/config"
+	"nav-web-site/util"
+	"nav-web-site/util/log"
+)
+
+// StructDNSAudit 定义解析记录改动的审计日志结构体，后台接口和 YAML 同步的每次改动各记一条
+type StructDNSAudit struct {
+	ID          int    `db:"id"`          // id
+	Admin_id    int    `db:"admin_id"`    // 操作的管理员
+	Source      string `db:"source"`      // 来源：api 后台接口、sync YAML 同步
+	Action      string `db:"action"`      // 改动：create、update、delete
+	Provider    string `db:"provider"`    // DNS 服务商
+	Zone        string `db:"zone"`        // 托管域
+	Record_id   string `db:"record_id"`   // 服务商的记录ID
+	Record_type string `db:"record_type"` // 记录类型
+	Record_name string `db:"record_name"` // 完整域名
+	Old_value   string `db:"old_value"`   // 改动前的记录（JSON），新增时为空
+	New_value   string `db:"new_value"`   // 改动后的记录（JSON），删除时为空
+	Create_time int64  `db:"create_time"` // 改动时间
+}
+
+// 获取表名（不含前后缀）
+func (s *StructDNSAudit) GetTableName() string {
+	return "dns_audit"
+}
+
+// 获取插入数据时的必填字段
+func (s *StructDNSAudit) GetRequiredFields() []string {
+	return []string{
+		"Source",
+		"Action",
+		"Provider",
+		"Zone",
+		"Create_time",
+	}
+}
+
+// 插入数据时查重的字段，审计日志不查重
+func (s StructDNSAudit) GetUniqueFields() []string {
+	return []string{}
+}
+
+// Select 方法查询 dns_audit 表的数据
+func (s *StructDNSAudit) Select(params QueryParams) ([]StructDNSAudit, int, error) {
+	var list []StructDNSAudit
+	results, err := GenericSelect(Db, s.GetTableName(), params, config.Config.MySQL.TablePrefix, "")
+	if err != nil {
+		return list, 400, util.WrapError(err, "Query failed(select):")
+	}
+
+	if len(results) > 0 {
+		for _, result := range results {
+			item, err := s.mapResultToStructItem(result)
+			if err != nil {
+				log.ErrorLogger.Println("将结果映射到StructDNSAudit时出错:", err)
+				continue
+			}
+
+			list = append(list, item)
+		}
+	} else {
+		// 如果没有查询到数据，返回一个错误
+		return list, 200, util.WrapError(fmt.Errorf("EmptyData"), "")
+	}
+	return list, 0, nil
+}
+
+// Insert 方法插入新的 dns_audit 记录
+func (s *StructDNSAudit) Insert(datas []StructDNSAudit) (int, []int64, error) {
+	count, ids, err := GenericInsert(
+		s.GetTableName(),
+		datas,
+		s.GetRequiredFields(),
+		config.Config.MySQL.TablePrefix,
+		"",
+	)
+	if err != nil {
+		return 0, ids, err
+	}
+	return count, ids, nil
+}
+
+// mapResultToStructItem 将查询结果映射到结构体
+func (s *StructDNSAudit) mapResultToStructItem(result map[string]interface{}) (StructDNSAudit, error) {
+	var item StructDNSAudit
+	var ok bool
+
+	if id, ok := result["id"].(int64); ok {
+		item.ID = int(id)
+	} else {
+		return item, util.WrapError(fmt.Errorf("错误：无法将id转换为int64：%v", result["id"]), "")
+	}
+
+	if adminID, ok := result["admin_id"].(int64); ok {
+		item.Admin_id = int(adminID)
+	} else {
+		return item, util.WrapError(fmt.Errorf("错误：无法将admin_id转换为int64：%v", result["admin_id"]), "")
+	}
+
+	if item.Source, ok = result["source"].(string); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将source转换为string：%v", result["source"]), "")
+	}
+
+	if item.Action, ok = result["action"].(string); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将action转换为string：%v", result["action"]), "")
+	}
+
+	if item.Provider, ok = result["provider"].(string); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将provider转换为string：%v", result["provider"]), "")
+	}
+
+	if item.Zone, ok = result["zone"].(string); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将zone转换为string：%v", result["zone"]), "")
+	}
+
+	if item.Record_id, ok = result["record_id"].(string); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将record_id转换为string：%v", result["record_id"]), "")
+	}
+
+	if item.Record_type, ok = result["record_type"].(string); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将record_type转换为string：%v", result["record_type"]), "")
+	}
+
+	if item.Record_name, ok = result["record_name"].(string); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将record_name转换为string：%v", result["record_name"]), "")
+	}
+
+	if item.Old_value, ok = result["old_value"].(string); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将old_value转换为string：%v", result["old_value"]), "")
+	}
+
+	if item.New_value, ok = result["new_value"].(string); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将new_value转换为string：%v", result["new_value"]), "")
+	}
+
+	if item.Create_time, ok = result["create_time"].(int64); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将create_time转换为int64：%v", result["create_time"]), "")
+	}
+
+	return item, nil
+}
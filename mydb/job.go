@@ -0,0 +1,270 @@
+package mydb
+
+import (
+	"fmt"
+	"nav-web-site/config"
+	"nav-web-site/util"
+	"nav-web-site/util/log"
+	"strconv"
+	"strings"
+)
+
+// StructJob 定义后台任务队列里的任务结构体，队列状态以这张表为准
+type StructJob struct {
+	ID           int    `db:"id"`           // id
+	Job_type     string `db:"job_type"`     // 任务类型
+	Payload      string `db:"payload"`      // JSON 参数
+	Status       string `db:"status"`       // 状态：queued 排队、running 执行中、succeeded 成功、dead 重试耗尽进入死信
+	Attempts     int    `db:"attempts"`     // 已执行次数
+	Max_attempts int    `db:"max_attempts"` // 最多执行次数
+	Run_at       int64  `db:"run_at"`       // 最早可执行时间，重试时按退避时间推后
+	Last_error   string `db:"last_error"`   // 最近一次失败的错误
+	Create_time  int64  `db:"create_time"`  // 创建时间
+	Update_time  int64  `db:"update_time"`  // 更新时间，执行中的任务用来判断是否超时
+	Finish_time  int64  `db:"finish_time"`  // 成功或进入死信的时间
+}
+
+// 获取表名（不含前后缀）
+func (s *StructJob) GetTableName() string {
+	return "job"
+}
+
+// 获取插入数据时的必填字段
+func (s *StructJob) GetRequiredFields() []string {
+	return []string{
+		"Job_type",
+		"Status",
+		"Max_attempts",
+		"Create_time",
+	}
+}
+
+// 插入数据时查重的字段，任务不查重
+func (s StructJob) GetUniqueFields() []string {
+	return []string{}
+}
+
+// Find 方法根据条件查询单个 job 记录
+func (s *StructJob) Find(params QueryParams) (StructJob, error) {
+	var job StructJob
+	results, err := GenericSelect(Db, s.GetTableName(), params, config.Config.MySQL.TablePrefix, "")
+	if err != nil {
+		return job, util.WrapError(err, "Query failed(find):")
+	}
+
+	if len(results) > 0 {
+		job, err = s.mapResultToStructItem(results[0])
+		if err != nil {
+			return job, util.WrapError(err, "将结果映射到StructJob时出错:")
+		}
+	} else {
+		// 如果没有查询到数据，返回一个错误
+		return job, util.WrapError(fmt.Errorf("EmptyData"), "")
+	}
+	return job, nil
+}
+
+// Select 方法查询 job 表的数据
+func (s *StructJob) Select(params QueryParams) ([]StructJob, int, error) {
+	var list []StructJob
+	results, err := GenericSelect(Db, s.GetTableName(), params, config.Config.MySQL.TablePrefix, "")
+	if err != nil {
+		return list, 400, util.WrapError(err, "Query failed(select):")
+	}
+
+	if len(results) > 0 {
+		for _, result := range results {
+			item, err := s.mapResultToStructItem(result)
+			if err != nil {
+				log.ErrorLogger.Println("将结果映射到StructJob时出错:", err)
+				continue
+			}
+
+			list = append(list, item)
+		}
+	} else {
+		// 如果没有查询到数据，返回一个错误
+		return list, 200, util.WrapError(fmt.Errorf("EmptyData"), "")
+	}
+	return list, 0, nil
+}
+
+// Insert 方法插入新的 job 记录
+func (s *StructJob) Insert(datas []StructJob) (int, []int64, error) {
+	count, ids, err := GenericInsert(
+		s.GetTableName(),
+		datas,
+		s.GetRequiredFields(),
+		config.Config.MySQL.TablePrefix,
+		"",
+	)
+	if err != nil {
+		return 0, ids, err
+	}
+	return count, ids, nil
+}
+
+// Update 方法更新 job 记录
+func (s *StructJob) Update(datas []StructJob, condition string) (int, []int64, error) {
+	count, ids, err := GenericUpdate(
+		s.GetTableName(),
+		datas,
+		condition,
+		config.Config.MySQL.TablePrefix,
+		"",
+	)
+	if err != nil {
+		return 0, ids, err
+	}
+	return count, ids, nil
+}
+
+// Delete 方法删除 job 记录
+func (s *StructJob) Delete(condition string) (int, []int64, error) {
+	count, ids, err := GenericDelete(
+		s.GetTableName(),
+		condition,
+		config.Config.MySQL.TablePrefix,
+		"",
+	)
+	if err != nil {
+		return 0, ids, err
+	}
+	return count, ids, nil
+}
+
+// Claim 认领排队中、已到执行时间且还有执行次数的任务，标记为执行中并增加执行次数。
+// 多个实例或工作协程同时认领同一个任务时只有一个成功
+func (s *StructJob) Claim(id int) (bool, error) {
+	now := util.GetTimestamp(10)
+	fullTableName := fmt.Sprintf("%s%s%s", config.Config.MySQL.TablePrefix, s.GetTableName(), "")
+	query := fmt.Sprintf("UPDATE %s SET status = 'running', attempts = attempts + 1, update_time = %d WHERE id = %d AND status = 'queued' AND run_at <= %d AND attempts < max_attempts",
+		fullTableName, now, id, now)
+	result, err := Db.Exec(query)
+	if err != nil {
+		return false, util.WrapError(err, "认领任务失败:")
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, util.WrapError(err, "认领任务失败:")
+	}
+	return affected == 1, nil
+}
+
+// Touch 刷新执行中任务的更新时间，长时间执行的任务定期调用，避免被当成超时放回队列
+func (s *StructJob) Touch(id int) error {
+	fullTableName := fmt.Sprintf("%s%s%s", config.Config.MySQL.TablePrefix, s.GetTableName(), "")
+	query := fmt.Sprintf("UPDATE %s SET update_time = %d WHERE id = %d AND status = 'running'", fullTableName, util.GetTimestamp(10), id)
+	if _, err := Db.Exec(query); err != nil {
+		return util.WrapError(err, "刷新任务时间失败:")
+	}
+	return nil
+}
+
+// RequeueStale 处理执行超时（实例崩溃或卡住）的任务：执行次数已用完的进入死信，其余放回队列。
+// 返回放回队列的数量和进入死信的任务id
+func (s *StructJob) RequeueStale(before int64) (int, []int, error) {
+	fullTableName := fmt.Sprintf("%s%s%s", config.Config.MySQL.TablePrefix, s.GetTableName(), "")
+	now := util.GetTimestamp(10)
+	staleCondition := fmt.Sprintf("status = 'running' AND update_time < %d", before)
+
+	var dead []int
+	rows, err := Db.Query(fmt.Sprintf("SELECT id FROM %s WHERE %s AND attempts >= max_attempts", fullTableName, staleCondition))
+	if err != nil {
+		return 0, nil, util.WrapError(err, "查询超时任务失败:")
+	}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, nil, util.WrapError(err, "查询超时任务失败:")
+		}
+		dead = append(dead, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, nil, util.WrapError(err, "查询超时任务失败:")
+	}
+
+	if len(dead) > 0 {
+		ids := make([]string, 0, len(dead))
+		for _, id := range dead {
+			ids = append(ids, strconv.Itoa(id))
+		}
+		query := fmt.Sprintf("UPDATE %s SET status = 'dead', last_error = '执行超时，重试次数已用完', update_time = %d, finish_time = %d WHERE id IN (%s) AND %s",
+			fullTableName, now, now, strings.Join(ids, ","), staleCondition)
+		if _, err := Db.Exec(query); err != nil {
+			return 0, nil, util.WrapError(err, "超时任务进入死信失败:")
+		}
+	}
+
+	query := fmt.Sprintf("UPDATE %s SET status = 'queued', last_error = '执行超时', update_time = %d WHERE %s AND attempts < max_attempts",
+		fullTableName, now, staleCondition)
+	result, err := Db.Exec(query)
+	if err != nil {
+		return 0, dead, util.WrapError(err, "放回超时任务失败:")
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, dead, util.WrapError(err, "放回超时任务失败:")
+	}
+	return int(affected), dead, nil
+}
+
+// mapResultToStructItem 将查询结果映射到结构体
+func (s *StructJob) mapResultToStructItem(result map[string]interface{}) (StructJob, error) {
+	var item StructJob
+	var ok bool
+
+	if id, ok := result["id"].(int64); ok {
+		item.ID = int(id)
+	} else {
+		return item, util.WrapError(fmt.Errorf("错误：无法将id转换为int64：%v", result["id"]), "")
+	}
+
+	if item.Job_type, ok = result["job_type"].(string); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将job_type转换为string：%v", result["job_type"]), "")
+	}
+
+	if item.Payload, ok = result["payload"].(string); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将payload转换为string：%v", result["payload"]), "")
+	}
+
+	if item.Status, ok = result["status"].(string); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将status转换为string：%v", result["status"]), "")
+	}
+
+	if attempts, ok := result["attempts"].(int64); ok {
+		item.Attempts = int(attempts)
+	} else {
+		return item, util.WrapError(fmt.Errorf("错误：无法将attempts转换为int64：%v", result["attempts"]), "")
+	}
+
+	if maxAttempts, ok := result["max_attempts"].(int64); ok {
+		item.Max_attempts = int(maxAttempts)
+	} else {
+		return item, util.WrapError(fmt.Errorf("错误：无法将max_attempts转换为int64：%v", result["max_attempts"]), "")
+	}
+
+	if item.Run_at, ok = result["run_at"].(int64); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将run_at转换为int64：%v", result["run_at"]), "")
+	}
+
+	if item.Last_error, ok = result["last_error"].(string); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将last_error转换为string：%v", result["last_error"]), "")
+	}
+
+	if item.Create_time, ok = result["create_time"].(int64); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将create_time转换为int64：%v", result["create_time"]), "")
+	}
+
+	if item.Update_time, ok = result["update_time"].(int64); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将update_time转换为int64：%v", result["update_time"]), "")
+	}
+
+	if item.Finish_time, ok = result["finish_time"].(int64); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将finish_time转换为int64：%v", result["finish_time"]), "")
+	}
+
+	return item, nil
+}
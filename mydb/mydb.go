@@ -16,12 +16,23 @@ import (
 )
 
 type TABLES struct {
-	Nav        StructNav
-	NavClass   StructNavClass
-	News       StructNews
-	NewsClass  StructNewsClass
-	Admin      StructAdmin
-	UploadFile StructUploadFile
+	Nav           StructNav
+	NavClass      StructNavClass
+	News          StructNews
+	NewsClass     StructNewsClass
+	Admin         StructAdmin
+	UploadFile    StructUploadFile
+	NewsFeed      StructNewsFeed
+	CrawlState    StructCrawlState
+	NewsCluster   StructNewsCluster
+	TaskRun       StructTaskRun
+	ScheduledTask StructScheduledTask
+	Job           StructJob
+	DNSAudit      StructDNSAudit
+	Certificate   StructCertificate
+	DDNSHistory   StructDDNSHistory
+	NavHealth     StructNavHealth
+	NavClickDaily StructNavClickDaily
 	// 其他表如 User, Product 等都可以类似嵌入
 }
 
@@ -71,3 +82,18 @@ func InitDB() {
 		log.ErrorLogger.Fatalf("Could not connect to Redis: %v", redis_err)
 	}
 }
+
+// CloseDB 关闭 Redis 和数据库连接，退出前在所有任务和请求结束后调用
+func CloseDB() {
+	if RedisClient != nil {
+		if err := RedisClient.Close(); err != nil {
+			log.ErrorLogger.Printf("Error closing Redis: %v", err)
+		}
+	}
+	if Db != nil {
+		if err := Db.Close(); err != nil {
+			log.ErrorLogger.Printf("Error closing database: %v", err)
+		}
+	}
+	log.InfoLogger.Println("Database and Redis connections closed")
+}
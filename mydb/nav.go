@@ -161,7 +161,8 @@ func (s *StructNav) Delete(condition string) (int, []int, error) {
 		idsToDelete = append(idsToDelete, record.ID)
 	}
 
-	query := fmt.Sprintf("DELETE FROM %s WHERE %s", s.GetTableName(), condition)
+	fullTableName := fmt.Sprintf("%s%s%s", config.Config.MySQL.TablePrefix, s.GetTableName(), "")
+	query := fmt.Sprintf("DELETE FROM %s WHERE %s", fullTableName, condition)
 
 	result, err := Db.Exec(query)
 	if err != nil {
@@ -176,6 +177,20 @@ func (s *StructNav) Delete(condition string) (int, []int, error) {
 	return int(affectedRows), idsToDelete, nil
 }
 
+// SetShow 只修改导航的显示状态，健康检查自动隐藏和恢复时使用，不覆盖管理员同时修改的其他字段
+func (s *StructNav) SetShow(id int, show bool) error {
+	fullTableName := fmt.Sprintf("%s%s%s", config.Config.MySQL.TablePrefix, s.GetTableName(), "")
+	isShow := 0
+	if show {
+		isShow = 1
+	}
+	query := fmt.Sprintf("UPDATE %s SET is_show = %d, update_time = %d WHERE id = %d", fullTableName, isShow, util.GetTimestamp(10), id)
+	if _, err := Db.Exec(query); err != nil {
+		return util.WrapError(err, "修改导航显示状态失败:")
+	}
+	return nil
+}
+
 // mapResultToStructItem 将查询结果映射到结构体
 func (s *StructNav) mapResultToStructItem(result map[string]interface{}) (StructNav, error) {
 	var item StructNav
@@ -1,6 +1,7 @@
 package mydb
 
 import (
+	"database/sql"
 	"fmt"
 	"nav-web-site/config"
 	"nav-web-site/util"
@@ -155,6 +156,44 @@ func (s *StructNavClass) Delete(condition string) (int, []int64, error) {
 	return count, ids, nil
 }
 
+// DeleteTree 在一个事务里删除分类和其中的导航，连同导航的健康检查结果和每日点击数，返回删除的分类数和导航 id
+func (s *StructNavClass) DeleteTree(classIDs []int) (int, []int, error) {
+	prefix := config.Config.MySQL.TablePrefix
+	classTable := prefix + s.GetTableName()
+	navTable := prefix + Tables.Nav.GetTableName()
+	var classCount int
+	var navIDs []int
+	err := Transaction(func(tx *sql.Tx) error {
+		var err error
+		navIDs, err = queryIDs(tx, fmt.Sprintf("SELECT id FROM %s WHERE class_id IN (%s)", navTable, joinIDs(classIDs)))
+		if err != nil {
+			return err
+		}
+		if len(navIDs) > 0 {
+			navList := joinIDs(navIDs)
+			for _, table := range []string{Tables.NavHealth.GetTableName(), Tables.NavClickDaily.GetTableName()} {
+				if _, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE nav_id IN (%s)", prefix+table, navList)); err != nil {
+					return util.WrapError(err, "删除导航的关联数据失败:")
+				}
+			}
+			if _, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE id IN (%s)", navTable, navList)); err != nil {
+				return util.WrapError(err, "删除导航失败:")
+			}
+		}
+		result, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE id IN (%s)", classTable, joinIDs(classIDs)))
+		if err != nil {
+			return util.WrapError(err, "删除导航分类失败:")
+		}
+		affected, err := result.RowsAffected()
+		classCount = int(affected)
+		return err
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	return classCount, navIDs, nil
+}
+
 // mapResultToStructItem 将查询结果映射到结构体
 func (s *StructNavClass) mapResultToStructItem(result map[string]interface{}) (StructNavClass, error) {
 	var item StructNavClass
@@ -0,0 +1,93 @@
+package mydb
+
+import (
+	"fmt"
+	"nav-web-site/config"
+	"nav-web-site/util"
+)
+
+// StructNavClickDaily 定义导航链接每天的点击数，每个导航每天一条，爬虫的点击不计入
+type StructNavClickDaily struct {
+	ID          int   `db:"id"`          // id
+	Nav_id      int   `db:"nav_id"`      // 导航id
+	Click_date  int   `db:"click_date"`  // 日期，如 20240131
+	Clicks      int64 `db:"clicks"`      // 点击数
+	Update_time int64 `db:"update_time"` // 最近一次写入的时间
+}
+
+// NavClickTotal 一个导航在一段时间内的点击数合计
+type NavClickTotal struct {
+	Nav_id int   `json:"Nav_id"`
+	Clicks int64 `json:"Clicks"`
+}
+
+// 获取表名（不含前后缀）
+func (s *StructNavClickDaily) GetTableName() string {
+	return "nav_click_daily"
+}
+
+// 获取插入数据时的必填字段
+func (s *StructNavClickDaily) GetRequiredFields() []string {
+	return []string{
+		"Nav_id",
+		"Click_date",
+	}
+}
+
+// 插入数据时查重的字段，一个导航每天只有一条
+func (s StructNavClickDaily) GetUniqueFields() []string {
+	return []string{
+		"Nav_id",
+		"Click_date",
+	}
+}
+
+// AddClicks 累加导航某天的点击数和导航的浏览量，当天没有记录时插入，依赖 (nav_id, click_date) 唯一索引。
+// 两条语句在同一个事务里执行，避免只写成功一半后重试时重复累加
+func (s *StructNavClickDaily) AddClicks(navID int, date int, clicks int64) error {
+	fullTableName := fmt.Sprintf("%s%s%s", config.Config.MySQL.TablePrefix, s.GetTableName(), "")
+	navTableName := fmt.Sprintf("%s%s%s", config.Config.MySQL.TablePrefix, Tables.Nav.GetTableName(), "")
+
+	tx, err := Db.Begin()
+	if err != nil {
+		return util.WrapError(err, "开启事务失败:")
+	}
+	defer tx.Rollback()
+
+	query := fmt.Sprintf("INSERT INTO %s (nav_id, click_date, clicks, update_time) VALUES (?, ?, ?, ?) "+
+		"ON DUPLICATE KEY UPDATE clicks = clicks + VALUES(clicks), update_time = VALUES(update_time)", fullTableName)
+	if _, err := tx.Exec(query, navID, date, clicks, util.GetTimestamp(10)); err != nil {
+		return util.WrapError(err, "累加导航每日点击数失败:")
+	}
+	// 不修改导航的 update_time
+	query = fmt.Sprintf("UPDATE %s SET views = views + ? WHERE id = ?", navTableName)
+	if _, err := tx.Exec(query, clicks, navID); err != nil {
+		return util.WrapError(err, "累加导航浏览量失败:")
+	}
+	if err := tx.Commit(); err != nil {
+		return util.WrapError(err, "提交导航点击数失败:")
+	}
+	return nil
+}
+
+// Totals 按导航合计 [startDate, endDate] 之间的点击数，点击多的在前
+func (s *StructNavClickDaily) Totals(startDate int, endDate int) ([]NavClickTotal, error) {
+	fullTableName := fmt.Sprintf("%s%s%s", config.Config.MySQL.TablePrefix, s.GetTableName(), "")
+	query := fmt.Sprintf("SELECT nav_id, SUM(clicks) AS total FROM %s WHERE click_date BETWEEN ? AND ? "+
+		"GROUP BY nav_id ORDER BY total DESC, nav_id ASC", fullTableName)
+	rows, err := Db.Query(query, startDate, endDate)
+	if err != nil {
+		return nil, util.WrapError(err, "查询导航点击数失败:")
+	}
+	defer rows.Close()
+
+	var list []NavClickTotal
+	for rows.Next() {
+		var item NavClickTotal
+		if err := rows.Scan(&item.Nav_id, &item.Clicks); err != nil {
+			return nil, util.WrapError(err, "读取导航点击数失败:")
+		}
+		list = append(list, item)
+	}
+	return list, rows.Err()
+}
@@ -0,0 +1,184 @@
+package mydb
+
+import (
+	"fmt"
+	"nav-web-site/config"
+	"nav-web-site/util"
+	"nav-web-site/util/log"
+)
+
+// StructNavHealth 定义导航链接的健康检查结果，每个导航一条，保存最近一次检查的结果
+type StructNavHealth struct {
+	ID              int    `db:"id"`              // id
+	Nav_id          int    `db:"nav_id"`          // 导航id
+	Url             string `db:"url"`             // 检查的链接地址
+	Status_code     int    `db:"status_code"`     // HTTP 状态码，请求失败时为0
+	Latency_ms      int64  `db:"latency_ms"`      // 响应耗时（毫秒）
+	Redirect_url    string `db:"redirect_url"`    // 跳转后的最终地址，没有跳转时为空
+	Tls_expire_time int64  `db:"tls_expire_time"` // HTTPS 证书到期时间，http 链接为0
+	Error           string `db:"error"`           // 请求失败的错误
+	Is_ok           bool   `db:"is_ok"`           // 最近一次检查是否正常
+	Fail_count      int    `db:"fail_count"`      // 连续失败次数
+	Auto_hidden     bool   `db:"auto_hidden"`     // 是否因连续失败被自动隐藏，恢复后自动显示
+	Last_ok_time    int64  `db:"last_ok_time"`    // 最近一次正常的时间
+	Check_time      int64  `db:"check_time"`      // 最近一次检查的时间
+}
+
+// 获取表名（不含前后缀）
+func (s *StructNavHealth) GetTableName() string {
+	return "nav_health"
+}
+
+// 获取插入数据时的必填字段
+func (s *StructNavHealth) GetRequiredFields() []string {
+	return []string{
+		"Nav_id",
+		"Url",
+		"Check_time",
+	}
+}
+
+// 插入数据时查重的字段，一个导航只有一条
+func (s StructNavHealth) GetUniqueFields() []string {
+	return []string{
+		"Nav_id",
+	}
+}
+
+// Select 方法查询 nav_health 表的数据
+func (s *StructNavHealth) Select(params QueryParams) ([]StructNavHealth, int, error) {
+	var list []StructNavHealth
+	results, err := GenericSelect(Db, s.GetTableName(), params, config.Config.MySQL.TablePrefix, "")
+	if err != nil {
+		return list, 400, util.WrapError(err, "Query failed(select):")
+	}
+
+	if len(results) > 0 {
+		for _, result := range results {
+			item, err := s.mapResultToStructItem(result)
+			if err != nil {
+				log.ErrorLogger.Println("将结果映射到StructNavHealth时出错:", err)
+				continue
+			}
+
+			list = append(list, item)
+		}
+	} else {
+		// 如果没有查询到数据，返回一个错误
+		return list, 200, util.WrapError(fmt.Errorf("EmptyData"), "")
+	}
+	return list, 0, nil
+}
+
+// Insert 方法插入新的 nav_health 记录
+func (s *StructNavHealth) Insert(datas []StructNavHealth) (int, []int64, error) {
+	count, ids, err := GenericInsert(
+		s.GetTableName(),
+		datas,
+		s.GetRequiredFields(),
+		config.Config.MySQL.TablePrefix,
+		"",
+	)
+	if err != nil {
+		return 0, ids, err
+	}
+	return count, ids, nil
+}
+
+// Update 方法更新 nav_health 记录
+func (s *StructNavHealth) Update(datas []StructNavHealth, condition string) (int, []int64, error) {
+	count, ids, err := GenericUpdate(
+		s.GetTableName(),
+		datas,
+		condition,
+		config.Config.MySQL.TablePrefix,
+		"",
+	)
+	if err != nil {
+		return 0, ids, err
+	}
+	return count, ids, nil
+}
+
+// Reset 清零导航的连续失败次数和自动隐藏标记，管理员修改了链接地址或显示状态后调用，
+// 之后的检查按新的地址和管理员设置的显示状态重新计数
+func (s *StructNavHealth) Reset(navID int) error {
+	fullTableName := fmt.Sprintf("%s%s%s", config.Config.MySQL.TablePrefix, s.GetTableName(), "")
+	query := fmt.Sprintf("UPDATE %s SET fail_count = 0, auto_hidden = 0 WHERE nav_id = %d", fullTableName, navID)
+	if _, err := Db.Exec(query); err != nil {
+		return util.WrapError(err, "重置导航检查状态失败:")
+	}
+	return nil
+}
+
+// mapResultToStructItem 将查询结果映射到结构体
+func (s *StructNavHealth) mapResultToStructItem(result map[string]interface{}) (StructNavHealth, error) {
+	var item StructNavHealth
+	var ok bool
+
+	if id, ok := result["id"].(int64); ok {
+		item.ID = int(id)
+	} else {
+		return item, util.WrapError(fmt.Errorf("错误：无法将id转换为int64：%v", result["id"]), "")
+	}
+
+	if navId, ok := result["nav_id"].(int64); ok {
+		item.Nav_id = int(navId)
+	} else {
+		return item, util.WrapError(fmt.Errorf("错误：无法将nav_id转换为int64：%v", result["nav_id"]), "")
+	}
+
+	if item.Url, ok = result["url"].(string); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将url转换为string：%v", result["url"]), "")
+	}
+
+	if statusCode, ok := result["status_code"].(int64); ok {
+		item.Status_code = int(statusCode)
+	} else {
+		return item, util.WrapError(fmt.Errorf("错误：无法将status_code转换为int64：%v", result["status_code"]), "")
+	}
+
+	if item.Latency_ms, ok = result["latency_ms"].(int64); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将latency_ms转换为int64：%v", result["latency_ms"]), "")
+	}
+
+	if item.Redirect_url, ok = result["redirect_url"].(string); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将redirect_url转换为string：%v", result["redirect_url"]), "")
+	}
+
+	if item.Tls_expire_time, ok = result["tls_expire_time"].(int64); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将tls_expire_time转换为int64：%v", result["tls_expire_time"]), "")
+	}
+
+	if item.Error, ok = result["error"].(string); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将error转换为string：%v", result["error"]), "")
+	}
+
+	if isOk, ok := result["is_ok"].(int64); ok {
+		item.Is_ok = isOk == 1
+	} else {
+		return item, util.WrapError(fmt.Errorf("错误：无法将is_ok转换为int64：%v", result["is_ok"]), "")
+	}
+
+	if failCount, ok := result["fail_count"].(int64); ok {
+		item.Fail_count = int(failCount)
+	} else {
+		return item, util.WrapError(fmt.Errorf("错误：无法将fail_count转换为int64：%v", result["fail_count"]), "")
+	}
+
+	if autoHidden, ok := result["auto_hidden"].(int64); ok {
+		item.Auto_hidden = autoHidden == 1
+	} else {
+		return item, util.WrapError(fmt.Errorf("错误：无法将auto_hidden转换为int64：%v", result["auto_hidden"]), "")
+	}
+
+	if item.Last_ok_time, ok = result["last_ok_time"].(int64); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将last_ok_time转换为int64：%v", result["last_ok_time"]), "")
+	}
+
+	if item.Check_time, ok = result["check_time"].(int64); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将check_time转换为int64：%v", result["check_time"]), "")
+	}
+
+	return item, nil
+}
@@ -1,10 +1,13 @@
 package mydb
 
 import (
+	"database/sql"
 	"fmt"
 	"nav-web-site/config"
 	"nav-web-site/util"
 	"nav-web-site/util/log"
+	"strconv"
+	"strings"
 )
 
 // StructNews 定义 news 结构体
@@ -23,6 +26,7 @@ type StructNews struct {
 	Is_show        bool   `db:"is_show"`
 	Status         int    `db:"status"`
 	Create_time    int64  `db:"create_time"`
+	Update_time    int64  `db:"update_time"` // 最近一次修改的时间，0表示没有修改过
 	Author         string `db:"author"`
 	Source         string `db:"source"`
 	View_count     int    `db:"view_count"`
@@ -32,6 +36,8 @@ type StructNews struct {
 	Is_headline    bool   `db:"is_headline"`           //是否头条
 	Is_recommended bool   `db:"is_recommended"`        //是否推荐
 	Content        string `db:"content"`               // 文章内容
+	Simhash        int64  `db:"simhash"`               // 标题和正文的 SimHash 指纹，用于近似重复检测，0表示未计算
+	Cluster_id     int    `db:"cluster_id"`            // 所属的故事聚合（news_cluster）id，0表示不属于任何聚合
 }
 
 // StructNewsContent 定义 news 内容结构体
@@ -234,32 +240,155 @@ func (s *StructNews) Update(datas []StructNews, condition string) (int, []int64,
 	return count, ids, nil
 }
 
-// Delete 方法删除 news 记录
+// Delete 方法删除 news 记录，在一个事务里同步删除 news_content 表中的正文，
+// 并整理新闻所在的故事聚合：删掉的是代表新闻时改用聚合里的其他新闻，聚合空了时删除
 func (s *StructNews) Delete(condition string) (int, []int64, error) {
-	count, ids, err := GenericDelete(
-		s.GetTableName(),
-		condition,
-		config.Config.MySQL.TablePrefix,
-		"",
-	)
+	newsTable := fmt.Sprintf("%s%s%s", config.Config.MySQL.TablePrefix, s.GetTableName(), "")
+	var count int
+	var ids []int64
+	err := Transaction(func(tx *sql.Tx) error {
+		deleting, err := queryIDs(tx, fmt.Sprintf("SELECT id FROM %s WHERE %s", newsTable, condition))
+		if err != nil {
+			return err
+		}
+		for _, id := range deleting {
+			ids = append(ids, int64(id))
+		}
+		count, err = s.DeleteInTx(tx, condition)
+		return err
+	})
 	if err != nil {
-		return 0, ids, err
+		return 0, nil, err
 	}
+	return count, ids, nil
+}
 
-	// 同步删除 news_content 表中的对应数据
-	for _, id := range ids {
-		_, _, err := GenericDelete(
-			"news_content",
-			fmt.Sprintf("news_id = %d", id),
-			config.Config.MySQL.TablePrefix,
-			"",
-		)
-		if err != nil {
-			return 0, ids, err
+// DeleteInTx 在事务 tx 里删除符合条件的新闻和正文，并整理它们所在的故事聚合，返回删除的新闻数
+func (s *StructNews) DeleteInTx(tx *sql.Tx, condition string) (int, error) {
+	newsTable := fmt.Sprintf("%s%s%s", config.Config.MySQL.TablePrefix, s.GetTableName(), "")
+	contentTable := fmt.Sprintf("%s%s%s", config.Config.MySQL.TablePrefix, "news_content", "")
+
+	clusterIDs, err := queryIDs(tx, fmt.Sprintf("SELECT DISTINCT cluster_id FROM %s WHERE (%s) AND cluster_id > 0", newsTable, condition))
+	if err != nil {
+		return 0, err
+	}
+	// 先按条件删正文，删掉新闻后就查不到对应的新闻 id 了
+	if _, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE news_id IN (SELECT id FROM %s WHERE %s)", contentTable, newsTable, condition)); err != nil {
+		return 0, util.WrapError(err, "删除新闻正文失败:")
+	}
+	result, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE %s", newsTable, condition))
+	if err != nil {
+		return 0, util.WrapError(err, "删除新闻失败:")
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, util.WrapError(err, "获取受影响的行数失败:")
+	}
+	if err := Tables.NewsCluster.settle(tx, clusterIDs); err != nil {
+		return 0, err
+	}
+	return int(deleted), nil
+}
+
+// LoadContents 一次查询把 news_content 表里的内容填回新闻列表，Select 默认不带正文
+func (s *StructNews) LoadContents(list []StructNews) error {
+	if len(list) == 0 {
+		return nil
+	}
+
+	ids := make([]string, 0, len(list))
+	for _, item := range list {
+		ids = append(ids, strconv.Itoa(item.ID))
+	}
+	fullTableName_content := fmt.Sprintf("%s%s%s", config.Config.MySQL.TablePrefix, "news_content", "")
+	contentQuery := fmt.Sprintf("SELECT news_id, content FROM %s WHERE news_id IN (%s)", fullTableName_content, strings.Join(ids, ","))
+	rows, err := Db.Query(contentQuery)
+	if err != nil {
+		return util.WrapError(err, "查询内容失败:")
+	}
+	defer rows.Close()
+
+	contents := make(map[int]string, len(list))
+	for rows.Next() {
+		var newsID int
+		var content sql.NullString
+		if err := rows.Scan(&newsID, &content); err != nil {
+			return util.WrapError(err, "扫描内容失败:")
 		}
+		contents[newsID] = content.String
+	}
+	if err := rows.Err(); err != nil {
+		return util.WrapError(err, "读取内容失败:")
 	}
 
-	return count, ids, nil
+	for i := range list {
+		if content, ok := contents[list[i].ID]; ok {
+			list[i].Content = content
+		}
+	}
+	return nil
+}
+
+// SetCluster 把新闻批量归入故事聚合，clusterID 为0时移出聚合
+func (s *StructNews) SetCluster(ids []int, clusterID int) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	idList := make([]string, 0, len(ids))
+	for _, id := range ids {
+		idList = append(idList, strconv.Itoa(id))
+	}
+	fullTableName := fmt.Sprintf("%s%s%s", config.Config.MySQL.TablePrefix, s.GetTableName(), "")
+	query := fmt.Sprintf("UPDATE %s SET cluster_id = %d WHERE id IN (%s)", fullTableName, clusterID, strings.Join(idList, ","))
+	if _, err := Db.Exec(query); err != nil {
+		return util.WrapError(err, "更新新闻聚合失败:")
+	}
+	return nil
+}
+
+// NewsFingerprint 近似重复检测用到的新闻指纹
+type NewsFingerprint struct {
+	ID         int
+	Simhash    int64
+	Cluster_id int
+}
+
+// Fingerprints 查询 since 之后创建、已计算指纹的新闻，只取检测需要的字段
+func (s *StructNews) Fingerprints(since int64) ([]NewsFingerprint, error) {
+	return s.fingerprints(fmt.Sprintf("create_time >= %d", since))
+}
+
+// FingerprintsAfter 查询 id 大于 afterID 且 since 之后创建、已计算指纹的新闻，用于增量更新指纹索引
+func (s *StructNews) FingerprintsAfter(afterID int, since int64) ([]NewsFingerprint, error) {
+	return s.fingerprints(fmt.Sprintf("id > %d AND create_time >= %d", afterID, since))
+}
+
+// FingerprintsByID 按 id 查询 since 之后创建的新闻指纹，已删除或不在时间范围内的不返回
+func (s *StructNews) FingerprintsByID(ids []int, since int64) ([]NewsFingerprint, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	return s.fingerprints(fmt.Sprintf("id IN (%s) AND create_time >= %d", joinIDs(ids), since))
+}
+
+func (s *StructNews) fingerprints(condition string) ([]NewsFingerprint, error) {
+	fullTableName := fmt.Sprintf("%s%s%s", config.Config.MySQL.TablePrefix, s.GetTableName(), "")
+	query := fmt.Sprintf("SELECT id, simhash, cluster_id FROM %s WHERE %s AND simhash <> 0", fullTableName, condition)
+	rows, err := Db.Query(query)
+	if err != nil {
+		return nil, util.WrapError(err, "查询新闻指纹失败:")
+	}
+	defer rows.Close()
+
+	var list []NewsFingerprint
+	for rows.Next() {
+		var item NewsFingerprint
+		if err := rows.Scan(&item.ID, &item.Simhash, &item.Cluster_id); err != nil {
+			return nil, util.WrapError(err, "扫描新闻指纹失败:")
+		}
+		list = append(list, item)
+	}
+	return list, rows.Err()
 }
 
 // mapResultToStructItem 将查询结果映射到结构体
@@ -337,6 +466,12 @@ func (s *StructNews) mapResultToStructItem(result map[string]interface{}) (Struc
 		return item, util.WrapError(fmt.Errorf("错误：无法将create_time转换为int64：%v", result["create_time"]), "")
 	}
 
+	if updateTime, ok := result["update_time"].(int64); ok {
+		item.Update_time = updateTime
+	} else if result["update_time"] != nil {
+		return item, util.WrapError(fmt.Errorf("错误：无法将update_time转换为int64：%v", result["update_time"]), "")
+	}
+
 	if item.Author, ok = result["author"].(string); !ok {
 		return item, util.WrapError(fmt.Errorf("错误：无法将author转换为string：%v", result["author"]), "")
 	}
@@ -379,5 +514,17 @@ func (s *StructNews) mapResultToStructItem(result map[string]interface{}) (Struc
 		return item, util.WrapError(fmt.Errorf("错误：无法将is_recommended转换为int64：%v", result["is_recommended"]), "")
 	}
 
+	if simhash, ok := result["simhash"].(int64); ok {
+		item.Simhash = simhash
+	} else {
+		return item, util.WrapError(fmt.Errorf("错误：无法将simhash转换为int64：%v", result["simhash"]), "")
+	}
+
+	if clusterID, ok := result["cluster_id"].(int64); ok {
+		item.Cluster_id = int(clusterID)
+	} else {
+		return item, util.WrapError(fmt.Errorf("错误：无法将cluster_id转换为int64：%v", result["cluster_id"]), "")
+	}
+
 	return item, nil
 }
@@ -1,6 +1,7 @@
 package mydb
 
 import (
+	"database/sql"
 	"fmt"
 	"nav-web-site/config"
 	"nav-web-site/util"
@@ -156,6 +157,30 @@ func (s *StructNewsClass) Delete(condition string) (int, []int64, error) {
 	return count, ids, nil
 }
 
+// DeleteTree 在一个事务里删除分类和其中的新闻（含正文），整理新闻所在的故事聚合，返回删除的分类数和新闻数
+func (s *StructNewsClass) DeleteTree(classIDs []int) (int, int, error) {
+	classTable := fmt.Sprintf("%s%s%s", config.Config.MySQL.TablePrefix, s.GetTableName(), "")
+	idList := joinIDs(classIDs)
+	var classCount, newsCount int
+	err := Transaction(func(tx *sql.Tx) error {
+		var err error
+		if newsCount, err = Tables.News.DeleteInTx(tx, "class_id IN ("+idList+")"); err != nil {
+			return err
+		}
+		result, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE id IN (%s)", classTable, idList))
+		if err != nil {
+			return util.WrapError(err, "删除新闻分类失败:")
+		}
+		affected, err := result.RowsAffected()
+		classCount = int(affected)
+		return err
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return classCount, newsCount, nil
+}
+
 // mapResultToStructItem 将查询结果映射到结构体
 func (s *StructNewsClass) mapResultToStructItem(result map[string]interface{}) (StructNewsClass, error) {
 	var item StructNewsClass
@@ -0,0 +1,218 @@
+package mydb
+
+import (
+	"database/sql"
+	"fmt"
+	"nav-web-site/config"
+	"nav-web-site/util"
+	"nav-web-site/util/log"
+)
+
+// StructNewsCluster 定义故事聚合结构体，不同来源报道同一事件的新闻归到同一个聚合
+type StructNewsCluster struct {
+	ID           int    `db:"id"`           // id
+	Title        string `db:"title"`        // 聚合标题，默认取最早那条新闻的标题
+	Lead_news_id int    `db:"lead_news_id"` // 代表新闻id
+	Create_time  int64  `db:"create_time"`  // 创建时间
+	Update_time  int64  `db:"update_time"`  // 更新时间
+}
+
+// 获取表名（不含前后缀）
+func (s *StructNewsCluster) GetTableName() string {
+	return "news_cluster"
+}
+
+// 获取插入数据时的必填字段
+func (s *StructNewsCluster) GetRequiredFields() []string {
+	return []string{
+		"Title",
+		"Lead_news_id",
+		"Create_time",
+	}
+}
+
+// 插入数据时查重的字段
+func (s StructNewsCluster) GetUniqueFields() []string {
+	return []string{"Lead_news_id"}
+}
+
+// Find 方法根据条件查询单个 news_cluster 记录
+func (s *StructNewsCluster) Find(params QueryParams) (StructNewsCluster, error) {
+	var cluster StructNewsCluster
+	results, err := GenericSelect(Db, s.GetTableName(), params, config.Config.MySQL.TablePrefix, "")
+	if err != nil {
+		return cluster, util.WrapError(err, "Query failed(find):")
+	}
+
+	if len(results) > 0 {
+		cluster, err = s.mapResultToStructItem(results[0])
+		if err != nil {
+			return cluster, util.WrapError(err, "将结果映射到StructNewsCluster时出错:")
+		}
+	} else {
+		// 如果没有查询到数据，返回一个错误
+		return cluster, util.WrapError(fmt.Errorf("EmptyData"), "")
+	}
+	return cluster, nil
+}
+
+// Select 方法查询 news_cluster 表的数据
+func (s *StructNewsCluster) Select(params QueryParams) ([]StructNewsCluster, int, error) {
+	var list []StructNewsCluster
+	results, err := GenericSelect(Db, s.GetTableName(), params, config.Config.MySQL.TablePrefix, "")
+	if err != nil {
+		return list, 400, util.WrapError(err, "Query failed(select):")
+	}
+
+	if len(results) > 0 {
+		for _, result := range results {
+			log.InfoLogger.Println("Processing result:", result)
+
+			item, err := s.mapResultToStructItem(result)
+			if err != nil {
+				log.ErrorLogger.Println("将结果映射到StructNewsCluster时出错:", err)
+				continue
+			}
+
+			list = append(list, item)
+		}
+	} else {
+		// 如果没有查询到数据，返回一个错误
+		return list, 200, util.WrapError(fmt.Errorf("EmptyData"), "")
+	}
+	return list, 0, nil
+}
+
+// Insert 方法插入新的 news_cluster 记录
+func (s *StructNewsCluster) Insert(datas []StructNewsCluster) (int, []int64, error) {
+	count, ids, err := GenericInsert(
+		s.GetTableName(),
+		datas,
+		s.GetRequiredFields(),
+		config.Config.MySQL.TablePrefix,
+		"",
+	)
+	if err != nil {
+		return 0, ids, err
+	}
+	return count, ids, nil
+}
+
+// Update 方法更新 news_cluster 记录
+func (s *StructNewsCluster) Update(datas []StructNewsCluster, condition string) (int, []int64, error) {
+	count, ids, err := GenericUpdate(
+		s.GetTableName(),
+		datas,
+		condition,
+		config.Config.MySQL.TablePrefix,
+		"",
+	)
+	if err != nil {
+		return 0, ids, err
+	}
+	return count, ids, nil
+}
+
+// Delete 方法删除 news_cluster 记录
+func (s *StructNewsCluster) Delete(condition string) (int, []int64, error) {
+	count, ids, err := GenericDelete(
+		s.GetTableName(),
+		condition,
+		config.Config.MySQL.TablePrefix,
+		"",
+	)
+	if err != nil {
+		return 0, ids, err
+	}
+	return count, ids, nil
+}
+
+// Merge 在一个事务里把 sourceIDs 聚合里的新闻全部移到 targetID 聚合，删除被合并的聚合并更新目标聚合的时间，返回移动的新闻数
+func (s *StructNewsCluster) Merge(targetID int, sourceIDs []int) (int, error) {
+	clusterTable := fmt.Sprintf("%s%s%s", config.Config.MySQL.TablePrefix, s.GetTableName(), "")
+	newsTable := fmt.Sprintf("%s%s%s", config.Config.MySQL.TablePrefix, Tables.News.GetTableName(), "")
+	moved := 0
+	err := Transaction(func(tx *sql.Tx) error {
+		result, err := tx.Exec(fmt.Sprintf("UPDATE %s SET cluster_id = ? WHERE cluster_id IN (%s)", newsTable, joinIDs(sourceIDs)), targetID)
+		if err != nil {
+			return util.WrapError(err, "移动聚合新闻失败:")
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return util.WrapError(err, "获取受影响的行数失败:")
+		}
+		moved = int(affected)
+		if _, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE id IN (%s)", clusterTable, joinIDs(sourceIDs))); err != nil {
+			return util.WrapError(err, "删除被合并的聚合失败:")
+		}
+		if _, err := tx.Exec(fmt.Sprintf("UPDATE %s SET update_time = ? WHERE id = ?", clusterTable), util.GetTimestamp(10), targetID); err != nil {
+			return util.WrapError(err, "更新故事聚合时间失败:")
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return moved, nil
+}
+
+// settle 删除或移走新闻后整理故事聚合：代表新闻已不在聚合里的改用聚合里最早的一条新闻，聚合里没有新闻时删除聚合
+func (s *StructNewsCluster) settle(tx *sql.Tx, ids []int) error {
+	clusterTable := fmt.Sprintf("%s%s%s", config.Config.MySQL.TablePrefix, s.GetTableName(), "")
+	newsTable := fmt.Sprintf("%s%s%s", config.Config.MySQL.TablePrefix, Tables.News.GetTableName(), "")
+	now := util.GetTimestamp(10)
+	for _, id := range ids {
+		var lead sql.NullInt64
+		if err := tx.QueryRow(fmt.Sprintf("SELECT MIN(id) FROM %s WHERE cluster_id = ?", newsTable), id).Scan(&lead); err != nil {
+			return util.WrapError(err, "查询聚合里的新闻失败:")
+		}
+		if !lead.Valid {
+			if _, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE id = ?", clusterTable), id); err != nil {
+				return util.WrapError(err, "删除空的故事聚合失败:")
+			}
+			continue
+		}
+		query := fmt.Sprintf("UPDATE %s SET lead_news_id = ?, update_time = ? WHERE id = ? AND lead_news_id NOT IN (SELECT id FROM %s WHERE cluster_id = ?)",
+			clusterTable, newsTable)
+		if _, err := tx.Exec(query, lead.Int64, now, id, id); err != nil {
+			return util.WrapError(err, "更新聚合的代表新闻失败:")
+		}
+	}
+	return nil
+}
+
+// mapResultToStructItem 将查询结果映射到结构体
+func (s *StructNewsCluster) mapResultToStructItem(result map[string]interface{}) (StructNewsCluster, error) {
+	var item StructNewsCluster
+	var ok bool
+
+	if id, ok := result["id"].(int64); ok {
+		item.ID = int(id)
+	} else {
+		return item, util.WrapError(fmt.Errorf("错误：无法将id转换为int64：%v", result["id"]), "")
+	}
+
+	if item.Title, ok = result["title"].(string); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将title转换为string：%v", result["title"]), "")
+	}
+
+	if leadNewsID, ok := result["lead_news_id"].(int64); ok {
+		item.Lead_news_id = int(leadNewsID)
+	} else {
+		return item, util.WrapError(fmt.Errorf("错误：无法将lead_news_id转换为int64：%v", result["lead_news_id"]), "")
+	}
+
+	if createTime, ok := result["create_time"].(int64); ok {
+		item.Create_time = createTime
+	} else {
+		return item, util.WrapError(fmt.Errorf("错误：无法将create_time转换为int64：%v", result["create_time"]), "")
+	}
+
+	if updateTime, ok := result["update_time"].(int64); ok {
+		item.Update_time = updateTime
+	} else {
+		return item, util.WrapError(fmt.Errorf("错误：无法将update_time转换为int64：%v", result["update_time"]), "")
+	}
+
+	return item, nil
+}
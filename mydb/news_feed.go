@@ -0,0 +1,204 @@
+package mydb
+
+import (
+	"fmt"
+	"nav-web-site/config"
+	"nav-web-site/util"
+	"nav-web-site/util/log"
+)
+
+// StructNewsFeed 定义新闻订阅源（RSS/Atom/JSON Feed）结构体
+type StructNewsFeed struct {
+	ID              int    `db:"id"`              // id
+	Admin_id        int    `db:"admin_id"`        // 管理员id
+	Name            string `db:"name"`            // 订阅源名称
+	Url             string `db:"url"`             // 订阅地址
+	Class_id        int    `db:"class_id"`        // 抓取的新闻存入的新闻分类
+	Is_enable       bool   `db:"is_enable"`       // 是否启用
+	Last_fetch_time int64  `db:"last_fetch_time"` // 最近一次抓取时间
+	Last_status     string `db:"last_status"`     // 最近一次抓取结果
+	Create_time     int64  `db:"create_time"`     // 创建时间
+	Update_time     int64  `db:"update_time"`     // 更新时间
+}
+
+// DefaultData 是一个构造函数，用于创建带有默认值的 StructNewsFeed 实例
+func (s *StructNewsFeed) DefaultData() StructNewsFeed {
+	return StructNewsFeed{
+		Is_enable:   true, // 默认值
+		Create_time: util.GetTimestamp(10),
+		Update_time: util.GetTimestamp(10),
+	}
+}
+
+// 获取表名（不含前后缀）
+func (s *StructNewsFeed) GetTableName() string {
+	return "news_feed"
+}
+
+// 获取插入数据时的必填字段
+func (s *StructNewsFeed) GetRequiredFields() []string {
+	return []string{
+		"Name",
+		"Url",
+		"Class_id",
+		"Create_time",
+	}
+}
+
+// 插入数据时查重的字段
+func (s StructNewsFeed) GetUniqueFields() []string {
+	return []string{"Url"}
+}
+
+// Find 方法根据条件查询单个 news_feed 记录
+func (s *StructNewsFeed) Find(params QueryParams) (StructNewsFeed, error) {
+	var feed StructNewsFeed
+	results, err := GenericSelect(Db, s.GetTableName(), params, config.Config.MySQL.TablePrefix, "")
+	if err != nil {
+		return feed, util.WrapError(err, "Query failed(find):")
+	}
+
+	if len(results) > 0 {
+		feed, err = s.mapResultToStructItem(results[0])
+		if err != nil {
+			return feed, util.WrapError(err, "将结果映射到StructNewsFeed时出错:")
+		}
+	} else {
+		// 如果没有查询到数据，返回一个错误
+		return feed, util.WrapError(fmt.Errorf("EmptyData"), "")
+	}
+	return feed, nil
+}
+
+// Select 方法查询 news_feed 表的数据
+func (s *StructNewsFeed) Select(params QueryParams) ([]StructNewsFeed, int, error) {
+	var list []StructNewsFeed
+	results, err := GenericSelect(Db, s.GetTableName(), params, config.Config.MySQL.TablePrefix, "")
+	if err != nil {
+		return list, 400, util.WrapError(err, "Query failed(select):")
+	}
+
+	if len(results) > 0 {
+		for _, result := range results {
+			log.InfoLogger.Println("Processing result:", result)
+
+			item, err := s.mapResultToStructItem(result)
+			if err != nil {
+				log.ErrorLogger.Println("将结果映射到StructNewsFeed时出错:", err)
+				continue
+			}
+
+			list = append(list, item)
+		}
+	} else {
+		// 如果没有查询到数据，返回一个错误
+		return list, 200, util.WrapError(fmt.Errorf("EmptyData"), "")
+	}
+	return list, 0, nil
+}
+
+// Insert 方法插入新的 news_feed 记录
+func (s *StructNewsFeed) Insert(datas []StructNewsFeed) (int, []int64, error) {
+	count, ids, err := GenericInsert(
+		s.GetTableName(),
+		datas,
+		s.GetRequiredFields(),
+		config.Config.MySQL.TablePrefix,
+		"",
+	)
+	if err != nil {
+		return 0, ids, err
+	}
+	return count, ids, nil
+}
+
+// Update 方法更新 news_feed 记录
+func (s *StructNewsFeed) Update(datas []StructNewsFeed, condition string) (int, []int64, error) {
+	count, ids, err := GenericUpdate(
+		s.GetTableName(),
+		datas,
+		condition,
+		config.Config.MySQL.TablePrefix,
+		"",
+	)
+	if err != nil {
+		return 0, ids, err
+	}
+	return count, ids, nil
+}
+
+// Delete 方法删除 news_feed 记录
+func (s *StructNewsFeed) Delete(condition string) (int, []int64, error) {
+	count, ids, err := GenericDelete(
+		s.GetTableName(),
+		condition,
+		config.Config.MySQL.TablePrefix,
+		"",
+	)
+	if err != nil {
+		return 0, ids, err
+	}
+	return count, ids, nil
+}
+
+// mapResultToStructItem 将查询结果映射到结构体
+func (s *StructNewsFeed) mapResultToStructItem(result map[string]interface{}) (StructNewsFeed, error) {
+	var item StructNewsFeed
+	var ok bool
+
+	if id, ok := result["id"].(int64); ok {
+		item.ID = int(id)
+	} else {
+		return item, util.WrapError(fmt.Errorf("错误：无法将id转换为int64：%v", result["id"]), "")
+	}
+
+	if adminID, ok := result["admin_id"].(int64); ok {
+		item.Admin_id = int(adminID)
+	} else {
+		return item, util.WrapError(fmt.Errorf("错误：无法将admin_id转换为int64：%v", result["admin_id"]), "")
+	}
+
+	if item.Name, ok = result["name"].(string); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将name转换为string：%v", result["name"]), "")
+	}
+
+	if item.Url, ok = result["url"].(string); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将url转换为string：%v", result["url"]), "")
+	}
+
+	if classID, ok := result["class_id"].(int64); ok {
+		item.Class_id = int(classID)
+	} else {
+		return item, util.WrapError(fmt.Errorf("错误：无法将class_id转换为int64：%v", result["class_id"]), "")
+	}
+
+	if isEnable, ok := result["is_enable"].(int64); ok {
+		item.Is_enable = isEnable == 1
+	} else {
+		return item, util.WrapError(fmt.Errorf("错误：无法将is_enable转换为int64：%v", result["is_enable"]), "")
+	}
+
+	if lastFetchTime, ok := result["last_fetch_time"].(int64); ok {
+		item.Last_fetch_time = lastFetchTime
+	} else {
+		return item, util.WrapError(fmt.Errorf("错误：无法将last_fetch_time转换为int64：%v", result["last_fetch_time"]), "")
+	}
+
+	if item.Last_status, ok = result["last_status"].(string); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将last_status转换为string：%v", result["last_status"]), "")
+	}
+
+	if createTime, ok := result["create_time"].(int64); ok {
+		item.Create_time = createTime
+	} else {
+		return item, util.WrapError(fmt.Errorf("错误：无法将create_time转换为int64：%v", result["create_time"]), "")
+	}
+
+	if updateTime, ok := result["update_time"].(int64); ok {
+		item.Update_time = updateTime
+	} else {
+		return item, util.WrapError(fmt.Errorf("错误：无法将update_time转换为int64：%v", result["update_time"]), "")
+	}
+
+	return item, nil
+}
@@ -420,7 +420,7 @@ func CheckExistingRecord[T any](db *sql.DB, data T, uniqueFields []string, table
 		}
 		value := reflect.ValueOf(data).FieldByName(field).Interface()
 		if str, ok := value.(string); ok {
-			condition += fmt.Sprintf("%s = '%s'", field, str)
+			condition += fmt.Sprintf("%s = '%s'", field, EscapeString(str))
 		} else {
 			condition += fmt.Sprintf("%s = %v", field, value)
 		}
@@ -540,3 +540,39 @@ func contains(slice []string, item string) bool {
 	}
 	return false
 }
+
+// GenericCount 通用统计记录条数
+func GenericCount(tableName string, condition string, tablePrefix string, tableSuffix string) (int, error) {
+	// 设置默认值
+	if tablePrefix == "" {
+		tablePrefix = config.Config.MySQL.TablePrefix
+	}
+	// 构建带前后缀的表名
+	fullTableName := fmt.Sprintf("%s%s%s", tablePrefix, tableName, tableSuffix)
+
+	query := fmt.Sprintf("SELECT COUNT(*) FROM %s", fullTableName)
+	if condition != "" {
+		query = fmt.Sprintf("%s WHERE %s", query, condition)
+	}
+	log.InfoLogger.Println("Constructed Count Query:", query)
+
+	var count int
+	if err := Db.QueryRow(query).Scan(&count); err != nil {
+		return 0, util.WrapError(err, "统计记录数失败:")
+	}
+	return count, nil
+}
+
+// EscapeString 转义拼接进SQL条件里的字符串值
+func EscapeString(value string) string {
+	replacer := strings.NewReplacer(
+		`\`, `\\`,
+		`'`, `\'`,
+		`"`, `\"`,
+		"\x00", `\0`,
+		"\n", `\n`,
+		"\r", `\r`,
+		"\x1a", `\Z`,
+	)
+	return replacer.Replace(value)
+}
@@ -0,0 +1,194 @@
+package mydb
+
+import (
+	"fmt"
+	"nav-web-site/config"
+	"nav-web-site/util"
+	"nav-web-site/util/log"
+)
+
+// StructScheduledTask 定义计划任务结构体，调度器按表里启用的任务定时执行
+type StructScheduledTask struct {
+	ID          int    `db:"id"`          // id
+	Name        string `db:"name"`        // 任务名称
+	Task_type   string `db:"task_type"`   // 任务类型，如 upload_gc、news163
+	Cron_expr   string `db:"cron_expr"`   // cron 表达式（分 时 日 月 周，也支持 @every 1h 等）
+	Timezone    string `db:"timezone"`    // cron 表达式所用的时区，为空时用服务器本地时区
+	Params      string `db:"params"`      // 传给任务的 JSON 参数
+	Is_enable   bool   `db:"is_enable"`   // 是否启用
+	Source      string `db:"source"`      // 来源：config 配置文件同步、admin 后台添加，配置文件的任务在后台不能改名称、类型、cron 表达式和时区，也不能删除
+	Create_time int64  `db:"create_time"` // 创建时间
+	Update_time int64  `db:"update_time"` // 更新时间
+}
+
+// DefaultData 是一个构造函数，用于创建带有默认值的 StructScheduledTask 实例
+func (s *StructScheduledTask) DefaultData() StructScheduledTask {
+	return StructScheduledTask{
+		Params:      "{}",
+		Is_enable:   true, // 默认值
+		Source:      "admin",
+		Create_time: util.GetTimestamp(10),
+		Update_time: util.GetTimestamp(10),
+	}
+}
+
+// 获取表名（不含前后缀）
+func (s *StructScheduledTask) GetTableName() string {
+	return "scheduled_task"
+}
+
+// 获取插入数据时的必填字段
+func (s *StructScheduledTask) GetRequiredFields() []string {
+	return []string{
+		"Name",
+		"Task_type",
+		"Cron_expr",
+		"Create_time",
+	}
+}
+
+// 插入数据时查重的字段
+func (s StructScheduledTask) GetUniqueFields() []string {
+	return []string{"Name"}
+}
+
+// Find 方法根据条件查询单个 scheduled_task 记录
+func (s *StructScheduledTask) Find(params QueryParams) (StructScheduledTask, error) {
+	var task StructScheduledTask
+	results, err := GenericSelect(Db, s.GetTableName(), params, config.Config.MySQL.TablePrefix, "")
+	if err != nil {
+		return task, util.WrapError(err, "Query failed(find):")
+	}
+
+	if len(results) > 0 {
+		task, err = s.mapResultToStructItem(results[0])
+		if err != nil {
+			return task, util.WrapError(err, "将结果映射到StructScheduledTask时出错:")
+		}
+	} else {
+		// 如果没有查询到数据，返回一个错误
+		return task, util.WrapError(fmt.Errorf("EmptyData"), "")
+	}
+	return task, nil
+}
+
+// Select 方法查询 scheduled_task 表的数据
+func (s *StructScheduledTask) Select(params QueryParams) ([]StructScheduledTask, int, error) {
+	var list []StructScheduledTask
+	results, err := GenericSelect(Db, s.GetTableName(), params, config.Config.MySQL.TablePrefix, "")
+	if err != nil {
+		return list, 400, util.WrapError(err, "Query failed(select):")
+	}
+
+	if len(results) > 0 {
+		for _, result := range results {
+			item, err := s.mapResultToStructItem(result)
+			if err != nil {
+				log.ErrorLogger.Println("将结果映射到StructScheduledTask时出错:", err)
+				continue
+			}
+
+			list = append(list, item)
+		}
+	} else {
+		// 如果没有查询到数据，返回一个错误
+		return list, 200, util.WrapError(fmt.Errorf("EmptyData"), "")
+	}
+	return list, 0, nil
+}
+
+// Insert 方法插入新的 scheduled_task 记录
+func (s *StructScheduledTask) Insert(datas []StructScheduledTask) (int, []int64, error) {
+	count, ids, err := GenericInsert(
+		s.GetTableName(),
+		datas,
+		s.GetRequiredFields(),
+		config.Config.MySQL.TablePrefix,
+		"",
+	)
+	if err != nil {
+		return 0, ids, err
+	}
+	return count, ids, nil
+}
+
+// Update 方法更新 scheduled_task 记录
+func (s *StructScheduledTask) Update(datas []StructScheduledTask, condition string) (int, []int64, error) {
+	count, ids, err := GenericUpdate(
+		s.GetTableName(),
+		datas,
+		condition,
+		config.Config.MySQL.TablePrefix,
+		"",
+	)
+	if err != nil {
+		return 0, ids, err
+	}
+	return count, ids, nil
+}
+
+// Delete 方法删除 scheduled_task 记录
+func (s *StructScheduledTask) Delete(condition string) (int, []int64, error) {
+	count, ids, err := GenericDelete(
+		s.GetTableName(),
+		condition,
+		config.Config.MySQL.TablePrefix,
+		"",
+	)
+	if err != nil {
+		return 0, ids, err
+	}
+	return count, ids, nil
+}
+
+// mapResultToStructItem 将查询结果映射到结构体
+func (s *StructScheduledTask) mapResultToStructItem(result map[string]interface{}) (StructScheduledTask, error) {
+	var item StructScheduledTask
+	var ok bool
+
+	if id, ok := result["id"].(int64); ok {
+		item.ID = int(id)
+	} else {
+		return item, util.WrapError(fmt.Errorf("错误：无法将id转换为int64：%v", result["id"]), "")
+	}
+
+	if item.Name, ok = result["name"].(string); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将name转换为string：%v", result["name"]), "")
+	}
+
+	if item.Task_type, ok = result["task_type"].(string); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将task_type转换为string：%v", result["task_type"]), "")
+	}
+
+	if item.Cron_expr, ok = result["cron_expr"].(string); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将cron_expr转换为string：%v", result["cron_expr"]), "")
+	}
+
+	if item.Timezone, ok = result["timezone"].(string); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将timezone转换为string：%v", result["timezone"]), "")
+	}
+
+	if item.Params, ok = result["params"].(string); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将params转换为string：%v", result["params"]), "")
+	}
+
+	if isEnable, ok := result["is_enable"].(int64); ok {
+		item.Is_enable = isEnable == 1
+	} else {
+		return item, util.WrapError(fmt.Errorf("错误：无法将is_enable转换为int64：%v", result["is_enable"]), "")
+	}
+
+	if item.Source, ok = result["source"].(string); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将source转换为string：%v", result["source"]), "")
+	}
+
+	if item.Create_time, ok = result["create_time"].(int64); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将create_time转换为int64：%v", result["create_time"]), "")
+	}
+
+	if item.Update_time, ok = result["update_time"].(int64); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将update_time转换为int64：%v", result["update_time"]), "")
+	}
+
+	return item, nil
+}
@@ -0,0 +1,193 @@
+package mydb
+
+import (
+	"fmt"
+	"nav-web-site/config"
+	"nav-web-site/util"
+	"nav-web-site/util/log"
+)
+
+// StructTaskRun 定义计划任务执行记录结构体
+type StructTaskRun struct {
+	ID           int    `db:"id"`           // id
+	Task_type    string `db:"task_type"`    // 任务类型
+	Trigger_type string `db:"trigger_type"` // 触发方式：cron 定时、manual 后台手动、queue 队列
+	Status       string `db:"status"`       // 执行状态：running、success、failed
+	Start_time   int64  `db:"start_time"`   // 开始时间
+	End_time     int64  `db:"end_time"`     // 结束时间
+	Duration_ms  int64  `db:"duration_ms"`  // 耗时（毫秒）
+	Fetched      int    `db:"fetched"`      // 抓取/处理的条数
+	Inserted     int    `db:"inserted"`     // 新增的条数
+	Skipped      int    `db:"skipped"`      // 跳过的条数
+	Failed       int    `db:"failed"`       // 失败的条数
+	Error        string `db:"error"`        // 错误信息
+	Stack        string `db:"stack"`        // panic 时的调用栈（截断）
+}
+
+// 获取表名（不含前后缀）
+func (s *StructTaskRun) GetTableName() string {
+	return "task_run"
+}
+
+// 获取插入数据时的必填字段
+func (s *StructTaskRun) GetRequiredFields() []string {
+	return []string{
+		"Task_type",
+		"Status",
+		"Start_time",
+	}
+}
+
+// 插入数据时查重的字段，执行记录不查重
+func (s StructTaskRun) GetUniqueFields() []string {
+	return []string{}
+}
+
+// Find 方法根据条件查询单个 task_run 记录
+func (s *StructTaskRun) Find(params QueryParams) (StructTaskRun, error) {
+	var run StructTaskRun
+	results, err := GenericSelect(Db, s.GetTableName(), params, config.Config.MySQL.TablePrefix, "")
+	if err != nil {
+		return run, util.WrapError(err, "Query failed(find):")
+	}
+
+	if len(results) > 0 {
+		run, err = s.mapResultToStructItem(results[0])
+		if err != nil {
+			return run, util.WrapError(err, "将结果映射到StructTaskRun时出错:")
+		}
+	} else {
+		// 如果没有查询到数据，返回一个错误
+		return run, util.WrapError(fmt.Errorf("EmptyData"), "")
+	}
+	return run, nil
+}
+
+// Select 方法查询 task_run 表的数据
+func (s *StructTaskRun) Select(params QueryParams) ([]StructTaskRun, int, error) {
+	var list []StructTaskRun
+	results, err := GenericSelect(Db, s.GetTableName(), params, config.Config.MySQL.TablePrefix, "")
+	if err != nil {
+		return list, 400, util.WrapError(err, "Query failed(select):")
+	}
+
+	if len(results) > 0 {
+		for _, result := range results {
+			item, err := s.mapResultToStructItem(result)
+			if err != nil {
+				log.ErrorLogger.Println("将结果映射到StructTaskRun时出错:", err)
+				continue
+			}
+
+			list = append(list, item)
+		}
+	} else {
+		// 如果没有查询到数据，返回一个错误
+		return list, 200, util.WrapError(fmt.Errorf("EmptyData"), "")
+	}
+	return list, 0, nil
+}
+
+// Insert 方法插入新的 task_run 记录
+func (s *StructTaskRun) Insert(datas []StructTaskRun) (int, []int64, error) {
+	count, ids, err := GenericInsert(
+		s.GetTableName(),
+		datas,
+		s.GetRequiredFields(),
+		config.Config.MySQL.TablePrefix,
+		"",
+	)
+	if err != nil {
+		return 0, ids, err
+	}
+	return count, ids, nil
+}
+
+// Update 方法更新 task_run 记录
+func (s *StructTaskRun) Update(datas []StructTaskRun, condition string) (int, []int64, error) {
+	count, ids, err := GenericUpdate(
+		s.GetTableName(),
+		datas,
+		condition,
+		config.Config.MySQL.TablePrefix,
+		"",
+	)
+	if err != nil {
+		return 0, ids, err
+	}
+	return count, ids, nil
+}
+
+// Delete 方法删除 task_run 记录
+func (s *StructTaskRun) Delete(condition string) (int, []int64, error) {
+	count, ids, err := GenericDelete(
+		s.GetTableName(),
+		condition,
+		config.Config.MySQL.TablePrefix,
+		"",
+	)
+	if err != nil {
+		return 0, ids, err
+	}
+	return count, ids, nil
+}
+
+// mapResultToStructItem 将查询结果映射到结构体
+func (s *StructTaskRun) mapResultToStructItem(result map[string]interface{}) (StructTaskRun, error) {
+	var item StructTaskRun
+	var ok bool
+
+	if id, ok := result["id"].(int64); ok {
+		item.ID = int(id)
+	} else {
+		return item, util.WrapError(fmt.Errorf("错误：无法将id转换为int64：%v", result["id"]), "")
+	}
+
+	if item.Task_type, ok = result["task_type"].(string); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将task_type转换为string：%v", result["task_type"]), "")
+	}
+
+	if item.Trigger_type, ok = result["trigger_type"].(string); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将trigger_type转换为string：%v", result["trigger_type"]), "")
+	}
+
+	if item.Status, ok = result["status"].(string); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将status转换为string：%v", result["status"]), "")
+	}
+
+	if item.Start_time, ok = result["start_time"].(int64); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将start_time转换为int64：%v", result["start_time"]), "")
+	}
+
+	if item.End_time, ok = result["end_time"].(int64); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将end_time转换为int64：%v", result["end_time"]), "")
+	}
+
+	if item.Duration_ms, ok = result["duration_ms"].(int64); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将duration_ms转换为int64：%v", result["duration_ms"]), "")
+	}
+
+	counts := map[string]*int{
+		"fetched":  &item.Fetched,
+		"inserted": &item.Inserted,
+		"skipped":  &item.Skipped,
+		"failed":   &item.Failed,
+	}
+	for field, target := range counts {
+		value, ok := result[field].(int64)
+		if !ok {
+			return item, util.WrapError(fmt.Errorf("错误：无法将%s转换为int64：%v", field, result[field]), "")
+		}
+		*target = int(value)
+	}
+
+	if item.Error, ok = result["error"].(string); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将error转换为string：%v", result["error"]), "")
+	}
+
+	if item.Stack, ok = result["stack"].(string); !ok {
+		return item, util.WrapError(fmt.Errorf("错误：无法将stack转换为string：%v", result["stack"]), "")
+	}
+
+	return item, nil
+}
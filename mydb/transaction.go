@@ -0,0 +1,53 @@
+package mydb
+
+import (
+	"database/sql"
+	"nav-web-site/util"
+	"strconv"
+	"strings"
+)
+
+// Transaction 在一个事务里执行 fn，fn 返回错误时回滚，否则提交。需要几条语句一起生效或一起失败时使用
+func Transaction(fn func(tx *sql.Tx) error) error {
+	tx, err := Db.Begin()
+	if err != nil {
+		return util.WrapError(err, "开启事务失败:")
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return util.WrapError(err, "提交事务失败:")
+	}
+	return nil
+}
+
+// queryIDs 在事务里查询一列整数，如要删除的记录 id
+func queryIDs(tx *sql.Tx, query string, args ...interface{}) ([]int, error) {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, util.WrapError(err, "查询失败:"+query)
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, util.WrapError(err, "读取查询结果失败:")
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// joinIDs 把 id 拼成 IN (...) 里用的列表
+func joinIDs(ids []int) string {
+	parts := make([]string, 0, len(ids))
+	for _, id := range ids {
+		parts = append(parts, strconv.Itoa(id))
+	}
+	return strings.Join(parts, ",")
+}
@@ -5,6 +5,7 @@ import (
 	"nav-web-site/config"
 	"nav-web-site/util"
 	"nav-web-site/util/log"
+	"regexp"
 )
 
 type StructUploadFile struct {
@@ -16,6 +17,40 @@ type StructUploadFile struct {
 	FileType   string `db:"file_type"`   // 文件类型
 	Extension  string `db:"extension"`   // 扩展名
 	UploadTime int64  `db:"upload_time"` // 上传时间
+	AdminID    int    `db:"admin_id"`    // 上传者（管理员ID）
+	IsPrivate  bool   `db:"is_private"`  // 是否私有，私有文件只能通过签名链接访问
+}
+
+// UploadFileRefColumn 会保存上传文件哈希（或 /images/{hash} 地址）的表字段
+type UploadFileRefColumn struct {
+	Table  string // 表名（不含前后缀）
+	Column string // 字段名
+}
+
+// UploadFileRefColumns 引用扫描和孤儿文件清理都以这里登记的字段为准
+var UploadFileRefColumns = []UploadFileRefColumn{
+	{Table: "news", Column: "imgurl"},
+	{Table: "nav", Column: "icon"},
+	{Table: "admin", Column: "avatar"},
+}
+
+// UploadFileRef 表示一条引用了上传文件的记录
+type UploadFileRef struct {
+	Table  string `json:"table"`
+	Column string `json:"column"`
+	ID     int    `json:"id"`
+}
+
+var (
+	// 上传文件的哈希是 sha256 的十六进制字符串，用于从引用字段的内容里找出哈希
+	uploadHashRegexp = regexp.MustCompile(`[0-9a-f]{64}`)
+	// 校验整个参数就是一个哈希，参数会拼进查询条件，不能只包含哈希
+	validUploadHashRegexp = regexp.MustCompile(`^[0-9a-f]{64}$`)
+)
+
+// IsUploadHash 判断是否是合法的文件哈希
+func IsUploadHash(hash string) bool {
+	return validUploadHashRegexp.MatchString(hash)
 }
 
 // DefaultData 是一个构造函数，用于创建带有默认值的 StructUploadFile 实例
@@ -109,6 +144,94 @@ func (s *StructUploadFile) Find(params QueryParams) (StructUploadFile, error) {
 	return item, nil
 }
 
+// Update 方法更新 upload_file 记录
+func (s *StructUploadFile) Update(datas []StructUploadFile, condition string) (int, []int64, error) {
+	count, ids, err := GenericUpdate(
+		s.GetTableName(),
+		datas,
+		condition,
+		config.Config.MySQL.TablePrefix,
+		"",
+	)
+	if err != nil {
+		return 0, ids, err
+	}
+	return count, ids, nil
+}
+
+// Delete 方法删除 upload_file 记录（只删数据库记录，不删磁盘文件）
+func (s *StructUploadFile) Delete(condition string) (int, []int64, error) {
+	count, ids, err := GenericDelete(
+		s.GetTableName(),
+		condition,
+		config.Config.MySQL.TablePrefix,
+		"",
+	)
+	if err != nil {
+		return 0, ids, err
+	}
+	return count, ids, nil
+}
+
+// Count 方法统计符合条件的 upload_file 记录数
+func (s *StructUploadFile) Count(condition string) (int, error) {
+	return GenericCount(s.GetTableName(), condition, config.Config.MySQL.TablePrefix, "")
+}
+
+// FindReferences 查询所有引用了指定哈希的记录
+func (s *StructUploadFile) FindReferences(hash string) ([]UploadFileRef, error) {
+	var refs []UploadFileRef
+	if !IsUploadHash(hash) {
+		return refs, util.WrapError(fmt.Errorf("无效的文件哈希：%s", hash), "")
+	}
+
+	for _, refColumn := range UploadFileRefColumns {
+		fullTableName := fmt.Sprintf("%s%s", config.Config.MySQL.TablePrefix, refColumn.Table)
+		query := fmt.Sprintf("SELECT id FROM %s WHERE %s LIKE ?", fullTableName, refColumn.Column)
+		rows, err := Db.Query(query, "%"+hash+"%")
+		if err != nil {
+			return refs, util.WrapError(err, "查询文件引用失败:"+query)
+		}
+
+		for rows.Next() {
+			var id int
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return refs, util.WrapError(err, "扫描文件引用失败:")
+			}
+			refs = append(refs, UploadFileRef{Table: refColumn.Table, Column: refColumn.Column, ID: id})
+		}
+		rows.Close()
+	}
+	return refs, nil
+}
+
+// ReferencedHashes 扫描所有引用字段，返回被引用的哈希及其引用次数
+func (s *StructUploadFile) ReferencedHashes() (map[string]int, error) {
+	hashes := make(map[string]int)
+	for _, refColumn := range UploadFileRefColumns {
+		fullTableName := fmt.Sprintf("%s%s", config.Config.MySQL.TablePrefix, refColumn.Table)
+		query := fmt.Sprintf("SELECT %s FROM %s WHERE %s <> ''", refColumn.Column, fullTableName, refColumn.Column)
+		rows, err := Db.Query(query)
+		if err != nil {
+			return hashes, util.WrapError(err, "扫描文件引用失败:"+query)
+		}
+
+		for rows.Next() {
+			var value string
+			if err := rows.Scan(&value); err != nil {
+				rows.Close()
+				return hashes, util.WrapError(err, "扫描文件引用失败:")
+			}
+			for _, hash := range uploadHashRegexp.FindAllString(value, -1) {
+				hashes[hash]++
+			}
+		}
+		rows.Close()
+	}
+	return hashes, nil
+}
+
 // mapResultToStructItem 将查询结果映射到结构体
 func (s *StructUploadFile) mapResultToStructItem(result map[string]interface{}) (StructUploadFile, error) {
 	var item StructUploadFile
@@ -152,5 +275,18 @@ func (s *StructUploadFile) mapResultToStructItem(result map[string]interface{})
 		return item, util.WrapError(fmt.Errorf("错误：无法将upload_time转换为int64：%v", result["upload_time"]), "")
 	}
 
+	// admin_id、is_private 是后加的列，还没执行 install.sql 里 ALTER 的旧库查不到这两列，按默认值处理
+	if adminID, ok := result["admin_id"].(int64); ok {
+		item.AdminID = int(adminID)
+	} else if result["admin_id"] != nil {
+		return item, util.WrapError(fmt.Errorf("错误：无法将admin_id转换为int64：%v", result["admin_id"]), "")
+	}
+
+	if isPrivate, ok := result["is_private"].(int64); ok {
+		item.IsPrivate = isPrivate == 1
+	} else if result["is_private"] != nil {
+		return item, util.WrapError(fmt.Errorf("错误：无法将is_private转换为int64：%v", result["is_private"]), "")
+	}
+
 	return item, nil
 }
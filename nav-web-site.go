@@ -3,23 +3,27 @@ package main
 import (
 	"nav-web-site/app/api/upload"
 	"nav-web-site/app/api/v1/admin"
+	"nav-web-site/app/api/v1/certificate"
+	"nav-web-site/app/api/v1/domain"
 	"nav-web-site/app/api/v1/nav"
 	"nav-web-site/app/api/v1/news"
-	"nav-web-site/app/webcrawler"
+	"nav-web-site/app/api/v1/site"
+	"nav-web-site/app/api/v1/task"
+	"nav-web-site/app/navclick"
+	"nav-web-site/app/queue"
+	"nav-web-site/app/tasks"
 	"nav-web-site/config"
 	"nav-web-site/middleware"
 	"nav-web-site/mydb"
 	"nav-web-site/util/log"
 	"net/http"
 	"os"
-	"runtime"
 	"time"
 
 	_ "nav-web-site/docs" // 这里导入生成的docs文件
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
-	"github.com/robfig/cron/v3"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 )
@@ -46,14 +50,23 @@ func main() {
 	gin.SetMode(runMode)
 
 	//初始数据库和redis
-	mydb.InitDB()
-	defer mydb.Db.Close() // 确保在程序结束时关闭数据库连接
+	mydb.InitDB() // 退出时由 runServer 在任务结束后关闭
 
-	// 启动定时任务检测 Goroutine
-	go startScheduledTaskChecker()
+	// 启动计划任务
+	startScheduledTaskChecker()
+
+	// 启动后台任务队列
+	queue.Start()
+
+	// 启动导航点击数的定时写回
+	navclick.Start()
 
 	//定义路由
 	r := gin.Default()
+	// 访问者IP用于登录校验和签名链接绑定IP，只信任配置的反向代理传来的 X-Forwarded-For，防止伪造
+	if err := r.SetTrustedProxies(config.Config.Server.TrustedProxies); err != nil {
+		log.ErrorLogger.Fatalf("无效的 server.trusted_proxies 配置: %v", err)
+	}
 
 	// 添加请求日志中间件
 	r.Use(RequestLoggerMiddleware())
@@ -86,6 +99,18 @@ func main() {
 		imageGroup.GET("/:hash", upload.GetImageByHash)
 	}
 
+	// 新闻订阅输出，公开访问
+	feedGroup := r.Group("/feed")
+	{
+		feedGroup.GET("/news.xml", news.GetNewsRSS)            // 全部新闻 RSS
+		feedGroup.GET("/news.atom", news.GetNewsAtom)          // 全部新闻 Atom
+		feedGroup.GET("/news.json", news.GetNewsJSONFeed)      // 全部新闻 JSON Feed
+		feedGroup.GET("/news/:file", news.GetClassSyndication) // 分类订阅，如 /feed/news/3.atom
+	}
+
+	// 导航跳转，记录点击数，公开访问
+	r.GET("/go/:id", nav.Redirect)
+
 	v1 := r.Group("/api/v1")
 
 	// 图片上传模块组
@@ -101,6 +126,43 @@ func main() {
 		// @Failure 400 {object} gin.H{"message": string}
 		// @Router /upload/image [post]
 		uploadGroup.POST("/image", upload.UploadImage)
+
+		// @Summary 获取上传文件列表
+		// @Description 媒体库列表，支持按类型、日期、大小、上传者筛选
+		// @Tags upload
+		// @Produce application/json
+		// @Router /upload/list [get]
+		uploadGroup.GET("/list", upload.GetFileList)
+
+		// @Summary 获取文件的引用情况
+		// @Tags upload
+		// @Produce application/json
+		// @Router /upload/refs/{hash} [get]
+		uploadGroup.GET("/refs/:hash", upload.GetFileRefs)
+
+		// @Summary 删除未被引用的文件
+		// @Tags upload
+		// @Produce application/json
+		// @Router /upload/delete/{hash} [delete]
+		uploadGroup.DELETE("/delete/:hash", upload.DeleteFile)
+
+		// @Summary 清理孤儿文件
+		// @Tags upload
+		// @Produce application/json
+		// @Router /upload/gc [post]
+		uploadGroup.POST("/gc", upload.CleanOrphans)
+
+		// @Summary 生成私有文件的签名链接
+		// @Tags upload
+		// @Produce application/json
+		// @Router /upload/sign [post]
+		uploadGroup.POST("/sign", upload.SignFileURL)
+
+		// @Summary 设置文件可见性
+		// @Tags upload
+		// @Produce application/json
+		// @Router /upload/visibility/{hash} [put]
+		uploadGroup.PUT("/visibility/:hash", upload.SetFileVisibility)
 	}
 
 	// 管理员用户模块组
@@ -174,6 +236,183 @@ func main() {
 		// @Success 200 {object} gin.H{"message": string}
 		// @Router /admin/delete/{id} [delete]
 		adminGroup.DELETE("/delete/:id", admin.DeleteUser)
+
+		// @Summary 获取任务执行记录
+		// @Description 列出计划任务和手动触发任务的执行记录
+		// @Tags admin
+		// @Produce json
+		// @Param task_type query string false "任务类型"
+		// @Param status query string false "执行状态"
+		// @Success 200 {object} task.TaskRunListData
+		// @Router /admin/tasks/runs [get]
+		adminGroup.GET("/tasks/runs", task.GetTaskRuns)
+
+		// @Summary 立即执行任务
+		// @Description 在后台立即执行指定类型的任务
+		// @Tags admin
+		// @Produce json
+		// @Param type path string true "任务类型"
+		// @Success 202 {object} mydb.StructTaskRun
+		// @Router /admin/tasks/{type}/run [post]
+		adminGroup.POST("/tasks/:type/run", task.RunTask)
+
+		// @Summary 添加计划任务
+		// @Description 添加计划任务并立即加入调度
+		// @Tags admin
+		// @Accept x-www-form-urlencoded
+		// @Produce json
+		// @Success 200 {object} mydb.StructScheduledTask
+		// @Router /admin/tasks/schedule/add [post]
+		adminGroup.POST("/tasks/schedule/add", task.AddScheduledTask)
+
+		// @Summary 修改计划任务
+		// @Description 修改计划任务并按新设置重新调度，可暂停或恢复
+		// @Tags admin
+		// @Accept x-www-form-urlencoded
+		// @Produce json
+		// @Param id path int true "计划任务ID"
+		// @Success 200 {object} mydb.StructScheduledTask
+		// @Router /admin/tasks/schedule/update/{id} [put]
+		adminGroup.PUT("/tasks/schedule/update/:id", task.UpdateScheduledTask)
+
+		// @Summary 删除计划任务
+		// @Description 删除计划任务并从调度中移除
+		// @Tags admin
+		// @Produce json
+		// @Param id path int true "计划任务ID"
+		// @Success 200 {object} gin.H{"message": string}
+		// @Router /admin/tasks/schedule/delete/{id} [delete]
+		adminGroup.DELETE("/tasks/schedule/delete/:id", task.DeleteScheduledTask)
+
+		// @Summary 获取计划任务列表
+		// @Description 获取全部计划任务及下一次执行时间
+		// @Tags admin
+		// @Produce json
+		// @Success 200 {object} []task.ScheduledTaskItem
+		// @Router /admin/tasks/schedule/list [get]
+		adminGroup.GET("/tasks/schedule/list", task.GetScheduledTaskList)
+
+		// @Summary 队列任务入队
+		// @Description 把任务放进后台队列执行，失败自动重试
+		// @Tags admin
+		// @Accept x-www-form-urlencoded
+		// @Produce json
+		// @Success 202 {object} mydb.StructJob
+		// @Router /admin/jobs/enqueue [post]
+		adminGroup.POST("/jobs/enqueue", task.EnqueueJob)
+
+		// @Summary 获取队列任务列表
+		// @Description 列出队列任务，可按状态筛选死信任务
+		// @Tags admin
+		// @Produce json
+		// @Success 200 {object} task.JobListData
+		// @Router /admin/jobs/list [get]
+		adminGroup.GET("/jobs/list", task.GetJobList)
+
+		// @Summary 查询队列任务状态
+		// @Description 根据任务ID查询状态
+		// @Tags admin
+		// @Produce json
+		// @Param id path int true "任务ID"
+		// @Success 200 {object} mydb.StructJob
+		// @Router /admin/jobs/{id} [get]
+		adminGroup.GET("/jobs/:id", task.GetJob)
+
+		// @Summary 死信任务重新入队
+		// @Description 把重试耗尽的任务放回队列
+		// @Tags admin
+		// @Produce json
+		// @Param id path int true "任务ID"
+		// @Success 200 {object} mydb.StructJob
+		// @Router /admin/jobs/{id}/retry [post]
+		adminGroup.POST("/jobs/:id/retry", task.RetryJob)
+
+		// @Summary 获取证书清单
+		// @Description 列出已签发的证书、到期状态和最近一次续期的结果
+		// @Tags admin
+		// @Produce json
+		// @Param status query string false "状态"
+		// @Success 200 {object} []certificate.CertificateItem
+		// @Router /admin/certificates/list [get]
+		adminGroup.GET("/certificates/list", certificate.GetCertificateList)
+	}
+
+	//解析记录管理路由组
+	dnsGroup := v1.Group("/dns")
+	dnsGroup.Use(middleware.AuthMiddleware())
+	{
+		// @Summary 获取托管域列表
+		// @Description 列出所有已配置的 DNS 服务商下的托管域
+		// @Tags dns
+		// @Produce json
+		// @Success 200 {object} []domain.ZoneItem
+		// @Router /dns/zones [get]
+		dnsGroup.GET("/zones", domain.GetZoneList)
+
+		// @Summary 获取解析记录列表
+		// @Description 列出托管域的解析记录
+		// @Tags dns
+		// @Produce json
+		// @Param zone query string true "托管域"
+		// @Success 200 {object} []dns.Record
+		// @Router /dns/records [get]
+		dnsGroup.GET("/records", domain.GetRecordList)
+
+		// @Summary 添加解析记录
+		// @Description 在托管域下添加解析记录
+		// @Tags dns
+		// @Accept x-www-form-urlencoded
+		// @Produce json
+		// @Success 200 {object} dns.Record
+		// @Router /dns/records/add [post]
+		dnsGroup.POST("/records/add", domain.AddRecord)
+
+		// @Summary 修改解析记录
+		// @Description 修改解析记录，没有传的字段保持不变
+		// @Tags dns
+		// @Accept x-www-form-urlencoded
+		// @Produce json
+		// @Param id path string true "记录ID"
+		// @Success 200 {object} dns.Record
+		// @Router /dns/records/update/{id} [put]
+		dnsGroup.PUT("/records/update/:id", domain.UpdateRecord)
+
+		// @Summary 删除解析记录
+		// @Description 删除托管域下的解析记录
+		// @Tags dns
+		// @Produce json
+		// @Param id path string true "记录ID"
+		// @Router /dns/records/delete/{id} [delete]
+		dnsGroup.DELETE("/records/delete/:id", domain.DeleteRecord)
+
+		// @Summary 按 YAML 同步解析记录
+		// @Description 默认只返回差异（dry_run），关闭后执行并写入审计日志
+		// @Tags dns
+		// @Accept x-www-form-urlencoded
+		// @Produce json
+		// @Success 200 {object} domain.SyncResult
+		// @Router /dns/sync [post]
+		dnsGroup.POST("/sync", domain.SyncRecords)
+
+		// @Summary 获取解析记录审计日志
+		// @Description 按时间倒序列出解析记录的改动
+		// @Tags dns
+		// @Produce json
+		// @Success 200 {object} domain.AuditListData
+		// @Router /dns/audit [get]
+		dnsGroup.GET("/audit", domain.GetAuditList)
+	}
+
+	// 站点首页，公开访问
+	siteGroup := v1.Group("/site")
+	{
+		// @Summary 获取首页数据
+		// @Description 显示中的分类树和链接、推荐链接、热门链接，结果缓存
+		// @Tags site
+		// @Produce json
+		// @Success 200 {object} site.HomeData
+		// @Router /site/home [get]
+		siteGroup.GET("/home", site.GetHome) // 获取首页数据
 	}
 
 	//导航模块路由组
@@ -207,6 +446,34 @@ func main() {
 		// @Router /nav/updateClass [put]
 		navGroup.PUT("/updateClass/:id", nav.UpdateClass) // 更新导航分类
 
+		// @Summary 删除导航分类
+		// @Description 分类下有子分类或导航时拒绝删除，cascade=true 时级联删除
+		// @Tags nav
+		// @Produce json
+		// @Param id path string true "导航分类ID"
+		// @Param cascade query bool false "是否级联删除"
+		// @Success 200 {object} util.APIResponse
+		// @Router /nav/deleteClass/{id} [delete]
+		navGroup.DELETE("/deleteClass/:id", nav.DeleteClass) // 删除导航分类
+
+		// @Summary 获取导航分类树
+		// @Description 按父级分类组织成树，同级按 Sort 排列
+		// @Tags nav
+		// @Produce json
+		// @Param only_show query bool false "只返回显示的分类"
+		// @Success 200 {object} []nav.ClassNode
+		// @Router /nav/getClassTree [get]
+		navGroup.GET("/getClassTree", nav.GetClassTree) // 获取导航分类树
+
+		// @Summary 获取导航分类的路径
+		// @Description 从顶级分类到该分类，用于面包屑导航
+		// @Tags nav
+		// @Produce json
+		// @Param id path int true "导航分类ID"
+		// @Success 200 {object} []mydb.StructNavClass
+		// @Router /nav/getClassPath/{id} [get]
+		navGroup.GET("/getClassPath/:id", nav.GetClassPath) // 获取导航分类的路径
+
 		// @Summary 添加导航信息数据
 		// @Description 添加导航信息数据
 		// @Tags nav
@@ -217,6 +484,15 @@ func main() {
 		// @Router /nav/addData [post]
 		navGroup.POST("/addData", nav.AddData) // 添加导航信息数据
 
+		// @Summary 抓取链接的网页信息
+		// @Description 抓取网页的标题、描述、关键词和图标，返回建议填写的导航字段
+		// @Tags nav
+		// @Produce json
+		// @Param url formData string true "链接地址"
+		// @Success 200 {object} navmeta.Metadata
+		// @Router /nav/fetchMeta [post]
+		navGroup.POST("/fetchMeta", nav.FetchMetadata) // 抓取链接的网页信息
+
 		// @Summary 获取导航列表
 		// @Description 获取所有导航信息的列表
 		// @Tags nav
@@ -243,6 +519,27 @@ func main() {
 		// @Success 200 {object} nav.UpdateDataResponse
 		// @Router /nav/updateData [put]
 		navGroup.PUT("/updateData/:id", nav.UpdateData) // 更新导航数据
+
+		// @Summary 获取死链报告
+		// @Description 列出最近一次检查失败的导航链接
+		// @Tags nav
+		// @Produce json
+		// @Param min_fails query int false "最少连续失败次数"
+		// @Success 200 {object} []nav.BrokenLink
+		// @Router /nav/health/broken [get]
+		navGroup.GET("/health/broken", nav.GetBrokenLinks) // 获取死链报告
+
+		// @Summary 获取各分类的热门链接
+		// @Description 按时间范围内的点击数排列各分类的导航
+		// @Tags nav
+		// @Produce json
+		// @Param class_id query int false "只看这个分类"
+		// @Param start query string false "开始日期"
+		// @Param end query string false "结束日期"
+		// @Param limit query int false "每个分类的数量"
+		// @Success 200 {object} []nav.ClassTopLinks
+		// @Router /nav/stats/top [get]
+		navGroup.GET("/stats/top", nav.GetTopLinks) // 获取各分类的热门链接
 	}
 
 	//新闻模块路由组
@@ -269,10 +566,11 @@ func main() {
 		newsGroup.PUT("/updateClass/:id", news.UpdateClass) // 编辑新闻分类
 
 		// @Summary 删除新闻分类
-		// @Description 根据新闻分类ID删除新闻分类
+		// @Description 分类下有子分类或新闻时拒绝删除，cascade=true 时级联删除
 		// @Tags news
 		// @Produce json
 		// @Param id path string true "新闻分类ID"
+		// @Param cascade query bool false "是否级联删除"
 		// @Success 200 {object} gin.H{"message": string}
 		// @Router /news/deleteClass/{id} [delete]
 		newsGroup.DELETE("/deleteClass/:id", news.DeleteClass) // 删除新闻分类
@@ -293,6 +591,25 @@ func main() {
 		// @Success 200 {object} news.GetClassDetail
 		// @Router /news/getClassDetail/{id} [get]
 		newsGroup.GET("/getClassDetail/:id", news.GetClassDetail) // 获取新闻分类详情
+
+		// @Summary 获取新闻分类树
+		// @Description 按父级分类组织成树，同级按 Sort 排列
+		// @Tags news
+		// @Produce json
+		// @Param only_show query bool false "只返回显示的分类"
+		// @Success 200 {object} []news.ClassNode
+		// @Router /news/getClassTree [get]
+		newsGroup.GET("/getClassTree", news.GetClassTree) // 获取新闻分类树
+
+		// @Summary 获取新闻分类的路径
+		// @Description 从顶级分类到该分类，用于面包屑导航
+		// @Tags news
+		// @Produce json
+		// @Param id path int true "新闻分类ID"
+		// @Success 200 {object} []mydb.StructNewsClass
+		// @Router /news/getClassPath/{id} [get]
+		newsGroup.GET("/getClassPath/:id", news.GetClassPath) // 获取新闻分类的路径
+
 		// @Summary 添加新闻
 		// @Description 添加新闻
 		// @Tags news
@@ -338,6 +655,48 @@ func main() {
 		// @Success 200 {object} gin.H{"message": string}
 		// @Router /news/delete/{id} [delete]
 		newsGroup.DELETE("/delete/:id", news.DeleteNews) // 删除新闻
+
+		// @Summary 添加新闻订阅源
+		// @Description 添加 RSS/Atom/JSON Feed 订阅源
+		// @Tags news
+		// @Router /news/feed/add [post]
+		newsGroup.POST("/feed/add", news.AddFeed) // 添加新闻订阅源
+
+		// @Summary 修改新闻订阅源
+		// @Description 根据订阅源ID修改订阅源
+		// @Tags news
+		// @Router /news/feed/update/{id} [put]
+		newsGroup.PUT("/feed/update/:id", news.UpdateFeed) // 修改新闻订阅源
+
+		// @Summary 删除新闻订阅源
+		// @Description 根据订阅源ID删除订阅源
+		// @Tags news
+		// @Router /news/feed/delete/{id} [delete]
+		newsGroup.DELETE("/feed/delete/:id", news.DeleteFeed) // 删除新闻订阅源
+
+		// @Summary 获取新闻订阅源列表
+		// @Description 获取所有订阅源及最近一次抓取结果
+		// @Tags news
+		// @Router /news/feed/list [get]
+		newsGroup.GET("/feed/list", news.GetFeedList) // 获取新闻订阅源列表
+
+		// @Summary 立即抓取新闻订阅源
+		// @Description 立即抓取指定订阅源并返回抓取统计
+		// @Tags news
+		// @Router /news/feed/fetch/{id} [post]
+		newsGroup.POST("/feed/fetch/:id", news.FetchFeed) // 立即抓取新闻订阅源
+
+		// @Summary 获取故事聚合列表
+		// @Description 获取近似重复新闻归并出的故事聚合
+		// @Tags news
+		// @Router /news/cluster/list [get]
+		newsGroup.GET("/cluster/list", news.GetClusterList) // 获取故事聚合列表
+
+		// @Summary 合并故事聚合
+		// @Description 把若干聚合里的新闻并入目标聚合
+		// @Tags news
+		// @Router /news/cluster/merge [post]
+		newsGroup.POST("/cluster/merge", news.MergeClusters) // 合并故事聚合
 	}
 	// 如果上面的路由都没匹配到，就到指定目录（如：/www/wwwroot/nav/）的对应url路径下查找文件，如果有就返回文件内容，否则就报404
 	r.NoRoute(func(c *gin.Context) {
@@ -366,7 +725,8 @@ func main() {
 		})
 	*/
 
-	r.Run(":8080")
+	// 启动 HTTP 服务，收到退出信号后优雅关闭
+	runServer(r)
 }
 
 // SwaggerAuthMiddleware 是一个简单的中间件，用于保护 Swagger 文档
@@ -398,64 +758,7 @@ func RequestLoggerMiddleware() gin.HandlerFunc {
 	}
 }
 
-// 开始计划任务
+// 开始计划任务，任务保存在 scheduled_task 表，可通过后台接口修改
 func startScheduledTaskChecker() {
-	c := cron.New()
-	for _, task := range config.Config.Tasks {
-		task := task                                                                           // 避免闭包问题
-		log.InfoLogger.Printf("Scheduling task %s with schedule %s", task.Type, task.Schedule) // 添加日志
-		_, err := c.AddFunc(task.Schedule, func() {
-			log.InfoLogger.Printf("Executing scheduled task %s", task.Type) // 添加日志
-			executeTask(task.Type)
-		})
-		if err != nil {
-			log.ErrorLogger.Printf("Error scheduling task %s: %v", task.Type, err)
-		}
-	}
-	c.Start()
-	log.InfoLogger.Println("Scheduled tasks started") // 添加日志
-}
-
-// 检测redis是否有任务
-func checkAndExecuteTasks() {
-	// 从redis查看有没有待执行的任务
-	keys, err := mydb.RedisClient.Keys(mydb.Ctx, "scheduled_task:*").Result()
-	if err != nil {
-		log.ErrorLogger.Println("Error fetching scheduled tasks:", err)
-		return
-	}
-
-	for _, key := range keys {
-		go executeTask(key)
-	}
-}
-
-// 按任务类型执行任务
-func executeTask(taskType string) {
-	log.InfoLogger.Printf("Starting task execution for %s", taskType)
-	defer func() {
-		if r := recover(); r != nil {
-			// 获取调用栈信息
-			buf := make([]byte, 1<<16)
-			stackSize := runtime.Stack(buf, false)
-			stackTrace := string(buf[0:stackSize])
-
-			// 获取真正的报错文件和行号
-			_, file, line, _ := runtime.Caller(2)
-			log.ErrorLogger.Printf("Task %s panicked at %s:%d: %v\nStack trace:\n%s", taskType, file, line, r, stackTrace)
-		}
-	}()
-	switch taskType {
-	case "news163":
-		// 获取163的头条新闻
-		webcrawler.FetchAndStoreNews163V2()
-		log.InfoLogger.Println("Executing task news163")
-		// ... 任务代码 ...
-	case "type2":
-		// 执行类型2的任务
-		log.InfoLogger.Println("Executing task type2")
-		// ... 任务代码 ...
-	default:
-		log.ErrorLogger.Println("Unknown task type:", taskType)
-	}
+	tasks.StartScheduler()
 }
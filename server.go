@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"nav-web-site/app/api/v1/certificate"
+	"nav-web-site/app/navclick"
+	"nav-web-site/app/queue"
+	"nav-web-site/app/tasks"
+	"nav-web-site/config"
+	"nav-web-site/mydb"
+	"nav-web-site/util/log"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// seconds 配置里的秒数转成时长，未配置时用默认值
+func seconds(value int, fallback time.Duration) time.Duration {
+	if value > 0 {
+		return time.Duration(value) * time.Second
+	}
+	return fallback
+}
+
+// newHTTPServer 按 server 配置创建 HTTP 服务
+func newHTTPServer(addr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: seconds(config.Config.Server.ReadHeaderTimeout, 10*time.Second),
+		ReadTimeout:       seconds(config.Config.Server.ReadTimeout, 60*time.Second),
+		WriteTimeout:      seconds(config.Config.Server.WriteTimeout, 120*time.Second),
+		IdleTimeout:       seconds(config.Config.Server.IdleTimeout, 120*time.Second),
+	}
+}
+
+// runServer 启动 HTTP 服务，开启 tls.enable 时同时启动 HTTPS 服务并自动申请和续期证书。
+// 收到 SIGINT/SIGTERM 后依次：停止接收新请求并等待进行中的请求，
+// 停止计划任务并等待执行中的任务，停止队列工作协程，最后关闭 Redis 和数据库
+func runServer(handler http.Handler) {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	addr := config.Config.Server.Addr
+	if addr == "" {
+		addr = ":8080"
+	}
+	servers := []*http.Server{newHTTPServer(addr, handler)}
+	serveErr := make(chan error, 2)
+	go func() {
+		log.InfoLogger.Printf("HTTP server listening on %s", addr)
+		serveErr <- servers[0].ListenAndServe()
+	}()
+
+	if config.Config.TLS.Enable {
+		tlsAddr := config.Config.TLS.Addr
+		if tlsAddr == "" {
+			tlsAddr = ":443"
+		}
+		manager := certificate.NewManager()
+		manager.Load()
+		go manager.Run(ctx)
+
+		tlsServer := newHTTPServer(tlsAddr, handler)
+		tlsServer.TLSConfig = manager.TLSConfig()
+		servers = append(servers, tlsServer)
+		go func() {
+			log.InfoLogger.Printf("HTTPS server listening on %s", tlsAddr)
+			serveErr <- tlsServer.ListenAndServeTLS("", "")
+		}()
+	}
+
+	select {
+	case err := <-serveErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			log.ErrorLogger.Printf("HTTP server error: %v", err)
+		}
+	case <-ctx.Done():
+		log.InfoLogger.Println("Shutdown signal received, draining...")
+	}
+	stop() // 再次收到信号时直接退出
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), seconds(config.Config.Server.ShutdownTimeout, 30*time.Second))
+	defer cancel()
+
+	for _, srv := range servers {
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.ErrorLogger.Printf("HTTP server %s shutdown: %v", srv.Addr, err)
+		} else {
+			log.InfoLogger.Printf("HTTP server %s stopped", srv.Addr)
+		}
+	}
+	if err := tasks.StopScheduler(shutdownCtx); err != nil {
+		log.ErrorLogger.Printf("Scheduled tasks did not finish in time: %v", err)
+	}
+	if err := queue.Stop(shutdownCtx); err != nil {
+		log.ErrorLogger.Printf("Job queue did not finish in time: %v", err)
+	} else {
+		log.InfoLogger.Println("Job queue stopped")
+	}
+	if err := navclick.Stop(shutdownCtx); err != nil {
+		log.ErrorLogger.Printf("Nav clicks were not flushed: %v", err)
+	}
+	mydb.CloseDB()
+	log.InfoLogger.Println("Server exited")
+}
@@ -0,0 +1,147 @@
+// Package classtree 处理按 Parent_id 组织的分类树：排列子分类、检测环、取子孙和路径。
+// 导航分类和新闻分类共用，只依赖 id、父级 id 和排序
+package classtree
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+var (
+	// ErrCycle 分类的父级关系形成了环
+	ErrCycle = errors.New("分类的父级关系形成了环")
+	// ErrNotEmpty 分类下还有子分类或内容，不使用级联删除时拒绝删除
+	ErrNotEmpty = errors.New("分类下还有子分类或内容，请先移走或使用级联删除")
+)
+
+// Item 分类树里的一个分类
+type Item struct {
+	ID        int
+	Parent_id int
+	Sort      int
+}
+
+// Children 按父级 id 分组排列子分类 id，同级按 Sort 从小到大、再按 id 排列。
+// 父级不存在的分类（孤儿）和处在环里的分类挂到根（0）下，保证每个分类都会出现在树里且只出现一次
+func Children(items []Item) map[int][]int {
+	byID := index(items)
+	children := make(map[int][]int)
+	for _, item := range items {
+		parent := item.Parent_id
+		if _, ok := byID[parent]; !ok || parent == item.ID || inCycle(byID, item.ID) {
+			parent = 0
+		}
+		children[parent] = append(children[parent], item.ID)
+	}
+	for parent := range children {
+		ids := children[parent]
+		sort.Slice(ids, func(i, j int) bool {
+			a, b := byID[ids[i]], byID[ids[j]]
+			if a.Sort != b.Sort {
+				return a.Sort < b.Sort
+			}
+			return a.ID < b.ID
+		})
+	}
+	return children
+}
+
+// Path 从根到 id 的分类 id，包含 id 本身。分类不存在时返回错误，遇到环时返回 ErrCycle
+func Path(items []Item, id int) ([]int, error) {
+	byID := index(items)
+	if _, ok := byID[id]; !ok {
+		return nil, fmt.Errorf("分类不存在: %d", id)
+	}
+	var path []int
+	seen := make(map[int]bool)
+	for current := id; current != 0; {
+		item, ok := byID[current]
+		if !ok {
+			// 父级已删除，路径到此为止
+			break
+		}
+		if seen[current] {
+			return nil, ErrCycle
+		}
+		seen[current] = true
+		path = append(path, current)
+		current = item.Parent_id
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path, nil
+}
+
+// CheckParent 检查把 id 的父级改成 parentID 是否合法：父级必须存在（0 表示顶级），且不能是自己或自己的子孙。
+// id 为0表示新分类，只检查父级是否存在
+func CheckParent(items []Item, id int, parentID int) error {
+	if parentID == 0 {
+		return nil
+	}
+	if parentID == id {
+		return fmt.Errorf("不能把分类的父级设为自己")
+	}
+	byID := index(items)
+	if _, ok := byID[parentID]; !ok {
+		return fmt.Errorf("父级分类不存在: %d", parentID)
+	}
+	if id == 0 {
+		return nil
+	}
+	for _, descendant := range Descendants(items, id) {
+		if descendant == parentID {
+			return fmt.Errorf("%w: 不能把分类移动到自己的子分类 %d 下", ErrCycle, parentID)
+		}
+	}
+	return nil
+}
+
+// Descendants id 的所有子孙分类 id，不含 id 本身
+func Descendants(items []Item, id int) []int {
+	children := make(map[int][]int)
+	for _, item := range items {
+		if item.ID != item.Parent_id {
+			children[item.Parent_id] = append(children[item.Parent_id], item.ID)
+		}
+	}
+	var result []int
+	seen := map[int]bool{id: true}
+	queue := []int{id}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, child := range children[current] {
+			if !seen[child] {
+				seen[child] = true
+				result = append(result, child)
+				queue = append(queue, child)
+			}
+		}
+	}
+	return result
+}
+
+func index(items []Item) map[int]Item {
+	byID := make(map[int]Item, len(items))
+	for _, item := range items {
+		byID[item.ID] = item
+	}
+	return byID
+}
+
+// inCycle 从 id 往上找父级时是否会回到 id
+func inCycle(byID map[int]Item, id int) bool {
+	seen := make(map[int]bool)
+	for current := byID[id].Parent_id; current != 0; current = byID[current].Parent_id {
+		if current == id {
+			return true
+		}
+		if _, ok := byID[current]; !ok || seen[current] {
+			return false
+		}
+		seen[current] = true
+	}
+	return false
+}
@@ -0,0 +1,119 @@
+package classtree
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// 1、2 是顶级分类，3 在 1 下；4 的父级不存在；5、6 互为父级形成环，8 挂在环上；7 的父级是自己
+var testItems = []Item{
+	{ID: 1, Parent_id: 0, Sort: 2},
+	{ID: 2, Parent_id: 0, Sort: 1},
+	{ID: 3, Parent_id: 1},
+	{ID: 4, Parent_id: 99},
+	{ID: 5, Parent_id: 6},
+	{ID: 6, Parent_id: 5},
+	{ID: 7, Parent_id: 7},
+	{ID: 8, Parent_id: 5},
+}
+
+func TestChildren(t *testing.T) {
+	got := Children(testItems)
+	want := map[int][]int{
+		// 孤儿和环里的分类挂到根下，同级按 Sort、再按 id 排列
+		0: {4, 5, 6, 7, 2, 1},
+		1: {3},
+		5: {8},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Children = %v, want %v", got, want)
+	}
+
+	// 每个分类在树里只出现一次
+	seen := make(map[int]int)
+	for _, ids := range got {
+		for _, id := range ids {
+			seen[id]++
+		}
+	}
+	for _, item := range testItems {
+		if seen[item.ID] != 1 {
+			t.Errorf("分类 %d 出现了 %d 次", item.ID, seen[item.ID])
+		}
+	}
+}
+
+func TestPath(t *testing.T) {
+	tests := []struct {
+		id      int
+		want    []int
+		wantErr error
+	}{
+		{1, []int{1}, nil},
+		{3, []int{1, 3}, nil},
+		{4, []int{4}, nil}, // 父级已删除，路径到自己为止
+		{5, nil, ErrCycle},
+		{7, nil, ErrCycle},
+		{8, nil, ErrCycle},
+	}
+	for _, tt := range tests {
+		got, err := Path(testItems, tt.id)
+		if !errors.Is(err, tt.wantErr) || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Path(%d) = %v, %v, want %v, %v", tt.id, got, err, tt.want, tt.wantErr)
+		}
+	}
+	if _, err := Path(testItems, 99); err == nil {
+		t.Error("不存在的分类应返回错误")
+	}
+}
+
+func TestDescendants(t *testing.T) {
+	tests := []struct {
+		id   int
+		want []int
+	}{
+		{1, []int{3}},
+		{2, nil},
+		{3, nil},
+		// 环里的分类不会重复，也不会包含自己
+		{5, []int{6, 8}},
+		{6, []int{5, 8}},
+		{7, nil},
+	}
+	for _, tt := range tests {
+		if got := Descendants(testItems, tt.id); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Descendants(%d) = %v, want %v", tt.id, got, tt.want)
+		}
+	}
+
+	deep := []Item{{ID: 1}, {ID: 2, Parent_id: 1}, {ID: 3, Parent_id: 2}, {ID: 4, Parent_id: 2}, {ID: 5, Parent_id: 3}}
+	if got, want := Descendants(deep, 1), []int{2, 3, 4, 5}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Descendants(deep, 1) = %v, want %v", got, want)
+	}
+}
+
+func TestCheckParent(t *testing.T) {
+	tests := []struct {
+		name      string
+		id        int
+		parentID  int
+		wantErr   bool
+		wantCycle bool
+	}{
+		{"改成顶级", 3, 0, false, false},
+		{"移到其他分类下", 3, 2, false, false},
+		{"新分类", 0, 1, false, false},
+		{"新分类的父级不存在", 0, 99, true, false},
+		{"父级是自己", 3, 3, true, false},
+		{"父级不存在", 3, 99, true, false},
+		{"移到自己的子分类下", 1, 3, true, true},
+		{"移到环上的子分类下", 5, 8, true, true},
+	}
+	for _, tt := range tests {
+		err := CheckParent(testItems, tt.id, tt.parentID)
+		if (err != nil) != tt.wantErr || errors.Is(err, ErrCycle) != tt.wantCycle {
+			t.Errorf("%s: CheckParent(%d, %d) = %v", tt.name, tt.id, tt.parentID, err)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+// Package notify 把告警发送到配置的通知渠道（钉钉、企业微信、飞书机器人或通用 webhook）
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"nav-web-site/config"
+	"nav-web-site/util/log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// Send 发送到所有配置的渠道，单个渠道失败不影响其他渠道，返回合并后的错误。没有配置渠道时只写日志
+func Send(ctx context.Context, title, text string) error {
+	channels := config.Config.Notify.Channels
+	if len(channels) == 0 {
+		log.InfoLogger.Printf("未配置通知渠道,通知内容: %s: %s", title, text)
+		return nil
+	}
+	var errs []error
+	for _, channel := range channels {
+		if err := send(ctx, channel, title, text); err != nil {
+			log.ErrorLogger.Printf("发送通知失败,渠道=%s: %v", channel.Type, err)
+			errs = append(errs, fmt.Errorf("%s: %v", channel.Type, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// send 按渠道类型组装消息
+func send(ctx context.Context, channel config.NotifyChannel, title, text string) error {
+	content := title + "\n" + text
+	webhookURL := channel.URL
+	var body interface{}
+	switch channel.Type {
+	case "dingtalk":
+		if channel.Secret != "" {
+			webhookURL = dingtalkSign(webhookURL, channel.Secret, time.Now())
+		}
+		body = map[string]interface{}{"msgtype": "text", "text": map[string]string{"content": content}}
+	case "wecom":
+		body = map[string]interface{}{"msgtype": "text", "text": map[string]string{"content": content}}
+	case "feishu":
+		body = map[string]interface{}{"msg_type": "text", "content": map[string]string{"text": content}}
+	case "webhook", "":
+		body = map[string]interface{}{"title": title, "text": text, "time": time.Now().Unix()}
+	default:
+		return fmt.Errorf("unsupported notify channel: %s", channel.Type)
+	}
+	return post(ctx, webhookURL, body)
+}
+
+// dingtalkSign 钉钉机器人的加签：HMAC-SHA256(timestamp + "\n" + secret)，Base64 后作为 sign 参数
+func dingtalkSign(webhookURL, secret string, now time.Time) string {
+	timestamp := strconv.FormatInt(now.UnixMilli(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "\n" + secret))
+	sign := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	return webhookURL + "&timestamp=" + timestamp + "&sign=" + url.QueryEscape(sign)
+}
+
+func post(ctx context.Context, webhookURL string, body interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook returned %s: %s", resp.Status, respBody)
+	}
+	// 钉钉、企业微信、飞书出错时 HTTP 状态仍是200，错误码在响应里
+	var result struct {
+		ErrCode *int   `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+		Code    *int   `json:"code"`
+		Msg     string `json:"msg"`
+	}
+	if json.Unmarshal(respBody, &result) == nil {
+		if result.ErrCode != nil && *result.ErrCode != 0 {
+			return fmt.Errorf("webhook error %d: %s", *result.ErrCode, result.ErrMsg)
+		}
+		if result.Code != nil && *result.Code != 0 {
+			return fmt.Errorf("webhook error %d: %s", *result.Code, result.Msg)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+// Package redislock 基于 Redis SET NX PX 的分布式锁，每次加锁分配一个递增的令牌作为锁的值，
+// 持有期间自动续期，续期失败超过有效期时判定锁已丢失
+package redislock
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// ErrNotAcquired 锁被其他实例持有
+var ErrNotAcquired = errors.New("锁已被其他实例持有")
+
+// ErrLockLost 持有期间锁过期或被其他实例取得
+var ErrLockLost = errors.New("锁已丢失")
+
+// refreshScript 只有锁仍属于自己（值等于令牌）时才续期
+var refreshScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+
+// releaseScript 只有锁仍属于自己时才删除，不会误删其他实例后来取得的锁
+var releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+// Lock 已取得的锁
+type Lock struct {
+	client *redis.Client
+	key    string
+	token  int64
+	ttl    time.Duration
+
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// Acquire 尝试取得锁，锁被占用时返回 ErrNotAcquired。
+// 令牌由 key:token 计数器递增生成，每次加锁都不同，续期、校验和释放时用它确认锁仍属于自己。
+// 令牌只在 Redis 里校验，不会随写入带到数据库，Check 通过后到写入之间锁仍可能丢失
+func Acquire(ctx context.Context, client *redis.Client, key string, ttl time.Duration) (*Lock, error) {
+	token, err := client.Incr(ctx, key+":token").Result()
+	if err != nil {
+		return nil, err
+	}
+	ok, err := client.SetNX(ctx, key, token, ttl).Result()
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrNotAcquired
+	}
+	return &Lock{client: client, key: key, token: token, ttl: ttl}, nil
+}
+
+// Refresh 续期，锁已不属于自己时返回 ErrLockLost
+func (l *Lock) Refresh(ctx context.Context) error {
+	result, err := refreshScript.Run(ctx, l.client, []string{l.key}, strconv.FormatInt(l.token, 10), l.ttl.Milliseconds()).Int()
+	if err != nil {
+		return err
+	}
+	if result == 0 {
+		return ErrLockLost
+	}
+	return nil
+}
+
+// Check 确认锁仍属于自己，写入外部数据前调用，锁已丢失时应放弃写入
+func (l *Lock) Check(ctx context.Context) error {
+	value, err := l.client.Get(ctx, l.key).Result()
+	if errors.Is(err, redis.Nil) {
+		return ErrLockLost
+	}
+	if err != nil {
+		return err
+	}
+	if value != strconv.FormatInt(l.token, 10) {
+		return ErrLockLost
+	}
+	return nil
+}
+
+// Hold 在后台每隔三分之一有效期续期一次，返回的 context 在锁丢失时以 ErrLockLost 为原因取消。
+// Redis 暂时不可用时继续重试，距上次成功续期超过有效期才判定丢失
+func (l *Lock) Hold(ctx context.Context) context.Context {
+	holdCtx, cancel := context.WithCancelCause(ctx)
+	l.stop = make(chan struct{})
+	l.done = make(chan struct{})
+
+	go func() {
+		defer close(l.done)
+		defer cancel(nil)
+		ticker := time.NewTicker(l.ttl / 3)
+		defer ticker.Stop()
+		lastRefresh := time.Now()
+		for {
+			select {
+			case <-l.stop:
+				return
+			case <-holdCtx.Done():
+				return
+			case <-ticker.C:
+				err := l.Refresh(holdCtx)
+				if err == nil {
+					lastRefresh = time.Now()
+					continue
+				}
+				if errors.Is(err, ErrLockLost) || time.Since(lastRefresh) >= l.ttl {
+					cancel(ErrLockLost)
+					return
+				}
+			}
+		}
+	}()
+	return holdCtx
+}
+
+// Release 停止续期并释放锁，锁已不属于自己时不做任何事
+func (l *Lock) Release(ctx context.Context) error {
+	l.stopOnce.Do(func() {
+		if l.stop != nil {
+			close(l.stop)
+			<-l.done
+		}
+	})
+	return releaseScript.Run(ctx, l.client, []string{l.key}, strconv.FormatInt(l.token, 10)).Err()
+}
@@ -0,0 +1,102 @@
+// Package safehttp 请求外部提供的地址（如后台填写的链接、正文里的图片）时使用的 http.Client。
+// 建立连接时检查实际连接的IP，拒绝内网、本机、链路本地等地址，跳转后的每次连接同样检查，
+// 域名解析到内网地址（包括 DNS rebinding）也会被拒绝，防止借服务端请求访问内部服务
+package safehttp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+const (
+	dialTimeout  = 10 * time.Second
+	maxRedirects = 5
+)
+
+// ErrBlockedAddress 目标地址是内网、本机或其他不允许访问的地址
+var ErrBlockedAddress = errors.New("不允许访问内网或本机地址")
+
+// blockedNets IsPrivate 等方法没有覆盖到的保留网段
+var blockedNets = mustParseCIDRs(
+	"0.0.0.0/8",      // 本网络
+	"100.64.0.0/10",  // 运营商级 NAT
+	"192.0.0.0/24",   // IETF 协议分配
+	"198.18.0.0/15",  // 网络性能测试
+	"240.0.0.0/4",    // 保留
+	"64:ff9b::/96",   // NAT64，后32位是 IPv4 地址
+	"64:ff9b:1::/48", // 本地 NAT64
+	"2002::/16",      // 6to4，内嵌的 IPv4 地址可能是内网
+)
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets
+}
+
+// Blocked 是否为不允许访问的地址：回环、内网、链路本地、组播、未指定地址和其他保留网段
+func Blocked(ip net.IP) bool {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return true
+	}
+	for _, ipNet := range blockedNets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// control 在连接建立前检查实际要连接的IP，此时域名已经解析完成
+func control(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || Blocked(ip) {
+		return fmt.Errorf("%w: %s", ErrBlockedAddress, host)
+	}
+	return nil
+}
+
+// transport 所有 client 共用，连接可以复用。不使用环境变量里的代理，经代理访问时检查不到目标地址
+var transport = &http.Transport{
+	DialContext:           (&net.Dialer{Timeout: dialTimeout, Control: control}).DialContext,
+	ForceAttemptHTTP2:     true,
+	MaxIdleConns:          10,
+	IdleConnTimeout:       90 * time.Second,
+	TLSHandshakeTimeout:   10 * time.Second,
+	ExpectContinueTimeout: time.Second,
+}
+
+// NewClient 创建只能访问公网地址的 http.Client，最多跟随5次跳转，只允许跳转到 http/https
+func NewClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("跳转次数超过%d次", maxRedirects)
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("不允许跳转到 %s 地址", req.URL.Scheme)
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,61 @@
+package safehttp
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestBlocked(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"127.0.0.1", true},
+		{"127.8.8.8", true},
+		{"10.0.0.1", true},
+		{"172.16.5.4", true},
+		{"192.168.1.1", true},
+		{"169.254.169.254", true}, // 云服务器的元数据地址
+		{"0.0.0.0", true},
+		{"100.64.0.1", true},
+		{"224.0.0.1", true},
+		{"::1", true},
+		{"::", true},
+		{"fe80::1", true},
+		{"fc00::1", true},
+		{"::ffff:127.0.0.1", true}, // IPv4 映射地址
+		{"::ffff:10.0.0.1", true},
+		{"64:ff9b::a00:1", true},
+		{"8.8.8.8", false},
+		{"1.1.1.1", false},
+		{"172.32.0.1", false},
+		{"2400:3200::1", false},
+	}
+	for _, tt := range tests {
+		if got := Blocked(net.ParseIP(tt.ip)); got != tt.want {
+			t.Errorf("Blocked(%s) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestClientRejectsLoopback(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("internal"))
+	}))
+	defer server.Close()
+
+	_, err := NewClient(5 * time.Second).Get(server.URL)
+	if !errors.Is(err, ErrBlockedAddress) {
+		t.Errorf("访问本机地址应返回 ErrBlockedAddress, got %v", err)
+	}
+	// 域名解析到本机地址同样拒绝
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	_, err = NewClient(5 * time.Second).Get("http://localhost:" + port)
+	if !errors.Is(err, ErrBlockedAddress) {
+		t.Errorf("localhost 应返回 ErrBlockedAddress, got %v", err)
+	}
+}
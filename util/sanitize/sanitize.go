@@ -0,0 +1,209 @@
+// Package sanitize 按白名单清洗 HTML，用于入库前的新闻正文
+package sanitize
+
+import (
+	"bytes"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// allowedTags 允许保留的标签及各自允许的属性
+var allowedTags = map[string][]string{
+	"p": nil, "br": nil, "hr": nil,
+	"h1": nil, "h2": nil, "h3": nil, "h4": nil, "h5": nil, "h6": nil,
+	"blockquote": nil, "pre": nil, "code": nil,
+	"b": nil, "strong": nil, "i": nil, "em": nil, "u": nil, "s": nil, "del": nil, "ins": nil,
+	"sub": nil, "sup": nil, "small": nil, "mark": nil,
+	"ul": nil, "ol": {"start"}, "li": nil, "dl": nil, "dt": nil, "dd": nil,
+	"a":      {"href", "title"},
+	"img":    {"src", "alt", "title", "width", "height"},
+	"figure": nil, "figcaption": nil,
+	"table": nil, "caption": nil, "thead": nil, "tbody": nil, "tfoot": nil, "tr": nil,
+	"th": {"colspan", "rowspan"},
+	"td": {"colspan", "rowspan"},
+}
+
+// droppedTags 连同内容一起删除的标签，其余不在白名单里的标签只去掉标签本身、保留内容
+var droppedTags = map[string]bool{
+	"script": true, "style": true, "noscript": true, "template": true,
+	"iframe": true, "frame": true, "frameset": true, "object": true, "embed": true, "applet": true,
+	"form": true, "input": true, "button": true, "select": true, "textarea": true, "option": true,
+	"svg": true, "math": true, "canvas": true, "audio": true, "video": true,
+	"head": true, "title": true, "meta": true, "link": true, "base": true,
+}
+
+// urlAttrs 需要检查协议并转成绝对地址的属性
+var urlAttrs = map[string]bool{"href": true, "src": true}
+
+// lazyImageAttrs 懒加载图片存放真实地址的属性，src 为空或是占位图时使用
+var lazyImageAttrs = []string{"data-src", "data-original", "data-actualsrc", "data-lazy-src"}
+
+// HTML 按白名单清洗 HTML 片段：去掉脚本、样式、表单、内嵌框架和事件属性，
+// 只保留常见排版标签，相对链接和图片地址按 baseURL 转成绝对地址（baseURL 为空时保留站内相对地址）
+func HTML(fragment string, baseURL string) string {
+	if strings.TrimSpace(fragment) == "" {
+		return ""
+	}
+	var base *url.URL
+	if baseURL != "" {
+		base, _ = url.Parse(baseURL)
+	}
+
+	context := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	nodes, err := html.ParseFragment(strings.NewReader(fragment), context)
+	if err != nil {
+		return html.EscapeString(fragment)
+	}
+
+	var buf bytes.Buffer
+	for _, node := range nodes {
+		for _, clean := range cleanNode(node, base) {
+			html.Render(&buf, clean)
+		}
+	}
+	return strings.TrimSpace(buf.String())
+}
+
+// cleanNode 清洗单个节点，返回替换它的节点列表（删除时为空，去掉标签时为其子节点）
+func cleanNode(node *html.Node, base *url.URL) []*html.Node {
+	switch node.Type {
+	case html.TextNode:
+		return []*html.Node{{Type: html.TextNode, Data: node.Data}}
+	case html.ElementNode:
+	case html.DocumentNode:
+		return cleanChildren(node, base)
+	default:
+		// 注释、doctype 等一律去掉
+		return nil
+	}
+
+	tag := strings.ToLower(node.Data)
+	if droppedTags[tag] {
+		return nil
+	}
+	allowedAttrs, ok := allowedTags[tag]
+	if !ok {
+		return cleanChildren(node, base)
+	}
+
+	clean := &html.Node{Type: html.ElementNode, Data: tag, DataAtom: atom.Lookup([]byte(tag))}
+	clean.Attr = cleanAttrs(tag, node, allowedAttrs, base)
+	if tag == "img" && (!hasAttr(clean, "src") || isTrackingPixel(clean)) {
+		return nil
+	}
+	if tag == "a" && hasAttr(clean, "href") {
+		clean.Attr = append(clean.Attr, html.Attribute{Key: "rel", Val: "nofollow noopener noreferrer"})
+	}
+	for _, child := range cleanChildren(node, base) {
+		clean.AppendChild(child)
+	}
+	return []*html.Node{clean}
+}
+
+// cleanChildren 清洗所有子节点
+func cleanChildren(node *html.Node, base *url.URL) []*html.Node {
+	var children []*html.Node
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		children = append(children, cleanNode(child, base)...)
+	}
+	return children
+}
+
+// cleanAttrs 只保留白名单里的属性，地址属性检查协议并补全成绝对地址
+func cleanAttrs(tag string, node *html.Node, allowed []string, base *url.URL) []html.Attribute {
+	var attrs []html.Attribute
+	for _, name := range allowed {
+		value, ok := attrValue(node, name)
+		if name == "src" && tag == "img" && (!ok || value == "" || strings.HasPrefix(value, "data:")) {
+			value, ok = lazyImageSrc(node)
+		}
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if urlAttrs[name] {
+			value = safeURL(value, base, tag == "a")
+			if value == "" {
+				continue
+			}
+		}
+		attrs = append(attrs, html.Attribute{Key: name, Val: value})
+	}
+	return attrs
+}
+
+// lazyImageSrc 取懒加载图片的真实地址
+func lazyImageSrc(node *html.Node) (string, bool) {
+	for _, name := range lazyImageAttrs {
+		if value, ok := attrValue(node, name); ok && strings.TrimSpace(value) != "" {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// safeURL 只允许 http/https（链接额外允许 mailto 和页内锚点），相对地址按 base 补全
+func safeURL(value string, base *url.URL, isLink bool) string {
+	if value == "" {
+		return ""
+	}
+	if isLink && strings.HasPrefix(value, "#") {
+		return value
+	}
+	u, err := url.Parse(value)
+	if err != nil {
+		return ""
+	}
+	if base != nil {
+		u = base.ResolveReference(u)
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https":
+		return u.String()
+	case "mailto":
+		if isLink {
+			return u.String()
+		}
+		return ""
+	case "":
+		// 没有 base 时只保留站内绝对路径，如 /images/{hash}
+		if strings.HasPrefix(u.Path, "/") && u.Host == "" {
+			return u.String()
+		}
+		if u.Host != "" {
+			u.Scheme = "https"
+			return u.String()
+		}
+	}
+	return ""
+}
+
+// isTrackingPixel 宽或高不超过1像素的图片基本都是统计用的跟踪图
+func isTrackingPixel(node *html.Node) bool {
+	for _, name := range []string{"width", "height"} {
+		if value, ok := attrValue(node, name); ok {
+			value = strings.TrimSuffix(strings.TrimSpace(value), "px")
+			if value == "0" || value == "1" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func attrValue(node *html.Node, name string) (string, bool) {
+	for _, attr := range node.Attr {
+		if attr.Namespace == "" && strings.EqualFold(attr.Key, name) {
+			return attr.Val, true
+		}
+	}
+	return "", false
+}
+
+func hasAttr(node *html.Node, name string) bool {
+	_, ok := attrValue(node, name)
+	return ok
+}
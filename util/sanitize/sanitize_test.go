@@ -0,0 +1,110 @@
+package sanitize
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHTMLDropsScriptURLs(t *testing.T) {
+	tests := []struct {
+		name     string
+		fragment string
+		want     string
+	}{
+		{"javascript 链接", `<a href="javascript:alert(1)">x</a>`, `<a>x</a>`},
+		{"大小写混写的协议", `<a href="JaVaScRiPt:alert(1)">x</a>`, `<a>x</a>`},
+		{"协议前后有空白", `<a href="  javascript:alert(1)  ">x</a>`, `<a>x</a>`},
+		{"协议中间夹着制表符", "<a href=\"java\tscript:alert(1)\">x</a>", `<a>x</a>`},
+		{"实体编码的协议", `<a href="&#106;avascript:alert(1)">x</a>`, `<a>x</a>`},
+		{"十六进制实体编码的协议", `<a href="&#x6A;&#x61;vascript&#x3A;alert(1)">x</a>`, `<a>x</a>`},
+		{"实体编码的制表符", `<a href="jav&#x09;ascript:alert(1)">x</a>`, `<a>x</a>`},
+		{"vbscript 链接", `<a href="vbscript:msgbox(1)">x</a>`, `<a>x</a>`},
+		{"data 链接", `<a href="data:text/html;base64,PHNjcmlwdD5hbGVydCgxKTwvc2NyaXB0Pg==">x</a>`, `<a>x</a>`},
+		{"data 图片", `<img src="data:image/svg+xml;base64,PHN2Zz48L3N2Zz4=">`, ``},
+		{"javascript 图片", `<img src="javascript:alert(1)">`, ``},
+	}
+	for _, tt := range tests {
+		if got := HTML(tt.fragment, "https://example.com/news/1.html"); got != tt.want {
+			t.Errorf("%s: HTML(%q) = %q, want %q", tt.name, tt.fragment, got, tt.want)
+		}
+	}
+}
+
+func TestHTMLDropsEventHandlers(t *testing.T) {
+	tests := []struct {
+		fragment string
+		want     string
+	}{
+		{`<img src="/a.png" onerror="alert(1)">`, `<img src="/a.png"/>`},
+		{`<p onclick="alert(1)" ONMOUSEOVER="alert(2)">text</p>`, `<p>text</p>`},
+		{`<a href="https://example.com/" onfocus="alert(1)" autofocus>x</a>`, `<a href="https://example.com/" rel="nofollow noopener noreferrer">x</a>`},
+		// 不在白名单里的标签只去掉标签，属性一起丢掉
+		{`<div style="background:url(javascript:alert(1))" onload="alert(1)">text</div>`, `text`},
+		{`<p style="x:expression(alert(1))">text</p>`, `<p>text</p>`},
+	}
+	for _, tt := range tests {
+		if got := HTML(tt.fragment, ""); got != tt.want {
+			t.Errorf("HTML(%q) = %q, want %q", tt.fragment, got, tt.want)
+		}
+	}
+}
+
+func TestHTMLDropsDangerousTags(t *testing.T) {
+	tests := []string{
+		`<script>alert(1)</script>`,
+		`<SCRIPT SRC="https://evil.example/x.js"></SCRIPT>`,
+		`<style>body{background:url("javascript:alert(1)")}</style>`,
+		`<svg onload="alert(1)"><script>alert(1)</script></svg>`,
+		`<svg><a xlink:href="javascript:alert(1)"><text>x</text></a></svg>`,
+		`<math><mtext><a href="javascript:alert(1)">x</a></mtext></math>`,
+		`<iframe src="https://evil.example/"></iframe>`,
+		`<object data="https://evil.example/x.swf"></object>`,
+		`<form action="https://evil.example/"><input name="q"></form>`,
+		`<base href="https://evil.example/">`,
+		`<meta http-equiv="refresh" content="0;url=javascript:alert(1)">`,
+		`<!-- <script>alert(1)</script> -->`,
+	}
+	for _, fragment := range tests {
+		if got := HTML(fragment, ""); got != "" {
+			t.Errorf("HTML(%q) = %q, want empty", fragment, got)
+		}
+	}
+}
+
+func TestHTMLEscapesText(t *testing.T) {
+	// 被当作文本的标签必须转义输出，不能在渲染时重新变成标签
+	got := HTML(`<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>`, "")
+	if strings.Contains(got, "<script") {
+		t.Errorf("转义的文本被还原成了标签: %q", got)
+	}
+	got = HTML(`<noscript><p title="</noscript><img src=x onerror=alert(1)>"></p></noscript>`, "")
+	if strings.Contains(got, "onerror") || strings.Contains(got, "<img") {
+		t.Errorf("noscript 里的内容应整体删除: %q", got)
+	}
+}
+
+func TestHTMLKeepsSafeContent(t *testing.T) {
+	tests := []struct {
+		fragment string
+		base     string
+		want     string
+	}{
+		{`<p>正文<b>加粗</b></p>`, "", `<p>正文<b>加粗</b></p>`},
+		{`<a href="/news/2.html">相对链接</a>`, "https://example.com/news/1.html", `<a href="https://example.com/news/2.html" rel="nofollow noopener noreferrer">相对链接</a>`},
+		{`<a href="//cdn.example.com/a">协议相对</a>`, "", `<a href="https://cdn.example.com/a" rel="nofollow noopener noreferrer">协议相对</a>`},
+		{`<a href="mailto:editor@example.com">邮件</a>`, "", `<a href="mailto:editor@example.com" rel="nofollow noopener noreferrer">邮件</a>`},
+		{`<a href="#section">锚点</a>`, "", `<a href="#section" rel="nofollow noopener noreferrer">锚点</a>`},
+		{`<img src="/images/abc">`, "", `<img src="/images/abc"/>`},
+		// 懒加载图片用真实地址，占位的 data: 图片不保留
+		{`<img src="data:image/gif;base64,R0lGOD" data-src="https://img.example.com/a.jpg">`, "", `<img src="https://img.example.com/a.jpg"/>`},
+		// 图片不允许 mailto
+		{`<img src="mailto:a@example.com">`, "", ``},
+		// 跟踪像素
+		{`<img src="https://t.example.com/p.gif" width="1" height="1">`, "", ``},
+	}
+	for _, tt := range tests {
+		if got := HTML(tt.fragment, tt.base); got != tt.want {
+			t.Errorf("HTML(%q, %q) = %q, want %q", tt.fragment, tt.base, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package simhash
+
+import "sort"
+
+// Index 按分段建立的指纹索引，查找汉明距离不超过 maxDistance 的指纹时不用和每个指纹比较。
+// 64 位指纹分成 maxDistance+1 段，距离不超过 maxDistance 的两个指纹至少有一段完全相同（抽屉原理），
+// 只需比较至少有一段相同的候选。不是并发安全的，多个协程使用时由调用方加锁
+type Index struct {
+	maxDistance  int
+	masks        []uint64           // 每一段的掩码
+	buckets      []map[uint64][]int // 每一段的值对应的 id
+	fingerprints map[int]uint64     // id 对应的指纹，以最后一次 Add 为准
+}
+
+// NewIndex 创建索引，maxDistance 为查找时允许的最大汉明距离
+func NewIndex(maxDistance int) *Index {
+	if maxDistance < 0 {
+		maxDistance = 0
+	}
+	bands := min(maxDistance+1, 64)
+	x := &Index{
+		maxDistance:  maxDistance,
+		masks:        make([]uint64, bands),
+		buckets:      make([]map[uint64][]int, bands),
+		fingerprints: make(map[int]uint64),
+	}
+	// 64 位尽量平均地分到各段，前面的段多分一位
+	start := 0
+	for i := 0; i < bands; i++ {
+		width := 64 / bands
+		if i < 64%bands {
+			width++
+		}
+		for bit := start; bit < start+width; bit++ {
+			x.masks[i] |= 1 << uint(bit)
+		}
+		start += width
+		x.buckets[i] = make(map[uint64][]int)
+	}
+	return x
+}
+
+// Len 索引里的指纹数
+func (x *Index) Len() int {
+	return len(x.fingerprints)
+}
+
+// Add 加入指纹，同一个 id 再次加入时以新的指纹为准
+func (x *Index) Add(id int, fingerprint uint64) {
+	if old, ok := x.fingerprints[id]; ok && old == fingerprint {
+		return
+	}
+	x.fingerprints[id] = fingerprint
+	for i, mask := range x.masks {
+		key := fingerprint & mask
+		x.buckets[i][key] = append(x.buckets[i][key], id)
+	}
+}
+
+// Near 返回和 fingerprint 的距离不超过 maxDistance 的 id，按距离从近到远、同距离按 id 排列
+func (x *Index) Near(fingerprint uint64) []int {
+	seen := make(map[int]bool)
+	var ids []int
+	for i, mask := range x.masks {
+		for _, id := range x.buckets[i][fingerprint&mask] {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			// 桶里可能留着 id 以前的指纹，按当前指纹判断
+			if Distance(x.fingerprints[id], fingerprint) <= x.maxDistance {
+				ids = append(ids, id)
+			}
+		}
+	}
+	sort.Slice(ids, func(a, b int) bool {
+		da, db := Distance(x.fingerprints[ids[a]], fingerprint), Distance(x.fingerprints[ids[b]], fingerprint)
+		if da != db {
+			return da < db
+		}
+		return ids[a] < ids[b]
+	})
+	return ids
+}
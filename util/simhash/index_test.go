@@ -0,0 +1,71 @@
+package simhash
+
+import (
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// flip 随机翻转 n 个不同的位
+func flip(r *rand.Rand, fingerprint uint64, n int) uint64 {
+	for _, bit := range r.Perm(64)[:n] {
+		fingerprint ^= 1 << uint(bit)
+	}
+	return fingerprint
+}
+
+func TestIndexNearMatchesScan(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, maxDistance := range []int{0, 3, 6, 10} {
+		index := NewIndex(maxDistance)
+		all := make(map[int]uint64)
+		base := r.Uint64()
+		for id := 1; id <= 500; id++ {
+			// 一半是 base 附近的指纹，保证有命中；另一半随机
+			fingerprint := r.Uint64()
+			if id%2 == 0 {
+				fingerprint = flip(r, base, r.Intn(2*maxDistance+2))
+			}
+			index.Add(id, fingerprint)
+			all[id] = fingerprint
+		}
+		if index.Len() != len(all) {
+			t.Fatalf("Len = %d, want %d", index.Len(), len(all))
+		}
+
+		for _, query := range []uint64{base, flip(r, base, maxDistance), r.Uint64()} {
+			var want []int
+			for id, fingerprint := range all {
+				if Distance(fingerprint, query) <= maxDistance {
+					want = append(want, id)
+				}
+			}
+			sort.Slice(want, func(a, b int) bool {
+				da, db := Distance(all[want[a]], query), Distance(all[want[b]], query)
+				if da != db {
+					return da < db
+				}
+				return want[a] < want[b]
+			})
+			if got := index.Near(query); !reflect.DeepEqual(got, want) {
+				t.Errorf("maxDistance=%d: Near(%x) = %v, 逐个比较得到 %v", maxDistance, query, got, want)
+			}
+		}
+	}
+}
+
+func TestIndexReAdd(t *testing.T) {
+	index := NewIndex(2)
+	index.Add(1, 0)
+	index.Add(1, 0xFFFF000000000000)
+	if got := index.Near(0); len(got) != 0 {
+		t.Errorf("指纹更新后不应再按旧指纹命中: %v", got)
+	}
+	if got := index.Near(0xFFFF000000000001); !reflect.DeepEqual(got, []int{1}) {
+		t.Errorf("Near = %v, want [1]", got)
+	}
+	if index.Len() != 1 {
+		t.Errorf("Len = %d, want 1", index.Len())
+	}
+}
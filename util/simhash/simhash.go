@@ -0,0 +1,86 @@
+// Package simhash 计算文本的 64 位 SimHash 指纹，用于新闻的近似重复检测
+package simhash
+
+import (
+	"hash/fnv"
+	"math/bits"
+	"strings"
+	"unicode"
+)
+
+// titleWeight 标题里的片段权重更高，换了措辞的同一条新闻标题往往仍然相近
+const titleWeight = 3
+
+// Fingerprint 计算标题和正文纯文本的 SimHash：
+// 把文本规整成只含字母、数字和汉字的序列，中文按相邻两字、英文按单词切片，
+// 每个片段做 FNV-64 哈希后按位累加权重，最后按正负取位
+func Fingerprint(title string, text string) uint64 {
+	var weights [64]int
+	total := 0
+	add := func(source string, weight int) {
+		for _, token := range tokens(source) {
+			h := fnv.New64a()
+			h.Write([]byte(token))
+			sum := h.Sum64()
+			for i := 0; i < 64; i++ {
+				if sum&(1<<uint(i)) != 0 {
+					weights[i] += weight
+				} else {
+					weights[i] -= weight
+				}
+			}
+			total++
+		}
+	}
+	add(title, titleWeight)
+	add(text, 1)
+	if total == 0 {
+		return 0
+	}
+
+	var fingerprint uint64
+	for i := 0; i < 64; i++ {
+		if weights[i] > 0 {
+			fingerprint |= 1 << uint(i)
+		}
+	}
+	return fingerprint
+}
+
+// Distance 两个指纹的汉明距离，越小越相似
+func Distance(a uint64, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// tokens 切片：连续的汉字取相邻两字，连续的字母数字作为一个单词，忽略标点和空白
+func tokens(text string) []string {
+	var result []string
+	var word []rune
+	var prevHan rune
+
+	flushWord := func() {
+		if len(word) > 0 {
+			result = append(result, strings.ToLower(string(word)))
+			word = word[:0]
+		}
+	}
+
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			flushWord()
+			if prevHan != 0 {
+				result = append(result, string([]rune{prevHan, r}))
+			}
+			prevHan = r
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			prevHan = 0
+			word = append(word, r)
+		default:
+			prevHan = 0
+			flushWord()
+		}
+	}
+	flushWord()
+	return result
+}
@@ -0,0 +1,81 @@
+package simhash
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokens(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"新能源汽车", []string{"新能", "能源", "源汽", "汽车"}},
+		{"Hello, World 2024!", []string{"hello", "world", "2024"}},
+		// 标点把汉字隔开，单个汉字不成片段；汉字和字母数字之间各自切分
+		{"涨价，好。iPhone16发布", []string{"涨价", "iphone16", "发布"}},
+		{"  ，。!?  ", nil},
+	}
+	for _, tt := range tests {
+		if got := tokens(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("tokens(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestFingerprintEmpty(t *testing.T) {
+	if got := Fingerprint("", ""); got != 0 {
+		t.Errorf("没有片段时指纹应为0, got %x", got)
+	}
+	if got := Fingerprint("。，", "!!"); got != 0 {
+		t.Errorf("只有标点时指纹应为0, got %x", got)
+	}
+}
+
+func TestFingerprintStable(t *testing.T) {
+	a := Fingerprint("新能源汽车销量再创新高", "1月新能源汽车销量同比增长三成")
+	b := Fingerprint("新能源汽车销量再创新高", "1月新能源汽车销量同比增长三成")
+	if a != b || a == 0 {
+		t.Errorf("相同文本的指纹应相同且不为0: %x %x", a, b)
+	}
+	// 大小写和标点、空白不影响指纹
+	if Fingerprint("Go 1.23 Released", "") != Fingerprint("go 1 23 released!", "") {
+		t.Error("大小写或标点改变了指纹")
+	}
+}
+
+func TestFingerprintSimilarity(t *testing.T) {
+	text := "国家统计局今天发布数据，一月份全国居民消费价格同比上涨百分之零点三，其中城市上涨百分之零点四，农村上涨百分之零点二，食品价格下降百分之二点一"
+	base := Fingerprint("一月份居民消费价格同比上涨", text)
+	similar := Fingerprint("一月居民消费价格同比上涨", text+"，非食品价格上涨")
+	different := Fingerprint("多地发布寒潮预警", "中央气象台今天继续发布寒潮蓝色预警，预计冷空气将自西向东影响我国大部地区，气温普遍下降六到十度，局地降温超过十二度")
+
+	near := Distance(base, similar)
+	far := Distance(base, different)
+	if near > 6 {
+		t.Errorf("改写过的同一条新闻距离 = %d, 应不超过默认阈值 6", near)
+	}
+	if far <= near || far <= 6 {
+		t.Errorf("不同新闻的距离 = %d, 应明显大于相似新闻的 %d", far, near)
+	}
+}
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		a, b uint64
+		want int
+	}{
+		{0, 0, 0},
+		{0xFFFFFFFFFFFFFFFF, 0, 64},
+		{0b1011, 0b0001, 2},
+		{1 << 63, 1, 2},
+	}
+	for _, tt := range tests {
+		if got := Distance(tt.a, tt.b); got != tt.want {
+			t.Errorf("Distance(%x, %x) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := Distance(tt.b, tt.a); got != tt.want {
+			t.Errorf("Distance 不对称: Distance(%x, %x) = %d", tt.b, tt.a, got)
+		}
+	}
+}
@@ -17,6 +17,7 @@ import (
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 // api接口返回输出的结构体
@@ -338,3 +339,29 @@ func MapToStructTags[T any](inputMap map[string]string, tag string) map[string]s
 
 	return result
 }
+
+// Truncate 把字符串截断到最多 size 个字节，不截断半个字符，用于写入有长度限制的字段（如错误信息）
+func Truncate(s string, size int) string {
+	if len(s) <= size {
+		return s
+	}
+	if size <= 0 {
+		return ""
+	}
+	// 截断点落在多字节字符中间时退到该字符的开头
+	for size > 0 && !utf8.RuneStart(s[size]) {
+		size--
+	}
+	return s[:size]
+}
+
+// TruncateRunes 把字符串截断到最多 max 个字符
+func TruncateRunes(s string, max int) string {
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	if max <= 0 {
+		return ""
+	}
+	return string([]rune(s)[:max])
+}
@@ -0,0 +1,44 @@
+package util
+
+import "testing"
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		s    string
+		size int
+		want string
+	}{
+		{"hello", 10, "hello"},
+		{"hello", 5, "hello"},
+		{"hello", 3, "hel"},
+		{"中文错误", 7, "中文"}, // 第7个字节落在“错”中间，退到“错”之前
+		{"中文错误", 6, "中文"},
+		{"ab中", 3, "ab"},
+		{"中", 0, ""},
+		// 前面有非法字节时不影响截断
+		{"\xffabc中", 5, "\xffabc"},
+	}
+	for _, tt := range tests {
+		if got := Truncate(tt.s, tt.size); got != tt.want {
+			t.Errorf("Truncate(%q, %d) = %q, want %q", tt.s, tt.size, got, tt.want)
+		}
+	}
+}
+
+func TestTruncateRunes(t *testing.T) {
+	tests := []struct {
+		s    string
+		max  int
+		want string
+	}{
+		{"hello", 10, "hello"},
+		{"中文标题很长", 4, "中文标题"},
+		{"ab中文", 3, "ab中"},
+		{"中文", 0, ""},
+	}
+	for _, tt := range tests {
+		if got := TruncateRunes(tt.s, tt.max); got != tt.want {
+			t.Errorf("TruncateRunes(%q, %d) = %q, want %q", tt.s, tt.max, got, tt.want)
+		}
+	}
+}